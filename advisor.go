@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+var gogcTargetOverhead = flag.Float64("gogc-target-overhead", 2, "GC overhead, in percent of the wall-clock time, the GOGC advisor suggests the lowest GOGC staying under. 0 turns the advice off")
+
+const (
+	// gogcMinCycles is the number of cycles GOGC is advised from, at
+	// least.
+	gogcMinCycles = 5
+
+	// gogcMinLive is the live heap, in megabytes, from which the heap
+	// goal tells GOGC: below it, the runtime uses a minimum goal of 4MB.
+	gogcMinLive = 4
+
+	// defaultGOGC is the GOGC of the runtime if the GOGC variable is not
+	// set.
+	defaultGOGC = 100
+)
+
+// gogcCandidates are the GOGC values the advisor predicts the GC of.
+var gogcCandidates = []int{25, 50, 100, 200, 400, 800}
+
+// gogcInputs is what the advisor knows of the run.
+type gogcInputs struct {
+	gogc            float64 // in use during the run
+	liveMB          float64 // average live heap after the cycles
+	gcPerSecond     float64
+	avgPauseMs      float64
+	overheadPercent float64 // of the wall-clock time spent collecting garbage
+	limitMB         float64 // memory limit, 0 if unknown
+}
+
+// gogcCandidate is the predicted GC of the run with a GOGC value.
+type gogcCandidate struct {
+	GOGC             int     `json:"gogc"`
+	GCPerMinute      float64 `json:"gc_per_minute"`
+	HeapPeakMB       float64 `json:"heap_peak_mb"`
+	OverheadPercent  float64 `json:"gc_overhead_percent"`
+	PausePerMinuteMs float64 `json:"pause_per_minute_ms"`
+	Current          bool    `json:"current,omitempty"`
+	OverMemoryLimit  bool    `json:"over_memory_limit,omitempty"`
+}
+
+// gogcAdvice suggests the lowest GOGC, so the smallest heap, keeping the
+// GC overhead under -gogc-target-overhead, within the memory limit.
+type gogcAdvice struct {
+	CurrentGOGC   int             `json:"current_gogc"`
+	SuggestedGOGC int             `json:"suggested_gogc"`
+	Candidates    []gogcCandidate `json:"candidates"`
+}
+
+// gogcInputs returns what the cycles of s tell for the advisor, with the
+// GOGC variable of the environment the program runs in, and the memory
+// limit in megabytes, or false if there are too few cycles or the GC is
+// off.
+func (s *runStats) gogcInputs(env string, limitMB float64) (gogcInputs, bool) {
+	d := s.Duration().Seconds()
+	if s.NumGC < gogcMinCycles || d <= 0 || s.liveTotal <= 0 || env == "off" {
+		return gogcInputs{}, false
+	}
+
+	in := gogcInputs{
+		gogc:        defaultGOGC,
+		liveMB:      float64(s.liveTotal) / float64(s.NumGC),
+		gcPerSecond: float64(s.NumGC-1) / d,
+		avgPauseMs:  s.avgPause(),
+		// The clock time of the first cycle is before the duration.
+		overheadPercent: 100 * s.clock / (d * 1000),
+		limitMB:         limitMB,
+	}
+	if gogc, err := strconv.Atoi(env); err == nil && gogc > 0 {
+		in.gogc = float64(gogc)
+	} else if len(s.goalRatios) >= gogcMinCycles-1 {
+		// The heap goal is the live heap grown by GOGC percent.
+		sorted := append([]float64(nil), s.goalRatios...)
+		sort.Float64s(sorted)
+		if gogc := math.Round(100 * (sorted[len(sorted)/2] - 1)); gogc > 0 {
+			in.gogc = gogc
+		}
+	}
+	return in, true
+}
+
+// adviseGOGC predicts the GC of the run with the candidate GOGC values, and
+// the current one, suggesting the lowest one keeping the overhead under
+// targetOverhead percent. If none does, the highest within the memory
+// limit is suggested.
+//
+// The number of cycles, so their overhead and pauses, are taken to be
+// inversely proportional to GOGC, the heap growing by GOGC percent of the
+// live heap between them.
+func adviseGOGC(in gogcInputs, targetOverhead float64) *gogcAdvice {
+	a := &gogcAdvice{CurrentGOGC: int(in.gogc), SuggestedGOGC: int(in.gogc)}
+
+	values := append([]int(nil), gogcCandidates...)
+	if i := sort.SearchInts(values, a.CurrentGOGC); i == len(values) || values[i] != a.CurrentGOGC {
+		values = append(values, a.CurrentGOGC)
+		sort.Ints(values)
+	}
+
+	suggested := -1
+	for _, gogc := range values {
+		scale := in.gogc / float64(gogc)
+		c := gogcCandidate{
+			GOGC:             gogc,
+			GCPerMinute:      60 * in.gcPerSecond * scale,
+			HeapPeakMB:       in.liveMB * (1 + float64(gogc)/100),
+			OverheadPercent:  in.overheadPercent * scale,
+			PausePerMinuteMs: 60 * in.gcPerSecond * scale * in.avgPauseMs,
+			Current:          gogc == a.CurrentGOGC,
+		}
+		c.OverMemoryLimit = in.limitMB > 0 && c.HeapPeakMB > in.limitMB
+		a.Candidates = append(a.Candidates, c)
+
+		if c.OverMemoryLimit {
+			continue
+		}
+		// The lowest one under the target, or else the highest one.
+		if suggested < 0 || a.Candidates[suggested].OverheadPercent > targetOverhead {
+			suggested = len(a.Candidates) - 1
+		}
+	}
+	if suggested >= 0 {
+		a.SuggestedGOGC = a.Candidates[suggested].GOGC
+	}
+	return a
+}
+
+// candidate returns the prediction for gogc.
+func (a *gogcAdvice) candidate(gogc int) gogcCandidate {
+	for _, c := range a.Candidates {
+		if c.GOGC == gogc {
+			return c
+		}
+	}
+	return gogcCandidate{}
+}
+
+// String sums up the advice in a line, e.g. for the exit table.
+func (a *gogcAdvice) String() string {
+	current, suggested := a.candidate(a.CurrentGOGC), a.candidate(a.SuggestedGOGC)
+	if a.SuggestedGOGC == a.CurrentGOGC {
+		return fmt.Sprintf("GOGC=%d suits the run: %.1f GCs/min, heap up to %.0fMB, %.1f%% GC overhead",
+			current.GOGC, current.GCPerMinute, current.HeapPeakMB, current.OverheadPercent)
+	}
+	return fmt.Sprintf("GOGC=%d instead of %d: %.1f GCs/min instead of %.1f, heap up to %.0fMB instead of %.0fMB, %.1f%% GC overhead instead of %.1f%%",
+		suggested.GOGC, current.GOGC,
+		suggested.GCPerMinute, current.GCPerMinute,
+		suggested.HeapPeakMB, current.HeapPeakMB,
+		suggested.OverheadPercent, current.OverheadPercent)
+}
+
+// gogcAdvice returns the advice for the run, or nil if it is turned off or
+// there are too few cycles.
+func (s *runStats) gogcAdvice() *gogcAdvice {
+	if *gogcTargetOverhead <= 0 {
+		return nil
+	}
+	in, ok := s.gogcInputs(os.Getenv("GOGC"), adviceMemoryLimit())
+	if !ok {
+		return nil
+	}
+	return adviseGOGC(in, *gogcTargetOverhead)
+}
+
+// adviceMemoryLimit returns the memory limit of the program in megabytes,
+// or 0 if it is unknown.
+func adviceMemoryLimit() float64 {
+	limit, err := detectMemoryLimit(*memoryLimit, os.Getenv("GOMEMLIMIT"), cgroupMemoryLimits)
+	if err != nil {
+		debugf("advising GOGC without a memory limit: %v", err)
+	}
+	return limit
+}
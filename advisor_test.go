@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+// newAdvisedStats returns the stats of 11 cycles, a second apart, of 20MB
+// of live heap and a goal of 40MB, each taking 10ms.
+func newAdvisedStats() *runStats {
+	s := newRunStats()
+	for i := 1; i <= 11; i++ {
+		s.addGC(&gctrace{ElapsedTime: float64(i), STWSclock: 1, MASclock: 8, STWMclock: 1, Heap0: 40, Heap1: 40, Heap3: 20})
+	}
+	return s
+}
+
+func TestGOGCInputs(t *testing.T) {
+	s := newAdvisedStats()
+
+	in, ok := s.gogcInputs("", 0)
+	if !ok {
+		t.Fatal("Expected enough cycles to advise GOGC.")
+	}
+	if in.gogc != 100 || in.liveMB != 20 || in.gcPerSecond != 1 || in.avgPauseMs != 2 {
+		t.Errorf("Expected GOGC=100, 20MB live, a cycle per second pausing 2ms. Got %+v instead.", in)
+	}
+	// 10 cycles of 10ms over 10s.
+	if math.Abs(in.overheadPercent-1) > 1e-9 {
+		t.Errorf("Expected an overhead of 1%%. Got %v instead.", in.overheadPercent)
+	}
+
+	if in, _ := s.gogcInputs("300", 0); in.gogc != 300 {
+		t.Errorf("Expected the GOGC of the environment. Got %v instead.", in.gogc)
+	}
+	if _, ok := s.gogcInputs("off", 0); ok {
+		t.Errorf("Expected no advice with the GC off.")
+	}
+
+	few := newRunStats()
+	few.addGC(&gctrace{ElapsedTime: 1, Heap3: 20})
+	few.addGC(&gctrace{ElapsedTime: 2, Heap3: 20})
+	if _, ok := few.gogcInputs("", 0); ok {
+		t.Errorf("Expected no advice from 2 cycles.")
+	}
+}
+
+func TestAdviseGOGC(t *testing.T) {
+	in := gogcInputs{gogc: 100, liveMB: 20, gcPerSecond: 1, avgPauseMs: 2, overheadPercent: 5}
+
+	a := adviseGOGC(in, 2)
+	if a.CurrentGOGC != 100 || len(a.Candidates) != len(gogcCandidates) {
+		t.Fatalf("Expected the candidates, GOGC=100 being the current one. Got %+v instead.", a)
+	}
+	// 5% at GOGC=100, so 2.5% at 200 and 1.25% at 400.
+	if a.SuggestedGOGC != 400 {
+		t.Errorf("Expected GOGC=400 to be suggested. Got %d instead.", a.SuggestedGOGC)
+	}
+	c := a.candidate(200)
+	if c.GCPerMinute != 30 || c.HeapPeakMB != 60 || c.OverheadPercent != 2.5 || c.PausePerMinuteMs != 60 {
+		t.Errorf("Expected 30 cycles a minute pausing 60ms, up to 60MB, for GOGC=200. Got %+v instead.", c)
+	}
+	if !a.candidate(100).Current || a.candidate(200).Current {
+		t.Errorf("Expected only GOGC=100 to be current. Got %+v instead.", a.Candidates)
+	}
+
+	// The heap may not grow over 80MB.
+	in.limitMB = 80
+	a = adviseGOGC(in, 2)
+	if a.SuggestedGOGC != 200 || !a.candidate(400).OverMemoryLimit || a.candidate(200).OverMemoryLimit {
+		t.Errorf("Expected GOGC=200 to be suggested, 400 being over the limit. Got %+v instead.", a)
+	}
+
+	// Lowering GOGC saves memory while under the target.
+	in = gogcInputs{gogc: 150, liveMB: 20, gcPerSecond: 1, overheadPercent: 0.3}
+	a = adviseGOGC(in, 2)
+	if a.SuggestedGOGC != 25 || !a.candidate(150).Current || len(a.Candidates) != len(gogcCandidates)+1 {
+		t.Errorf("Expected GOGC=25 to be suggested among the candidates and 150. Got %+v instead.", a)
+	}
+	if !strings.HasPrefix(a.String(), "GOGC=25 instead of 150: ") {
+		t.Errorf("Expected the advice to tell GOGC=25 instead of 150. Got %q instead.", a.String())
+	}
+}
+
+func TestWriteExitTableGOGCAdvice(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeExitTable(&b, newAdvisedStats()); err != nil {
+		t.Fatalf("writeExitTable returned an error: %v", err)
+	}
+	if !strings.Contains(b.String(), "GOGC advice: GOGC=50 instead of 100: ") {
+		t.Errorf("Expected the exit table to suggest GOGC=50. Got %q instead.", b.String())
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var alertRules alertRulesFlag
+var alertWebhookURL = flag.String("alert-webhook", "", "URL to POST a JSON payload to when an -alert fires or recovers")
+var externalURL = flag.String("external-url", "", "URL of the web UI linked to from alerts, e.g. behind a proxy. Defaults to the URL gcvis listens on")
+
+// gcvisURL is the URL of the web UI, once started.
+var gcvisURL string
+
+// alertRecentEvents is the number of GC events whose statistics are sent
+// with alerts.
+const alertRecentEvents = 100
+
+func init() {
+	flag.Var(&alertRules, "alert", "alert rule evaluated on every event, e.g. 'stw>10ms for 3 events' or 'heap>2GiB', notified when it fires and when it recovers. Can be repeated. See the README for the metrics")
+
+	RegisterSink("alerts", newAlertSink)
+	RegisterNotifier("banner", func() (Notifier, error) { return alertBanner, nil })
+	RegisterNotifier("webhook", newWebhookNotifier)
+}
+
+// alertMetric is a value of every GC, or every scavenger, event alert
+// rules can be made on, in unit: milliseconds or megabytes. Rules on it are
+// shaded on the series of the web UI labelled series, if any.
+type alertMetric struct {
+	unit   string
+	series string
+	gc     func(t *gctrace) float64
+	scvg   func(s *scvgtrace) float64
+}
+
+var alertMetrics = map[string]alertMetric{
+	"stw":                {unit: "ms", series: "STW sweep clock", gc: func(t *gctrace) float64 { return t.STWSclock + t.STWMclock }},
+	"stw_sweep":          {unit: "ms", series: "STW sweep clock", gc: func(t *gctrace) float64 { return t.STWSclock }},
+	"stw_mark":           {unit: "ms", series: "STW mark clock", gc: func(t *gctrace) float64 { return t.STWMclock }},
+	"mark":               {unit: "ms", series: "con mas clock", gc: func(t *gctrace) float64 { return t.MASclock }},
+	"gc_overhead":        {unit: "%", gc: func(t *gctrace) float64 { return t.GCOverhead }},
+	"heap":               {unit: "MB", series: "gc.heapinuse", gc: func(t *gctrace) float64 { return float64(t.Heap0) }},
+	"heap_live":          {unit: "MB", series: "gc.heapinuse", gc: func(t *gctrace) float64 { return float64(t.Heap3) }},
+	"heap_goal":          {unit: "MB", series: "gc.heapinuse", gc: func(t *gctrace) float64 { return float64(t.Heap1) }},
+	"scvg_inuse":         {unit: "MB", series: "scvg.inuse", scvg: func(s *scvgtrace) float64 { return float64(s.Inuse) }},
+	"scvg_sys":           {unit: "MB", series: "scvg.sys", scvg: func(s *scvgtrace) float64 { return float64(s.Sys) }},
+	"scvg_released":      {unit: "MB", series: "scvg.released", scvg: func(s *scvgtrace) float64 { return float64(s.Released) }},
+	"scvg_consumed":      {unit: "MB", series: "scvg.consumed", scvg: func(s *scvgtrace) float64 { return float64(s.Consumed) }},
+	"scvg_released_idle": {unit: "%", series: "scvg.released/idle", scvg: scvgReleasedPercent},
+	"scvg_sys_gap":       {unit: "MB", series: "scvg.sys-inuse", scvg: func(s *scvgtrace) float64 { return float64(scvgSysGap(s)) }},
+}
+
+func alertMetricNames() []string {
+	names := make([]string, 0, len(alertMetrics))
+	for name := range alertMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// alertRule fires when a metric is above, or below, a value for For
+// events in a row, and recovers with the first event that is not.
+type alertRule struct {
+	Expr   string // as given to -alert
+	Metric string
+	Below  bool
+	Value  float64 // in the unit of the metric
+	For    int
+}
+
+// parseAlertRule parses metric>value or metric<value, optionally followed
+// by "for N events", value being a number in the unit of the metric, or a
+// duration or size with its own unit, e.g. 10ms or 2GiB.
+func parseAlertRule(s string) (alertRule, error) {
+	r := alertRule{Expr: s, For: 1}
+
+	cond := s
+	if i := strings.Index(s, " for "); i >= 0 {
+		cond = s[:i]
+		n := strings.TrimSpace(s[i+len(" for "):])
+		n = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(n, "events"), "event"))
+		var err error
+		if r.For, err = strconv.Atoi(n); err != nil || r.For < 1 {
+			return alertRule{}, fmt.Errorf("invalid alert %q, expected a number of events after for", s)
+		}
+	}
+
+	i := strings.IndexAny(cond, "<>")
+	if i <= 0 {
+		return alertRule{}, fmt.Errorf("invalid alert %q, expected metric>value or metric<value", s)
+	}
+	r.Metric, r.Below = strings.TrimSpace(cond[:i]), cond[i] == '<'
+	m, ok := alertMetrics[r.Metric]
+	if !ok {
+		return alertRule{}, fmt.Errorf("invalid alert %q, unknown metric %s, expected one of %s", s, r.Metric, strings.Join(alertMetricNames(), ", "))
+	}
+
+	var err error
+	if r.Value, err = parseStatValue(strings.TrimSpace(cond[i+1:]), m.unit); err != nil {
+		return alertRule{}, fmt.Errorf("invalid alert %q: %v", s, err)
+	}
+	return r, nil
+}
+
+func (r alertRule) exceededBy(value float64) bool {
+	if r.Below {
+		return value < r.Value
+	}
+	return value > r.Value
+}
+
+// alertRulesFlag collects repeated -alert flags.
+type alertRulesFlag []alertRule
+
+func (f *alertRulesFlag) String() string {
+	s := make([]string, len(*f))
+	for i, r := range *f {
+		s[i] = r.Expr
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *alertRulesFlag) Set(value string) error {
+	r, err := parseAlertRule(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, r)
+	return nil
+}
+
+func (f *alertRulesFlag) repeatable() {}
+
+// Alert is a rule firing, or recovering, as told to notifiers.
+type Alert struct {
+	Rule   string       `json:"rule"`
+	Metric string       `json:"metric"` // the rule is on
+	Firing bool         `json:"firing"` // false once recovered
+	Value  float64      `json:"value"`  // of the event that made the rule fire or recover
+	Unit   string       `json:"unit"`
+	Since  time.Time    `json:"since"` // time the rule fired at
+	Event  *logLine     `json:"event"`
+	Detail string       `json:"detail,omitempty"` // what else is known, e.g. when the heap reaches the memory limit
+	Trace  string       `json:"trace,omitempty"`  // line the event was parsed from
+	Recent *exitSummary `json:"recent,omitempty"` // statistics of the last GC events
+	URL    string       `json:"url,omitempty"`    // of the web UI
+}
+
+func (a *Alert) String() string {
+	state := "recovered"
+	if a.Firing {
+		state = "firing"
+	}
+	s := fmt.Sprintf("alert %s %s: %s", a.Rule, state, a.valueString())
+	if a.Detail != "" {
+		s += ", " + a.Detail
+	}
+	return s
+}
+
+// valueString returns the value of the event with its unit, e.g. 12ms.
+func (a *Alert) valueString() string {
+	return strconv.FormatFloat(a.Value, 'f', -1, 64) + a.Unit
+}
+
+// Notifier is told of the alerts firing and recovering. Notifiers working
+// in the background also implement io.Closer, to finish before gcvis exits.
+type Notifier interface {
+	Notify(a *Alert) error
+}
+
+// NotifierFactory creates a notifier from its command line flags. It
+// returns a nil Notifier when the flags leave the notifier turned off.
+type NotifierFactory func() (Notifier, error)
+
+var notifierFactories = map[string]NotifierFactory{}
+
+// RegisterNotifier makes a notifier available under name. Notifiers
+// register themselves from an init function, next to their flags.
+func RegisterNotifier(name string, factory NotifierFactory) {
+	if _, ok := notifierFactories[name]; ok {
+		panic(fmt.Sprintf("gcvis: notifier %q registered twice", name))
+	}
+	notifierFactories[name] = factory
+}
+
+// newNotifiers creates every registered notifier turned on by the command
+// line flags.
+func newNotifiers() ([]Notifier, error) {
+	names := make([]string, 0, len(notifierFactories))
+	for name := range notifierFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var notifiers []Notifier
+	for _, name := range names {
+		n, err := notifierFactories[name]()
+		if err != nil {
+			return nil, fmt.Errorf("%s notifier: %v", name, err)
+		}
+		if n != nil {
+			debugf("%s notifier turned on", name)
+			notifiers = append(notifiers, n)
+		}
+	}
+	return notifiers, nil
+}
+
+// alertSink evaluates the -alert rules on every event, telling the
+// notifiers when they fire and recover. -anomalies fire as rules named
+// after their metric, e.g. "stw anomaly", recovering with the next event
+// that is not anomalous, and a -leak-after growth of the live heap as a
+// "heap leak" rule.
+type alertSink struct {
+	rules     []*alertState
+	notifiers []Notifier
+	recent    []*gctrace // the last alertRecentEvents GC events
+
+	anomalies     *anomalyFinder
+	anomalyStates map[string]*alertState
+
+	leak      *leakDetector
+	leakState *alertState
+}
+
+type alertState struct {
+	alertRule
+	metric alertMetric
+	count  int       // events in a row exceeding the rule
+	since  time.Time // time the rule fired at, zero if not firing
+	detail string    // of the last event, told with the alert
+}
+
+func newAlertSink() (Sink, error) {
+	if len(alertRules) == 0 && !*detectAnomalies && *leakAfter <= 0 {
+		return nil, nil
+	}
+
+	notifiers, err := newNotifiers()
+	if err != nil {
+		return nil, err
+	}
+	s := newAlerter(alertRules, newAnomalyFinder(), notifiers)
+	if s.leak, err = newLeakDetector(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newAlerter returns a sink evaluating rules, and the anomalies found by
+// anomalies if not nil.
+func newAlerter(rules []alertRule, anomalies *anomalyFinder, notifiers []Notifier) *alertSink {
+	s := &alertSink{notifiers: notifiers, anomalies: anomalies, anomalyStates: map[string]*alertState{}}
+	for _, r := range rules {
+		s.rules = append(s.rules, &alertState{alertRule: r, metric: alertMetrics[r.Metric]})
+	}
+	for _, m := range anomalyMetrics {
+		s.anomalyStates[m.name] = &alertState{
+			alertRule: alertRule{Expr: m.name + " anomaly", Metric: m.name, For: 1},
+			metric:    alertMetric{unit: m.unit},
+		}
+	}
+	s.leakState = &alertState{alertRule: alertRule{Expr: "heap leak", Metric: "heap_live", For: 1}, metric: alertMetric{unit: "MB/min"}}
+	return s
+}
+
+func (s *alertSink) ConsumeGC(t *gctrace) error {
+	if len(s.recent) == alertRecentEvents {
+		s.recent = append(s.recent[:0], s.recent[1:]...)
+	}
+	s.recent = append(s.recent, t)
+
+	var errs sinkErrors
+	for _, r := range s.rules {
+		if r.metric.gc != nil {
+			value := r.metric.gc(t)
+			errs.Add(s.evaluate(r, value, r.exceededBy(value), t.Raw, func() *logLine { return newGCLogLine(t) }))
+		}
+	}
+	if s.anomalies != nil {
+		for _, c := range s.anomalies.check(t, t.ElapsedTime) {
+			r := s.anomalyStates[c.Metric]
+			errs.Add(s.evaluate(r, c.Value, c.Anomalous, t.Raw, func() *logLine { return newGCLogLine(t) }))
+		}
+	}
+	if s.leak != nil {
+		elapsed := t.ElapsedTime
+		if elapsed == 0 {
+			elapsed = time.Now().Sub(traceStartTime()).Seconds()
+		}
+		live := float64(t.Heap3)
+		slope, leaking := s.leak.observe(elapsed, live)
+		s.leakState.detail = s.leak.detail(elapsed, live, slope)
+		errs.Add(s.evaluate(s.leakState, math.Round(slope*60*100)/100, leaking, t.Raw, func() *logLine { return newGCLogLine(t) }))
+	}
+	return errs.Err()
+}
+
+func (s *alertSink) ConsumeScvg(t *scvgtrace) error {
+	var errs sinkErrors
+	for _, r := range s.rules {
+		if r.metric.scvg != nil {
+			value := r.metric.scvg(t)
+			errs.Add(s.evaluate(r, value, r.exceededBy(value), t.Raw, func() *logLine { return newScvgLogLine(t) }))
+		}
+	}
+	return errs.Err()
+}
+
+// evaluate updates the state of r with the value of an event, parsed from
+// raw, whose log line is only made if notified, and whether the value
+// exceeds the rule.
+func (s *alertSink) evaluate(r *alertState, value float64, exceeded bool, raw string, event func() *logLine) error {
+	if exceeded {
+		r.count++
+	} else {
+		r.count = 0
+	}
+
+	firing := !r.since.IsZero()
+	switch {
+	case !firing && r.count >= r.For:
+		r.since = time.Now()
+	case firing && r.count == 0:
+	default:
+		return nil
+	}
+
+	a := &Alert{
+		Rule:   r.Expr,
+		Metric: r.Metric,
+		Firing: r.count > 0,
+		Value:  value,
+		Unit:   r.metric.unit,
+		Since:  r.since,
+		Detail: r.detail,
+		Event:  event(),
+		Trace:  raw,
+		Recent: s.recentSummary(),
+		URL:    alertURL(),
+	}
+	if !a.Firing {
+		r.since = time.Time{}
+	}
+	infof("%s", a)
+
+	var errs sinkErrors
+	for _, n := range s.notifiers {
+		errs.Add(n.Notify(a))
+	}
+	return errs.Err()
+}
+
+// recentSummary sums up the last GC events.
+func (s *alertSink) recentSummary() *exitSummary {
+	if len(s.recent) == 0 {
+		return nil
+	}
+
+	stats := newRunStats()
+	for _, t := range s.recent {
+		stats.addGC(t)
+	}
+	return newExitSummary(stats)
+}
+
+// alertURL returns the URL of the web UI linked to from alerts.
+func alertURL() string {
+	if *externalURL != "" {
+		return *externalURL
+	}
+	return gcvisURL
+}
+
+func (s *alertSink) Flush() error { return nil }
+
+// Close waits for the notifiers still at work in the background, such as
+// the profiles being captured.
+func (s *alertSink) Close() error {
+	var errs sinkErrors
+	for _, n := range s.notifiers {
+		if c, ok := n.(io.Closer); ok {
+			errs.Add(c.Close())
+		}
+	}
+	return errs.Err()
+}
+
+// evaluates tells -filter to let every event through to the rules.
+func (s *alertSink) evaluates() {}
+
+// alertBanner is the notifier keeping the alerts firing, shown in a banner
+// of the web UI.
+var alertBanner = &bannerNotifier{firing: map[string]*Alert{}}
+
+type bannerNotifier struct {
+	mu     sync.Mutex
+	firing map[string]*Alert
+}
+
+func (b *bannerNotifier) Notify(a *Alert) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if a.Firing {
+		b.firing[a.Rule] = a
+	} else {
+		delete(b.firing, a.Rule)
+	}
+	return nil
+}
+
+// Firing returns the alerts firing, the oldest first.
+func (b *bannerNotifier) Firing() []*Alert {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	alerts := make([]*Alert, 0, len(b.firing))
+	for _, a := range b.firing {
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Since.Before(alerts[j].Since) })
+	return alerts
+}
+
+// webhookNotifier posts every alert as JSON to -alert-webhook.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookNotifier() (Notifier, error) {
+	if *alertWebhookURL == "" {
+		return nil, nil
+	}
+	return &webhookNotifier{url: *alertWebhookURL, client: &http.Client{Timeout: pushTimeout}}, nil
+}
+
+func (n *webhookNotifier) Notify(a *Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	return sendWithBackoff("alert", func() (bool, error) {
+		return postBody(n.client, n.url, header, body)
+	})
+}
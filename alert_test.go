@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	for s, expected := range map[string]alertRule{
+		"stw>10ms for 3 events": {Metric: "stw", Value: 10, For: 3},
+		"heap>2GiB":             {Metric: "heap", Value: 2048, For: 1},
+		"heap_goal < 64":        {Metric: "heap_goal", Below: true, Value: 64, For: 1},
+		"mark>1s for 1 event":   {Metric: "mark", Value: 1000, For: 1},
+	} {
+		r, err := parseAlertRule(s)
+		if err != nil {
+			t.Errorf("parseAlertRule(%q) returned an error: %v", s, err)
+			continue
+		}
+		expected.Expr = s
+		if r != expected {
+			t.Errorf("Expected %q to parse as %+v. Got %+v instead.", s, expected, r)
+		}
+	}
+
+	for _, s := range []string{"stw", "pause>10ms", "stw>soon", "stw>10ms for ever", "stw>10ms for 0 events"} {
+		if _, err := parseAlertRule(s); err == nil {
+			t.Errorf("Expected %q to be rejected.", s)
+		}
+	}
+}
+
+type fakeNotifier struct {
+	alerts []*Alert
+}
+
+func (n *fakeNotifier) Notify(a *Alert) error {
+	n.alerts = append(n.alerts, a)
+	return nil
+}
+
+func TestAlertSink(t *testing.T) {
+	rule, _ := parseAlertRule("stw>10ms for 2 events")
+	notifier := &fakeNotifier{}
+	s := newAlerter([]alertRule{rule}, nil, []Notifier{notifier})
+
+	for _, stw := range []float64{11, 2, 11, 12, 13, 1, 1} {
+		s.ConsumeGC(&gctrace{STWMclock: stw})
+	}
+	s.ConsumeScvg(&scvgtrace{})
+
+	if len(notifier.alerts) != 2 {
+		t.Fatalf("Expected the rule to fire and recover once. Got %d alerts instead.", len(notifier.alerts))
+	}
+	fired, recovered := notifier.alerts[0], notifier.alerts[1]
+	if !fired.Firing || fired.Value != 12 || fired.Unit != "ms" || fired.Event == nil || fired.Event.GC.STWMclock != 12 {
+		t.Errorf("Expected the rule to fire on the second event over 10ms. Got %+v instead.", fired)
+	}
+	if recovered.Firing || recovered.Value != 1 || !recovered.Since.Equal(fired.Since) {
+		t.Errorf("Expected the rule to recover on the first event under 10ms. Got %+v instead.", recovered)
+	}
+}
+
+func TestBannerNotifier(t *testing.T) {
+	b := &bannerNotifier{firing: map[string]*Alert{}}
+	b.Notify(&Alert{Rule: "heap>1GiB", Firing: true})
+	b.Notify(&Alert{Rule: "stw>10ms", Firing: true})
+	b.Notify(&Alert{Rule: "stw>10ms"})
+
+	if firing := b.Firing(); len(firing) != 1 || firing[0].Rule != "heap>1GiB" {
+		t.Errorf("Expected only heap>1GiB to be firing. Got %v instead.", firing)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var a Alert
+		body, _ := ioutil.ReadAll(req.Body)
+		json.Unmarshal(body, &a)
+		received <- a
+	}))
+	defer server.Close()
+
+	n := &webhookNotifier{url: server.URL, client: server.Client()}
+	if err := n.Notify(&Alert{Rule: "stw>10ms", Firing: true, Value: 12, Unit: "ms"}); err != nil {
+		t.Fatalf("Notify returned an error: %v", err)
+	}
+	if a := <-received; a.Rule != "stw>10ms" || !a.Firing || a.Value != 12 {
+		t.Errorf("Expected the alert to be posted. Got %+v instead.", a)
+	}
+}
+
+func TestWebhookNotifierFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	n := &webhookNotifier{url: server.URL, client: server.Client()}
+	if err := n.Notify(&Alert{Rule: "stw>10ms", Firing: true}); err == nil || !strings.Contains(err.Error(), "dropping alert") {
+		t.Errorf("Expected the failure to be returned. Got %v instead.", err)
+	}
+}
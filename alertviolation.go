@@ -0,0 +1,99 @@
+package main
+
+import "math"
+
+// AlertViolation is a period of time, in seconds, an -alert rule fired, from
+// the event it fired with to the one it recovered with. The last one of a
+// rule is ongoing until it recovers.
+type AlertViolation struct {
+	Rule     string
+	Series   string // label of the series of the web UI the rule is on, if any
+	Unit     string
+	Peak     float64 // value the furthest past the rule while it fired
+	From, To float64
+	Ongoing  bool
+}
+
+// alertTracker evaluates an -alert rule on every event of a graph, like the
+// alerts sink does, keeping the periods it fired.
+type alertTracker struct {
+	rule       alertRule
+	metric     alertMetric
+	count      int // events in a row exceeding the rule
+	violations []AlertViolation
+}
+
+// observe evaluates the rule with the value of the event at elapsed
+// seconds.
+func (t *alertTracker) observe(value, elapsed float64) {
+	exceeded := t.rule.exceededBy(value)
+	if exceeded {
+		t.count++
+	} else {
+		t.count = 0
+	}
+
+	firing := t.firing()
+	switch {
+	case !firing && t.count >= t.rule.For:
+		t.violations = append(t.violations, AlertViolation{
+			Rule:    t.rule.Expr,
+			Series:  t.metric.series,
+			Unit:    t.metric.unit,
+			Peak:    value,
+			From:    elapsed,
+			To:      elapsed,
+			Ongoing: true,
+		})
+	case firing:
+		last := &t.violations[len(t.violations)-1]
+		last.To, last.Ongoing = elapsed, exceeded
+		if exceeded && t.rule.Below {
+			last.Peak = math.Min(last.Peak, value)
+		} else if exceeded {
+			last.Peak = math.Max(last.Peak, value)
+		}
+	}
+}
+
+// firing tells whether the rule fired as of the latest event.
+func (t *alertTracker) firing() bool {
+	return len(t.violations) > 0 && t.violations[len(t.violations)-1].Ongoing
+}
+
+// alertTrackers are the trackers of the -alert rules of a graph.
+type alertTrackers []*alertTracker
+
+// newAlertTrackers returns the trackers of rules, or nil without any.
+func newAlertTrackers(rules []alertRule) alertTrackers {
+	var trackers alertTrackers
+	for _, r := range rules {
+		trackers = append(trackers, &alertTracker{rule: r, metric: alertMetrics[r.Metric]})
+	}
+	return trackers
+}
+
+func (ts alertTrackers) observeGC(gc *gctrace, elapsed float64) {
+	for _, t := range ts {
+		if t.metric.gc != nil {
+			t.observe(t.metric.gc(gc), elapsed)
+		}
+	}
+}
+
+func (ts alertTrackers) observeScvg(scvg *scvgtrace, elapsed float64) {
+	for _, t := range ts {
+		if t.metric.scvg != nil {
+			t.observe(t.metric.scvg(scvg), elapsed)
+		}
+	}
+}
+
+// violations returns the periods the rules fired, rule by rule.
+func (ts alertTrackers) violations() []AlertViolation {
+	var violations []AlertViolation
+	for _, t := range ts {
+		violations = append(violations, t.violations...)
+	}
+	return violations
+}
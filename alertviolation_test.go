@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAlertTracker(t *testing.T) {
+	r, err := parseAlertRule("stw>10ms for 2 events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := &alertTracker{rule: r, metric: alertMetrics[r.Metric]}
+	for i, v := range []float64{12, 5, 11, 15, 13, 4, 20} {
+		tracker.observe(v, float64(i+1))
+	}
+
+	expected := []AlertViolation{
+		{Rule: "stw>10ms for 2 events", Series: "STW sweep clock", Unit: "ms", Peak: 15, From: 4, To: 6},
+	}
+	if !reflect.DeepEqual(tracker.violations, expected) {
+		t.Errorf("Expected the rule to fire from the second event in a row to the one it recovered with, %+v. Got %+v instead.", expected, tracker.violations)
+	}
+
+	tracker.observe(25, 8)
+	if v := tracker.violations; len(v) != 2 || !v[1].Ongoing || v[1].From != 8 || v[1].Peak != 25 {
+		t.Errorf("Expected a second, ongoing, violation. Got %+v instead.", v)
+	}
+}
+
+func TestAlertTrackerBelow(t *testing.T) {
+	r, err := parseAlertRule("scvg_released_idle<50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := &alertTracker{rule: r, metric: alertMetrics[r.Metric]}
+	for i, v := range []float64{40, 20, 30, 60} {
+		tracker.observe(v, float64(i+1))
+	}
+
+	expected := []AlertViolation{
+		{Rule: "scvg_released_idle<50", Series: "scvg.released/idle", Unit: "%", Peak: 20, From: 1, To: 4},
+	}
+	if !reflect.DeepEqual(tracker.violations, expected) {
+		t.Errorf("Expected the lowest value as the peak, %+v. Got %+v instead.", expected, tracker.violations)
+	}
+}
+
+func TestGraphAlertViolations(t *testing.T) {
+	defer func(rules alertRulesFlag) { alertRules = rules }(alertRules)
+	alertRules = nil
+	for _, rule := range []string{"heap>100", "scvg_sys>200", "gc_overhead>50"} {
+		if err := alertRules.Set(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 1, Heap0: 150})
+	graph.AddScavengerGraphPoint(&scvgtrace{ElapsedTime: 2, Sys: 250})
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 2, ElapsedTime: 3, Heap0: 50})
+
+	s := graph.Snapshot()
+	if len(s.AlertViolations) != 2 {
+		t.Fatalf("Expected violations of the heap and scavenger rules. Got %+v instead.", s.AlertViolations)
+	}
+	if v := s.AlertViolations[0]; v.Series != "gc.heapinuse" || v.From != 1 || v.To != 3 || v.Ongoing {
+		t.Errorf("Expected the heap rule to have fired from 1s to 3s on the heap. Got %+v instead.", v)
+	}
+	if v := s.AlertViolations[1]; v.Series != "scvg.sys" || v.From != 2 || !v.Ongoing {
+		t.Errorf("Expected the scavenger rule to be firing since 2s. Got %+v instead.", v)
+	}
+
+	restored := NewGraph("fake title", GCVIS_TMPL)
+	restored.Restore(s)
+	restored.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 1, Heap0: 120})
+	r := restored.Snapshot()
+	if len(r.AlertViolations) != 3 || r.AlertViolations[1].Ongoing || r.AlertViolations[2].From != 4 {
+		t.Errorf("Expected the restored violations to be over, and the new one after them. Got %+v instead.", r.AlertViolations)
+	}
+}
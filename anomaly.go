@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var detectAnomalies = flag.Bool("anomalies", false, "flag the STW pauses and allocation rates far above their recent median on the graphs, and notify them as alerts")
+var anomalyThreshold = flag.Float64("anomaly-threshold", 5, "how many median absolute deviations above the recent median a value of -anomalies is anomalous from")
+
+const (
+	// anomalyWindow is the number of recent values the median and the
+	// median absolute deviation are computed over.
+	anomalyWindow = 100
+
+	// anomalyMinValues is the number of values needed before any is
+	// flagged, for the median to mean something.
+	anomalyMinValues = 20
+
+	// anomalyMinSpread is the smallest deviation, relative to the
+	// median, taken into account, so that values varying very little do
+	// not make every small change an anomaly.
+	anomalyMinSpread = 0.1
+
+	// madScale makes the median absolute deviation comparable to a
+	// standard deviation, for normally distributed values.
+	madScale = 1.4826
+)
+
+// Anomaly is a value of a metric of a GC event far above its recent
+// median.
+type Anomaly struct {
+	Time   float64 // seconds
+	Metric string
+	Value  float64
+	Median float64 // of the recent values
+	Unit   string
+}
+
+func (a Anomaly) String() string {
+	return fmt.Sprintf("%s anomaly: %.2f%s, median %.2f%s", a.Metric, a.Value, a.Unit, a.Median, a.Unit)
+}
+
+// anomalyDetector flags the values of a metric more than threshold median
+// absolute deviations above the median of the values before them.
+type anomalyDetector struct {
+	threshold float64
+	values    []float64 // the last anomalyWindow values, oldest first
+}
+
+// observe adds v to the recent values, returning their median before v,
+// and whether v is anomalous.
+func (d *anomalyDetector) observe(v float64) (float64, bool) {
+	var median float64
+	anomalous := false
+	if len(d.values) > 0 {
+		var mad float64
+		median, mad = medianAbsoluteDeviation(d.values)
+		spread := math.Max(madScale*mad, anomalyMinSpread*math.Abs(median))
+		anomalous = len(d.values) >= anomalyMinValues && spread > 0 && v > median+d.threshold*spread
+	}
+
+	if len(d.values) == anomalyWindow {
+		d.values = append(d.values[:0], d.values[1:]...)
+	}
+	d.values = append(d.values, v)
+	return median, anomalous
+}
+
+// medianAbsoluteDeviation returns the median of values, and the median of
+// their absolute deviations from it.
+func medianAbsoluteDeviation(values []float64) (float64, float64) {
+	sorted := append([]float64(nil), values...)
+	median := medianOf(sorted)
+	for i, v := range values {
+		sorted[i] = math.Abs(v - median)
+	}
+	return median, medianOf(sorted)
+}
+
+// medianOf sorts values, returning their median.
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// anomalyMetrics are the metrics of GC events checked for anomalies, in
+// the order they are checked in.
+var anomalyMetrics = []struct {
+	name, unit string
+}{
+	{"stw", "ms"},
+	{"alloc_rate", "MB/s"},
+}
+
+// anomalyFinder checks the metrics of every GC event for anomalies.
+type anomalyFinder struct {
+	detectors map[string]*anomalyDetector
+
+	// The previous event, the allocation rate being the growth of the
+	// heap since its live heap.
+	seen        bool
+	lastLive    int64
+	lastElapsed float64
+}
+
+// anomalyCheck is the value of a metric of a GC event, and whether it is
+// anomalous.
+type anomalyCheck struct {
+	Anomaly
+	Anomalous bool
+}
+
+// newAnomalyFinder returns a finder of the anomalies of -anomalies, or nil
+// if they are not detected.
+func newAnomalyFinder() *anomalyFinder {
+	if !*detectAnomalies {
+		return nil
+	}
+
+	f := &anomalyFinder{detectors: map[string]*anomalyDetector{}}
+	for _, m := range anomalyMetrics {
+		f.detectors[m.name] = &anomalyDetector{threshold: *anomalyThreshold}
+	}
+	return f
+}
+
+// check returns the values of the metrics of t, at elapsed seconds, which
+// can be told. The allocation rate cannot for the first event, or events
+// not telling when they were traced.
+func (f *anomalyFinder) check(t *gctrace, elapsed float64) []anomalyCheck {
+	values := map[string]float64{"stw": t.STWSclock + t.STWMclock}
+	if f.seen && t.ElapsedTime > f.lastElapsed {
+		values["alloc_rate"] = float64(t.Heap0-f.lastLive) / (t.ElapsedTime - f.lastElapsed)
+	}
+	f.seen, f.lastLive, f.lastElapsed = true, t.Heap3, t.ElapsedTime
+
+	var checks []anomalyCheck
+	for _, m := range anomalyMetrics {
+		v, ok := values[m.name]
+		if !ok {
+			continue
+		}
+		median, anomalous := f.detectors[m.name].observe(v)
+		checks = append(checks, anomalyCheck{
+			Anomaly:   Anomaly{Time: elapsed, Metric: m.name, Value: v, Median: median, Unit: m.unit},
+			Anomalous: anomalous,
+		})
+	}
+	return checks
+}
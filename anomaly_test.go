@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAnomalyDetector(t *testing.T) {
+	d := &anomalyDetector{threshold: 5}
+
+	// Too few values to tell an anomaly.
+	if _, anomalous := d.observe(100); anomalous {
+		t.Errorf("Expected no anomaly before %d values.", anomalyMinValues)
+	}
+	d.values = nil
+
+	for i := 0; i < anomalyMinValues; i++ {
+		if _, anomalous := d.observe(float64(10 + i%3)); anomalous {
+			t.Fatalf("Expected no anomaly in steady values. Got one at %d instead.", i)
+		}
+	}
+
+	if median, anomalous := d.observe(14); anomalous || median != 11 {
+		t.Errorf("Expected 14 not to be anomalous, with a median of 11. Got %v, %v instead.", anomalous, median)
+	}
+	if _, anomalous := d.observe(60); !anomalous {
+		t.Errorf("Expected 60 to be anomalous.")
+	}
+	if _, anomalous := d.observe(2); anomalous {
+		t.Errorf("Expected values under the median not to be anomalous.")
+	}
+}
+
+func TestAnomalyDetectorWindow(t *testing.T) {
+	d := &anomalyDetector{threshold: 5}
+	for i := 0; i < 2*anomalyWindow; i++ {
+		d.observe(float64(i))
+	}
+	if len(d.values) != anomalyWindow || d.values[0] != anomalyWindow {
+		t.Errorf("Expected the last %d values to be kept. Got %d from %v instead.", anomalyWindow, len(d.values), d.values[0])
+	}
+}
+
+func TestAnomalyDetectorSteadyValues(t *testing.T) {
+	d := &anomalyDetector{threshold: 5}
+	for i := 0; i < anomalyMinValues; i++ {
+		d.observe(10)
+	}
+
+	// Without any deviation, the spread is a tenth of the median.
+	if _, anomalous := d.observe(14); anomalous {
+		t.Errorf("Expected 14 not to be anomalous.")
+	}
+	if _, anomalous := d.observe(16); !anomalous {
+		t.Errorf("Expected 16 to be anomalous.")
+	}
+}
+
+func TestAnomalyFinder(t *testing.T) {
+	defer func(v bool) { *detectAnomalies = v }(*detectAnomalies)
+	*detectAnomalies = true
+
+	f := newAnomalyFinder()
+	checks := f.check(&gctrace{ElapsedTime: 1, STWSclock: 1, STWMclock: 2, Heap0: 10, Heap3: 4}, 1)
+	if len(checks) != 1 || checks[0].Metric != "stw" || checks[0].Value != 3 {
+		t.Fatalf("Expected only the STW of the first event to be checked. Got %+v instead.", checks)
+	}
+
+	checks = f.check(&gctrace{ElapsedTime: 3, Heap0: 24, Heap3: 4}, 13)
+	if len(checks) != 2 || checks[1].Metric != "alloc_rate" || checks[1].Value != 10 || checks[1].Unit != "MB/s" || checks[1].Time != 13 {
+		t.Errorf("Expected an allocation rate of 10MB/s at 13s. Got %+v instead.", checks)
+	}
+
+	*detectAnomalies = false
+	if f := newAnomalyFinder(); f != nil {
+		t.Errorf("Expected no finder without -anomalies. Got %+v instead.", f)
+	}
+}
+
+func TestGraphAnomalies(t *testing.T) {
+	defer func(v bool) { *detectAnomalies = v }(*detectAnomalies)
+	*detectAnomalies = true
+
+	g := NewGraph("anomalies", GCVIS_TMPL)
+	for i := 1; i <= anomalyMinValues; i++ {
+		g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: float64(i), STWMclock: 1})
+	}
+	g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: 100, STWMclock: 50})
+
+	s := g.Snapshot()
+	if len(s.Anomalies) != 1 || s.Anomalies[0].Metric != "stw" || s.Anomalies[0].Time != 100 || s.Anomalies[0].Median != 1 {
+		t.Errorf("Expected an STW anomaly at 100s. Got %+v instead.", s.Anomalies)
+	}
+}
+
+func TestAlertSinkAnomalies(t *testing.T) {
+	defer func(v bool) { *detectAnomalies = v }(*detectAnomalies)
+	*detectAnomalies = true
+
+	notifier := &fakeNotifier{}
+	s := newAlerter(nil, newAnomalyFinder(), []Notifier{notifier})
+	for i := 0; i < anomalyMinValues; i++ {
+		s.ConsumeGC(&gctrace{STWMclock: 1})
+	}
+	s.ConsumeGC(&gctrace{STWMclock: 50})
+	s.ConsumeGC(&gctrace{STWMclock: 1})
+
+	if len(notifier.alerts) != 2 {
+		t.Fatalf("Expected the anomaly to fire and recover. Got %d alerts instead.", len(notifier.alerts))
+	}
+	if a := notifier.alerts[0]; a.Rule != "stw anomaly" || !a.Firing || a.Value != 50 || a.Unit != "ms" {
+		t.Errorf("Expected an STW anomaly of 50ms firing. Got %+v instead.", a)
+	}
+	if a := notifier.alerts[1]; a.Rule != "stw anomaly" || a.Firing {
+		t.Errorf("Expected the STW anomaly to recover. Got %+v instead.", a)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// timedTrace is a GC trace at its time on the graph, in seconds.
+type timedTrace struct {
+	elapsed float64
+	gc      *gctrace
+}
+
+// traceLog keeps the GC traces of a graph, for the summaries of windows of
+// it, evicting them like the points of the graph are.
+type traceLog struct {
+	traces    []timedTrace // oldest first
+	max       int
+	retention float64 // seconds
+}
+
+func newTraceLog(max int, retention time.Duration) traceLog {
+	return traceLog{max: max, retention: retention.Seconds()}
+}
+
+func (l *traceLog) add(t timedTrace) {
+	l.traces = append(l.traces, t)
+
+	drop := 0
+	if l.max > 0 && len(l.traces) > l.max {
+		drop = len(l.traces) - l.max
+	}
+	if l.retention > 0 {
+		for drop < len(l.traces) && l.traces[drop].elapsed < t.elapsed-l.retention {
+			drop++
+		}
+	}
+	l.traces = l.traces[drop:]
+}
+
+// between returns the traces between from and to seconds, included.
+func (l *traceLog) between(from, to float64) []timedTrace {
+	var traces []timedTrace
+	for _, t := range l.traces {
+		if t.elapsed >= from && t.elapsed <= to {
+			traces = append(traces, t)
+		}
+	}
+	return traces
+}
+
+// windowSummary sums up the GC events of a window of the graph, as served
+// at /api/v1/summary. The window defaults to the first and last events.
+type windowSummary struct {
+	FromSeconds float64 `json:"from_seconds"`
+	ToSeconds   float64 `json:"to_seconds"`
+	*exitSummary
+
+	// GCCPUFraction is the share of the CPU time of the window spent
+	// collecting garbage, outside of idle marking.
+	GCCPUFraction float64 `json:"gc_cpu_fraction"`
+
+	SLOs []sloReport `json:"slos,omitempty"` // of -slo
+}
+
+// Summary sums up the GC events between from and to seconds of the graph,
+// which may be infinite.
+func (g *Graph) Summary(from, to float64) *windowSummary {
+	g.mu.Lock()
+	g.applyPending()
+	traces := g.gcTraces.between(from, to)
+	g.mu.Unlock()
+
+	if math.IsInf(from, -1) {
+		from = 0
+		if len(traces) > 0 {
+			from = traces[0].elapsed
+		}
+	}
+	if math.IsInf(to, 1) {
+		to = from
+		if len(traces) > 0 {
+			to = traces[len(traces)-1].elapsed
+		}
+	}
+
+	stats := newRunStats()
+	var cpu float64 // milliseconds
+	var procs int64
+	for _, t := range traces {
+		stats.addGC(t.gc)
+		cpu += t.gc.STWScpu + t.gc.MASAssistcpu + t.gc.MASBGcpu + t.gc.STWMcpu
+		if t.gc.Nproc > procs {
+			procs = t.gc.Nproc
+		}
+	}
+
+	s := &windowSummary{FromSeconds: from, ToSeconds: to, exitSummary: newExitSummary(stats)}
+
+	// The traces may not tell when they were written, the graph does.
+	d := to - from
+	s.DurationSeconds, s.AllocRateMBPerSecond = d, 0
+	if d > 0 && stats.NumGC >= 2 {
+		s.AllocRateMBPerSecond = float64(stats.allocated) / d
+	}
+	if d > 0 && procs > 0 {
+		s.GCCPUFraction = cpu / (d * 1000 * float64(procs))
+	}
+
+	g.mu.Lock()
+	s.SLOs = g.slos.reports(from, to)
+	g.mu.Unlock()
+	return s
+}
+
+// lastTraceTime returns the time of the latest GC trace kept, in seconds.
+func (g *Graph) lastTraceTime() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.applyPending()
+
+	if n := len(g.gcTraces.traces); n > 0 {
+		return g.gcTraces.traces[n-1].elapsed
+	}
+	return 0
+}
+
+// handleSummary serves the summary of the window of the graph between the
+// from and to query parameters, in seconds, or of the last query
+// parameter, a duration before the latest event.
+func (h *HttpServer) handleSummary(w http.ResponseWriter, req *http.Request) {
+	from, to, err := h.windowParams(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.graph.Summary(from, to))
+}
+
+// windowParams returns the window of the graph between the from and to
+// query parameters, in seconds, or of the last query parameter, a duration
+// before the latest event. Bounds not given are infinite.
+func (h *HttpServer) windowParams(req *http.Request) (float64, float64, error) {
+	query := req.URL.Query()
+	from, to := math.Inf(-1), math.Inf(1)
+	for name, bound := range map[string]*float64{"from": &from, "to": &to} {
+		if v := query.Get(name); v != "" {
+			var err error
+			if *bound, err = strconv.ParseFloat(v, 64); err != nil {
+				return 0, 0, fmt.Errorf("invalid %s %q", name, v)
+			}
+		}
+	}
+
+	if v := query.Get("last"); v != "" {
+		last, err := time.ParseDuration(v)
+		if err != nil || last <= 0 {
+			return 0, 0, fmt.Errorf("invalid last %q", v)
+		}
+		to = h.graph.lastTraceTime()
+		from = to - last.Seconds()
+	}
+	return from, to, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTraceLog(t *testing.T) {
+	l := newTraceLog(3, 10*time.Second)
+	for i := 1; i <= 4; i++ {
+		l.add(timedTrace{elapsed: float64(i), gc: &gctrace{NumGC: int64(i)}})
+	}
+	if len(l.traces) != 3 || l.traces[0].gc.NumGC != 2 {
+		t.Errorf("Expected the last 3 traces to be kept. Got %+v instead.", l.traces)
+	}
+
+	l.add(timedTrace{elapsed: 14, gc: &gctrace{NumGC: 5}})
+	if len(l.traces) != 2 || l.traces[0].gc.NumGC != 4 {
+		t.Errorf("Expected the traces of the last 10s to be kept. Got %+v instead.", l.traces)
+	}
+
+	if between := l.between(10, 20); len(between) != 1 || between[0].gc.NumGC != 5 {
+		t.Errorf("Expected only the trace at 14s. Got %+v instead.", between)
+	}
+}
+
+func newSummaryGraph() *Graph {
+	g := NewGraph("summary", GCVIS_TMPL)
+	for i := int64(1); i <= 5; i++ {
+		g.AddGCTraceGraphPoint(&gctrace{
+			ElapsedTime: float64(10 * i),
+			NumGC:       i,
+			Nproc:       4,
+			STWSclock:   float64(i),
+			STWScpu:     100,
+			MASBGcpu:    300,
+			MASIdlecpu:  1000,
+			Heap0:       50,
+			Heap3:       10 * i,
+		})
+	}
+	return g
+}
+
+func TestGraphSummary(t *testing.T) {
+	g := newSummaryGraph()
+
+	s := g.Summary(20, 40)
+	if s.NumGC != 3 || s.FromSeconds != 20 || s.ToSeconds != 40 || s.DurationSeconds != 20 {
+		t.Errorf("Expected the 3 cycles of 20s to 40s. Got %+v instead.", s)
+	}
+	if s.PausePercentilesMs["max"] != 4 || s.HeapMinMB != 20 || s.HeapHighWaterMB != 50 {
+		t.Errorf("Expected a max pause of 4ms and a heap of 20 to 50MB. Got %+v instead.", s.exitSummary)
+	}
+	// 30MB then 20MB allocated over 20s.
+	if s.AllocRateMBPerSecond != 2.5 {
+		t.Errorf("Expected an allocation rate of 2.5MB/s. Got %v instead.", s.AllocRateMBPerSecond)
+	}
+	// 3 cycles of 400ms of CPU time, over 20s of 4 processors.
+	if math.Abs(s.GCCPUFraction-0.015) > 1e-9 {
+		t.Errorf("Expected a GC CPU fraction of 0.015. Got %v instead.", s.GCCPUFraction)
+	}
+
+	all := g.Summary(math.Inf(-1), math.Inf(1))
+	if all.NumGC != 5 || all.FromSeconds != 10 || all.ToSeconds != 50 {
+		t.Errorf("Expected the whole graph, from 10s to 50s. Got %+v instead.", all)
+	}
+
+	empty := NewGraph("empty", GCVIS_TMPL).Summary(math.Inf(-1), math.Inf(1))
+	if empty.NumGC != 0 || empty.DurationSeconds != 0 || empty.GCCPUFraction != 0 {
+		t.Errorf("Expected an empty summary. Got %+v instead.", empty)
+	}
+	if _, err := json.Marshal(empty); err != nil {
+		t.Errorf("Expected an empty summary to encode. Got %v instead.", err)
+	}
+}
+
+func TestHttpServerSummary(t *testing.T) {
+	server := NewHttpServer("127.0.0.1", "0", newSummaryGraph())
+
+	go server.Start()
+	defer server.Close()
+
+	response, err := http.Get(server.Url() + "api/v1/summary?last=15s")
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	var s map[string]interface{}
+	err = json.NewDecoder(response.Body).Decode(&s)
+	response.Body.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s["gc_count"] != 2.0 || s["from_seconds"] != 35.0 || s["to_seconds"] != 50.0 {
+		t.Errorf("Expected the 2 cycles of the last 15s. Got %v instead.", s)
+	}
+	if _, ok := s["gc_cpu_fraction"]; !ok {
+		t.Errorf("Expected the GC CPU fraction. Got %v instead.", s)
+	}
+
+	for _, query := range []string{"from=soon", "last=-1s"} {
+		response, err := http.Get(server.Url() + "api/v1/summary?" + query)
+		if err != nil {
+			t.Fatalf("HTTP request returned an error: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s. Got %d instead.", query, response.StatusCode)
+		}
+	}
+}
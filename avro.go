@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// avroSchema is an Avro record schema of primitive fields, possibly
+// nullable, used to encode events in the Avro binary encoding.
+type avroSchema struct {
+	fields []avroField
+}
+
+type avroField struct {
+	name   string
+	types  []string // the branches of a union, or a single type
+	def    interface{}
+	hasDef bool
+}
+
+// parseAvroSchema parses a record schema whose fields are primitive types,
+// e.g. "long" or {"type": "long", "logicalType": "timestamp-millis"}, or
+// unions of them like ["null", "double"].
+func parseAvroSchema(b []byte) (*avroSchema, error) {
+	var record struct {
+		Type   string
+		Fields []struct {
+			Name    string
+			Type    json.RawMessage
+			Default json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, fmt.Errorf("invalid Avro schema: %v", err)
+	}
+	if record.Type != "record" {
+		return nil, fmt.Errorf("invalid Avro schema: expected a record, got %q", record.Type)
+	}
+
+	s := &avroSchema{}
+	for _, f := range record.Fields {
+		field := avroField{name: f.Name}
+
+		var union []json.RawMessage
+		if err := json.Unmarshal(f.Type, &union); err != nil {
+			union = []json.RawMessage{f.Type}
+		}
+		for _, t := range union {
+			typ, err := avroPrimitiveType(t)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Avro schema: field %s: %v", f.Name, err)
+			}
+			field.types = append(field.types, typ)
+		}
+
+		if f.Default != nil {
+			if err := json.Unmarshal(f.Default, &field.def); err != nil {
+				return nil, fmt.Errorf("invalid Avro schema: field %s: %v", f.Name, err)
+			}
+			field.hasDef = true
+		}
+
+		s.fields = append(s.fields, field)
+	}
+
+	return s, nil
+}
+
+func avroPrimitiveType(b json.RawMessage) (string, error) {
+	var typ string
+	if err := json.Unmarshal(b, &typ); err != nil {
+		var complex struct{ Type string }
+		if err := json.Unmarshal(b, &complex); err != nil {
+			return "", err
+		}
+		typ = complex.Type
+	}
+
+	switch typ {
+	case "null", "boolean", "int", "long", "float", "double", "string":
+		return typ, nil
+	}
+	return "", fmt.Errorf("unsupported type %q", typ)
+}
+
+// encode encodes values, as decoded from JSON, as a record. A field without
+// a value takes its default, or null when it is nullable.
+func (s *avroSchema) encode(values map[string]interface{}) ([]byte, error) {
+	var b []byte
+	for _, f := range s.fields {
+		v, ok := values[f.name]
+		if !ok && f.hasDef {
+			v, ok = f.def, true
+		}
+
+		branch := -1
+		for i, typ := range f.types {
+			if (typ == "null") == (!ok || v == nil) {
+				branch = i
+				break
+			}
+		}
+		if branch < 0 {
+			return nil, fmt.Errorf("no value for Avro field %s", f.name)
+		}
+
+		if len(f.types) > 1 {
+			b = appendZigzag(b, int64(branch))
+		}
+
+		var err error
+		if b, err = appendAvroValue(b, f.types[branch], v); err != nil {
+			return nil, fmt.Errorf("Avro field %s: %v", f.name, err)
+		}
+	}
+
+	return b, nil
+}
+
+func appendAvroValue(b []byte, typ string, v interface{}) ([]byte, error) {
+	switch typ {
+	case "null":
+		return b, nil
+	case "boolean":
+		if v, ok := v.(bool); ok {
+			if v {
+				return append(b, 1), nil
+			}
+			return append(b, 0), nil
+		}
+	case "int", "long":
+		if v, ok := v.(float64); ok {
+			return appendZigzag(b, int64(v)), nil
+		}
+	case "float":
+		if v, ok := v.(float64); ok {
+			var buf [4]byte
+			binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(v)))
+			return append(b, buf[:]...), nil
+		}
+	case "double":
+		if v, ok := v.(float64); ok {
+			return appendFixed64(b, math.Float64bits(v)), nil
+		}
+	case "string":
+		if v, ok := v.(string); ok {
+			b = appendZigzag(b, int64(len(v)))
+			return append(b, v...), nil
+		}
+	}
+
+	return nil, fmt.Errorf("cannot encode %v as %s", v, typ)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAvroSchemaEncode(t *testing.T) {
+	s, err := parseAvroSchema([]byte(`{
+		"type": "record",
+		"name": "GC",
+		"fields": [
+			{"name": "time", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+			{"name": "srv", "type": "string"},
+			{"name": "HeapGoal", "type": ["null", "long"]},
+			{"name": "Inuse", "type": ["null", "long"]},
+			{"name": "STWMclock", "type": "double"},
+			{"name": "env", "type": "string", "default": "prod"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	b, err := s.encode(map[string]interface{}{
+		"time":      float64(1000),
+		"srv":       "api",
+		"HeapGoal":  float64(4),
+		"STWMclock": 0.5,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0xd0, 0x0f, // 1000
+		0x06, 'a', 'p', 'i',
+		0x02, 0x08, // branch 1, 4
+		0x00,                         // branch 0, null
+		0, 0, 0, 0, 0, 0, 0xe0, 0x3f, // 0.5
+		0x08, 'p', 'r', 'o', 'd',
+	}
+	if !bytes.Equal(b, expected) {
+		t.Errorf("Expected %x. Got %x instead.", expected, b)
+	}
+
+	if _, err := s.encode(map[string]interface{}{"time": float64(1)}); err == nil {
+		t.Errorf("Expected an error for a missing field.")
+	}
+}
+
+func TestParseAvroSchemaErrors(t *testing.T) {
+	for _, schema := range []string{
+		`not json`,
+		`{"type": "enum"}`,
+		`{"type": "record", "fields": [{"name": "a", "type": {"type": "array", "items": "long"}}]}`,
+	} {
+		if _, err := parseAvroSchema([]byte(schema)); err == nil {
+			t.Errorf("Expected an error for schema %s.", schema)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// runBench runs a program several times, recording the GC traces of every
+// run, and sums up the statistics of the runs, so that a change is judged
+// against the noise between runs of the same code.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	n := fs.Int("n", 5, "number of runs")
+	dir := fs.String("dir", "", "directory to record the runs to, as run-1.jsonl and so on. Defaults to a new temporary directory")
+	format := fs.String("format", "text", "format of the statistics: text or json")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || *n < 1 {
+		fmt.Fprintln(os.Stderr, "bench: expected a number of runs and a program, e.g. gcvis bench -n 5 -- ./prog")
+		os.Exit(2)
+	}
+
+	if *dir == "" {
+		var err error
+		if *dir, err = ioutil.TempDir("", "gcvis-bench"); err != nil {
+			fatalf("%v", err)
+		}
+	} else if err := os.MkdirAll(*dir, 0755); err != nil {
+		fatalf("%v", err)
+	}
+
+	b := &benchResult{Dir: *dir}
+	for i := 1; i <= *n; i++ {
+		path := filepath.Join(*dir, fmt.Sprintf("run-%d.jsonl", i))
+		infof("bench: run %d of %d, recorded to %s", i, *n, path)
+		summary, err := benchRun(fs.Args(), path)
+		if err != nil {
+			fatalf("bench: run %d: %v", i, err)
+		}
+		b.Runs = append(b.Runs, summary)
+	}
+
+	if err := b.write(os.Stdout, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+
+// benchRun runs args once, recording its events to path, and returns the
+// summary of the run.
+func benchRun(args []string, path string) (*exitSummary, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	record := &recordSink{w: f, enc: json.NewEncoder(f)}
+
+	subcommand := NewSubCommand(args)
+	parser := NewParser(subcommand.PipeRead)
+	stats := newRunStats()
+
+	// The traces tell the time since the run started, not gcvis.
+	started := time.Now()
+	at := func(elapsed float64) time.Time {
+		return started.Add(time.Duration(elapsed * float64(time.Second)))
+	}
+
+	go subcommand.Run()
+	go parser.Run()
+	for {
+		select {
+		case t := <-parser.GcChan:
+			stats.addGC(t)
+			err = record.recordGC(t, at(t.ElapsedTime))
+		case t := <-parser.ScvgChan:
+			stats.addScvg(t)
+			err = record.recordScvg(t, at(t.ElapsedTime))
+		case output := <-parser.NoMatchChan:
+			if !*quiet {
+				fmt.Fprintln(os.Stderr, output)
+			}
+		case <-parser.Done():
+			if parser.Err != nil {
+				return nil, parser.Err
+			}
+			if err := subcommand.Err(); err != nil {
+				return nil, err
+			}
+			return newExitSummary(stats), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// benchResult sums up the runs of a benchmark.
+type benchResult struct {
+	Dir  string // the runs are recorded to
+	Runs []*exitSummary
+}
+
+// benchStat is a statistic of every run of a benchmark.
+type benchStat struct {
+	Name   string    `json:"name"`
+	Unit   string    `json:"unit"`
+	Values []float64 `json:"values"` // by run
+}
+
+// Mean returns the mean of the values.
+func (s benchStat) Mean() float64 {
+	total := 0.0
+	for _, v := range s.Values {
+		total += v
+	}
+	return total / float64(len(s.Values))
+}
+
+// Stddev returns the sample standard deviation of the values, or 0 for a
+// single run.
+func (s benchStat) Stddev() float64 {
+	if len(s.Values) < 2 {
+		return 0
+	}
+	mean, squares := s.Mean(), 0.0
+	for _, v := range s.Values {
+		squares += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(squares / float64(len(s.Values)-1))
+}
+
+func (s benchStat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":   s.Name,
+		"unit":   s.Unit,
+		"values": s.Values,
+		"mean":   s.Mean(),
+		"stddev": s.Stddev(),
+	})
+}
+
+// stats returns the statistics of the runs compared.
+func (b *benchResult) stats() []benchStat {
+	stats := []benchStat{
+		{Name: "GC cycles"},
+		{Name: "p99 pause", Unit: "ms"},
+		{Name: "Longest pause", Unit: "ms"},
+		{Name: "Total pause", Unit: "ms"},
+		{Name: "Heap high water", Unit: "MB"},
+		{Name: "Allocation rate", Unit: "MB/s"},
+	}
+	for _, r := range b.Runs {
+		for i, v := range []float64{
+			float64(r.NumGC),
+			r.PausePercentilesMs["p99"],
+			r.PausePercentilesMs["max"],
+			r.TotalPauseMs,
+			float64(r.HeapHighWaterMB),
+			r.AllocRateMBPerSecond,
+		} {
+			stats[i].Values = append(stats[i].Values, v)
+		}
+	}
+	return stats
+}
+
+func (b *benchResult) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"runs":       len(b.Runs),
+			"dir":        b.Dir,
+			"statistics": b.stats(),
+		})
+	case "text":
+		fmt.Fprintf(w, "%d runs, recorded to %s\n\n", len(b.Runs), b.Dir)
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "\tmean\tstddev\t\n")
+		for _, s := range b.stats() {
+			fmt.Fprintf(tw, "%s\t%s\t±%s\t\n", s.Name, formatDiffValue(s.Mean(), s.Unit), formatDiffValue(s.Stddev(), s.Unit))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("bench: unsupported format %q", format)
+}
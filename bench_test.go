@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBenchRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "run-1.jsonl")
+	script := "printf '%s' " + shellQuote(diffCandidateLog) + " 1>&2"
+	summary, err := benchRun([]string{"/usr/bin/env", "bash", "-c", script}, path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.NumGC != 3 || summary.HeapHighWaterMB != 8 {
+		t.Errorf("Expected 3 cycles up to 8MB. Got %+v instead.", summary)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	traces, err := readRecording(f)
+	if err != nil || len(traces) != 3 || traces[2].elapsed != 35 {
+		t.Errorf("Expected the run recorded, over 35s. Got %+v, %v instead.", traces, err)
+	}
+
+	if _, err := benchRun([]string{"/usr/bin/env", "bash", "-c", "exit 1"}, path); err == nil {
+		t.Errorf("Expected a failing run to be an error.")
+	}
+}
+
+// shellQuote quotes s for bash.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func TestBenchStat(t *testing.T) {
+	s := benchStat{Name: "GC cycles", Values: []float64{2, 4, 4, 4, 5, 5, 7, 9}}
+	if s.Mean() != 5 {
+		t.Errorf("Expected a mean of 5. Got %v instead.", s.Mean())
+	}
+	if d := s.Stddev(); d < 2.13 || d > 2.14 {
+		t.Errorf("Expected a standard deviation of 2.14. Got %v instead.", d)
+	}
+	if d := (benchStat{Values: []float64{3}}).Stddev(); d != 0 {
+		t.Errorf("Expected no deviation of a single run. Got %v instead.", d)
+	}
+}
+
+func TestBenchWrite(t *testing.T) {
+	b := &benchResult{Dir: "/tmp/bench", Runs: []*exitSummary{
+		{NumGC: 10, TotalPauseMs: 4, PausePercentilesMs: map[string]float64{"p99": 1, "max": 2}},
+		{NumGC: 12, TotalPauseMs: 6, PausePercentilesMs: map[string]float64{"p99": 3, "max": 4}},
+	}}
+
+	var text bytes.Buffer
+	if err := b.write(&text, "text"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{"2 runs, recorded to /tmp/bench", "GC cycles", "p99 pause", "±"} {
+		if !strings.Contains(text.String(), expected) {
+			t.Errorf("Expected the statistics to contain %q. Got:\n%s", expected, text.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := b.write(&out, "json"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var decoded struct {
+		Runs       int
+		Statistics []struct {
+			Name   string
+			Values []float64
+			Mean   float64
+		}
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Runs != 2 || decoded.Statistics[0].Name != "GC cycles" || decoded.Statistics[0].Mean != 11 || len(decoded.Statistics[0].Values) != 2 {
+		t.Errorf("Expected the cycles of 2 runs, 11 on average. Got %+v instead.", decoded)
+	}
+
+	if err := b.write(&out, "csv"); err == nil {
+		t.Errorf("Expected csv to be unsupported.")
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// bundleVersion is the version of the format of .gcvis bundles, bumped
+// when older ones can no longer be imported.
+const bundleVersion = 1
+
+// bundleExt is the extension of bundles.
+const bundleExt = ".gcvis"
+
+// sessionCommand is the command line of the program run, if any, saved to
+// the bundles of the session.
+var sessionCommand []string
+
+// bundle is a session saved to a .gcvis file, gzipped JSON, to be opened by
+// gcvis import elsewhere: the graph with its annotations, the GC traces the
+// summaries are computed from, and what was run.
+type bundle struct {
+	Version  int
+	Metadata bundleMetadata
+	Graph    *Graph
+	Events   []bundleEvent // oldest first
+}
+
+type bundleMetadata struct {
+	Title       string
+	CommandLine []string `json:",omitempty"`
+	Exported    time.Time
+	Gcvis       versionInfo // that exported the bundle, and the Go version it was built with
+}
+
+// bundleEvent is a GC trace of a bundle, at its time on the graph.
+type bundleEvent struct {
+	Elapsed float64
+	GC      *gctrace
+}
+
+// newBundle returns the bundle of g, run with command if not nil.
+func newBundle(g *Graph, command []string) *bundle {
+	snapshot := g.Snapshot()
+	return &bundle{
+		Version: bundleVersion,
+		Metadata: bundleMetadata{
+			Title:       snapshot.Title,
+			CommandLine: command,
+			Exported:    time.Now().UTC(),
+			Gcvis:       buildVersion(),
+		},
+		Graph:  snapshot,
+		Events: g.bundleEvents(),
+	}
+}
+
+// bundleEvents returns the GC traces kept for the summaries of g.
+func (g *Graph) bundleEvents() []bundleEvent {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.applyPending()
+
+	events := make([]bundleEvent, 0, len(g.gcTraces.traces))
+	for _, t := range g.gcTraces.traces {
+		events = append(events, bundleEvent{Elapsed: t.elapsed, GC: t.gc})
+	}
+	return events
+}
+
+// graph returns the graph of the bundle, to be served as it was exported.
+func (b *bundle) graph() *Graph {
+	g := NewGraph(b.Metadata.Title, GCVIS_TMPL)
+	g.Restore(b.Graph)
+	g.Epoch = b.Graph.Epoch
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, e := range b.Events {
+		g.gcTraces.add(timedTrace{e.Elapsed, e.GC})
+	}
+	return g
+}
+
+func writeBundle(w io.Writer, b *bundle) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(b); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+func readBundle(r io.Reader) (*bundle, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a gcvis bundle: %v", err)
+	}
+	defer zr.Close()
+
+	var b bundle
+	if err := json.NewDecoder(zr).Decode(&b); err != nil {
+		return nil, fmt.Errorf("not a gcvis bundle: %v", err)
+	}
+	if b.Version != bundleVersion || b.Graph == nil {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	return &b, nil
+}
+
+// handleBundle serves the bundle of the session, for it to be imported
+// elsewhere.
+func (h *HttpServer) handleBundle(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="session`+bundleExt+`"`)
+	if err := writeBundle(w, newBundle(h.graph, sessionCommand)); err != nil {
+		errorf("cannot write the bundle: %v", err)
+	}
+}
+
+// runImport serves the session of a bundle until gcvis is interrupted.
+func runImport(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "import: expected a "+bundleExt+" bundle")
+		os.Exit(2)
+	}
+	path := args[0]
+	flag.CommandLine.Parse(args[1:])
+
+	f, err := os.Open(path)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	b, err := readBundle(f)
+	f.Close()
+	if err != nil {
+		fatalf("import: %s: %v", path, err)
+	}
+
+	title := b.Metadata.Title
+	if len(b.Metadata.CommandLine) > 0 {
+		title = strings.Join(b.Metadata.CommandLine, " ")
+	}
+	infof("session of %s, exported %s by gcvis %s", title, b.Metadata.Exported.Format(time.RFC3339), b.Metadata.Gcvis)
+
+	server := NewHttpServer(*iface, *port, b.graph())
+	server.SetPortRetry(*portRetry)
+	go server.Start()
+
+	infof("server started on %s", server.Url())
+	notifyReady()
+	waitForInterrupt()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	g := newSummaryGraph()
+	g.Annotate("deploy")
+
+	var b bytes.Buffer
+	if err := writeBundle(&b, newBundle(g, []string{"./server", "-load=high"})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	bundle, err := readBundle(&b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if m := bundle.Metadata; m.Title != "summary" || strings.Join(m.CommandLine, " ") != "./server -load=high" || m.Gcvis.GoVersion == "" {
+		t.Errorf("Expected the metadata of the session. Got %+v instead.", m)
+	}
+	if len(bundle.Events) != 5 || bundle.Events[4].Elapsed != 50 || bundle.Events[4].GC.NumGC != 5 {
+		t.Errorf("Expected the 5 GC traces of the session. Got %+v instead.", bundle.Events)
+	}
+
+	imported := bundle.graph()
+	snapshot := imported.Snapshot()
+	if len(snapshot.HeapUse.Points()) != 5 || len(snapshot.Annotations) != 1 || snapshot.Annotations[0].Text != "deploy" {
+		t.Errorf("Expected the points and annotations of the session. Got %+v instead.", snapshot)
+	}
+	if s := imported.Summary(20, 40); s.NumGC != 3 || s.PausePercentilesMs["max"] != 4 {
+		t.Errorf("Expected the summaries of the session. Got %+v instead.", s)
+	}
+
+	if _, err := readBundle(strings.NewReader("{}")); err == nil {
+		t.Errorf("Expected JSON not to be a bundle.")
+	}
+}
+
+func TestHttpServerBundle(t *testing.T) {
+	server := NewHttpServer("127.0.0.1", "0", newSummaryGraph())
+	go server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.Url() + "api/v1/bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Disposition") != `attachment; filename="session.gcvis"` {
+		t.Errorf("Expected a session.gcvis attachment. Got %q instead.", resp.Header.Get("Content-Disposition"))
+	}
+
+	bundle, err := readBundle(resp.Body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s := bundle.graph().Summary(math.Inf(-1), math.Inf(1)); s.NumGC != 5 {
+		t.Errorf("Expected the 5 cycles of the session. Got %+v instead.", s)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/snappy"
+	"github.com/segmentio/kafka-go"
+)
+
+// configCheck is a check of gcvis check: of flags that are otherwise only
+// checked once the sinks are created, or of the connectivity to a sink.
+// Checks of sinks turned off are skipped.
+type configCheck struct {
+	name    string
+	enabled func() bool
+	check   func() error
+}
+
+func always() bool { return true }
+
+// configChecks are the checks of gcvis check, in order. They create none of
+// the sinks, which may create files or take over the terminal.
+var configChecks = []configCheck{
+	{"series", always, func() error {
+		_, err := parseSeries(*seriesFlag)
+		return err
+	}},
+	{"filter", always, func() error {
+		_, err := newEventFilter()
+		return err
+	}},
+	{"sink-overflow", always, func() error {
+		switch *sinkOverflow {
+		case overflowDrop, overflowSpill, overflowBlock:
+			return nil
+		}
+		return fmt.Errorf("invalid -sink-overflow %q, expected drop, spill or block", *sinkOverflow)
+	}},
+	{"log-format", func() bool { return *logFormat != "" }, func() error {
+		_, err := parseLogFormat(*logFormat)
+		return err
+	}},
+	{"serial", func() bool { return *serialPort != "" }, func() error {
+		_, _, err := parseSerial(*serialPort)
+		return err
+	}},
+	{"loki", func() bool { return *lokiURL != "" }, checkLoki},
+	{"remote-write", func() bool { return *remoteWriteURL != "" }, checkRemoteWrite},
+	{"kafka", func() bool { return *kafkaBrokers != "" }, checkKafka},
+	{"victoriametrics", func() bool { return *vmURL != "" }, func() error {
+		if _, err := parseLabels(*vmLabels); err != nil {
+			return fmt.Errorf("invalid -victoriametrics-labels: %v", err)
+		}
+		return nil
+	}},
+	{"grafana-live", func() bool { return *grafanaLiveURL != "" }, func() error {
+		if *grafanaLiveStream == "" || strings.Contains(*grafanaLiveStream, "/") {
+			return fmt.Errorf("invalid -grafana-live-stream %q", *grafanaLiveStream)
+		}
+		return nil
+	}},
+}
+
+// runCheck runs the configChecks on the flags, as loaded from the
+// environment and the -config file, writing their results to w. It starts
+// neither a program nor the server, and returns whether all checks passed.
+func runCheck(w io.Writer) bool {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	ok := true
+	for _, c := range configChecks {
+		if !c.enabled() {
+			continue
+		}
+		if err := c.check(); err != nil {
+			fmt.Fprintf(tw, "%s\tFAIL\t%v\n", c.name, err)
+			ok = false
+			continue
+		}
+		fmt.Fprintf(tw, "%s\tok\n", c.name)
+	}
+	return ok
+}
+
+// checkLoki checks the labels of the Loki sink, and that Loki is ready.
+func checkLoki() error {
+	if _, err := parseLabels(*lokiLabels); err != nil {
+		return fmt.Errorf("invalid -loki-labels: %v", err)
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimRight(*lokiURL, "/")+"/ready", nil)
+	if err != nil {
+		return err
+	}
+	if *lokiTenant != "" {
+		req.Header.Set("X-Scope-OrgID", *lokiTenant)
+	}
+	resp, err := (&http.Client{Timeout: pushTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Loki is not ready: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// checkRemoteWrite sends an empty remote_write request, accepted by the
+// endpoint if it is reachable and the credentials are valid.
+func checkRemoteWrite() error {
+	body := snappy.Encode(nil, encodeWriteRequest(nil))
+	_, err := postBody(&http.Client{Timeout: pushTimeout}, *remoteWriteURL, remoteWriteHeader(), body)
+	return err
+}
+
+// checkKafka checks the Avro schema of the Kafka sink, if any, and that a
+// broker answers.
+func checkKafka() error {
+	if *kafkaAvroSchema != "" {
+		b, err := ioutil.ReadFile(*kafkaAvroSchema)
+		if err != nil {
+			return err
+		}
+		if _, err := parseAvroSchema(b); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	var err error
+	for _, broker := range strings.Split(*kafkaBrokers, ",") {
+		var conn *kafka.Conn
+		if conn, err = kafka.DialContext(ctx, "tcp", broker); err != nil {
+			continue
+		}
+		_, err = conn.Brokers()
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
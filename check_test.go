@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunCheck(t *testing.T) {
+	loki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/ready" {
+			http.NotFound(w, req)
+		}
+	}))
+	defer loki.Close()
+
+	remoteWrite := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer remoteWrite.Close()
+
+	// Nothing listens on a port just released.
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kafkaBroker := l.Addr().String()
+	l.Close()
+
+	defer func(saved string) { *lokiURL = saved }(*lokiURL)
+	defer func(saved string) { *remoteWriteURL = saved }(*remoteWriteURL)
+	defer func(saved string) { *kafkaBrokers = saved }(*kafkaBrokers)
+	defer func(saved string) { *sinkOverflow = saved }(*sinkOverflow)
+	*lokiURL = loki.URL
+	*remoteWriteURL = remoteWrite.URL
+	*kafkaBrokers = kafkaBroker
+	*sinkOverflow = "wait"
+
+	var out bytes.Buffer
+	if runCheck(&out) {
+		t.Errorf("Expected the checks to fail.")
+	}
+
+	lines := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		fields := strings.Fields(line)
+		lines[fields[0]] = fields[1]
+	}
+	expected := map[string]string{
+		"series":        "ok",
+		"filter":        "ok",
+		"sink-overflow": "FAIL",
+		"loki":          "ok",
+		"remote-write":  "FAIL",
+		"kafka":         "FAIL",
+	}
+	for name, result := range expected {
+		if lines[name] != result {
+			t.Errorf("Expected %s to be %s. Got %q instead:\n%s", name, result, lines[name], out.String())
+		}
+	}
+	if _, ok := lines["grafana-live"]; ok {
+		t.Errorf("Expected the checks of sinks turned off to be skipped. Got:\n%s", out.String())
+	}
+}
+
+func TestRunCheckDefaults(t *testing.T) {
+	var out bytes.Buffer
+	if !runCheck(&out) {
+		t.Errorf("Expected the default flags to pass the checks. Got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var checkpointPath = flag.String("checkpoint", "", "path of a file the graph and its annotations are saved to every -checkpoint-every and on exit, to be restored with -resume")
+var checkpointEvery = flag.Duration("checkpoint-every", time.Minute, "how often the graph is saved to -checkpoint")
+var resume = flag.Bool("resume", false, "restore the graph saved to -checkpoint by an earlier run, e.g. after gcvis restarted during a soak test, the new traces following it")
+
+// checkpointVersion is the version of the format of checkpoints, bumped
+// when older ones can no longer be restored.
+const checkpointVersion = 1
+
+type checkpoint struct {
+	Version int
+	Saved   time.Time
+	Graph   *Graph
+}
+
+// writeCheckpoint saves the graph to path, replacing the previous
+// checkpoint only once written, so that a crash never leaves a truncated
+// one.
+func writeCheckpoint(path string, g *Graph) error {
+	b, err := json.Marshal(checkpoint{Version: checkpointVersion, Saved: time.Now(), Graph: g.Snapshot()})
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// readCheckpoint returns the graph saved to path, or nil if there is no
+// checkpoint yet.
+func readCheckpoint(path string) (*Graph, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c checkpoint
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("cannot read checkpoint %s: %v", path, err)
+	}
+	if c.Version != checkpointVersion || c.Graph == nil {
+		return nil, fmt.Errorf("cannot read checkpoint %s: unsupported version %d", path, c.Version)
+	}
+	return c.Graph, nil
+}
+
+// restoreCheckpoint restores the graph saved to -checkpoint into g.
+func restoreCheckpoint(g *Graph) error {
+	saved, err := readCheckpoint(*checkpointPath)
+	if err != nil {
+		return err
+	}
+	if saved == nil {
+		infof("no checkpoint to resume from at %s", *checkpointPath)
+		return nil
+	}
+
+	g.Restore(saved)
+	infof("resumed the graph saved to %s", *checkpointPath)
+	return nil
+}
+
+// startCheckpoints saves g to -checkpoint every -checkpoint-every, until
+// the returned function is called, which saves it one last time.
+func startCheckpoints(g *Graph) func() {
+	if *checkpointPath == "" {
+		return func() {}
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(*checkpointEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := writeCheckpoint(*checkpointPath, g); err != nil {
+					errorf("cannot write checkpoint: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		if err := writeCheckpoint(*checkpointPath, g); err != nil {
+			errorf("cannot write checkpoint: %v", err)
+		}
+	}
+}
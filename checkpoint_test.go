@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gcvis.checkpoint")
+
+	if saved, err := readCheckpoint(path); saved != nil || err != nil {
+		t.Errorf("Expected no checkpoint yet. Got %v, %v instead.", saved, err)
+	}
+
+	g := NewGraph("title", GCVIS_TMPL)
+	g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: 10, NumGC: 1, Heap1: 4})
+	g.AddScavengerGraphPoint(&scvgtrace{ElapsedTime: 12, Inuse: 3})
+	g.Annotate("deploy")
+	if err := writeCheckpoint(path, g); err != nil {
+		t.Fatalf("writeCheckpoint returned an error: %v", err)
+	}
+
+	saved, err := readCheckpoint(path)
+	if err != nil {
+		t.Fatalf("readCheckpoint returned an error: %v", err)
+	}
+
+	restored := NewGraph("title", GCVIS_TMPL)
+	restored.Restore(saved)
+	restored.AddGCTraceGraphPoint(&gctrace{ElapsedTime: 1, NumGC: 1, Heap1: 6})
+
+	s := restored.Snapshot()
+	heap := s.HeapUse.Points()
+	if len(heap) != 2 || heap[0] != (graphPoints{10, 4}) || heap[1] != (graphPoints{13, 6}) {
+		t.Errorf("Expected the new trace to follow the restored one. Got %v instead.", heap)
+	}
+	if s.ScvgInuse.Len() != 1 || len(s.Annotations) != 1 || s.Annotations[0].Text != "deploy" {
+		t.Errorf("Expected the scavenger point and annotation to be restored. Got %v and %v instead.", s.ScvgInuse.Points(), s.Annotations)
+	}
+	if s.Cursor() != "2.1" {
+		t.Errorf("Expected the cursor to count the restored traces. Got %s instead.", s.Cursor())
+	}
+}
+
+func TestReadCheckpointRejectsGarbage(t *testing.T) {
+	f, err := ioutil.TempFile("", "gcvis-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"Version":42}`)
+	f.Close()
+
+	if _, err := readCheckpoint(f.Name()); err == nil {
+		t.Errorf("Expected an unsupported checkpoint to be rejected.")
+	}
+}
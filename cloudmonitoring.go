@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+var gcmEnabled = flag.Bool("gcm", false, "export metrics to Google Cloud Monitoring, as custom metrics of the detected GCE instance or GKE container")
+var gcmProject = flag.String("gcm-project", "", "Google Cloud project metrics are written to, instead of the one of the instance")
+var gcmInterval = flag.Duration("gcm-interval", time.Minute, "how often metrics are written to Google Cloud Monitoring, which accepts a point per series every 5 seconds at most")
+var gcmEndpoint = flag.String("gcm-endpoint", "https://monitoring.googleapis.com", "base URL of the Cloud Monitoring API")
+
+// gcmMetricPrefix is the prefix of the types of the custom metrics.
+const gcmMetricPrefix = "custom.googleapis.com/gcvis/"
+
+// gcmTokenSource returns the credentials used to write metrics: the
+// application default credentials, e.g. the service account of the
+// instance.
+var gcmTokenSource = func(ctx context.Context) (oauth2.TokenSource, error) {
+	return google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/monitoring.write")
+}
+
+func init() {
+	RegisterSink("gcm", newGCMSink)
+}
+
+// gcmSink writes metrics with the Cloud Monitoring timeSeries.create API.
+// As a series takes a point every 5 seconds at most, every interval it
+// writes the latest value of each metric, except pauses which are the
+// longest of the interval.
+type gcmSink struct {
+	url      string
+	resource map[string]interface{}
+	labels   map[string]string
+
+	client *http.Client
+
+	mu     sync.Mutex
+	values map[string]float64 // of the current interval, by metric name
+	names  []string           // of values, in the order they were first seen
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newGCMSink() (Sink, error) {
+	if !*gcmEnabled {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	tokens, err := gcmTokenSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := gcmDetectResource(*gcmProject)
+	if err != nil {
+		return nil, err
+	}
+	project := resource["labels"].(map[string]string)["project_id"]
+
+	labels := metricLabels()
+	delete(labels, "host")
+
+	s := &gcmSink{
+		url:      strings.TrimRight(*gcmEndpoint, "/") + "/v3/projects/" + project + "/timeSeries",
+		resource: resource,
+		labels:   labels,
+		client: &http.Client{
+			Timeout:   pushTimeout,
+			Transport: &oauth2.Transport{Source: oauth2.ReuseTokenSource(nil, tokens)},
+		},
+		values: map[string]float64{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go s.sendPeriodically(*gcmInterval)
+
+	return s, nil
+}
+
+// gcmDetectResource returns the monitored resource gcvis runs on: a
+// container on GKE, an instance on GCE, or else a generic node named after
+// the host. On GKE, the namespace, pod and container names are read from
+// the NAMESPACE, POD_NAME and CONTAINER_NAME environment variables, which
+// the downward API can set.
+func gcmDetectResource(project string) (map[string]interface{}, error) {
+	if !metadata.OnGCE() {
+		if project == "" {
+			return nil, fmt.Errorf("-gcm-project is required outside of Google Cloud")
+		}
+		return map[string]interface{}{
+			"type": "generic_node",
+			"labels": map[string]string{
+				"project_id": project,
+				"location":   "global",
+				"namespace":  *serviceName,
+				"node_id":    ownHost,
+			},
+		}, nil
+	}
+
+	if project == "" {
+		var err error
+		if project, err = metadata.ProjectID(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cluster, err := metadata.InstanceAttributeValue("cluster-name"); err == nil && cluster != "" {
+		location, err := metadata.InstanceAttributeValue("cluster-location")
+		if err != nil {
+			return nil, err
+		}
+		pod := os.Getenv("POD_NAME")
+		if pod == "" {
+			pod = ownHost
+		}
+		return map[string]interface{}{
+			"type": "k8s_container",
+			"labels": map[string]string{
+				"project_id":     project,
+				"location":       location,
+				"cluster_name":   cluster,
+				"namespace_name": os.Getenv("NAMESPACE"),
+				"pod_name":       pod,
+				"container_name": os.Getenv("CONTAINER_NAME"),
+			},
+		}, nil
+	}
+
+	id, err := metadata.InstanceID()
+	if err != nil {
+		return nil, err
+	}
+	zone, err := metadata.Zone()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"type": "gce_instance",
+		"labels": map[string]string{
+			"project_id":  project,
+			"instance_id": id,
+			"zone":        zone,
+		},
+	}, nil
+}
+
+func (s *gcmSink) ConsumeGC(t *gctrace) error {
+	s.add(gcMetrics(t))
+	return nil
+}
+
+func (s *gcmSink) ConsumeScvg(t *scvgtrace) error {
+	s.add(scvgMetrics(t))
+	return nil
+}
+
+func (s *gcmSink) add(metrics []metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range metrics {
+		previous, ok := s.values[m.Name]
+		if !ok {
+			s.names = append(s.names, m.Name)
+		}
+		if ok && strings.HasSuffix(m.Name, "_milliseconds") && previous > m.Value {
+			continue
+		}
+		s.values[m.Name] = m.Value
+	}
+}
+
+func (s *gcmSink) sendPeriodically(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Flush writes the values of the current interval, and starts a new one.
+func (s *gcmSink) Flush() error {
+	s.mu.Lock()
+	values, names := s.values, s.names
+	s.values, s.names = map[string]float64{}, nil
+	s.mu.Unlock()
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	series := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		series = append(series, map[string]interface{}{
+			"metric": map[string]interface{}{
+				"type":   gcmMetricPrefix + strings.TrimPrefix(name, "gcvis_"),
+				"labels": s.labels,
+			},
+			"resource":   s.resource,
+			"metricKind": "GAUGE",
+			"valueType":  "DOUBLE",
+			"points": []interface{}{
+				map[string]interface{}{
+					"interval": map[string]string{"endTime": now},
+					"value":    map[string]float64{"doubleValue": values[name]},
+				},
+			},
+		})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"timeSeries": series})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	retryWithBackoff(fmt.Sprintf("%d Cloud Monitoring time series", len(series)), func() (bool, error) {
+		return postBody(s.client, s.url, header, body)
+	})
+	return nil
+}
+
+func (s *gcmSink) Close() error {
+	close(s.stop)
+	<-s.done
+
+	return s.Flush()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestGCMSinkOnGKE(t *testing.T) {
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		values := map[string]string{
+			"/computeMetadata/v1/project/project-id":                   "my-project",
+			"/computeMetadata/v1/instance/attributes/cluster-name":     "my-cluster",
+			"/computeMetadata/v1/instance/attributes/cluster-location": "europe-west1",
+		}
+		value, ok := values[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Metadata-Flavor", "Google")
+		w.Write([]byte(value))
+	}))
+	defer metadataServer.Close()
+
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(metadataServer.URL, "http://"))
+	t.Setenv("NAMESPACE", "prod")
+	t.Setenv("POD_NAME", "api-1234")
+	t.Setenv("CONTAINER_NAME", "api")
+
+	var (
+		path, auth string
+		request    struct {
+			TimeSeries []struct {
+				Metric struct {
+					Type   string
+					Labels map[string]string
+				}
+				Resource struct {
+					Type   string
+					Labels map[string]string
+				}
+				Points []struct {
+					Value struct{ DoubleValue float64 }
+				}
+			}
+		}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path, auth = req.URL.Path, req.Header.Get("Authorization")
+		json.NewDecoder(req.Body).Decode(&request)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	savedEnabled, savedEndpoint, savedTokenSource := *gcmEnabled, *gcmEndpoint, gcmTokenSource
+	*gcmEnabled, *gcmEndpoint = true, server.URL
+	gcmTokenSource = func(ctx context.Context) (oauth2.TokenSource, error) {
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "secret"}), nil
+	}
+	defer func() { *gcmEnabled, *gcmEndpoint, gcmTokenSource = savedEnabled, savedEndpoint, savedTokenSource }()
+
+	sink, err := newGCMSink()
+	if err != nil {
+		t.Fatalf("newGCMSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{Heap1: 33, STWMclock: 2})
+	sink.ConsumeGC(&gctrace{Heap1: 34, STWMclock: 1})
+	sink.Close()
+
+	if path != "/v3/projects/my-project/timeSeries" || auth != "Bearer secret" {
+		t.Errorf("Expected an authenticated request for my-project. Got %s with %q instead.", path, auth)
+	}
+
+	values := map[string]float64{}
+	for _, series := range request.TimeSeries {
+		if series.Resource.Type != "k8s_container" || series.Resource.Labels["cluster_name"] != "my-cluster" ||
+			series.Resource.Labels["pod_name"] != "api-1234" || series.Resource.Labels["namespace_name"] != "prod" {
+			t.Errorf("Expected a k8s_container resource of the pod. Got %+v instead.", series.Resource)
+		}
+		if series.Metric.Labels["srv"] != *serviceName {
+			t.Errorf("Expected the srv label. Got %v instead.", series.Metric.Labels)
+		}
+		values[series.Metric.Type] = series.Points[0].Value.DoubleValue
+	}
+
+	if values[gcmMetricPrefix+"heap_goal_megabytes"] != 34 {
+		t.Errorf("Expected the latest heap goal of 34. Got %v instead.", values)
+	}
+	if values[gcmMetricPrefix+"stw_mark_clock_milliseconds"] != 2 {
+		t.Errorf("Expected the longest pause of 2. Got %v instead.", values)
+	}
+}
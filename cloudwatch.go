@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	logstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+var cloudWatchNamespace = flag.String("cloudwatch-namespace", "", "CloudWatch namespace to publish metrics in, e.g. gcvis")
+var cloudWatchLogGroup = flag.String("cloudwatch-log-group", "", "CloudWatch Logs group to write events to, created if needed")
+var cloudWatchLogStream = flag.String("cloudwatch-log-stream", "", "CloudWatch Logs stream to write events to, created if needed. Defaults to the host name")
+var cloudWatchRegion = flag.String("cloudwatch-region", "", "AWS region of CloudWatch, instead of the one of the AWS configuration")
+var cloudWatchEndpoint = flag.String("cloudwatch-endpoint", "", "URL of a CloudWatch compatible endpoint, e.g. for LocalStack")
+var cloudWatchInterval = flag.Duration("cloudwatch-interval", time.Minute, "how often metrics and events are sent to CloudWatch")
+
+// CloudWatch request limits. PutLogEvents requests are at most 1 MB, or
+// about 1000 log lines.
+const (
+	cloudWatchMaxMetrics = 1000
+	cloudWatchMaxEvents  = 1000
+)
+
+func init() {
+	RegisterSink("cloudwatch-metrics", newCloudWatchMetricsSink)
+	RegisterSink("cloudwatch-logs", newCloudWatchLogsSink)
+}
+
+// loadAWSConfig loads the AWS configuration from the environment, shared
+// files, or the ECS and EC2 roles, as the AWS CLI does.
+func loadAWSConfig() (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+	if *cloudWatchRegion != "" {
+		opts = append(opts, config.WithRegion(*cloudWatchRegion))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+
+	return config.LoadDefaultConfig(ctx, opts...)
+}
+
+// cloudWatchMetricsSink publishes metrics with PutMetricData, with Service
+// and Host dimensions. Metric names drop the gcvis_ prefix, the namespace
+// telling where they come from.
+type cloudWatchMetricsSink struct {
+	namespace  string
+	dimensions []cwtypes.Dimension
+
+	client  *cloudwatch.Client
+	batcher *batcher
+}
+
+func newCloudWatchMetricsSink() (Sink, error) {
+	if *cloudWatchNamespace == "" {
+		return nil, nil
+	}
+
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []func(*cloudwatch.Options)
+	if *cloudWatchEndpoint != "" {
+		opts = append(opts, cloudwatch.WithEndpointResolver(cloudwatch.EndpointResolverFromURL(*cloudWatchEndpoint)))
+	}
+
+	s := &cloudWatchMetricsSink{
+		namespace: *cloudWatchNamespace,
+		dimensions: []cwtypes.Dimension{
+			{Name: aws.String("Service"), Value: aws.String(*serviceName)},
+			{Name: aws.String("Host"), Value: aws.String(ownHost)},
+		},
+		client: cloudwatch.NewFromConfig(cfg, opts...),
+	}
+	s.batcher = newBatcher(cloudWatchMaxMetrics, *cloudWatchInterval, s.send)
+
+	return s, nil
+}
+
+func (s *cloudWatchMetricsSink) ConsumeGC(t *gctrace) error {
+	s.add(gcMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *cloudWatchMetricsSink) ConsumeScvg(t *scvgtrace) error {
+	s.add(scvgMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *cloudWatchMetricsSink) add(metrics []metric, ts time.Time) {
+	for _, m := range metrics {
+		s.batcher.Add(cwtypes.MetricDatum{
+			MetricName: aws.String(strings.TrimPrefix(m.Name, "gcvis_")),
+			Value:      aws.Float64(m.Value),
+			Unit:       cloudWatchUnit(m.Name),
+			Timestamp:  aws.Time(ts),
+			Dimensions: s.dimensions,
+		})
+	}
+}
+
+func cloudWatchUnit(name string) cwtypes.StandardUnit {
+	switch {
+	case strings.HasSuffix(name, "_megabytes"):
+		return cwtypes.StandardUnitMegabytes
+	case strings.HasSuffix(name, "_milliseconds"):
+		return cwtypes.StandardUnitMilliseconds
+	}
+	return cwtypes.StandardUnitCount
+}
+
+func (s *cloudWatchMetricsSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *cloudWatchMetricsSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+func (s *cloudWatchMetricsSink) send(batch []interface{}) {
+	data := make([]cwtypes.MetricDatum, len(batch))
+	for i, datum := range batch {
+		data[i] = datum.(cwtypes.MetricDatum)
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d CloudWatch metrics", len(data)), func() (bool, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+		defer cancel()
+
+		_, err := s.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(s.namespace),
+			MetricData: data,
+		})
+		// The SDK already retries the errors worth retrying.
+		return false, err
+	})
+}
+
+// cloudWatchLogsSink writes the log lines to a CloudWatch Logs stream with
+// PutLogEvents.
+type cloudWatchLogsSink struct {
+	group  string
+	stream string
+
+	client  *cloudwatchlogs.Client
+	batcher *batcher
+	created bool // whether the group and stream exist
+}
+
+func newCloudWatchLogsSink() (Sink, error) {
+	if *cloudWatchLogGroup == "" {
+		return nil, nil
+	}
+
+	cfg, err := loadAWSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []func(*cloudwatchlogs.Options)
+	if *cloudWatchEndpoint != "" {
+		opts = append(opts, cloudwatchlogs.WithEndpointResolver(cloudwatchlogs.EndpointResolverFromURL(*cloudWatchEndpoint)))
+	}
+
+	s := &cloudWatchLogsSink{
+		group:  *cloudWatchLogGroup,
+		stream: *cloudWatchLogStream,
+		client: cloudwatchlogs.NewFromConfig(cfg, opts...),
+	}
+	if s.stream == "" {
+		s.stream = ownHost
+	}
+	s.batcher = newBatcher(cloudWatchMaxEvents, *cloudWatchInterval, s.send)
+
+	return s, nil
+}
+
+func (s *cloudWatchLogsSink) ConsumeGC(t *gctrace) error {
+	return s.add(newGCLogLine(t))
+}
+
+func (s *cloudWatchLogsSink) ConsumeScvg(t *scvgtrace) error {
+	return s.add(newScvgLogLine(t))
+}
+
+func (s *cloudWatchLogsSink) add(l *logLine) error {
+	b, err := l.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	s.batcher.Add(logstypes.InputLogEvent{
+		Message:   aws.String(string(b)),
+		Timestamp: aws.Int64(timestampMillis(l.Time)),
+	})
+	return nil
+}
+
+func (s *cloudWatchLogsSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *cloudWatchLogsSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+// send puts batch into the log stream, creating the group and stream the
+// first time.
+func (s *cloudWatchLogsSink) send(batch []interface{}) {
+	events := make([]logstypes.InputLogEvent, len(batch))
+	for i, event := range batch {
+		events[i] = event.(logstypes.InputLogEvent)
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d CloudWatch log events", len(events)), func() (bool, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+		defer cancel()
+
+		if !s.created {
+			if err := s.create(ctx); err != nil {
+				return false, err
+			}
+			s.created = true
+		}
+
+		_, err := s.client.PutLogEvents(ctx, &cloudwatchlogs.PutLogEventsInput{
+			LogGroupName:  aws.String(s.group),
+			LogStreamName: aws.String(s.stream),
+			LogEvents:     events,
+		})
+		return false, err
+	})
+}
+
+// create creates the log group and stream, unless they already exist.
+func (s *cloudWatchLogsSink) create(ctx context.Context) error {
+	var exists *logstypes.ResourceAlreadyExistsException
+
+	_, err := s.client.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName: aws.String(s.group),
+	})
+	if err != nil && !errors.As(err, &exists) {
+		return err
+	}
+
+	_, err = s.client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(s.group),
+		LogStreamName: aws.String(s.stream),
+	})
+	if err != nil && !errors.As(err, &exists) {
+		return err
+	}
+	return nil
+}
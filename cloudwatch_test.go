@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func setAWSTestCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+}
+
+func TestCloudWatchMetricsSink(t *testing.T) {
+	setAWSTestCredentials(t)
+
+	var (
+		mu   sync.Mutex
+		form url.Values
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		mu.Lock()
+		form = req.PostForm
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<PutMetricDataResponse xmlns="http://monitoring.amazonaws.com/doc/2010-08-01/"><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></PutMetricDataResponse>`))
+	}))
+	defer server.Close()
+
+	saved := []string{*cloudWatchNamespace, *cloudWatchEndpoint}
+	*cloudWatchNamespace, *cloudWatchEndpoint = "gcvis", server.URL
+	defer func() { *cloudWatchNamespace, *cloudWatchEndpoint = saved[0], saved[1] }()
+
+	sink, err := newCloudWatchMetricsSink()
+	if err != nil {
+		t.Fatalf("newCloudWatchMetricsSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{Heap1: 33})
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if form.Get("Action") != "PutMetricData" || form.Get("Namespace") != "gcvis" {
+		t.Fatalf("Expected PutMetricData in the gcvis namespace. Got %v instead.", form)
+	}
+
+	found := false
+	for k, v := range form {
+		if strings.HasSuffix(k, ".MetricName") && v[0] == "heap_goal_megabytes" {
+			prefix := strings.TrimSuffix(k, "MetricName")
+			found = form.Get(prefix+"Value") == "33" && form.Get(prefix+"Unit") == "Megabytes" &&
+				form.Get(prefix+"Dimensions.member.1.Name") == "Service"
+		}
+	}
+	if !found {
+		t.Errorf("Expected a heap_goal_megabytes datum of 33 megabytes. Got %v instead.", form)
+	}
+}
+
+func TestCloudWatchLogsSink(t *testing.T) {
+	setAWSTestCredentials(t)
+
+	var (
+		mu      sync.Mutex
+		targets []string
+		body    string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		target := req.Header.Get("X-Amz-Target")
+
+		mu.Lock()
+		targets = append(targets, target)
+		if strings.HasSuffix(target, "PutLogEvents") {
+			body = string(b)
+		}
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if strings.HasSuffix(target, "CreateLogGroup") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type": "ResourceAlreadyExistsException", "message": "exists"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	saved := []string{*cloudWatchLogGroup, *cloudWatchLogStream, *cloudWatchEndpoint}
+	*cloudWatchLogGroup, *cloudWatchLogStream, *cloudWatchEndpoint = "gc", "api", server.URL
+	defer func() { *cloudWatchLogGroup, *cloudWatchLogStream, *cloudWatchEndpoint = saved[0], saved[1], saved[2] }()
+
+	sink, err := newCloudWatchLogsSink()
+	if err != nil {
+		t.Fatalf("newCloudWatchLogsSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{Heap1: 33})
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := "Logs_20140328.CreateLogGroup,Logs_20140328.CreateLogStream,Logs_20140328.PutLogEvents"
+	if strings.Join(targets, ",") != expected {
+		t.Errorf("Expected requests %s. Got %v instead.", expected, targets)
+	}
+	if !strings.Contains(body, `"logStreamName":"api"`) || !strings.Contains(body, `\"HeapGoal\":33`) {
+		t.Errorf("Expected the GC log line in the api stream. Got %s instead.", body)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+)
+
+var coalesceWindow = flag.Duration("coalesce", 100*time.Millisecond, "width of the windows of trace time the GC cycles are aggregated in on the graph, when a program collects faster than that. The sinks, alerts, SLOs, anomalies and statistics still get every cycle. 0 never aggregates them")
+
+// coalescer passes the first GC trace of every window of trace time
+// through, and sums up the next ones of the window into a single trace,
+// sent once it ends, so that a program collecting hundreds of times per
+// second does not flood the graph. Aggregated traces carry the heap sizes,
+// overhead and computed fields of the last cycle, and the total time of all
+// of them. Traces without an elapsed time are never aggregated.
+type coalescer struct {
+	window time.Duration
+	out    func(sum *gctrace, cycles []*gctrace)
+
+	open    bool
+	opened  float64    // elapsed time, in seconds, the current window started at
+	pending *gctrace   // aggregate of the cycles of the window after the first one
+	cycles  []*gctrace // summed up into pending
+	timer   *time.Timer
+}
+
+// newCoalescer returns a coalescer of window, passing on to out every
+// trace, or aggregate of the cycles it sums up.
+func newCoalescer(window time.Duration, out func(sum *gctrace, cycles []*gctrace)) *coalescer {
+	return &coalescer{window: window, out: out}
+}
+
+// Add coalesces t.
+func (c *coalescer) Add(t *gctrace) {
+	if c.window <= 0 {
+		c.out(t, nil)
+		return
+	}
+
+	if t.ElapsedTime == 0 || !c.open || t.ElapsedTime < c.opened || t.ElapsedTime-c.opened >= c.window.Seconds() {
+		c.Flush()
+		c.open, c.opened = t.ElapsedTime != 0, t.ElapsedTime
+		c.out(t, nil)
+		return
+	}
+
+	if c.pending == nil {
+		pending := *t
+		c.pending = &pending
+		// The program may not collect again for a while: the cycles of
+		// the window are sent anyway once it would have ended.
+		c.timer = time.NewTimer(c.window)
+	} else {
+		mergeGCTraces(c.pending, t)
+	}
+	c.cycles = append(c.cycles, t)
+}
+
+// C receives when the current window ends, if cycles are pending.
+func (c *coalescer) C() <-chan time.Time {
+	if c.timer == nil {
+		return nil
+	}
+	return c.timer.C
+}
+
+// Flush sends the pending cycles. The cycles of the window read after it
+// are summed up anew.
+func (c *coalescer) Flush() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+
+	if c.pending != nil {
+		c.out(c.pending, c.cycles)
+		c.pending, c.cycles = nil, nil
+	}
+}
+
+// mergeGCTraces adds t, the next cycle, to the aggregate dst.
+func mergeGCTraces(dst, t *gctrace) {
+	dst.ElapsedTime, dst.NumGC, dst.Nproc = t.ElapsedTime, t.NumGC, t.Nproc
+	dst.Heap0, dst.Heap1, dst.Heap2, dst.Heap3 = t.Heap0, t.Heap1, t.Heap2, t.Heap3
+	dst.GCOverhead, dst.Computed = t.GCOverhead, t.Computed
+
+	dst.STWSclock += t.STWSclock
+	dst.MASclock += t.MASclock
+	dst.STWMclock += t.STWMclock
+	dst.STWScpu += t.STWScpu
+	dst.MASAssistcpu += t.MASAssistcpu
+	dst.MASBGcpu += t.MASBGcpu
+	dst.MASIdlecpu += t.MASIdlecpu
+	dst.STWMcpu += t.STWMcpu
+
+	dst.Forced = dst.Forced || t.Forced
+	dst.Raw = strings.Join([]string{dst.Raw, t.Raw}, "\n")
+}
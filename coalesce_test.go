@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCoalescer(t *testing.T) {
+	var out []*gctrace
+	var summed [][]*gctrace
+	c := newCoalescer(100*time.Millisecond, func(t *gctrace, cycles []*gctrace) {
+		out = append(out, t)
+		summed = append(summed, cycles)
+	})
+
+	// Read all at once, as when piping a log, but 10ms apart in the trace.
+	for i := 0; i < 5; i++ {
+		c.Add(&gctrace{NumGC: int64(i + 1), ElapsedTime: 1 + float64(i)/100, Heap1: int64(10 * (i + 1)), STWSclock: 1, Raw: "gc"})
+	}
+	if len(out) != 1 || out[0].NumGC != 1 || summed[0] != nil {
+		t.Fatalf("Expected the first cycle of the window to be sent at once. Got %v instead.", out)
+	}
+	if c.C() == nil {
+		t.Fatalf("Expected the end of the window to be awaited.")
+	}
+
+	c.Add(&gctrace{NumGC: 6, ElapsedTime: 1.2})
+	if len(out) != 3 {
+		t.Fatalf("Expected the next window to send the other cycles as one. Got %d traces instead.", len(out))
+	}
+	if agg := out[1]; agg.NumGC != 5 || agg.Heap1 != 50 || agg.STWSclock != 4 || agg.Raw != "gc\ngc\ngc\ngc" || len(summed[1]) != 4 {
+		t.Errorf("Expected the last cycle with the total time of 4 cycles. Got %+v instead.", agg)
+	}
+	if out[2].NumGC != 6 || c.C() != nil {
+		t.Errorf("Expected the first cycle of a new window to be sent at once. Got %v instead.", out)
+	}
+}
+
+func TestCoalescerTimer(t *testing.T) {
+	var out []*gctrace
+	c := newCoalescer(10*time.Millisecond, func(t *gctrace, cycles []*gctrace) { out = append(out, t) })
+
+	c.Add(&gctrace{NumGC: 1, ElapsedTime: 1})
+	c.Add(&gctrace{NumGC: 2, ElapsedTime: 1.001})
+
+	select {
+	case <-c.C():
+		c.Flush()
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the window to end.")
+	}
+	if len(out) != 2 || out[1].NumGC != 2 {
+		t.Errorf("Expected the pending cycle to be sent when the window ends. Got %v instead.", out)
+	}
+}
+
+func TestCoalescerOff(t *testing.T) {
+	n := 0
+	c := newCoalescer(0, func(t *gctrace, cycles []*gctrace) { n++ })
+
+	for i := 0; i < 3; i++ {
+		c.Add(&gctrace{ElapsedTime: 1})
+	}
+	if n != 3 || c.C() != nil {
+		t.Errorf("Expected every cycle to be sent. Got %d instead.", n)
+	}
+}
+
+func TestCoalescerWithoutElapsedTime(t *testing.T) {
+	n := 0
+	c := newCoalescer(time.Second, func(t *gctrace, cycles []*gctrace) { n++ })
+
+	for i := 0; i < 3; i++ {
+		c.Add(&gctrace{})
+	}
+	if n != 3 || c.C() != nil {
+		t.Errorf("Expected the cycles without an elapsed time to be sent as is. Got %d instead.", n)
+	}
+}
+
+func TestGraphAddCoalescedGraphPoint(t *testing.T) {
+	defer func(rules alertRulesFlag) { alertRules = rules }(alertRules)
+	alertRules = nil
+	if err := alertRules.Set("stw>10ms"); err != nil {
+		t.Fatal(err)
+	}
+
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	cycles := []*gctrace{
+		{NumGC: 1, ElapsedTime: 1, STWSclock: 6},
+		{NumGC: 2, ElapsedTime: 1.05, STWSclock: 6},
+	}
+	sum := *cycles[0]
+	mergeGCTraces(&sum, cycles[1])
+	graph.AddCoalescedGraphPoint(&sum, cycles)
+
+	s := graph.Snapshot()
+	if points := s.STWSclock.Points(); len(points) != 1 || points[0][1] != 12 {
+		t.Errorf("Expected a single point of the total time. Got %v instead.", points)
+	}
+	if len(s.AlertViolations) != 0 {
+		t.Errorf("Expected the rule to be evaluated on every cycle, none exceeding it. Got %+v instead.", s.AlertViolations)
+	}
+	if events := graph.Events(eventFilter{to: 10}); len(events) != 2 {
+		t.Errorf("Expected the events of both cycles. Got %+v instead.", events)
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// runReplay visualises the traces of a log file, - being the standard
+// input, at the -speed they were traced at, and keeps serving them once
+// read. Flags may follow the file.
+func runReplay(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "replay: expected a log file")
+		os.Exit(2)
+	}
+	path := args[0]
+	flag.CommandLine.Parse(args[1:])
+	if flag.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "replay: expected a single log file")
+		os.Exit(2)
+	}
+	if err := applyLogLevel(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := applyStartTime(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := applyTimeZone(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := applyKubernetes(); err != nil {
+		fatalf("%v", err)
+	}
+
+	speed, err := parseReplaySpeed(*replaySpeed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	f, err := openTraceLog(path)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if speed > 0 {
+		r = newPacedReader(f, speed)
+	}
+
+	runSession(path, r, nil, true)
+}
+
+// openTraceLog opens the log file at path, or the standard input for -.
+func openTraceLog(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// runServe serves the sessions stored in the -db database, the most recent
+// one at /, until gcvis is interrupted.
+func runServe() {
+	if *dbPath == "" {
+		fmt.Fprintln(os.Stderr, "serve: -db is required")
+		os.Exit(2)
+	}
+
+	history, err := OpenSQLiteHistory(*dbPath)
+	if err != nil {
+		fatalf("cannot open database: %v", err)
+	}
+	defer history.Close()
+
+	graph, err := latestSessionGraph(history)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	server := NewHttpServer(*iface, *port, graph)
+	server.SetPortRetry(*portRetry)
+	server.SetHistory(history)
+	go server.Start()
+
+	infof("server started on %s", server.Url())
+	notifyReady()
+	waitForInterrupt()
+}
+
+// latestSessionGraph returns the graph of the most recent session of
+// history, or an empty graph if there is none.
+func latestSessionGraph(history SessionHistory) (*Graph, error) {
+	sessions, err := history.Sessions()
+	if err != nil || len(sessions) == 0 {
+		return NewGraph("gcvis", GCVIS_TMPL), err
+	}
+	return history.SessionGraph(sessions[0].ID, GCVIS_TMPL)
+}
+
+// runExport writes the graph of a stored session as JSON, as served at
+// /sessions/<id>/graph.json, or as a bundle to import.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.StringVar(dbPath, "db", *dbPath, "path of the SQLite database the session is stored in")
+	session := fs.Int64("session", 0, "ID of the session to export. Defaults to the most recent one")
+	out := fs.String("o", "", "path of the file to write the session to, instead of the standard output. A bundle to import if it ends with "+bundleExt)
+	fs.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := exportSession(w, *dbPath, *session, strings.HasSuffix(*out, bundleExt)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// exportSession writes the graph of the session id of the database at
+// path, or of the most recent session if id is 0, as a bundle if asked to.
+func exportSession(w io.Writer, path string, id int64, asBundle bool) error {
+	if path == "" {
+		return fmt.Errorf("export: -db is required")
+	}
+
+	history, err := OpenSQLiteHistory(path)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+
+	var graph *Graph
+	if id == 0 {
+		graph, err = latestSessionGraph(history)
+	} else {
+		graph, err = history.SessionGraph(id, GCVIS_TMPL)
+	}
+	if err != nil {
+		return err
+	}
+	if graph == nil {
+		return fmt.Errorf("export: no session %d in %s", id, path)
+	}
+
+	if asBundle {
+		return writeBundle(w, newBundle(graph, nil))
+	}
+	return json.NewEncoder(w).Encode(graph)
+}
+
+// runReport sums up the GC traces of a log file, or of the standard input.
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	format := fs.String("format", "", "format of the report: text, json, or html for a standalone page graphing the traces. Defaults to html if -o ends with .html, text otherwise")
+	out := fs.String("o", "", "path of the file to write the report to, instead of the standard output")
+	fs.Parse(args)
+
+	path := "-"
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	if *format == "" {
+		*format = "text"
+		if strings.HasSuffix(*out, ".html") {
+			*format = "html"
+		}
+	}
+
+	r, err := openTraceLog(path)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer r.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := writeReport(w, r, path, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// writeReport writes the report of the traces of r, read from the log file
+// named title.
+func writeReport(w io.Writer, r io.Reader, title, format string) error {
+	if format == "html" {
+		graph := NewGraph(title, GCVIS_TMPL)
+		stats := newRunStats()
+		err := readTraces(r, func(t *gctrace) {
+			graph.AddGCTraceGraphPoint(t)
+			stats.addGC(t)
+		}, func(t *scvgtrace) {
+			graph.AddScavengerGraphPoint(t)
+			stats.addScvg(t)
+		})
+		if err != nil {
+			return err
+		}
+		return writeHTMLReport(w, graph, stats)
+	}
+
+	var summary summaryFields
+	var first, last float64
+	err := readTraces(r, func(t *gctrace) {
+		if summary.NumGC == 0 {
+			first = t.ElapsedTime
+		}
+		last = t.ElapsedTime
+		summary.add(t)
+	}, nil)
+	if err != nil {
+		return err
+	}
+	summary.IntervalSeconds = last - first
+
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(summary)
+	case "text":
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "GC cycles:\t%d\n", summary.NumGC)
+		fmt.Fprintf(tw, "Duration:\t%.3fs\n", summary.IntervalSeconds)
+		fmt.Fprintf(tw, "Total pause:\t%.3fms\n", summary.TotalPause)
+		fmt.Fprintf(tw, "Longest pause:\t%.3fms\n", summary.MaxPause)
+		fmt.Fprintf(tw, "Heap high water:\t%dMB\n", summary.HeapHighWater)
+		return tw.Flush()
+	}
+	return fmt.Errorf("report: unsupported format %q", format)
+}
+
+// readTraces parses the traces of r, handing them to gc and scvg, either
+// of which may be nil, until r ends.
+func readTraces(r io.Reader, gc func(*gctrace), scvg func(*scvgtrace)) error {
+	parser := NewParser(r)
+	go parser.Run()
+
+	handle := func(t *gctrace, s *scvgtrace) {
+		if t != nil && gc != nil {
+			gc(t)
+		}
+		if s != nil && scvg != nil {
+			scvg(s)
+		}
+	}
+
+	for {
+		select {
+		case t := <-parser.GcChan:
+			handle(t, nil)
+		case s := <-parser.ScvgChan:
+			handle(nil, s)
+		case <-parser.NoMatchChan:
+		case <-parser.Done():
+			// The last traces may still be buffered.
+			for {
+				select {
+				case t := <-parser.GcChan:
+					handle(t, nil)
+				case s := <-parser.ScvgChan:
+					handle(nil, s)
+				default:
+					return parser.Err
+				}
+			}
+		}
+	}
+}
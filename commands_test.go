@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const reportTraces = `gc 1 @0.011s 2%: 0.010+1.5+0.020 ms clock, 0.040+0.10/1.0/2.0+0.080 ms cpu, 4->5->1 MB, 5 MB goal, 4 P
+some program output
+scvg0: inuse: 3, idle: 1, sys: 5, released: 0, consumed: 5 (MB)
+gc 2 @1.511s 1%: 2.5+3.0+0.5 ms clock, 0.040+0.10/1.0/2.0+0.080 ms cpu, 9->10->2 MB, 10 MB goal, 4 P
+`
+
+func TestWriteReport(t *testing.T) {
+	var w bytes.Buffer
+	if err := writeReport(&w, strings.NewReader(reportTraces), "trace.log", "json"); err != nil {
+		t.Fatalf("writeReport returned an error: %v", err)
+	}
+
+	var summary summaryFields
+	if err := json.Unmarshal(w.Bytes(), &summary); err != nil {
+		t.Fatalf("Report is not valid JSON: %v", err)
+	}
+	expected := summaryFields{IntervalSeconds: 1.5, NumGC: 2, TotalPause: 3.03, MaxPause: 2.5, HeapHighWater: 9}
+	if summary != expected {
+		t.Errorf("Expected %+v. Got %+v instead.", expected, summary)
+	}
+
+	w.Reset()
+	if err := writeReport(&w, strings.NewReader(reportTraces), "trace.log", "text"); err != nil {
+		t.Fatalf("writeReport returned an error: %v", err)
+	}
+	for _, expected := range []string{"GC cycles:        2\n", "Longest pause:    2.500ms\n", "Heap high water:  9MB\n"} {
+		if !strings.Contains(w.String(), expected) {
+			t.Errorf("Expected report to contain %q. Got:\n%s", expected, w.String())
+		}
+	}
+
+	if err := writeReport(&w, strings.NewReader(reportTraces), "trace.log", "xml"); err == nil {
+		t.Errorf("Expected an unsupported format to be rejected.")
+	}
+}
+
+func TestExportSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gcvis.db")
+	for _, heap := range []int64{10, 20} {
+		store, err := OpenSQLiteStore(path, "session")
+		if err != nil {
+			t.Fatalf("OpenSQLiteStore returned an error: %v", err)
+		}
+		store.ConsumeGC(&gctrace{ElapsedTime: 1, Heap1: heap})
+		store.Close()
+	}
+
+	for id, expected := range map[int64]int64{0: 20, 1: 10} {
+		var w bytes.Buffer
+		if err := exportSession(&w, path, id, false); err != nil {
+			t.Fatalf("exportSession returned an error: %v", err)
+		}
+		var graph struct{ LastGC *GCSummary }
+		if err := json.Unmarshal(w.Bytes(), &graph); err != nil {
+			t.Fatalf("Export is not valid JSON: %v", err)
+		}
+		if graph.LastGC == nil || graph.LastGC.HeapGoal != expected {
+			t.Errorf("Expected session %d to have a heap goal of %d. Got %s instead.", id, expected, w.String())
+		}
+	}
+
+	if err := exportSession(ioutil.Discard, path, 3, false); err == nil {
+		t.Errorf("Expected an unknown session to be rejected.")
+	}
+}
+
+func TestOpenSQLiteHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	history, err := OpenSQLiteHistory(filepath.Join(dir, "gcvis.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteHistory returned an error: %v", err)
+	}
+	defer history.Close()
+
+	if sessions, _ := history.Sessions(); len(sessions) != 0 {
+		t.Errorf("Expected no session to be started. Got %v instead.", sessions)
+	}
+	if err := history.Annotate("deploy"); err != errNoSession {
+		t.Errorf("Expected annotating without a session to fail. Got %v instead.", err)
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	var w bytes.Buffer
+	if err := writeReport(&w, strings.NewReader(reportTraces), "trace.log", "html"); err != nil {
+		t.Fatalf("writeReport returned an error: %v", err)
+	}
+
+	page := w.String()
+	for _, expected := range []string{"<title>gcvis - trace.log</title>", `<pre id="report">`, "GC cycles over", "https://cdnjs"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Expected the report to contain %q.", expected)
+		}
+	}
+	if strings.Contains(page, "pullAndRedraw();") || strings.Contains(page, `href="/graph.json"`) {
+		t.Errorf("Expected a static report, which does not poll the server.")
+	}
+}
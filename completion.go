@@ -0,0 +1,432 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// gcvisCommands are the commands of gcvis, as completed by the shells.
+var gcvisCommands = []struct {
+	name, description string
+}{
+	{"run", "run a program, visualising its garbage collections"},
+	{"replay", "visualise the garbage collections traced in a log file"},
+	{"serve", "serve the sessions stored in a database"},
+	{"export", "write the graph of a stored session as JSON"},
+	{"import", "serve the session of a bundle"},
+	{"snapshot", "write the current state of a running gcvis as JSON"},
+	{"check", "check the flags, the config file and the sinks"},
+	{"report", "sum up the garbage collections traced in a log file"},
+	{"diff", "compare the garbage collections of two recordings"},
+	{"bench", "run a program several times, summing up the runs"},
+	{"grafana-dashboard", "write a Grafana dashboard of the exported metrics"},
+	{"completion", "write a shell completion script"},
+}
+
+// completionFlag describes a flag of a command for completion scripts.
+// Flags taking a value complete it with the words of values, files if
+// files is set, or nothing.
+type completionFlag struct {
+	name, description string
+	takesValue        bool
+	values            []string
+	files             bool
+}
+
+// commandFlags are the flags of the commands parsing their own flag set.
+var commandFlags = map[string][]completionFlag{
+	"export": {
+		{name: "db", description: "path of the SQLite database the session is stored in", takesValue: true, files: true},
+		{name: "session", description: "ID of the session to export", takesValue: true},
+		{name: "o", description: "path of the file to write the session to", takesValue: true, files: true},
+	},
+	"snapshot": {
+		{name: "o", description: "path of the file to write the snapshot to", takesValue: true, files: true},
+	},
+	"report": {
+		{name: "format", description: "format of the report", takesValue: true, values: []string{"text", "json", "html"}},
+		{name: "o", description: "path of the file to write the report to", takesValue: true, files: true},
+	},
+	"diff": {
+		{name: "format", description: "format of the comparison", takesValue: true, values: []string{"text", "json", "html"}},
+		{name: "o", description: "path of the file to write the comparison to", takesValue: true, files: true},
+	},
+	"bench": {
+		{name: "n", description: "number of runs", takesValue: true},
+		{name: "dir", description: "directory to record the runs to", takesValue: true, files: true},
+		{name: "format", description: "format of the statistics", takesValue: true, values: []string{"text", "json"}},
+	},
+	"grafana-dashboard": {
+		{name: "datasource", description: "type of the datasource the dashboard queries", takesValue: true, values: []string{"loki", "prometheus"}},
+		{name: "title", description: "title of the dashboard", takesValue: true},
+	},
+}
+
+// traceLogExtensions are the extensions of the files completed for replay,
+// report and diff: GC trace logs and -record recordings.
+var traceLogExtensions = []string{"log", "jsonl"}
+
+// runCompletion implements the completion command, which prints a
+// completion script for bash, zsh or fish.
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "completion: expected bash, zsh or fish")
+		os.Exit(2)
+	}
+
+	if err := writeCompletion(os.Stdout, args[0], globalCompletionFlags(flag.CommandLine)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+
+// globalCompletionFlags returns the flags of fs, which apply to the program
+// run and the replay and serve commands.
+func globalCompletionFlags(fs *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		b, isBool := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			name:        f.Name,
+			description: completionDescription(f.Usage),
+			takesValue:  !isBool || !b.IsBoolFlag(),
+			files:       f.Name == "config" || f.Name == "db" || f.Name == "record",
+		})
+	})
+	return flags
+}
+
+// completionDescription shortens a flag usage to its first sentence.
+func completionDescription(usage string) string {
+	usage = strings.Join(strings.Fields(usage), " ")
+	for i := 0; i < len(usage); i++ {
+		end := strings.Index(usage[i:], ". ")
+		if end < 0 {
+			break
+		}
+		i += end
+		if !strings.HasSuffix(usage[:i], "e.g") && !strings.HasSuffix(usage[:i], "i.e") {
+			usage = usage[:i]
+			break
+		}
+	}
+	return strings.TrimSuffix(usage, ".")
+}
+
+func writeCompletion(w io.Writer, shell string, flags []completionFlag) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, flags)
+	case "zsh":
+		writeZshCompletion(w, flags)
+	case "fish":
+		writeFishCompletion(w, flags)
+	default:
+		return fmt.Errorf("completion: unsupported shell %q, expected bash, zsh or fish", shell)
+	}
+	return nil
+}
+
+func commandNames() []string {
+	names := make([]string, len(gcvisCommands))
+	for i, c := range gcvisCommands {
+		names[i] = c.name
+	}
+	return names
+}
+
+func flagNames(flags []completionFlag) string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = "-" + f.name
+	}
+	return strings.Join(names, " ")
+}
+
+func writeBashCompletion(w io.Writer, flags []completionFlag) {
+	var valueFlags []string
+	for _, f := range flags {
+		if f.takesValue {
+			valueFlags = append(valueFlags, f.name)
+		}
+	}
+
+	fmt.Fprintf(w, `# bash completion for gcvis, generated by gcvis completion bash.
+# Load it with: source <(gcvis completion bash)
+
+_gcvis_flags=%q
+_gcvis_value_flags=" %s "
+
+_gcvis_trace_logs() {
+	local cur=$1
+	COMPREPLY=($(compgen -d -- "$cur")`, flagNames(flags), strings.Join(valueFlags, " "))
+	for _, ext := range traceLogExtensions {
+		fmt.Fprintf(w, ` $(compgen -f -X '!*.%s' -- "$cur")`, ext)
+	}
+	fmt.Fprintf(w, `)
+}
+
+_gcvis() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	local command= i word name
+
+	# The command is the first word which is neither a flag nor its value.
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		word=${COMP_WORDS[i]}
+		if [[ $word != -* ]]; then
+			command=$word
+			break
+		fi
+		name=${word#-}
+		name=${name#-}
+		if [[ $name != *=* && $_gcvis_value_flags == *" $name "* ]]; then
+			((i++))
+		fi
+	done
+
+	case $command in
+	"")
+		if [[ $cur == -* ]]; then
+			COMPREPLY=($(compgen -W "$_gcvis_flags" -- "$cur"))
+		else
+			COMPREPLY=($(compgen -W %[1]q -- "$cur") $(compgen -c -- "$cur"))
+		fi
+		;;
+	replay)
+		if [[ $cur == -* ]]; then
+			COMPREPLY=($(compgen -W "$_gcvis_flags" -- "$cur"))
+		else
+			_gcvis_trace_logs "$cur"
+		fi
+		;;
+	serve | check)
+		COMPREPLY=($(compgen -W "$_gcvis_flags" -- "$cur"))
+		;;
+	export)
+		COMPREPLY=($(compgen -W %[2]q -- "$cur"))
+		;;
+	snapshot)
+		COMPREPLY=($(compgen -W %[7]q -- "$cur"))
+		;;
+	report)
+		if [[ $cur == -* ]]; then
+			COMPREPLY=($(compgen -W %[3]q -- "$cur"))
+		else
+			_gcvis_trace_logs "$cur"
+		fi
+		;;
+	diff)
+		if [[ $cur == -* ]]; then
+			COMPREPLY=($(compgen -W %[5]q -- "$cur"))
+		else
+			_gcvis_trace_logs "$cur"
+		fi
+		;;
+	bench)
+		if [[ $cur == -* ]]; then
+			COMPREPLY=($(compgen -W %[6]q -- "$cur"))
+		else
+			COMPREPLY=($(compgen -c -- "$cur"))
+		fi
+		;;
+	grafana-dashboard)
+		COMPREPLY=($(compgen -W %[4]q -- "$cur"))
+		;;
+	completion)
+		COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+		;;
+	run)
+		if ((i + 1 == COMP_CWORD)); then
+			COMPREPLY=($(compgen -c -- "$cur"))
+		fi
+		;;
+	esac
+}
+
+complete -o default -o filenames -F _gcvis gcvis
+`,
+		strings.Join(commandNames(), " "),
+		flagNames(commandFlags["export"]),
+		flagNames(commandFlags["report"]),
+		flagNames(commandFlags["grafana-dashboard"]),
+		flagNames(commandFlags["diff"]),
+		flagNames(commandFlags["bench"]),
+		flagNames(commandFlags["snapshot"]))
+}
+
+// zshFlagSpecs returns the _arguments specs of flags, quoted.
+func zshFlagSpecs(flags []completionFlag) []string {
+	escape := strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`)
+
+	var specs []string
+	for _, f := range flags {
+		spec := "-" + f.name
+		if f.takesValue {
+			spec += "="
+		}
+		spec += "[" + escape.Replace(f.description) + "]"
+		if f.takesValue {
+			spec += ":" + f.name + ":"
+			switch {
+			case f.files:
+				spec += "_files"
+			case len(f.values) > 0:
+				spec += "(" + strings.Join(f.values, " ") + ")"
+			}
+		}
+		specs = append(specs, "'"+spec+"'")
+	}
+	return specs
+}
+
+func writeZshCompletion(w io.Writer, flags []completionFlag) {
+	var commands []string
+	for _, c := range gcvisCommands {
+		commands = append(commands, fmt.Sprintf("\t\t'%s:%s'", c.name, c.description))
+	}
+	traceLogs := fmt.Sprintf(`_files -g "*.(%s)"`, strings.Join(traceLogExtensions, "|"))
+
+	fmt.Fprintf(w, `#compdef gcvis
+# zsh completion for gcvis, generated by gcvis completion zsh.
+# Load it with: source <(gcvis completion zsh)
+
+_gcvis() {
+	local -a commands flags
+	local state line
+
+	commands=(
+%[1]s
+	)
+	flags=(
+%[2]s
+	)
+
+	_arguments -C $flags '1: :->command' '*:: :->args' && return
+
+	case $state in
+	command)
+		_describe -t commands 'gcvis command' commands
+		_command_names -e
+		;;
+	args)
+		case $words[1] in
+		replay)
+			_arguments $flags '1:trace log:%[3]s'
+			;;
+		serve | check)
+			_arguments $flags
+			;;
+		export)
+			_arguments \
+%[4]s
+			;;
+		snapshot)
+			_arguments \
+%[9]s \
+				'1:url:'
+			;;
+		report)
+			_arguments \
+%[5]s \
+				'1:trace log:%[3]s'
+			;;
+		diff)
+			_arguments \
+%[7]s \
+				'1:baseline:%[3]s' \
+				'2:candidate:%[3]s'
+			;;
+		bench)
+			_arguments \
+%[8]s \
+				'*::program:_normal'
+			;;
+		grafana-dashboard)
+			_arguments \
+%[6]s
+			;;
+		completion)
+			_arguments '1:shell:(bash zsh fish)'
+			;;
+		run)
+			shift words
+			(( CURRENT-- ))
+			_normal
+			;;
+		*)
+			_normal
+			;;
+		esac
+		;;
+	esac
+}
+
+compdef _gcvis gcvis
+`,
+		strings.Join(commands, "\n"),
+		"\t\t"+strings.Join(zshFlagSpecs(flags), "\n\t\t"),
+		traceLogs,
+		zshArguments(commandFlags["export"]),
+		zshArguments(commandFlags["report"]),
+		zshArguments(commandFlags["grafana-dashboard"]),
+		zshArguments(commandFlags["diff"]),
+		zshArguments(commandFlags["bench"]),
+		zshArguments(commandFlags["snapshot"]))
+}
+
+// zshArguments returns the specs of flags as continued _arguments lines.
+func zshArguments(flags []completionFlag) string {
+	return "\t\t\t\t" + strings.Join(zshFlagSpecs(flags), " \\\n\t\t\t\t")
+}
+
+func writeFishCompletion(w io.Writer, flags []completionFlag) {
+	quote := func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	}
+	writeFlags := func(condition string, flags []completionFlag) {
+		for _, f := range flags {
+			fmt.Fprintf(w, "complete -c gcvis -n %s -o %s", quote(condition), f.name)
+			switch {
+			case f.files:
+				fmt.Fprint(w, " -r -F")
+			case len(f.values) > 0:
+				fmt.Fprintf(w, " -x -a %s", quote(strings.Join(f.values, " ")))
+			case f.takesValue:
+				fmt.Fprint(w, " -x")
+			}
+			fmt.Fprintf(w, " -d %s\n", quote(f.description))
+		}
+	}
+
+	fmt.Fprint(w, `# fish completion for gcvis, generated by gcvis completion fish.
+# Load it with: gcvis completion fish | source
+
+complete -c gcvis -e
+`)
+
+	fmt.Fprintln(w, "\n# Commands, or the program to run.")
+	for _, c := range gcvisCommands {
+		fmt.Fprintf(w, "complete -c gcvis -n __fish_use_subcommand -f -a %s -d %s\n", c.name, quote(c.description))
+	}
+	fmt.Fprintln(w, "complete -c gcvis -n __fish_use_subcommand -f -a '(__fish_complete_command)'")
+
+	fmt.Fprintln(w, "\n# Flags of gcvis, of the program run, replay and serve.")
+	writeFlags("not __fish_seen_subcommand_from export snapshot report diff bench grafana-dashboard completion", flags)
+
+	fmt.Fprintln(w, "\n# Trace logs and recordings to replay, report on or compare.")
+	var suffixes []string
+	for _, ext := range traceLogExtensions {
+		suffixes = append(suffixes, "__fish_complete_suffix ."+ext)
+	}
+	fmt.Fprintf(w, "complete -c gcvis -n '__fish_seen_subcommand_from replay report diff' -f -a %s\n", quote("("+strings.Join(suffixes, "; ")+")"))
+
+	for _, name := range []string{"export", "snapshot", "report", "diff", "bench", "grafana-dashboard"} {
+		fmt.Fprintf(w, "\n# Flags of %s.\n", name)
+		writeFlags("__fish_seen_subcommand_from "+name, commandFlags[name])
+	}
+
+	fmt.Fprintln(w, "\ncomplete -c gcvis -n '__fish_seen_subcommand_from completion' -f -a 'bash zsh fish'")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func completionTestFlags() []completionFlag {
+	fs := flag.NewFlagSet("gcvis", flag.ContinueOnError)
+	fs.String("p", "4500", "specify port to use.")
+	fs.Bool("metrics", false, "serve Prometheus metrics at /metrics")
+	fs.String("config", "", "path of a YAML, TOML or JSON file setting flags by name, e.g. gcvis.yaml. Flags set on the command line win")
+	return globalCompletionFlags(fs)
+}
+
+func TestGlobalCompletionFlags(t *testing.T) {
+	flags := completionTestFlags()
+	if len(flags) != 3 {
+		t.Fatalf("Expected 3 flags. Got %d instead.", len(flags))
+	}
+
+	config, metrics, port := flags[0], flags[1], flags[2]
+	if !config.takesValue || !config.files || config.description != "path of a YAML, TOML or JSON file setting flags by name, e.g. gcvis.yaml" {
+		t.Errorf("Expected -config to complete files. Got %+v instead.", config)
+	}
+	if metrics.takesValue {
+		t.Errorf("Expected -metrics to take no value. Got %+v instead.", metrics)
+	}
+	if !port.takesValue || port.files || port.description != "specify port to use" {
+		t.Errorf("Expected -p to take a value. Got %+v instead.", port)
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var b bytes.Buffer
+		if err := writeCompletion(&b, shell, completionTestFlags()); err != nil {
+			t.Fatalf("writeCompletion(%s) returned an error: %v", shell, err)
+		}
+
+		script := b.String()
+		for _, expected := range []string{"replay", "grafana-dashboard", "metrics", "config", "datasource", "jsonl"} {
+			if !strings.Contains(script, expected) {
+				t.Errorf("Expected the %s script to complete %s.", shell, expected)
+			}
+		}
+
+		// Check the syntax of the script when the shell is installed.
+		if _, err := exec.LookPath(shell); err != nil {
+			continue
+		}
+		cmd := exec.Command(shell, "-n")
+		cmd.Stdin = &b
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("Expected a valid %s script. Got %v instead: %s", shell, err, out)
+		}
+	}
+
+	if err := writeCompletion(&bytes.Buffer{}, "powershell", nil); err == nil {
+		t.Errorf("Expected powershell to be unsupported.")
+	}
+}
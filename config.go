@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var configPath = flag.String("config", "", "path of a YAML, TOML or JSON file setting flags by name, e.g. loki-url: http://localhost:3100. Flags given on the command line or in GCVIS_* environment variables take precedence")
+
+// loadConfig sets the flags of fs found in the config file at path, unless
+// they were already set, on the command line or from the environment. Keys
+// are flag names, and values:
+//   - scalars, e.g. loki-url: http://localhost:3100;
+//   - lists, for repeatable flags, e.g. webhook-threshold: [...];
+//   - tables, for key=value flags, e.g. label: {env: prod}.
+//
+// Tables whose key is not a flag name group flags sharing a prefix, e.g.
+// loki: {url: ..., tenant: ...} sets -loki-url and -loki-tenant.
+func loadConfig(fs *flag.FlagSet, path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &values)
+	case ".toml":
+		err = toml.Unmarshal(content, &values)
+	case ".json":
+		err = json.Unmarshal(content, &values)
+	default:
+		return fmt.Errorf("unknown config format %q, expected .yaml, .toml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	return applyConfig(fs, path, "", values, set)
+}
+
+func applyConfig(fs *flag.FlagSet, path, prefix string, values map[string]interface{}, set map[string]bool) error {
+	for _, key := range sortedConfigKeys(values) {
+		name := prefix + key
+		value := values[key]
+
+		if fs.Lookup(name) == nil {
+			if table, ok := value.(map[string]interface{}); ok {
+				if err := applyConfig(fs, path, name+"-", table, set); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("unknown flag %q in %s", name, path)
+		}
+		if set[name] {
+			continue
+		}
+
+		var args []string
+		switch value := value.(type) {
+		case []interface{}:
+			for _, v := range value {
+				args = append(args, configValue(v))
+			}
+		case map[string]interface{}:
+			for _, k := range sortedConfigKeys(value) {
+				args = append(args, k+"="+configValue(value[k]))
+			}
+		default:
+			args = []string{configValue(value)}
+		}
+
+		for _, arg := range args {
+			if err := fs.Set(name, arg); err != nil {
+				return fmt.Errorf("invalid value %q for %s in %s: %v", arg, name, path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configValue returns v as a flag value. JSON numbers are all float64,
+// written out in full so that integer flags take 1000000 rather than 1e+06.
+func configValue(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func sortedConfigKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// configFlags returns a flag set with flags of every kind.
+func configFlags() (*flag.FlagSet, *string, *string, *time.Duration, *bool, labelsFlag, *thresholdsFlag) {
+	fs := flag.NewFlagSet("gcvis", flag.ContinueOnError)
+	url := fs.String("loki-url", "", "")
+	tenant := fs.String("loki-tenant", "", "")
+	wait := fs.Duration("loki-batch-wait", time.Second, "")
+	metrics := fs.Bool("metrics", false, "")
+	labels := labelsFlag{}
+	fs.Var(labels, "label", "")
+	thresholds := &thresholdsFlag{}
+	fs.Var(thresholds, "webhook-threshold", "")
+
+	return fs, url, tenant, wait, metrics, labels, thresholds
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile returned an error: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	path := writeConfig(t, "gcvis.yaml", `
+loki:
+  url: http://localhost:3100
+  tenant: team-a
+  batch-wait: 5s
+metrics: true
+label:
+  env: prod
+  sha: abc123
+webhook-threshold:
+  - stw_mark_clock_milliseconds>5
+  - heap_goal_megabytes>1024
+`)
+
+	fs, url, tenant, wait, metrics, labels, thresholds := configFlags()
+	if err := fs.Parse([]string{"-loki-tenant=team-b"}); err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	if err := loadConfig(fs, path); err != nil {
+		t.Fatalf("loadConfig returned an error: %v", err)
+	}
+
+	if *url != "http://localhost:3100" || *wait != 5*time.Second || !*metrics {
+		t.Errorf("Expected the config to set the flags. Got %q %v %v instead.", *url, *wait, *metrics)
+	}
+	if *tenant != "team-b" {
+		t.Errorf("Expected the command line to take precedence. Got %q instead.", *tenant)
+	}
+	if labels.String() != "env=prod,sha=abc123" {
+		t.Errorf("Expected labels from a table. Got %q instead.", labels.String())
+	}
+	if len(*thresholds) != 2 {
+		t.Errorf("Expected thresholds from a list. Got %v instead.", thresholds)
+	}
+}
+
+func TestLoadConfigTOML(t *testing.T) {
+	path := writeConfig(t, "gcvis.toml", `
+metrics = true
+loki-batch-wait = "2s"
+
+[loki]
+url = "http://localhost:3100"
+
+[label]
+env = "staging"
+`)
+
+	fs, url, _, wait, metrics, labels, _ := configFlags()
+	if err := loadConfig(fs, path); err != nil {
+		t.Fatalf("loadConfig returned an error: %v", err)
+	}
+
+	if *url != "http://localhost:3100" || *wait != 2*time.Second || !*metrics || labels["env"] != "staging" {
+		t.Errorf("Expected the config to set the flags. Got %q %v %v %v instead.", *url, *wait, *metrics, labels)
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeConfig(t, "gcvis.json", `{"max-points": 1000000, "ratio": 0.25, "loki": {"url": "http://localhost:3100"}}`)
+
+	fs, url, _, _, _, _, _ := configFlags()
+	maxPoints := fs.Int("max-points", 0, "")
+	ratio := fs.Float64("ratio", 0, "")
+	if err := loadConfig(fs, path); err != nil {
+		t.Fatalf("loadConfig returned an error: %v", err)
+	}
+
+	if *maxPoints != 1000000 || *ratio != 0.25 || *url != "http://localhost:3100" {
+		t.Errorf("Expected the config to set the flags. Got %d %v %q instead.", *maxPoints, *ratio, *url)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown.yaml": "lokiurl: http://localhost:3100\n",
+		"invalid.yaml": "loki-batch-wait: soon\n",
+		"gcvis.ini":    "metrics=true\n",
+	} {
+		fs, _, _, _, _, _, _ := configFlags()
+		if err := loadConfig(fs, writeConfig(t, name, content)); err == nil {
+			t.Errorf("Expected %s to be rejected.", name)
+		}
+	}
+}
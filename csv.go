@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var csvOut = flag.String("csv", "", "path of a CSV file to append one row per GC event to")
+var csvScvgOut = flag.String("csv-scvg", "", "path of a CSV file to append one row per scavenger event to")
+var csvFlushInterval = flag.Duration("csv-flush-interval", time.Second, "how often buffered CSV rows are written to disk")
+
+var (
+	csvGCHeader   = []string{"Time", "ElapsedTime", "NumGC", "Heap0", "Heap1", "Heap2", "Heap3", "STWSclock", "MASclock", "STWMclock", "STWScpu", "MASAssistcpu", "MASBGcpu", "MASIdlecpu", "STWMcpu"}
+	csvScvgHeader = []string{"Time", "ElapsedTime", "inuse", "idle", "sys", "released", "consumed"}
+)
+
+func init() {
+	RegisterSink("csv", newCSVSink)
+}
+
+// csvSink appends GC events, and optionally scavenger events, to CSV files.
+// Rows are buffered and written every csvFlushInterval.
+type csvSink struct {
+	mu   sync.Mutex
+	gc   *csvFile
+	scvg *csvFile
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// csvFile is a CSV file opened for appending.
+type csvFile struct {
+	f *os.File
+	b *bufio.Writer
+	w *csv.Writer
+}
+
+func newCSVSink() (Sink, error) {
+	if *csvOut == "" && *csvScvgOut == "" {
+		return nil, nil
+	}
+
+	s := &csvSink{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	var err error
+	if *csvOut != "" {
+		if s.gc, err = openCSVFile(*csvOut, csvGCHeader); err != nil {
+			return nil, err
+		}
+	}
+	if *csvScvgOut != "" {
+		if s.scvg, err = openCSVFile(*csvScvgOut, csvScvgHeader); err != nil {
+			s.gc.close()
+			return nil, err
+		}
+	}
+
+	go s.flushPeriodically(*csvFlushInterval)
+
+	return s, nil
+}
+
+// openCSVFile opens path for appending, writing header first when the file
+// is empty.
+func openCSVFile(path string, header []string) (*csvFile, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	b := bufio.NewWriter(f)
+	c := &csvFile{f: f, b: b, w: csv.NewWriter(b)}
+	if info.Size() == 0 {
+		c.w.Write(header)
+	}
+
+	return c, nil
+}
+
+func (c *csvFile) flush() error {
+	if c == nil {
+		return nil
+	}
+
+	c.w.Flush()
+	if err := c.w.Error(); err != nil {
+		return err
+	}
+	return c.b.Flush()
+}
+
+func (c *csvFile) close() error {
+	if c == nil {
+		return nil
+	}
+
+	if err := c.flush(); err != nil {
+		c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
+
+func (s *csvSink) ConsumeGC(t *gctrace) error {
+	if s.gc == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.gc.w.Write([]string{
+		exportTime(traceTime(t.ElapsedTime)).Format(time.RFC3339Nano),
+		csvFloat(t.ElapsedTime),
+		csvInt(t.NumGC),
+		csvInt(t.Heap0),
+		csvInt(t.Heap1),
+		csvInt(t.Heap2),
+		csvInt(t.Heap3),
+		csvFloat(t.STWSclock),
+		csvFloat(t.MASclock),
+		csvFloat(t.STWMclock),
+		csvFloat(t.STWScpu),
+		csvFloat(t.MASAssistcpu),
+		csvFloat(t.MASBGcpu),
+		csvFloat(t.MASIdlecpu),
+		csvFloat(t.STWMcpu),
+	})
+}
+
+func (s *csvSink) ConsumeScvg(t *scvgtrace) error {
+	if s.scvg == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.scvg.w.Write([]string{
+		exportTime(traceTime(t.ElapsedTime)).Format(time.RFC3339Nano),
+		csvFloat(t.ElapsedTime),
+		csvInt(t.Inuse),
+		csvInt(t.Idle),
+		csvInt(t.Sys),
+		csvInt(t.Released),
+		csvInt(t.Consumed),
+	})
+}
+
+func (s *csvSink) flushPeriodically(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *csvSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.gc.flush(); err != nil {
+		return err
+	}
+	return s.scvg.flush()
+}
+
+func (s *csvSink) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs sinkErrors
+	errs.Add(s.gc.close())
+	errs.Add(s.scvg.close())
+	return errs.Err()
+}
+
+func csvInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func csvFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
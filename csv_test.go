@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCSVSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	gcPath := filepath.Join(dir, "gc.csv")
+	scvgPath := filepath.Join(dir, "scvg.csv")
+
+	savedGC, savedScvg := *csvOut, *csvScvgOut
+	*csvOut, *csvScvgOut = gcPath, scvgPath
+	defer func() { *csvOut, *csvScvgOut = savedGC, savedScvg }()
+
+	// write twice, to check the header is only written to new files
+	for i := 0; i < 2; i++ {
+		sink, err := newCSVSink()
+		if err != nil {
+			t.Fatalf("newCSVSink returned an error: %v", err)
+		}
+		sink.ConsumeGC(&gctrace{NumGC: int64(i + 1), Heap1: 33, STWSclock: 0.11})
+		sink.ConsumeScvg(&scvgtrace{Inuse: 12})
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Close returned an error: %v", err)
+		}
+	}
+
+	rows := readCSV(t, gcPath)
+	if len(rows) != 3 {
+		t.Fatalf("Expected a header and 2 rows. Got %v instead.", rows)
+	}
+	if rows[0][2] != "NumGC" || rows[1][2] != "1" || rows[2][2] != "2" || rows[2][4] != "33" || rows[2][7] != "0.11" {
+		t.Errorf("Unexpected GC rows: %v", rows)
+	}
+
+	rows = readCSV(t, scvgPath)
+	if len(rows) != 3 || rows[0][2] != "inuse" || rows[1][2] != "12" {
+		t.Errorf("Unexpected scavenger rows: %v", rows)
+	}
+}
+
+func readCSV(t *testing.T, path string) [][]string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("File is not valid CSV: %v", err)
+	}
+	return rows
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var pidFile = flag.String("pidfile", "", "file to write the process id of gcvis to while it runs, refusing to start if it names a running gcvis")
+var daemonMode = flag.Bool("daemon", false, "run in the background, detached from the terminal, e.g. as a sidecar started by an init script. Under systemd, prefer Type=notify without it")
+var daemonLog = flag.String("daemon-log", "", "file the output of gcvis and of the program is appended to with -daemon. Defaults to discarding it")
+
+// daemonEnv is set in the environment of the process running in the
+// background, so that it does not detach again.
+const daemonEnv = "GCVIS_DAEMONIZED"
+
+// daemonize starts gcvis again in the background with the same arguments,
+// in a session of its own, and exits once it started. It returns in the
+// process running in the background, or without -daemon.
+func daemonize() {
+	if !*daemonMode || os.Getenv(daemonEnv) != "" {
+		return
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		fatalf("-daemon: %v", err)
+	}
+	out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if *daemonLog != "" {
+		out, err = os.OpenFile(*daemonLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	}
+	if err != nil {
+		fatalf("-daemon: %v", err)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), daemonEnv+"=1")
+	cmd.Stdout, cmd.Stderr = out, out
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		fatalf("-daemon: %v", err)
+	}
+
+	fmt.Println(cmd.Process.Pid)
+	os.Exit(0)
+}
+
+var (
+	pidFileMu      sync.Mutex
+	writtenPIDFile string // path of the pid file written, until removed
+)
+
+// writePIDFile writes the process id of gcvis to -pidfile, if set, until
+// removePIDFile. A pid file left by a gcvis that did not exit cleanly is
+// overwritten, but not that of a gcvis still running.
+func writePIDFile() error {
+	if *pidFile == "" {
+		return nil
+	}
+
+	if b, err := ioutil.ReadFile(*pidFile); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && pid != os.Getpid() && processRunning(pid) {
+			return fmt.Errorf("-pidfile %s: gcvis is already running as process %d", *pidFile, pid)
+		}
+	}
+	if err := ioutil.WriteFile(*pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return fmt.Errorf("-pidfile: %v", err)
+	}
+	pidFileMu.Lock()
+	writtenPIDFile = *pidFile
+	pidFileMu.Unlock()
+
+	// Terminated by a service manager, gcvis would otherwise leave it.
+	terminated := make(chan os.Signal, 1)
+	signal.Notify(terminated, syscall.SIGTERM)
+	go func() {
+		<-terminated
+		removePIDFile()
+		os.Exit(128 + int(syscall.SIGTERM))
+	}()
+	return nil
+}
+
+// removePIDFile removes the pid file, if written.
+func removePIDFile() {
+	pidFileMu.Lock()
+	defer pidFileMu.Unlock()
+
+	if writtenPIDFile == "" {
+		return
+	}
+	if err := os.Remove(writtenPIDFile); err != nil && !os.IsNotExist(err) {
+		errorf("cannot remove the pid file: %v", err)
+	}
+	writtenPIDFile = ""
+}
+
+// notifyReady tells systemd that gcvis is serving, for services of
+// Type=notify.
+func notifyReady() {
+	if err := sdNotify(os.Getenv("NOTIFY_SOCKET"), "READY=1"); err != nil {
+		errorf("cannot notify systemd: %v", err)
+	}
+}
+
+// sdNotify sends state to the systemd notification socket, if any.
+// Sockets of the abstract namespace start with @.
+func sdNotify(socket, state string) error {
+	if socket == "" {
+		return nil
+	}
+
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
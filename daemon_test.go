@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPIDFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(saved string) { *pidFile = saved }(*pidFile)
+	*pidFile = filepath.Join(dir, "gcvis.pid")
+
+	running := exec.Command("/usr/bin/env", "sleep", "10")
+	if err := running.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer running.Process.Kill()
+	if err := ioutil.WriteFile(*pidFile, []byte(strconv.Itoa(running.Process.Pid)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePIDFile(); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("Expected the pid file of a running gcvis to be kept. Got %v instead.", err)
+	}
+	removePIDFile()
+	if _, err := os.Stat(*pidFile); err != nil {
+		t.Errorf("Expected the pid file of another gcvis not to be removed. Got %v instead.", err)
+	}
+
+	running.Process.Kill()
+	running.Wait()
+	if err := writePIDFile(); err != nil {
+		t.Fatalf("Expected a stale pid file to be overwritten. Got %v instead.", err)
+	}
+	b, err := ioutil.ReadFile(*pidFile)
+	if err != nil || strings.TrimSpace(string(b)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Expected the pid file to hold %d. Got %q, %v instead.", os.Getpid(), b, err)
+	}
+
+	removePIDFile()
+	if _, err := os.Stat(*pidFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the pid file to be removed. Got %v instead.", err)
+	}
+}
+
+func TestSdNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenPacket("unixgram", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := sdNotify(socket, "READY=1"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	buf := make([]byte, 64)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "READY=1" {
+		t.Errorf("Expected READY=1 to be sent. Got %q, %v instead.", buf[:n], err)
+	}
+
+	if err := sdNotify("", "READY=1"); err != nil {
+		t.Errorf("Expected nothing to be sent outside of systemd. Got %v instead.", err)
+	}
+}
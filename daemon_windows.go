@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detach makes cmd run without a console of its own.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// processRunning tells whether the process pid exists.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
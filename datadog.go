@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var datadogAPIKey = flag.String("datadog-api-key", os.Getenv("DD_API_KEY"), "Datadog API key to submit metrics with. Defaults to $DD_API_KEY")
+var datadogSite = flag.String("datadog-site", envOr("DD_SITE", "datadoghq.com"), "Datadog site metrics are submitted to, or the URL of a proxy. Defaults to $DD_SITE")
+var datadogService = flag.String("datadog-service", os.Getenv("DD_SERVICE"), "service tag of the Datadog metrics, instead of -s. Defaults to $DD_SERVICE")
+var datadogEnv = flag.String("datadog-env", os.Getenv("DD_ENV"), "env tag of the Datadog metrics. Defaults to $DD_ENV")
+var datadogVersion = flag.String("datadog-version", os.Getenv("DD_VERSION"), "version tag of the Datadog metrics. Defaults to $DD_VERSION")
+var datadogBatchWait = flag.Duration("datadog-batch-wait", 10*time.Second, "maximum time a metric waits before being submitted to Datadog")
+
+// datadogBatchSize bounds the number of points of a submission.
+const datadogBatchSize = 1000
+
+func init() {
+	RegisterSink("datadog", newDatadogSink)
+}
+
+func envOr(name, value string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return value
+}
+
+// datadogTags returns the unified service tagging tags, service, env and
+// version, the -label ones, and the entity ID the agent uses to find the
+// container metrics come from, sorted.
+func datadogTags() []string {
+	service := *datadogService
+	if service == "" {
+		service = *serviceName
+	}
+
+	tags := []string{"service:" + service}
+	if *datadogEnv != "" {
+		tags = append(tags, "env:"+*datadogEnv)
+	}
+	if *datadogVersion != "" {
+		tags = append(tags, "version:"+*datadogVersion)
+	}
+	for k, v := range extraLabels {
+		tags = append(tags, k+":"+v)
+	}
+	// Origin detection, when the agent runs as a DaemonSet.
+	if entityID := os.Getenv("DD_ENTITY_ID"); entityID != "" {
+		tags = append(tags, "dd.internal.entity_id:"+entityID)
+	}
+	sort.Strings(tags)
+
+	return tags
+}
+
+// datadogAPIURL returns the base URL of the API of site, e.g. datadoghq.eu,
+// or site itself when it is a URL.
+func datadogAPIURL(site string) string {
+	if strings.Contains(site, "://") {
+		return strings.TrimRight(site, "/")
+	}
+	return "https://api." + site
+}
+
+// datadogSink submits metrics with the Datadog series API, as gauges named
+// gcvis.<metric>, e.g. gcvis.heap_goal_megabytes.
+type datadogSink struct {
+	url    string
+	header http.Header
+	tags   []string
+
+	client  *http.Client
+	batcher *batcher
+}
+
+func newDatadogSink() (Sink, error) {
+	if *datadogAPIKey == "" {
+		return nil, nil
+	}
+
+	s := &datadogSink{
+		url: datadogAPIURL(*datadogSite) + "/api/v1/series",
+		header: http.Header{
+			"Content-Type": {"application/json"},
+			"Dd-Api-Key":   {*datadogAPIKey},
+		},
+		tags:   datadogTags(),
+		client: &http.Client{Timeout: pushTimeout},
+	}
+	s.batcher = newBatcher(datadogBatchSize, *datadogBatchWait, s.send)
+
+	return s, nil
+}
+
+func (s *datadogSink) ConsumeGC(t *gctrace) error {
+	s.add(gcMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *datadogSink) ConsumeScvg(t *scvgtrace) error {
+	s.add(scvgMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *datadogSink) add(metrics []metric, ts time.Time) {
+	for _, m := range metrics {
+		s.batcher.Add(map[string]interface{}{
+			"metric": "gcvis." + strings.TrimPrefix(m.Name, "gcvis_"),
+			"type":   "gauge",
+			"points": [][2]float64{{float64(ts.Unix()), m.Value}},
+			"host":   ownHost,
+			"tags":   s.tags,
+		})
+	}
+}
+
+func (s *datadogSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *datadogSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+func (s *datadogSink) send(batch []interface{}) {
+	body, err := json.Marshal(map[string]interface{}{"series": batch})
+	if err != nil {
+		errorf("cannot encode Datadog series: %v", err)
+		return
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d Datadog points", len(batch)), func() (bool, error) {
+		return postBody(s.client, s.url, s.header, body)
+	})
+}
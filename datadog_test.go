@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDatadogTags(t *testing.T) {
+	t.Setenv("DD_ENTITY_ID", "pod-uid")
+
+	saved := []string{*datadogService, *datadogEnv, *datadogVersion}
+	*datadogService, *datadogEnv, *datadogVersion = "", "prod", "1.2.3"
+	defer func() { *datadogService, *datadogEnv, *datadogVersion = saved[0], saved[1], saved[2] }()
+
+	expected := "dd.internal.entity_id:pod-uid,env:prod,service:" + *serviceName + ",version:1.2.3"
+	if tags := strings.Join(datadogTags(), ","); tags != expected {
+		t.Errorf("Expected tags %s. Got %s instead.", expected, tags)
+	}
+}
+
+func TestDatadogSink(t *testing.T) {
+	var (
+		path, key string
+		request   struct {
+			Series []struct {
+				Metric string
+				Type   string
+				Points [][2]float64
+				Host   string
+				Tags   []string
+			}
+		}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path, key = req.URL.Path, req.Header.Get("DD-API-KEY")
+		json.NewDecoder(req.Body).Decode(&request)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	saved := []string{*datadogAPIKey, *datadogSite, *datadogService}
+	*datadogAPIKey, *datadogSite, *datadogService = "secret", server.URL, "api"
+	defer func() { *datadogAPIKey, *datadogSite, *datadogService = saved[0], saved[1], saved[2] }()
+
+	sink, err := newDatadogSink()
+	if err != nil {
+		t.Fatalf("newDatadogSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{Heap1: 33})
+	sink.Close()
+
+	if path != "/api/v1/series" || key != "secret" {
+		t.Errorf("Expected a series submission with the API key. Got %s with %q instead.", path, key)
+	}
+	if len(request.Series) != len(gcMetrics(&gctrace{})) {
+		t.Fatalf("Expected a series per metric. Got %d instead.", len(request.Series))
+	}
+
+	found := false
+	for _, series := range request.Series {
+		if series.Metric == "gcvis.heap_goal_megabytes" {
+			found = series.Points[0][1] == 33 && series.Type == "gauge" && series.Host == ownHost
+		}
+		if strings.Join(series.Tags, ",") != "service:api" {
+			t.Errorf("Expected the service:api tag. Got %v instead.", series.Tags)
+		}
+	}
+	if !found {
+		t.Errorf("Expected a gcvis.heap_goal_megabytes gauge of 33. Got %+v instead.", request.Series)
+	}
+}
+
+func TestStatsdSinkDatadogTags(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket returned an error: %v", err)
+	}
+	defer conn.Close()
+
+	savedAddr, savedTags, savedEnv := *statsdAddr, *statsdTags, *datadogEnv
+	*statsdAddr, *statsdTags, *datadogEnv = conn.LocalAddr().String(), true, "prod"
+	defer func() { *statsdAddr, *statsdTags, *datadogEnv = savedAddr, savedTags, savedEnv }()
+
+	sink, err := newStatsdSink()
+	if err != nil {
+		t.Fatalf("newStatsdSink returned an error: %v", err)
+	}
+	defer sink.Close()
+	sink.ConsumeScvg(&scvgtrace{Inuse: 1})
+
+	buf := make([]byte, statsdMaxPacket)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom returned an error: %v", err)
+	}
+
+	if packet := string(buf[:n]); !strings.Contains(packet, ",env:prod,") {
+		t.Errorf("Expected the env tag. Got %q instead.", packet)
+	}
+}
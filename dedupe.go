@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+var dedupeWindow = flag.Duration("dedupe", 0, "window GC traces are deduplicated in, by GC number and elapsed time, for programs whose standard error is mirrored, e.g. by tee, so that every cycle is read twice. 0 keeps every trace")
+
+// dedupeKey identifies a GC cycle of the traced program.
+type dedupeKey struct {
+	numGC   int64
+	elapsed float64
+}
+
+// deduper drops the GC traces of a cycle read again within a window.
+type deduper struct {
+	window time.Duration
+	seen   map[dedupeKey]time.Time // time each cycle was first read at
+}
+
+func newDeduper(window time.Duration) *deduper {
+	return &deduper{window: window, seen: map[dedupeKey]time.Time{}}
+}
+
+// Duplicate tells whether t, read at now, is of a cycle read within the
+// window before.
+func (d *deduper) Duplicate(t *gctrace, now time.Time) bool {
+	if d.window <= 0 {
+		return false
+	}
+
+	for key, at := range d.seen {
+		if now.Sub(at) >= d.window {
+			delete(d.seen, key)
+		}
+	}
+
+	key := dedupeKey{t.NumGC, t.ElapsedTime}
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+	d.seen[key] = now
+	return false
+}
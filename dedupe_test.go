@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeduper(t *testing.T) {
+	d := newDeduper(time.Second)
+
+	start := time.Now()
+	if d.Duplicate(&gctrace{NumGC: 1, ElapsedTime: 0.5}, start) {
+		t.Errorf("Expected the first trace of a cycle to be kept.")
+	}
+	if !d.Duplicate(&gctrace{NumGC: 1, ElapsedTime: 0.5}, start.Add(10*time.Millisecond)) {
+		t.Errorf("Expected the mirrored trace of a cycle to be dropped.")
+	}
+	if d.Duplicate(&gctrace{NumGC: 2, ElapsedTime: 0.5}, start.Add(20*time.Millisecond)) {
+		t.Errorf("Expected the trace of another cycle to be kept.")
+	}
+
+	// A restarted program collects garbage with the same numbers again.
+	if d.Duplicate(&gctrace{NumGC: 1, ElapsedTime: 0.5}, start.Add(2*time.Second)) {
+		t.Errorf("Expected a trace read after the window to be kept.")
+	}
+	if len(d.seen) != 1 {
+		t.Errorf("Expected the cycles read before the window to be forgotten. Got %v instead.", d.seen)
+	}
+}
+
+func TestDeduperOff(t *testing.T) {
+	d := newDeduper(0)
+
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if d.Duplicate(&gctrace{NumGC: 1, ElapsedTime: 0.5}, now) {
+			t.Errorf("Expected every trace to be kept without a window.")
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gmaz42/gcvis/parse"
+)
+
+// runDiff compares the GC traces of two recordings, or trace logs, e.g. of
+// a benchmark before and after a change.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "", "format of the comparison: text, json, or html for a standalone page. Defaults to html if -o ends with .html, text otherwise")
+	out := fs.String("o", "", "path of the file to write the comparison to, instead of the standard output")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "diff: expected a baseline and a candidate recording")
+		os.Exit(2)
+	}
+
+	if *format == "" {
+		*format = "text"
+		if strings.HasSuffix(*out, ".html") {
+			*format = "html"
+		}
+	}
+
+	var runs [2][]timedTrace
+	for i, path := range fs.Args() {
+		r, err := openTraceLog(path)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		runs[i], err = readRecording(r)
+		r.Close()
+		if err != nil {
+			fatalf("diff: cannot read %s: %v", path, err)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	d := diffRuns(runs[0], runs[1])
+	d.Baseline, d.Candidate = fs.Arg(0), fs.Arg(1)
+	if err := d.write(w, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// readRecording returns the GC traces of a -record recording, or of a trace
+// log, with their time in seconds since the first one.
+func readRecording(r io.Reader) ([]timedTrace, error) {
+	parser := NewParser(nil)
+
+	var traces []timedTrace
+	var first float64
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	sc.Split(parse.ScanLines)
+	for sc.Scan() {
+		line := sc.Text()
+
+		var t *gctrace
+		var at float64
+		var event recordEvent
+		if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &event) == nil {
+			if event.Type != "gc" || event.GC == nil {
+				continue
+			}
+			t = event.GC
+			at = float64(event.Time.UnixNano()) / 1e9
+		} else if t, _ = parser.ParseLine(line); t != nil {
+			at = t.ElapsedTime
+		} else {
+			continue
+		}
+
+		if len(traces) == 0 {
+			first = at
+		}
+		traces = append(traces, timedTrace{elapsed: at - first, gc: t})
+	}
+	return traces, sc.Err()
+}
+
+// traceDiff compares two runs over the time both lasted, from their first GC.
+type traceDiff struct {
+	Baseline, Candidate string  // names of the runs
+	DurationSeconds     float64 // compared
+	Skipped             [2]int  // cycles of each run past the duration
+	Rows                []diffRow
+}
+
+// diffRow is a statistic of both runs, lower being better for all of them.
+type diffRow struct {
+	Name                string
+	Unit                string
+	Baseline, Candidate float64
+}
+
+// Delta returns how much the statistic changed.
+func (r diffRow) Delta() float64 {
+	return r.Candidate - r.Baseline
+}
+
+// Percent returns how much the statistic changed, in percent of the
+// baseline, or NaN without a baseline.
+func (r diffRow) Percent() float64 {
+	if r.Baseline == 0 {
+		return math.NaN()
+	}
+	return 100 * r.Delta() / r.Baseline
+}
+
+// MarshalJSON adds the deltas, leaving out the percentage without a
+// baseline.
+func (r diffRow) MarshalJSON() ([]byte, error) {
+	row := map[string]interface{}{
+		"name":      r.Name,
+		"unit":      r.Unit,
+		"baseline":  r.Baseline,
+		"candidate": r.Candidate,
+		"delta":     r.Delta(),
+	}
+	if p := r.Percent(); !math.IsNaN(p) {
+		row["delta_percent"] = p
+	}
+	return json.Marshal(row)
+}
+
+// diffRuns compares the cycles of baseline and candidate over the time both
+// lasted, aligning them on their first cycle.
+func diffRuns(baseline, candidate []timedTrace) *traceDiff {
+	d := &traceDiff{DurationSeconds: math.Min(lastTraceElapsed(baseline), lastTraceElapsed(candidate))}
+
+	var summaries [2]*exitSummary
+	for i, traces := range [2][]timedTrace{baseline, candidate} {
+		stats := newRunStats()
+		for _, t := range traces {
+			if t.elapsed > d.DurationSeconds {
+				d.Skipped[i]++
+				continue
+			}
+			stats.addGC(t.gc)
+		}
+		summaries[i] = newExitSummary(stats)
+		// The traces may not tell when they were written, the recordings do.
+		summaries[i].AllocRateMBPerSecond = 0
+		if d.DurationSeconds > 0 {
+			summaries[i].AllocRateMBPerSecond = float64(stats.allocated) / d.DurationSeconds
+		}
+	}
+
+	b, c := summaries[0], summaries[1]
+	d.Rows = []diffRow{
+		{"GC cycles", "", float64(b.NumGC), float64(c.NumGC)},
+		{"Total pause", "ms", b.TotalPauseMs, c.TotalPauseMs},
+		{"p50 pause", "ms", b.PausePercentilesMs["p50"], c.PausePercentilesMs["p50"]},
+		{"p90 pause", "ms", b.PausePercentilesMs["p90"], c.PausePercentilesMs["p90"]},
+		{"p99 pause", "ms", b.PausePercentilesMs["p99"], c.PausePercentilesMs["p99"]},
+		{"Longest pause", "ms", b.PausePercentilesMs["max"], c.PausePercentilesMs["max"]},
+		{"Heap high water", "MB", float64(b.HeapHighWaterMB), float64(c.HeapHighWaterMB)},
+		{"Allocation rate", "MB/s", b.AllocRateMBPerSecond, c.AllocRateMBPerSecond},
+	}
+	return d
+}
+
+func lastTraceElapsed(traces []timedTrace) float64 {
+	if len(traces) == 0 {
+		return 0
+	}
+	return traces[len(traces)-1].elapsed
+}
+
+func (d *traceDiff) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"baseline":         d.Baseline,
+			"candidate":        d.Candidate,
+			"duration_seconds": d.DurationSeconds,
+			"skipped_cycles":   d.Skipped,
+			"statistics":       d.Rows,
+		})
+	case "text":
+		fmt.Fprintf(w, "Comparing the first %.3fs of %s and %s\n", d.DurationSeconds, d.Baseline, d.Candidate)
+		if d.Skipped[0] > 0 || d.Skipped[1] > 0 {
+			fmt.Fprintf(w, "Skipping the last %d and %d cycles, past the end of the shorter run\n", d.Skipped[0], d.Skipped[1])
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "\tbaseline\tcandidate\tdelta\t\n")
+		for _, r := range d.Rows {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", r.Name, formatDiffValue(r.Baseline, r.Unit), formatDiffValue(r.Candidate, r.Unit), formatDiffDelta(r))
+		}
+		return tw.Flush()
+	case "html":
+		return diffTemplate.Execute(w, d)
+	}
+	return fmt.Errorf("diff: unsupported format %q", format)
+}
+
+func formatDiffValue(v float64, unit string) string {
+	if unit == "" || unit == "MB" {
+		return fmt.Sprintf("%.0f%s", v, unit)
+	}
+	return fmt.Sprintf("%.3f%s", v, unit)
+}
+
+// formatDiffDelta formats the change of r, e.g. +1.500ms (+12.5%).
+func formatDiffDelta(r diffRow) string {
+	s := formatDiffValue(r.Delta(), r.Unit)
+	if r.Delta() >= 0 {
+		s = "+" + s
+	}
+	if p := r.Percent(); !math.IsNaN(p) {
+		s += fmt.Sprintf(" (%+.1f%%)", p)
+	}
+	return s
+}
+
+var diffTemplate = template.Must(template.New("diff").Funcs(template.FuncMap{
+	"value":   formatDiffValue,
+	"delta":   formatDiffDelta,
+	"version": func() string { return buildVersion().String() },
+}).Parse(`<html>
+<head>
+<title>gcvis diff - {{ .Baseline }} and {{ .Candidate }}</title>
+<style>
+body { font-family: sans-serif; margin: 20px; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 12px; text-align: right; border-bottom: 1px solid #ddd; }
+th:first-child, td:first-child { text-align: left; }
+.better { color: #080; }
+.worse { color: #d00; }
+</style>
+</head>
+<body>
+<h1>gcvis diff</h1>
+<p>Comparing the first {{ printf "%.3f" .DurationSeconds }}s of {{ .Baseline }} and {{ .Candidate }}.
+{{- if or (index .Skipped 0) (index .Skipped 1) }} Skipping the last {{ index .Skipped 0 }} and {{ index .Skipped 1 }} cycles, past the end of the shorter run.{{ end }}</p>
+<table>
+<tr><th></th><th>baseline</th><th>candidate</th><th>delta</th></tr>
+{{- range .Rows }}
+<tr><td>{{ .Name }}</td><td>{{ value .Baseline .Unit }}</td><td>{{ value .Candidate .Unit }}</td><td class="{{ if lt .Delta 0.0 }}better{{ else if gt .Delta 0.0 }}worse{{ end }}">{{ delta . }}</td></tr>
+{{- end }}
+</table>
+<p><small>gcvis {{ version }}</small></p>
+</body>
+</html>
+`))
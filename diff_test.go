@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const diffBaselineRecording = `{"type":"gc","time":"2021-11-03T14:00:10Z","gc":{"NumGC":1,"Heap0":4,"Heap3":2,"STWSclock":1},"raw":"gc 1"}
+{"type":"scvg","time":"2021-11-03T14:00:11Z","scvg":{"Inuse":4},"raw":"scvg"}
+{"type":"gc","time":"2021-11-03T14:00:20Z","gc":{"NumGC":2,"Heap0":12,"Heap3":2,"STWSclock":3},"raw":"gc 2"}
+{"type":"gc","time":"2021-11-03T14:00:30Z","gc":{"NumGC":3,"Heap0":12,"Heap3":2,"STWSclock":2},"raw":"gc 3"}
+`
+
+const diffCandidateLog = `starting
+gc 1 @5.000s 1%: 0.5+1+0.5 ms clock, 0+0/0/0+0 ms cpu, 4->4->2 MB, 5 MB goal, 4 P
+gc 2 @25.000s 1%: 0.5+1+0.5 ms clock, 0+0/0/0+0 ms cpu, 8->8->2 MB, 5 MB goal, 4 P
+gc 3 @40.000s 1%: 0.5+1+0.5 ms clock, 0+0/0/0+0 ms cpu, 8->8->2 MB, 5 MB goal, 4 P
+`
+
+func TestReadRecording(t *testing.T) {
+	traces, err := readRecording(strings.NewReader(diffBaselineRecording))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(traces) != 3 || traces[0].elapsed != 0 || traces[2].elapsed != 20 || traces[2].gc.NumGC != 3 {
+		t.Errorf("Expected 3 cycles over 20s. Got %+v instead.", traces)
+	}
+
+	traces, err = readRecording(strings.NewReader(diffCandidateLog))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(traces) != 3 || traces[0].elapsed != 0 || traces[2].elapsed != 35 || traces[1].gc.Heap0 != 8 {
+		t.Errorf("Expected 3 cycles over 35s. Got %+v instead.", traces)
+	}
+}
+
+func newTestDiff(t *testing.T) *traceDiff {
+	baseline, err := readRecording(strings.NewReader(diffBaselineRecording))
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidate, err := readRecording(strings.NewReader(diffCandidateLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := diffRuns(baseline, candidate)
+	d.Baseline, d.Candidate = "before.jsonl", "after.log"
+	return d
+}
+
+func TestDiffRuns(t *testing.T) {
+	d := newTestDiff(t)
+
+	if d.DurationSeconds != 20 || d.Skipped != [2]int{0, 1} {
+		t.Errorf("Expected the first 20s to be compared, skipping the last candidate cycle. Got %vs, %v instead.", d.DurationSeconds, d.Skipped)
+	}
+
+	rows := map[string]diffRow{}
+	for _, r := range d.Rows {
+		rows[r.Name] = r
+	}
+	if r := rows["GC cycles"]; r.Baseline != 3 || r.Candidate != 2 || r.Delta() != -1 {
+		t.Errorf("Expected 3 cycles then 2. Got %+v instead.", r)
+	}
+	if r := rows["Longest pause"]; r.Baseline != 3 || r.Candidate != 1 || r.Percent() < -66.7 || r.Percent() > -66.6 {
+		t.Errorf("Expected the longest pause to go from 3ms to 1ms. Got %+v instead.", r)
+	}
+	// 20MB allocated in 20s, then 6MB.
+	if r := rows["Allocation rate"]; r.Baseline != 1 || r.Candidate != 0.3 {
+		t.Errorf("Expected the allocation rate to go from 1MB/s to 0.3MB/s. Got %+v instead.", r)
+	}
+	if r := rows["Heap high water"]; r.Baseline != 12 || r.Candidate != 8 {
+		t.Errorf("Expected the heap high water to go from 12MB to 8MB. Got %+v instead.", r)
+	}
+}
+
+func TestDiffWrite(t *testing.T) {
+	d := newTestDiff(t)
+
+	var b bytes.Buffer
+	if err := d.write(&b, "text"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{"Comparing the first 20.000s of before.jsonl and after.log", "GC cycles", "-1 (-33.3%)", "Skipping the last 0 and 1 cycles"} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("Expected the text comparison to contain %q. Got %s instead.", expected, b.String())
+		}
+	}
+
+	b.Reset()
+	if err := d.write(&b, "json"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var decoded struct {
+		Statistics []map[string]interface{} `json:"statistics"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil || len(decoded.Statistics) != len(d.Rows) || decoded.Statistics[0]["delta"] != -1.0 {
+		t.Errorf("Expected the statistics with their deltas. Got %s, %v instead.", b.String(), err)
+	}
+
+	b.Reset()
+	if err := d.write(&b, "html"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(b.String(), `<td class="better">-1 (-33.3%)</td>`) {
+		t.Errorf("Expected fewer cycles to be better. Got %s instead.", b.String())
+	}
+
+	if err := d.write(&b, "xml"); err == nil {
+		t.Errorf("Expected an unsupported format to be rejected.")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxDownsamplePoints caps the points per series a client may ask for.
+const maxDownsamplePoints = 10000
+
+// Window returns a copy of the graph, without its template, keeping the
+// points between from and to seconds, each series downsampled to at most
+// points points.
+func (g *Graph) Window(from, to float64, points int) *Graph {
+	// Downsampling is the costly part, done without holding the lock.
+	s := g.Snapshot()
+
+	w := s.emptyCopy()
+	dst := w.series()
+	for i, r := range s.series() {
+		for _, p := range lttb(pointsBetween(r.Points(), from, to), points) {
+			dst[i].add(p)
+		}
+	}
+	return w
+}
+
+// Since returns a copy of the graph, without its template, keeping the
+// points added after cursor, and false if cursor is not a cursor of the
+// graph or some of the points were evicted already.
+func (g *Graph) Since(cursor string) (*Graph, bool) {
+	// The load and resident memory samples are only counted once there
+	// are some.
+	var gcs, scvgs, loads, rss int64
+	if _, err := fmt.Sscanf(cursor, "%d.%d.%d.%d", &gcs, &scvgs, &loads, &rss); err != nil {
+		rss = 0
+		if _, err := fmt.Sscanf(cursor, "%d.%d.%d", &gcs, &scvgs, &loads); err != nil {
+			loads = 0
+			if _, err := fmt.Sscanf(cursor, "%d.%d", &gcs, &scvgs); err != nil {
+				return nil, false
+			}
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.applyPending()
+
+	if gcs > g.gcAdded || scvgs > g.scvgAdded || loads > g.loadAdded || rss > g.rssAdded {
+		return nil, false
+	}
+
+	at := map[seriesKind]int64{gcSeries: gcs, scvgSeries: scvgs, loadSeries: loads, rssSeries: rss}
+	d := g.emptyCopy()
+	dst := d.series()
+	for i, r := range g.series() {
+		points, ok := r.since(at[r.kind])
+		if !ok {
+			return nil, false
+		}
+		for _, p := range points {
+			dst[i].add(p)
+		}
+	}
+	return d, true
+}
+
+// Cursor returns the position of updates after the traces added so far.
+func (g *Graph) Cursor() string {
+	if g.rssAdded > 0 {
+		return fmt.Sprintf("%d.%d.%d.%d", g.gcAdded, g.scvgAdded, g.loadAdded, g.rssAdded)
+	}
+	if g.loadAdded > 0 {
+		return fmt.Sprintf("%d.%d.%d", g.gcAdded, g.scvgAdded, g.loadAdded)
+	}
+	return fmt.Sprintf("%d.%d", g.gcAdded, g.scvgAdded)
+}
+
+// seriesKind tells what the points of a series are added with, each kind
+// counted apart by the cursor.
+type seriesKind int
+
+const (
+	gcSeries   seriesKind = iota // a point per GC cycle
+	scvgSeries                   // per scavenger trace
+	loadSeries                   // per load sample
+	rssSeries                    // per resident memory sample
+)
+
+// graphSeries is a series of a graph, of its kind.
+type graphSeries struct {
+	*pointRing
+	kind seriesKind
+}
+
+// series returns the series of the graph.
+func (g *Graph) series() []graphSeries {
+	return []graphSeries{
+		{&g.HeapUse, gcSeries},
+		{&g.ScvgInuse, scvgSeries}, {&g.ScvgIdle, scvgSeries}, {&g.ScvgSys, scvgSeries},
+		{&g.ScvgReleased, scvgSeries}, {&g.ScvgConsumed, scvgSeries},
+		{&g.ScvgReleasedPercent, scvgSeries}, {&g.ScvgSysGap, scvgSeries},
+		{&g.STWSclock, gcSeries}, {&g.MASclock, gcSeries}, {&g.STWMclock, gcSeries},
+		{&g.STWScpu, gcSeries}, {&g.MASAssistcpu, gcSeries}, {&g.MASBGcpu, gcSeries},
+		{&g.MASIdlecpu, gcSeries}, {&g.STWMcpu, gcSeries},
+		{&g.Load, loadSeries},
+		{&g.GCNumber, gcSeries}, {&g.Allocated, gcSeries},
+		{&g.RSS, rssSeries}, {&g.Fragmentation, rssSeries},
+	}
+}
+
+// added returns the number of the traces or samples added to the graph the
+// series of kind get their points with.
+func (g *Graph) added(kind seriesKind) *int64 {
+	switch kind {
+	case scvgSeries:
+		return &g.scvgAdded
+	case loadSeries:
+		return &g.loadAdded
+	case rssSeries:
+		return &g.rssAdded
+	}
+	return &g.gcAdded
+}
+
+// pointsBetween returns the points between from and to seconds, included.
+func pointsBetween(points []graphPoints, from, to float64) []graphPoints {
+	start := 0
+	for start < len(points) && points[start][0] < from {
+		start++
+	}
+	end := start
+	for end < len(points) && points[end][0] <= to {
+		end++
+	}
+	return points[start:end]
+}
+
+// lttb downsamples points to threshold points with the largest triangle
+// three buckets algorithm, which keeps the points standing out the most,
+// such as spikes. Points are returned as is if there are fewer than
+// threshold, or threshold is under 3.
+func lttb(points []graphPoints, threshold int) []graphPoints {
+	if threshold >= len(points) || threshold < 3 {
+		return points
+	}
+
+	sampled := make([]graphPoints, 0, threshold)
+	sampled = append(sampled, points[0])
+
+	// The first and last points are kept, the others split into
+	// threshold-2 buckets of every points.
+	every := float64(len(points)-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		// The third point of the triangles is the average of the next
+		// bucket, or the last point.
+		nextStart := int(float64(i+1)*every) + 1
+		nextEnd := int(float64(i+2)*every) + 1
+		if nextEnd > len(points) {
+			nextEnd = len(points)
+		}
+		var avgX, avgY float64
+		for j := nextStart; j < nextEnd; j++ {
+			avgX += points[j][0]
+			avgY += points[j][1]
+		}
+		if n := float64(nextEnd - nextStart); n > 0 {
+			avgX /= n
+			avgY /= n
+		} else {
+			avgX, avgY = points[len(points)-1][0], points[len(points)-1][1]
+		}
+
+		// Keep the point of the bucket making the largest triangle with
+		// the last kept point and the average of the next bucket.
+		start := int(float64(i)*every) + 1
+		end := int(float64(i+1)*every) + 1
+		maxArea, next := -1.0, start
+		for j := start; j < end; j++ {
+			area := math.Abs((points[a][0]-avgX)*(points[j][1]-points[a][1]) - (points[a][0]-points[j][0])*(avgY-points[a][1]))
+			if area > maxArea {
+				maxArea, next = area, j
+			}
+		}
+
+		sampled = append(sampled, points[next])
+		a = next
+	}
+
+	return append(sampled, points[len(points)-1])
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLTTBKeepsSpikes(t *testing.T) {
+	var points []graphPoints
+	for i := 0; i < 1000; i++ {
+		y := 1.0
+		if i == 437 {
+			y = 100
+		}
+		points = append(points, graphPoints{float64(i), y})
+	}
+
+	sampled := lttb(points, 50)
+	if len(sampled) != 50 {
+		t.Fatalf("Expected 50 points. Got %d instead.", len(sampled))
+	}
+	if sampled[0] != points[0] || sampled[49] != points[999] {
+		t.Errorf("Expected the first and last points to be kept. Got %v and %v instead.", sampled[0], sampled[49])
+	}
+
+	spike := false
+	for i, p := range sampled {
+		if i > 0 && p[0] <= sampled[i-1][0] {
+			t.Errorf("Expected points in chronological order. Got %v after %v.", p, sampled[i-1])
+		}
+		if p == points[437] {
+			spike = true
+		}
+	}
+	if !spike {
+		t.Errorf("Expected the spike to be kept. Got %v instead.", sampled)
+	}
+
+	if few := lttb(points[:10], 50); len(few) != 10 {
+		t.Errorf("Expected fewer points than the threshold to be kept as is. Got %d instead.", len(few))
+	}
+}
+
+func TestGraphWindow(t *testing.T) {
+	g := NewGraph("title", GCVIS_TMPL)
+	for i := 1; i <= 100; i++ {
+		g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: float64(i), Heap1: int64(i)})
+	}
+
+	req := httptest.NewRequest("GET", "/graph.json?points=10&from=50&to=80", nil)
+	w, err := graphWindow(g, req)
+	if err != nil {
+		t.Fatalf("graphWindow returned an error: %v", err)
+	}
+
+	points := w.HeapUse.Points()
+	if len(points) != 10 || points[0][0] != 50 || points[9][0] != 80 {
+		t.Errorf("Expected 10 points from 50s to 80s. Got %v instead.", points)
+	}
+	if g.HeapUse.Len() != 100 {
+		t.Errorf("Expected the graph to keep its 100 points. Got %d instead.", g.HeapUse.Len())
+	}
+
+	if _, err := json.Marshal(w); err != nil {
+		t.Errorf("Expected the window to be encoded. Got %v instead.", err)
+	}
+
+	req = httptest.NewRequest("GET", "/graph.json", nil)
+	if w, _ := graphWindow(g, req); w == g || w.HeapUse.Len() != 100 {
+		t.Errorf("Expected a snapshot of the whole graph without points.")
+	}
+
+	req = httptest.NewRequest("GET", "/graph.json?points=10&from=soon", nil)
+	if _, err := graphWindow(g, req); err == nil {
+		t.Errorf("Expected an invalid from to be rejected.")
+	}
+}
+
+func TestGraphResponseSince(t *testing.T) {
+	defer func(max int) { *maxPoints = max }(*maxPoints)
+	*maxPoints = 50
+
+	g := NewGraph("title", GCVIS_TMPL)
+	for i := 1; i <= 40; i++ {
+		g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: float64(i), Heap1: int64(i)})
+	}
+
+	req := httptest.NewRequest("GET", "/graph.json?since=", nil)
+	resp, err := graphResponse(g, req)
+	if err != nil {
+		t.Fatalf("graphResponse returned an error: %v", err)
+	}
+	full := resp.(*graphUpdate)
+	if !full.Full || full.HeapUse.Len() != 40 || full.Cursor != "40.0" {
+		t.Fatalf("Expected a full update of 40 points up to 40.0. Got %v, %d, %s instead.", full.Full, full.HeapUse.Len(), full.Cursor)
+	}
+
+	for i := 41; i <= 45; i++ {
+		g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: float64(i), Heap1: int64(i)})
+	}
+	req = httptest.NewRequest("GET", "/graph.json?since="+full.Cursor, nil)
+	resp, _ = graphResponse(g, req)
+	delta := resp.(*graphUpdate)
+	points := delta.HeapUse.Points()
+	if delta.Full || len(points) != 5 || points[0][0] != 41 || delta.Cursor != "45.0" {
+		t.Errorf("Expected the 5 points after 40s up to 45.0. Got %v, %v, %s instead.", delta.Full, points, delta.Cursor)
+	}
+	if delta.LastGC == nil || delta.LastGC.HeapGoal != 45 {
+		t.Errorf("Expected the last GC with the update. Got %v instead.", delta.LastGC)
+	}
+
+	if _, err := json.Marshal(delta); err != nil {
+		t.Errorf("Expected the update to be encoded. Got %v instead.", err)
+	}
+
+	for i := 46; i <= 100; i++ {
+		g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: float64(i), Heap1: int64(i)})
+	}
+	for _, cursor := range []string{delta.Cursor, "1000.0", "soon"} {
+		req = httptest.NewRequest("GET", "/graph.json?points=10&since="+cursor, nil)
+		resp, _ = graphResponse(g, req)
+		if u := resp.(*graphUpdate); !u.Full || u.HeapUse.Len() != 10 || u.Cursor != "100.0" {
+			t.Errorf("Expected cursor %s to get a full update of 10 points. Got %v, %d instead.", cursor, u.Full, u.HeapUse.Len())
+		}
+	}
+}
+
+func TestGraphSeriesKinds(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	start := traceStartTime()
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 1, Heap0: 10, Heap3: 5})
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 2, ElapsedTime: 2, Heap0: 10, Heap3: 5})
+	graph.AddScavengerGraphPoint(&scvgtrace{ElapsedTime: 2, Inuse: 10, Idle: 5, Sys: 20})
+	for i := 3; i <= 5; i++ {
+		graph.AddLoadPoint(start.Add(time.Duration(i)*time.Second), 100)
+	}
+	for i := 3; i <= 6; i++ {
+		graph.AddRSSPoint(start.Add(time.Duration(i)*time.Second), 80)
+	}
+
+	s := graph.Snapshot()
+	for i, r := range s.series() {
+		if added := *s.added(r.kind); r.total != added {
+			t.Errorf("Expected series %d to get a point per event of its kind, %d. Got %d instead.", i, added, r.total)
+		}
+	}
+}
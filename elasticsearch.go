@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var esURL = flag.String("es-url", "", "base URL of an Elasticsearch or OpenSearch cluster to index events in, e.g. http://localhost:9200")
+var esIndex = flag.String("es-index", "gcvis-%{+yyyy.MM.dd}", "index events are written to. %{+format} is replaced by the event date, with yyyy, yy, MM, dd and HH")
+var esUsername = flag.String("es-username", "", "username for Elasticsearch basic authentication")
+var esPassword = flag.String("es-password", "", "password for Elasticsearch basic authentication")
+var esAPIKey = flag.String("es-api-key", "", "base64 encoded Elasticsearch API key")
+var esBatchSize = flag.Int("es-batch-size", 500, "maximum number of events sent in a single bulk request")
+var esBatchWait = flag.Duration("es-batch-wait", 5*time.Second, "maximum time an event waits before being indexed")
+
+var esDatePattern = regexp.MustCompile(`%\{\+([^}]*)\}`)
+
+var esDateLayout = strings.NewReplacer("yyyy", "2006", "yy", "06", "MM", "01", "dd", "02", "HH", "15")
+
+func init() {
+	RegisterSink("elasticsearch", newElasticsearchSink)
+}
+
+// elasticsearchSink indexes the log lines with the _bulk API. Documents
+// rejected because the cluster is overloaded are sent again, the others
+// are logged and dropped.
+type elasticsearchSink struct {
+	url    string
+	header http.Header
+
+	client  *http.Client
+	batcher *batcher
+}
+
+// esDocument is a document to index in index.
+type esDocument struct {
+	index  string
+	source []byte
+}
+
+func newElasticsearchSink() (Sink, error) {
+	if *esURL == "" {
+		return nil, nil
+	}
+
+	s := &elasticsearchSink{
+		url:    strings.TrimRight(*esURL, "/") + "/_bulk",
+		header: http.Header{"Content-Type": {"application/x-ndjson"}},
+		client: &http.Client{Timeout: pushTimeout},
+	}
+	switch {
+	case *esAPIKey != "":
+		s.header.Set("Authorization", "ApiKey "+*esAPIKey)
+	case *esUsername != "":
+		credentials := base64.StdEncoding.EncodeToString([]byte(*esUsername + ":" + *esPassword))
+		s.header.Set("Authorization", "Basic "+credentials)
+	}
+	s.batcher = newBatcher(*esBatchSize, *esBatchWait, s.send)
+
+	return s, nil
+}
+
+func (s *elasticsearchSink) ConsumeGC(t *gctrace) error {
+	return s.add(newGCLogLine(t))
+}
+
+func (s *elasticsearchSink) ConsumeScvg(t *scvgtrace) error {
+	return s.add(newScvgLogLine(t))
+}
+
+func (s *elasticsearchSink) add(l *logLine) error {
+	source, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	s.batcher.Add(esDocument{index: esIndexName(*esIndex, l.Time), source: source})
+	return nil
+}
+
+// esIndexName replaces the %{+format} dates of pattern with t, in UTC.
+func esIndexName(pattern string, t time.Time) string {
+	return esDatePattern.ReplaceAllStringFunc(pattern, func(date string) string {
+		format := esDatePattern.FindStringSubmatch(date)[1]
+		return t.UTC().Format(esDateLayout.Replace(format))
+	})
+}
+
+func (s *elasticsearchSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *elasticsearchSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+func (s *elasticsearchSink) send(batch []interface{}) {
+	docs := make([]esDocument, len(batch))
+	for i, doc := range batch {
+		docs[i] = doc.(esDocument)
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d Elasticsearch documents", len(docs)), func() (bool, error) {
+		var err error
+		docs, err = s.bulk(docs)
+		return len(docs) > 0, err
+	})
+}
+
+// bulk indexes docs, returning the ones worth sending again.
+func (s *elasticsearchSink) bulk(docs []esDocument) ([]esDocument, error) {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		action, _ := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": doc.index}})
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc.source)
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequest("POST", s.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.header {
+		req.Header[k] = v
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return docs, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5 {
+			return docs, err
+		}
+		return nil, err
+	}
+
+	var result struct {
+		Errors bool
+		Items  []map[string]struct {
+			Status int
+			Error  json.RawMessage
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid bulk response: %v", err)
+	}
+	if !result.Errors {
+		return nil, nil
+	}
+
+	var retry []esDocument
+	var dropped int
+	var lastErr json.RawMessage
+	for i, item := range result.Items {
+		if i >= len(docs) {
+			break
+		}
+		status := item["index"]
+		switch {
+		case status.Status == http.StatusTooManyRequests || status.Status/100 == 5:
+			retry = append(retry, docs[i])
+			lastErr = status.Error
+		case status.Status/100 != 2:
+			dropped++
+			lastErr = status.Error
+		}
+	}
+	if dropped > 0 {
+		errorf("dropping %d Elasticsearch documents: %s", dropped, lastErr)
+	}
+	if len(retry) > 0 {
+		return retry, fmt.Errorf("%d documents rejected: %s", len(retry), lastErr)
+	}
+	return nil, nil
+}
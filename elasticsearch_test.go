@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestESIndexName(t *testing.T) {
+	ts := time.Date(2021, 11, 3, 14, 21, 38, 0, time.UTC)
+	for pattern, expected := range map[string]string{
+		"gcvis-%{+yyyy.MM.dd}":   "gcvis-2021.11.03",
+		"gcvis-%{+yy.MM}-%{+HH}": "gcvis-21.11-14",
+		"gcvis":                  "gcvis",
+	} {
+		if got := esIndexName(pattern, ts); got != expected {
+			t.Errorf("Expected index %s for %s. Got %s instead.", expected, pattern, got)
+		}
+	}
+}
+
+func TestElasticsearchSinkBulk(t *testing.T) {
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/_bulk" || req.Header.Get("Authorization") != "ApiKey secret" {
+			t.Errorf("Unexpected request %s with authorization %q.", req.URL.Path, req.Header.Get("Authorization"))
+		}
+
+		var lines []string
+		scanner := bufio.NewScanner(req.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		requests = append(requests, lines)
+
+		if len(requests) == 1 {
+			w.Write([]byte(`{"errors": true, "items": [
+				{"index": {"status": 201}},
+				{"index": {"status": 429, "error": {"type": "es_rejected_execution_exception"}}},
+				{"index": {"status": 400, "error": {"type": "mapper_parsing_exception"}}}
+			]}`))
+			return
+		}
+		w.Write([]byte(`{"errors": false, "items": [{"index": {"status": 201}}]}`))
+	}))
+	defer server.Close()
+
+	saved := []string{*esURL, *esAPIKey}
+	*esURL, *esAPIKey = server.URL+"/", "secret"
+	defer func() { *esURL, *esAPIKey = saved[0], saved[1] }()
+
+	sink, err := newElasticsearchSink()
+	if err != nil {
+		t.Fatalf("newElasticsearchSink returned an error: %v", err)
+	}
+	for i := int64(1); i <= 3; i++ {
+		sink.ConsumeGC(&gctrace{NumGC: i})
+	}
+	sink.Close()
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 bulk requests. Got %d instead.", len(requests))
+	}
+	if len(requests[0]) != 6 || len(requests[1]) != 2 {
+		t.Fatalf("Expected 3 documents then 1. Got %q instead.", requests)
+	}
+
+	var action map[string]map[string]string
+	if err := json.Unmarshal([]byte(requests[0][0]), &action); err != nil || !strings.HasPrefix(action["index"]["_index"], "gcvis-") {
+		t.Errorf("Expected an index action in a gcvis- index. Got %s instead.", requests[0][0])
+	}
+
+	var l logLine
+	if err := json.Unmarshal([]byte(requests[1][1]), &l); err != nil || l.GC == nil || l.GC.NumGC != 2 {
+		t.Errorf("Expected the rejected GC 2 to be sent again. Got %s instead.", requests[1][1])
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables setting flags.
+const envPrefix = "GCVIS_"
+
+// envNames are the environment variables of the flags whose names are too
+// short to tell what they set.
+var envNames = map[string]string{
+	"i": "GCVIS_IFACE",
+	"p": "GCVIS_PORT",
+	"s": "GCVIS_SERVICE",
+}
+
+// repeatable is implemented by the flags that can be repeated, which take
+// comma separated values from the environment.
+type repeatable interface {
+	flag.Value
+	repeatable()
+}
+
+// envName returns the environment variable setting the flag name, e.g.
+// GCVIS_LOKI_URL for -loki-url.
+func envName(name string) string {
+	if env, ok := envNames[name]; ok {
+		return env
+	}
+	return envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// loadEnv sets the flags of fs not set on the command line from the
+// environment, getenv looking variables up. Repeatable flags take comma
+// separated values, e.g. GCVIS_LABEL=env=prod,team=core.
+func loadEnv(fs *flag.FlagSet, getenv func(string) string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value := getenv(envName(f.Name))
+		if err != nil || set[f.Name] || value == "" {
+			return
+		}
+
+		values := []string{value}
+		if _, ok := f.Value.(repeatable); ok {
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if serr := fs.Set(f.Name, strings.TrimSpace(v)); serr != nil {
+				err = fmt.Errorf("invalid value %q for %s in %s: %v", v, f.Name, envName(f.Name), serr)
+				return
+			}
+		}
+	})
+	return err
+}
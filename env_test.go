@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"p":               "GCVIS_PORT",
+		"s":               "GCVIS_SERVICE",
+		"loki-url":        "GCVIS_LOKI_URL",
+		"loki-batch-wait": "GCVIS_LOKI_BATCH_WAIT",
+	} {
+		if env := envName(name); env != expected {
+			t.Errorf("Expected -%s to be set by %s. Got %s instead.", name, expected, env)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	env := map[string]string{
+		"GCVIS_LOKI_URL":          "http://loki:3100",
+		"GCVIS_LOKI_TENANT":       "team-a",
+		"GCVIS_LOKI_BATCH_WAIT":   "3s",
+		"GCVIS_METRICS":           "true",
+		"GCVIS_LABEL":             "env=prod, team=core",
+		"GCVIS_WEBHOOK_THRESHOLD": "stw_mark_clock_milliseconds>5",
+	}
+
+	fs, url, tenant, wait, metrics, labels, thresholds := configFlags()
+	if err := fs.Parse([]string{"-loki-tenant=team-b"}); err != nil {
+		t.Fatalf("Parse returned an error: %v", err)
+	}
+	if err := loadEnv(fs, func(name string) string { return env[name] }); err != nil {
+		t.Fatalf("loadEnv returned an error: %v", err)
+	}
+
+	if *url != "http://loki:3100" || *wait != 3*time.Second || !*metrics {
+		t.Errorf("Expected the environment to set the flags. Got %q %v %v instead.", *url, *wait, *metrics)
+	}
+	if *tenant != "team-b" {
+		t.Errorf("Expected the command line to take precedence. Got %q instead.", *tenant)
+	}
+	if labels.String() != "env=prod,team=core" || len(*thresholds) != 1 {
+		t.Errorf("Expected repeatable flags to be split. Got %v %v instead.", labels, thresholds)
+	}
+
+	// The config file does not override the environment.
+	path := writeConfig(t, "gcvis.yaml", "loki-url: http://localhost:3100\nmetrics: false\n")
+	if err := loadConfig(fs, path); err != nil {
+		t.Fatalf("loadConfig returned an error: %v", err)
+	}
+	if *url != "http://loki:3100" || !*metrics {
+		t.Errorf("Expected the environment to take precedence over the config file. Got %q %v instead.", *url, *metrics)
+	}
+}
+
+func TestLoadEnvInvalid(t *testing.T) {
+	fs, _, _, _, _, _, _ := configFlags()
+	err := loadEnv(fs, func(name string) string {
+		if name == "GCVIS_LOKI_BATCH_WAIT" {
+			return "soon"
+		}
+		return ""
+	})
+	if err == nil {
+		t.Errorf("Expected an invalid value to be rejected.")
+	}
+}
+
+func TestRepeatableFlags(t *testing.T) {
+	flag.VisitAll(func(f *flag.Flag) {
+		_, ok := f.Value.(repeatable)
+		if strings.Contains(f.Usage, "Can be repeated") != ok {
+			t.Errorf("Expected -%s to take comma separated values from %s if and only if it can be repeated.", f.Name, envName(f.Name))
+		}
+	})
+}
+
+func TestLoadEnvRepeatable(t *testing.T) {
+	fs := flag.NewFlagSet("gcvis", flag.ContinueOnError)
+	var slos sloFlag
+	fs.Var(&slos, "slo", "")
+	var fields computeFlag
+	fs.Var(&fields, "compute", "")
+
+	env := map[string]string{
+		"GCVIS_SLO":     "p99_stw<5ms over 5m,max_stw<10ms over 1m",
+		"GCVIS_COMPUTE": "a=heap_goal-heap_live,b=a*2",
+	}
+	if err := loadEnv(fs, func(name string) string { return env[name] }); err != nil {
+		t.Fatalf("loadEnv returned an error: %v", err)
+	}
+	if len(slos) != 2 || len(fields) != 2 {
+		t.Errorf("Expected both objectives and fields to be set. Got %v and %v instead.", slos.String(), fields.String())
+	}
+}
+
+func TestLoadEnvSinks(t *testing.T) {
+	fs := flag.NewFlagSet("gcvis", flag.ContinueOnError)
+	var names sinksFlag
+	fs.Var(&names, "sinks", "")
+
+	env := map[string]string{"GCVIS_SINKS": "prometheus, journald"}
+	if err := loadEnv(fs, func(name string) string { return env[name] }); err != nil {
+		t.Fatalf("loadEnv returned an error: %v", err)
+	}
+	if names.String() != "prometheus,journald" {
+		t.Errorf("Expected the sinks to be listed. Got %q instead.", names.String())
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/gmaz42/gcvis/parse"
+)
+
+// gcEvent is a GC event of the graph, as listed at /api/v1/events: the
+// fields parsed from its trace, at its time on the graph.
+type gcEvent struct {
+	Time    float64 // seconds
+	PauseMs float64 // stop the world, sweep and mark
+	*parse.GCTrace
+}
+
+// eventFilter selects the GC events listed.
+type eventFilter struct {
+	from, to   float64 // seconds, included
+	minPauseMs float64 // of the stop the world pauses, sweep and mark
+	forced     bool    // only the cycles forced, e.g. by runtime.GC
+}
+
+// Events returns the GC events kept of the graph, oldest first, that f
+// selects.
+func (g *Graph) Events(f eventFilter) []gcEvent {
+	g.mu.Lock()
+	g.applyPending()
+	traces := g.gcTraces.between(f.from, f.to)
+	g.mu.Unlock()
+
+	events := []gcEvent{}
+	for _, t := range traces {
+		pause := t.gc.STWSclock + t.gc.STWMclock
+		if pause < f.minPauseMs || (f.forced && !t.gc.Forced) {
+			continue
+		}
+		events = append(events, gcEvent{Time: t.elapsed, PauseMs: pause, GCTrace: t.gc})
+	}
+	return events
+}
+
+// handleEvents serves the GC events of the window of the graph between the
+// from and to query parameters, or of the last one, as windowParams reads
+// them, whose pause lasted at least the min_pause query parameter, in
+// milliseconds, and which were forced if the forced one is true.
+func (h *HttpServer) handleEvents(w http.ResponseWriter, req *http.Request) {
+	from, to, err := h.windowParams(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f := eventFilter{from: from, to: to}
+
+	query := req.URL.Query()
+	if v := query.Get("min_pause"); v != "" {
+		if f.minPauseMs, err = strconv.ParseFloat(v, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid min_pause %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("forced"); v != "" {
+		if f.forced, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, fmt.Sprintf("invalid forced %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.graph.Events(f))
+}
+
+var eventsTmpl = template.Must(template.New("events").Funcs(tmplFuncs).Parse(EVENTS_TMPL))
+
+// handleEventsPage serves the table of the GC events, which pulls them from
+// /api/v1/events.
+func (h *HttpServer) handleEventsPage(w http.ResponseWriter, req *http.Request) {
+	if err := eventsTmpl.Execute(w, struct{ Title string }{h.graph.Title}); err != nil {
+		errorf("cannot write the events page: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newEventsGraph() *Graph {
+	g := NewGraph("fake title", GCVIS_TMPL)
+	for i := int64(1); i <= 5; i++ {
+		g.AddGCTraceGraphPoint(&gctrace{
+			ElapsedTime: float64(10 * i),
+			NumGC:       i,
+			STWSclock:   float64(i),
+			STWMclock:   0.5,
+			Heap0:       40,
+			Forced:      i == 2 || i == 4,
+		})
+	}
+	return g
+}
+
+func eventNumbers(events []gcEvent) []int64 {
+	var numbers []int64
+	for _, e := range events {
+		numbers = append(numbers, e.NumGC)
+	}
+	return numbers
+}
+
+func TestGraphEvents(t *testing.T) {
+	g := newEventsGraph()
+	all := eventFilter{from: math.Inf(-1), to: math.Inf(1)}
+
+	events := g.Events(all)
+	if len(events) != 5 || events[0].Time != 10 || events[0].PauseMs != 1.5 || events[0].Heap0 != 40 {
+		t.Errorf("Expected the 5 events, the first at 10s with a pause of 1.5ms. Got %+v instead.", events)
+	}
+
+	for _, c := range []struct {
+		filter   eventFilter
+		expected string
+	}{
+		{eventFilter{from: 20, to: 40}, "[2 3 4]"},
+		{eventFilter{from: math.Inf(-1), to: math.Inf(1), minPauseMs: 3.5}, "[3 4 5]"},
+		{eventFilter{from: math.Inf(-1), to: math.Inf(1), forced: true}, "[2 4]"},
+		{eventFilter{from: 30, to: math.Inf(1), minPauseMs: 3, forced: true}, "[4]"},
+		{eventFilter{from: math.Inf(-1), to: math.Inf(1), minPauseMs: 10}, "[]"},
+	} {
+		if got := fmt.Sprint(eventNumbers(g.Events(c.filter))); got != c.expected {
+			t.Errorf("Expected events %s for %+v. Got %s instead.", c.expected, c.filter, got)
+		}
+	}
+}
+
+func TestHttpServerEvents(t *testing.T) {
+	server := NewHttpServer("127.0.0.1", "0", newEventsGraph())
+
+	go server.Start()
+	defer server.Close()
+
+	response, err := http.Get(server.Url() + "api/v1/events?last=25s&forced=true")
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	defer response.Body.Close()
+
+	var events []gcEvent
+	if err := json.NewDecoder(response.Body).Decode(&events); err != nil {
+		t.Fatalf("Response is not valid JSON: %v", err)
+	}
+	if len(events) != 1 || events[0].NumGC != 4 || !events[0].Forced {
+		t.Errorf("Expected the forced event of the last 25s, the 4th. Got %+v instead.", events)
+	}
+
+	for _, query := range []string{"min_pause=long", "forced=maybe", "from=yesterday", "last=-1s"} {
+		response, err := http.Get(server.Url() + "api/v1/events?" + query)
+		if err != nil {
+			t.Fatalf("HTTP request returned an error: %v", err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s. Got %d instead.", query, response.StatusCode)
+		}
+	}
+}
+
+func TestHttpServerEventsPage(t *testing.T) {
+	server := NewHttpServer("127.0.0.1", "0", newEventsGraph())
+
+	go server.Start()
+	defer server.Close()
+
+	response, err := http.Get(server.Url() + "events")
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("Error while reading response body: %v", err)
+	}
+	if !strings.Contains(string(body), "<title>gcvis - fake title - events</title>") || !strings.Contains(string(body), "/api/v1/events") {
+		t.Errorf("Expected the events page of the graph. Got %s instead.", body)
+	}
+}
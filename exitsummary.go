@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+)
+
+var exitSummaryOut = flag.String("exit-summary", "", "path of a JSON file to write a summary of the whole run to when gcvis exits, for benchmark harnesses, or stdout or stderr")
+
+// exitSummary sums up a whole run. Pauses are the stop the world time of
+// the cycles.
+type exitSummary struct {
+	NumGC                int64              `json:"gc_count"`
+	DurationSeconds      float64            `json:"duration_seconds"`
+	TotalPauseMs         float64            `json:"total_pause_ms"`
+	AvgPauseMs           float64            `json:"avg_pause_ms"`
+	PausePercentilesMs   map[string]float64 `json:"pause_percentiles_ms"` // p50, p90, p95, p99 and max
+	HeapHighWaterMB      int64              `json:"heap_high_water_mb"`
+	HeapMinMB            int64              `json:"heap_min_mb"`
+	AllocRateMBPerSecond float64            `json:"alloc_rate_mb_per_second"`
+	GOGCAdvice           *gogcAdvice        `json:"gogc_advice,omitempty"`
+	GOMEMLIMITAdvice     *memLimitAdvice    `json:"gomemlimit_advice,omitempty"`
+	Benchmarks           []goBenchmark      `json:"benchmarks,omitempty"` // of go test -bench
+}
+
+func newExitSummary(s *runStats) *exitSummary {
+	return &exitSummary{
+		NumGC:           s.NumGC,
+		DurationSeconds: s.Duration().Seconds(),
+		TotalPauseMs:    s.totalPause(),
+		AvgPauseMs:      s.avgPause(),
+		PausePercentilesMs: map[string]float64{
+			"p50": s.pausePercentile(50),
+			"p90": s.pausePercentile(90),
+			"p95": s.pausePercentile(95),
+			"p99": s.pausePercentile(99),
+			"max": s.pausePercentile(100),
+		},
+		HeapHighWaterMB:      s.HeapMax,
+		HeapMinMB:            s.HeapMin,
+		AllocRateMBPerSecond: s.allocRate(),
+		GOGCAdvice:           s.gogcAdvice(),
+		GOMEMLIMITAdvice:     s.memLimitAdvice(),
+		Benchmarks:           s.benchmarks.done,
+	}
+}
+
+// writeExitSummary writes the summary of s to path, replacing the file.
+func writeExitSummary(path string, s *runStats) error {
+	b, err := json.MarshalIndent(newExitSummary(s), "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	switch path {
+	case "stdout":
+		_, err = os.Stdout.Write(b)
+	case "stderr":
+		_, err = os.Stderr.Write(b)
+	default:
+		err = ioutil.WriteFile(path, b, 0644)
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteExitSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := newRunStats()
+	s.addGC(&gctrace{ElapsedTime: 1, STWSclock: 1, STWMclock: 1, Heap0: 10, Heap3: 4})
+	s.addGC(&gctrace{ElapsedTime: 3, STWSclock: 2, STWMclock: 4, Heap0: 24, Heap3: 6})
+
+	path := filepath.Join(dir, "summary.json")
+	if err := writeExitSummary(path, s); err != nil {
+		t.Fatalf("writeExitSummary returned an error: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary exitSummary
+	if err := json.Unmarshal(b, &summary); err != nil {
+		t.Fatalf("Expected a JSON summary. Got %v instead: %s", err, b)
+	}
+
+	if summary.NumGC != 2 || summary.DurationSeconds != 2 || summary.TotalPauseMs != 8 || summary.HeapHighWaterMB != 24 {
+		t.Errorf("Expected 2 cycles over 2s, pausing 8ms, up to 24MB. Got %+v instead.", summary)
+	}
+	if summary.PausePercentilesMs["p99"] != 6 || summary.PausePercentilesMs["p50"] != 2 {
+		t.Errorf("Expected a p50 of 2ms and a p99 of 6ms. Got %v instead.", summary.PausePercentilesMs)
+	}
+	// 24MB at the start of the second cycle, 4MB live after the first.
+	if summary.AllocRateMBPerSecond != 10 {
+		t.Errorf("Expected 10MB allocated per second. Got %v instead.", summary.AllocRateMBPerSecond)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var exitTable = flag.Bool("exit-table", true, "print a table summing up the run when gcvis exits, if the standard error is a terminal and -q is not set")
+
+// printExitTable prints the table summing up the run, if the standard
+// error is a terminal someone is watching.
+func printExitTable(stats *runStats) {
+	if !*exitTable || *quiet || !terminal.IsTerminal(int(os.Stderr.Fd())) {
+		return
+	}
+	writeExitTable(os.Stderr, stats)
+}
+
+// writeExitTable writes the table summing up stats, so that quick runs do
+// not need the browser.
+func writeExitTable(w io.Writer, stats *runStats) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\nGC cycles\tforced\tmin pause\tavg pause\tp95 pause\tmax pause\theap\tscavenger released\tduration\t\n")
+	fmt.Fprintf(tw, "%d\t%d\t%.3fms\t%.3fms\t%.3fms\t%.3fms\t%dMB-%dMB\t%s\t%.1fs\t\n",
+		stats.NumGC,
+		stats.Forced,
+		stats.pausePercentile(0),
+		stats.avgPause(),
+		stats.pausePercentile(95),
+		stats.pausePercentile(100),
+		stats.HeapMin, stats.HeapMax,
+		scvgReleasedCell(stats),
+		stats.Duration().Seconds())
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(stats.benchmarks.done) > 0 {
+		if err := writeBenchmarks(w, stats.benchmarks.done); err != nil {
+			return err
+		}
+	}
+
+	if advice := stats.gogcAdvice(); advice != nil {
+		if _, err := fmt.Fprintf(w, "\nGOGC advice: %s\n", advice); err != nil {
+			return err
+		}
+	}
+	if advice := stats.memLimitAdvice(); advice != nil {
+		if _, err := fmt.Fprintf(w, "\nGOMEMLIMIT advice: %s\n", advice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scvgReleasedCell returns the memory released by the scavenger, or - if
+// there was no scavenger trace.
+func scvgReleasedCell(stats *runStats) string {
+	if stats.NumScvg == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%dMB", stats.ScvgReleased)
+}
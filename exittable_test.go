@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteExitTable(t *testing.T) {
+	defer func(files []string) { cgroupMemoryLimits = files }(cgroupMemoryLimits)
+	cgroupMemoryLimits = nil
+
+	s := newRunStats()
+	s.addGC(&gctrace{ElapsedTime: 1, STWSclock: 0.5, STWMclock: 0.5, Heap0: 10, Heap3: 4})
+	s.addGC(&gctrace{ElapsedTime: 3, STWSclock: 1, STWMclock: 2, Heap0: 24, Heap3: 6, Forced: true})
+	s.addScvg(&scvgtrace{Released: 3})
+
+	var b bytes.Buffer
+	if err := writeExitTable(&b, s); err != nil {
+		t.Fatalf("writeExitTable returned an error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a header and a row. Got %q instead.", b.String())
+	}
+	row := strings.Fields(lines[1])
+	expected := []string{"2", "1", "1.000ms", "2.000ms", "3.000ms", "3.000ms", "4MB-24MB", "3MB", "2.0s"}
+	if strings.Join(row, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected the row %v. Got %v instead.", expected, row)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expr is an arithmetic and boolean expression on the metrics of an event,
+// e.g. stw > 1 && heap_live / heap_goal > 0.9. Booleans are 1 or 0, and a
+// variable the event has no value of is NaN, making comparisons false.
+type expr interface {
+	eval(vars map[string]float64) float64
+}
+
+type (
+	exprNumber float64
+	exprVar    string
+	exprNot    struct{ x expr }
+	exprNeg    struct{ x expr }
+	exprBinary struct {
+		op   string
+		x, y expr
+	}
+)
+
+func (e exprNumber) eval(map[string]float64) float64 { return float64(e) }
+
+func (e exprVar) eval(vars map[string]float64) float64 {
+	if v, ok := vars[string(e)]; ok {
+		return v
+	}
+	return math.NaN()
+}
+
+func (e exprNot) eval(vars map[string]float64) float64 { return exprBool(!exprTrue(e.x.eval(vars))) }
+
+func (e exprNeg) eval(vars map[string]float64) float64 { return -e.x.eval(vars) }
+
+func (e exprBinary) eval(vars map[string]float64) float64 {
+	x := e.x.eval(vars)
+	switch e.op {
+	case "&&":
+		return exprBool(exprTrue(x) && exprTrue(e.y.eval(vars)))
+	case "||":
+		return exprBool(exprTrue(x) || exprTrue(e.y.eval(vars)))
+	}
+
+	y := e.y.eval(vars)
+	switch e.op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/":
+		return x / y
+	case "<":
+		return exprBool(x < y)
+	case "<=":
+		return exprBool(x <= y)
+	case ">":
+		return exprBool(x > y)
+	case ">=":
+		return exprBool(x >= y)
+	case "==":
+		return exprBool(x == y)
+	case "!=":
+		return exprBool(x != y && !math.IsNaN(x) && !math.IsNaN(y))
+	}
+	panic("gcvis: unknown operator " + e.op)
+}
+
+func exprBool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// exprTrue tells whether v is true: neither 0 nor NaN.
+func exprTrue(v float64) bool {
+	return v != 0 && !math.IsNaN(v)
+}
+
+// exprPrecedence are the binary operators by increasing precedence.
+var exprPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"<=", ">=", "==", "!=", "<", ">"},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+// parseExpr parses s, whose variables must be among known.
+func parseExpr(s string, known map[string]bool) (expr, error) {
+	p := &exprParser{tokens: tokenizeExpr(s), known: known}
+	e, err := p.parse(0)
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", s, err)
+	}
+	return e, nil
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+	known  map[string]bool
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// parse parses the binary operators of level and above.
+func (p *exprParser) parse(level int) (expr, error) {
+	if level == len(exprPrecedence) {
+		return p.unary()
+	}
+
+	x, err := p.parse(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if !containsString(exprPrecedence[level], op) {
+			return x, nil
+		}
+		p.pos++
+		y, err := p.parse(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = exprBinary{op, x, y}
+	}
+}
+
+func (p *exprParser) unary() (expr, error) {
+	tok := p.peek()
+	p.pos++
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end")
+	case tok == "!":
+		x, err := p.unary()
+		return exprNot{x}, err
+	case tok == "-":
+		x, err := p.unary()
+		return exprNeg{x}, err
+	case tok == "(":
+		x, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return x, nil
+	case isExprIdent(tok):
+		if !p.known[tok] {
+			return nil, fmt.Errorf("unknown variable %s", tok)
+		}
+		return exprVar(tok), nil
+	}
+
+	v, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	return exprNumber(v), nil
+}
+
+// tokenizeExpr splits s into numbers, identifiers, operators and
+// parentheses.
+func tokenizeExpr(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case unicode.IsLetter(c) || c == '_' || unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || strings.ContainsRune("_.", rune(s[j]))) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+			continue
+		}
+
+		tok := s[i : i+1]
+		if i+1 < len(s) {
+			switch two := s[i : i+2]; two {
+			case "&&", "||", "<=", ">=", "==", "!=":
+				tok = two
+			}
+		}
+		tokens = append(tokens, tok)
+		i += len(tok)
+	}
+	return tokens
+}
+
+func isExprIdent(tok string) bool {
+	c := rune(tok[0])
+	return unicode.IsLetter(c) || c == '_'
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	known := map[string]bool{"stw": true, "heap_live": true, "heap_goal": true}
+	vars := map[string]float64{"stw": 2, "heap_live": 90, "heap_goal": 100}
+
+	for s, expected := range map[string]float64{
+		"stw > 1":                            1,
+		"stw>1 && heap_live > 100":           0,
+		"stw > 5 || heap_live/heap_goal>=.9": 1,
+		"!(stw > 1)":                         0,
+		"heap_goal - heap_live * 2 / 4":      55,
+		"(heap_goal - heap_live) * 2":        20,
+		"-stw + 3":                           1,
+		"stw == 2 && stw != 3":               1,
+		"1e3":                                1000,
+	} {
+		e, err := parseExpr(s, known)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %q: %v", s, err)
+			continue
+		}
+		if v := e.eval(vars); v != expected {
+			t.Errorf("Expected %q to be %v. Got %v instead.", s, expected, v)
+		}
+	}
+
+	for _, invalid := range []string{"", "stw >", "stw > 1)", "(stw > 1", "pause > 1", "stw $ 1", "1 2"} {
+		if _, err := parseExpr(invalid, known); err == nil {
+			t.Errorf("Expected %q to be invalid.", invalid)
+		}
+	}
+}
+
+func TestExprMissingVariable(t *testing.T) {
+	known := map[string]bool{"stw": true}
+	for s, expected := range map[string]float64{"stw > 1": 0, "stw < 1": 0, "stw != 1": 0, "!(stw > 1)": 1} {
+		e, err := parseExpr(s, known)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := e.eval(map[string]float64{}); v != expected {
+			t.Errorf("Expected %q of an event without stw to be %v. Got %v instead.", s, expected, v)
+		}
+	}
+
+	e, _ := parseExpr("stw * 2", known)
+	if v := e.eval(nil); !math.IsNaN(v) {
+		t.Errorf("Expected NaN. Got %v instead.", v)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// failIfExitCode is the exit code of gcvis when a -fail-if assertion fails.
+const failIfExitCode = 3
+
+var failIfs assertionsFlag
+
+func init() {
+	flag.Var(&failIfs, "fail-if", "assertion on the whole run, e.g. 'p99_stw>5ms' or 'heap_max>1GiB', failing gcvis with exit code 3 when true at exit. Can be repeated. See the README for the statistics")
+}
+
+// runStatistic is a statistic of a run assertions can be made on. Values
+// are in unit: milliseconds, seconds, megabytes, or none for counts.
+type runStatistic struct {
+	unit  string
+	value func(s *runStats) float64
+}
+
+var runStatistics = map[string]runStatistic{
+	"gc_count":  {"", func(s *runStats) float64 { return float64(s.NumGC) }},
+	"p50_stw":   {"ms", func(s *runStats) float64 { return s.pausePercentile(50) }},
+	"p90_stw":   {"ms", func(s *runStats) float64 { return s.pausePercentile(90) }},
+	"p95_stw":   {"ms", func(s *runStats) float64 { return s.pausePercentile(95) }},
+	"p99_stw":   {"ms", func(s *runStats) float64 { return s.pausePercentile(99) }},
+	"max_stw":   {"ms", func(s *runStats) float64 { return s.pausePercentile(100) }},
+	"avg_stw":   {"ms", func(s *runStats) float64 { return s.avgPause() }},
+	"total_stw": {"ms", func(s *runStats) float64 { return s.totalPause() }},
+	"heap_min":  {"MB", func(s *runStats) float64 { return float64(s.HeapMin) }},
+	"heap_max":  {"MB", func(s *runStats) float64 { return float64(s.HeapMax) }},
+	"duration":  {"s", func(s *runStats) float64 { return s.Duration().Seconds() }},
+}
+
+// assertion fails a run when a statistic is above, or below, a value.
+type assertion struct {
+	Expr  string // as given to -fail-if
+	Stat  string
+	Below bool
+	Value float64 // in the unit of the statistic
+}
+
+// parseAssertion parses stat>value or stat<value, value being a number in
+// the unit of the statistic, or a duration or size with its own unit, e.g.
+// 5ms or 1GiB.
+func parseAssertion(s string) (assertion, error) {
+	i := strings.IndexAny(s, "<>")
+	if i <= 0 {
+		return assertion{}, fmt.Errorf("invalid assertion %q, expected statistic>value or statistic<value", s)
+	}
+
+	a := assertion{Expr: s, Stat: strings.TrimSpace(s[:i]), Below: s[i] == '<'}
+	stat, ok := runStatistics[a.Stat]
+	if !ok {
+		return assertion{}, fmt.Errorf("invalid assertion %q, unknown statistic %s, expected one of %s", s, a.Stat, strings.Join(runStatisticNames(), ", "))
+	}
+
+	var err error
+	if a.Value, err = parseStatValue(strings.TrimSpace(s[i+1:]), stat.unit); err != nil {
+		return assertion{}, fmt.Errorf("invalid assertion %q: %v", s, err)
+	}
+	return a, nil
+}
+
+func runStatisticNames() []string {
+	names := make([]string, 0, len(runStatistics))
+	for name := range runStatistics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sizeUnits are the size units of values, in megabytes. Like in the
+// traces, KB, MB and GB are powers of 1024.
+var sizeUnits = []struct {
+	suffix string
+	mb     float64
+}{
+	{"KiB", 1.0 / 1024}, {"MiB", 1}, {"GiB", 1024}, {"TiB", 1024 * 1024},
+	{"KB", 1.0 / 1024}, {"MB", 1}, {"GB", 1024}, {"TB", 1024 * 1024},
+	{"B", 1.0 / (1 << 20)},
+}
+
+// parseStatValue parses a value of a statistic in unit.
+func parseStatValue(s, unit string) (float64, error) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, nil
+	}
+
+	switch unit {
+	case "ms", "s":
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		if unit == "s" {
+			return d.Seconds(), nil
+		}
+		return float64(d) / float64(time.Millisecond), nil
+	case "MB":
+		for _, u := range sizeUnits {
+			if strings.HasSuffix(s, u.suffix) {
+				v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), 64)
+				if err != nil {
+					return 0, fmt.Errorf("invalid size %q", s)
+				}
+				return v * u.mb, nil
+			}
+		}
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return 0, fmt.Errorf("invalid number %q", s)
+}
+
+// failedBy reports whether the assertion fails for value.
+func (a assertion) failedBy(value float64) bool {
+	if a.Below {
+		return value < a.Value
+	}
+	return value > a.Value
+}
+
+// failedAssertions returns a message for each of assertions failing for
+// stats.
+func failedAssertions(stats *runStats, assertions []assertion) []string {
+	var failed []string
+	for _, a := range assertions {
+		stat := runStatistics[a.Stat]
+		if v := stat.value(stats); a.failedBy(v) {
+			failed = append(failed, fmt.Sprintf("%s failed: %s is %s%s", a.Expr, a.Stat, strconv.FormatFloat(v, 'f', -1, 64), stat.unit))
+		}
+	}
+	return failed
+}
+
+// assertionsFlag collects repeated -fail-if flags.
+type assertionsFlag []assertion
+
+func (f *assertionsFlag) String() string {
+	s := make([]string, len(*f))
+	for i, a := range *f {
+		s[i] = a.Expr
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *assertionsFlag) Set(value string) error {
+	a, err := parseAssertion(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, a)
+	return nil
+}
+
+func (f *assertionsFlag) repeatable() {}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAssertion(t *testing.T) {
+	for s, expected := range map[string]assertion{
+		"p99_stw>5ms":   {Expr: "p99_stw>5ms", Stat: "p99_stw", Value: 5},
+		"max_stw>1.5s":  {Expr: "max_stw>1.5s", Stat: "max_stw", Value: 1500},
+		"avg_stw > 2":   {Expr: "avg_stw > 2", Stat: "avg_stw", Value: 2},
+		"heap_max>1GiB": {Expr: "heap_max>1GiB", Stat: "heap_max", Value: 1024},
+		"heap_min<512k": {},
+		"gc_count<10":   {Expr: "gc_count<10", Stat: "gc_count", Below: true, Value: 10},
+		"p99_pause>5ms": {},
+		"p99_stw=5ms":   {},
+		"duration>1GiB": {},
+	} {
+		a, err := parseAssertion(s)
+		if expected.Stat == "" {
+			if err == nil {
+				t.Errorf("Expected %q to be rejected. Got %+v instead.", s, a)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(a, expected) {
+			t.Errorf("Expected %q to be parsed as %+v. Got %+v, %v instead.", s, expected, a, err)
+		}
+	}
+}
+
+func TestFailedAssertions(t *testing.T) {
+	s := newRunStats()
+	s.addGC(&gctrace{ElapsedTime: 1, STWSclock: 2, STWMclock: 5, Heap0: 2048})
+
+	var assertions assertionsFlag
+	for _, expr := range []string{"p99_stw>5ms", "heap_max>1GiB", "gc_count<1", "max_stw>10ms"} {
+		if err := assertions.Set(expr); err != nil {
+			t.Fatalf("Set(%q) returned an error: %v", expr, err)
+		}
+	}
+
+	failed := failedAssertions(s, assertions)
+	expected := []string{
+		"p99_stw>5ms failed: p99_stw is 7ms",
+		"heap_max>1GiB failed: heap_max is 2048MB",
+	}
+	if !reflect.DeepEqual(failed, expected) {
+		t.Errorf("Expected %q. Got %q instead.", expected, failed)
+	}
+}
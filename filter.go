@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var filterExpr = flag.String("filter", "", "expression the events must match to reach the sinks, e.g. 'stw_ms > 1 || heap_live > 512', on the -alert metrics, the exported metrics without their gcvis_ prefix and the -compute fields. The graph and the -alert rules still get every event")
+var computedFields computeFlag
+
+func init() {
+	flag.Var(&computedFields, "compute", "name=expression field computed for every event, e.g. 'heap_headroom=heap_goal-heap_live', exported as gcvis_name and added to the log lines. Can be repeated, an expression using the fields computed before it.")
+}
+
+// computedField is a field added to the events, computed from their
+// metrics.
+type computedField struct {
+	Name string
+	Expr string
+	expr expr
+}
+
+// computeFlag collects repeated -compute flags.
+type computeFlag []computedField
+
+func (f *computeFlag) String() string {
+	s := make([]string, len(*f))
+	for i, c := range *f {
+		s[i] = c.Name + "=" + c.Expr
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *computeFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 || !isExprIdent(value[:i]) {
+		return fmt.Errorf("invalid field %q, expected name=expression", value)
+	}
+
+	c := computedField{Name: strings.TrimSpace(value[:i]), Expr: strings.TrimSpace(value[i+1:])}
+	known := f.variables()
+	if known[c.Name] {
+		return fmt.Errorf("invalid field %q, %s is already a variable", value, c.Name)
+	}
+
+	var err error
+	if c.expr, err = parseExpr(c.Expr, known); err != nil {
+		return err
+	}
+	*f = append(*f, c)
+	return nil
+}
+
+func (f *computeFlag) repeatable() {}
+
+// variables returns the names the expressions can use: those of the
+// metrics, and of the fields computed so far.
+func (f computeFlag) variables() map[string]bool {
+	known := map[string]bool{}
+	for name := range eventVars(&gctrace{}, &scvgtrace{}) {
+		known[name] = true
+	}
+	for _, c := range f {
+		known[c.Name] = true
+	}
+	return known
+}
+
+// eventVars returns the variables of the event t, or s if t is nil, for
+// the expressions. The -alert metrics of pauses are also named after their
+// unit, e.g. stw_ms.
+func eventVars(t *gctrace, s *scvgtrace) map[string]float64 {
+	vars := map[string]float64{}
+	var metrics []metric
+	if t != nil {
+		metrics = append(metrics, allGCMetrics(t)...)
+		for name, m := range alertMetrics {
+			if m.gc != nil {
+				vars[name] = m.gc(t)
+				if m.unit == "ms" {
+					vars[name+"_ms"] = vars[name]
+				}
+			}
+		}
+	}
+	if s != nil {
+		metrics = append(metrics, allScvgMetrics(s)...)
+		for name, m := range alertMetrics {
+			if m.scvg != nil {
+				vars[name] = m.scvg(s)
+			}
+		}
+	}
+	for _, m := range metrics {
+		vars[strings.TrimPrefix(m.Name, "gcvis_")] = m.Value
+	}
+	return vars
+}
+
+// computeFields returns the -compute fields of an event with vars, adding
+// them to vars, or nil if there is none.
+func computeFields(vars map[string]float64) map[string]float64 {
+	if len(computedFields) == 0 {
+		return nil
+	}
+	fields := make(map[string]float64, len(computedFields))
+	for _, c := range computedFields {
+		v := c.expr.eval(vars)
+		fields[c.Name] = v
+		vars[c.Name] = v
+	}
+	return fields
+}
+
+// ComputeGC sets the -compute fields of t.
+func ComputeGC(t *gctrace) {
+	t.Computed = computeFields(eventVars(t, nil))
+}
+
+// ComputeScvg sets the -compute fields of t.
+func ComputeScvg(t *scvgtrace) {
+	t.Computed = computeFields(eventVars(nil, t))
+}
+
+// computedMetrics returns the metrics of the fields computed for an event.
+func computedMetrics(fields map[string]float64) []metric {
+	var metrics []metric
+	for _, c := range computedFields {
+		if v, ok := fields[c.Name]; ok {
+			metrics = append(metrics, metric{"gcvis_" + c.Name, v})
+		}
+	}
+	return metrics
+}
+
+// evaluator is implemented by the sinks evaluating every event rather than
+// exporting them, e.g. the alerts, which -filter does not apply to.
+type evaluator interface {
+	Sink
+	evaluates()
+}
+
+// newEventFilter returns the -filter expression, or nil if there is none.
+func newEventFilter() (expr, error) {
+	if *filterExpr == "" {
+		return nil, nil
+	}
+	return parseExpr(*filterExpr, computedFields.variables())
+}
+
+// filteredSink passes on to a sink the events matching a filter only.
+type filteredSink struct {
+	Sink
+	filter expr
+}
+
+func (s *filteredSink) ConsumeGC(t *gctrace) error {
+	if !s.match(eventVars(t, nil), t.Computed) {
+		return nil
+	}
+	return s.Sink.ConsumeGC(t)
+}
+
+func (s *filteredSink) ConsumeScvg(t *scvgtrace) error {
+	if !s.match(eventVars(nil, t), t.Computed) {
+		return nil
+	}
+	return s.Sink.ConsumeScvg(t)
+}
+
+func (s *filteredSink) match(vars, computed map[string]float64) bool {
+	for name, v := range computed {
+		vars[name] = v
+	}
+	return exprTrue(s.filter.eval(vars))
+}
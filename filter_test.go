@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComputeFlag(t *testing.T) {
+	var f computeFlag
+	for _, value := range []string{"headroom=heap_goal-heap_live", "headroom_share=headroom/heap_goal"} {
+		if err := f.Set(value); err != nil {
+			t.Fatalf("Unexpected error setting %q: %v", value, err)
+		}
+	}
+	if f.String() != "headroom=heap_goal-heap_live,headroom_share=headroom/heap_goal" {
+		t.Errorf("Expected the fields in order. Got %s instead.", f.String())
+	}
+
+	for _, invalid := range []string{"headroom", "=stw", "stw=stw*2", "x=later", "x=stw >"} {
+		if err := f.Set(invalid); err == nil {
+			t.Errorf("Expected %q to be invalid.", invalid)
+		}
+	}
+}
+
+func TestComputeGC(t *testing.T) {
+	defer func(fields computeFlag) { computedFields = fields }(computedFields)
+	computedFields = nil
+	computedFields.Set("headroom=heap_goal-heap_live")
+	computedFields.Set("pause_share=stw/(stw_sweep_clock_milliseconds+mark+stw_mark)")
+
+	trace := &gctrace{Heap1: 100, Heap3: 60, STWSclock: 1, MASclock: 2, STWMclock: 1}
+	ComputeGC(trace)
+	if trace.Computed["headroom"] != 40 || trace.Computed["pause_share"] != 0.5 {
+		t.Errorf("Expected a headroom of 40MB and half of the cycle paused. Got %v instead.", trace.Computed)
+	}
+
+	found := false
+	for _, m := range gcMetrics(trace) {
+		found = found || m.Name == "gcvis_headroom" && m.Value == 40
+	}
+	if !found {
+		t.Errorf("Expected gcvis_headroom to be exported. Got %v instead.", gcMetrics(trace))
+	}
+	if l := newGCLogLine(trace); l.Computed["headroom"] != 40 {
+		t.Errorf("Expected the field in the log line. Got %v instead.", l.Computed)
+	}
+
+	scvg := &scvgtrace{Inuse: 10}
+	ComputeScvg(scvg)
+	if _, ok := scvg.Computed["headroom"]; !ok {
+		t.Errorf("Expected the field computed for scavenger traces too. Got %v instead.", scvg.Computed)
+	}
+}
+
+func TestFilteredSink(t *testing.T) {
+	defer func(filter string, fields computeFlag) { *filterExpr, computedFields = filter, fields }(*filterExpr, computedFields)
+	computedFields = nil
+	computedFields.Set("headroom=heap_goal-heap_live")
+	*filterExpr = "stw > 1 || headroom < 10"
+
+	cycles := &cyclesSink{}
+	sink, err := QueueSink("cycles", cycles)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, trace := range []*gctrace{
+		{NumGC: 1, STWSclock: 0.5, Heap1: 100, Heap3: 50},
+		{NumGC: 2, STWSclock: 2, Heap1: 100, Heap3: 50},
+		{NumGC: 3, STWSclock: 0.5, Heap1: 100, Heap3: 95},
+	} {
+		ComputeGC(trace)
+		sink.ConsumeGC(trace)
+	}
+	sink.ConsumeScvg(&scvgtrace{Inuse: 1})
+	sink.Close()
+
+	if len(cycles.cycles) != 2 || cycles.cycles[0] != 2 || cycles.cycles[1] != 3 || cycles.scvg != 0 {
+		t.Errorf("Expected cycles 2 and 3 only. Got %v and %d scavenger traces instead.", cycles.cycles, cycles.scvg)
+	}
+
+	*filterExpr = "pauses > 1"
+	if _, err := QueueSink("fake", &fakeSink{}); err == nil || !strings.Contains(err.Error(), "unknown variable pauses") {
+		t.Errorf("Expected an unknown variable to be rejected. Got %v instead.", err)
+	}
+}
+
+func TestFilteredSinkEvaluator(t *testing.T) {
+	defer func(filter string) { *filterExpr = filter }(*filterExpr)
+	*filterExpr = "stw_ms > 1"
+
+	exported, evaluated := &cyclesSink{}, &evaluatingSink{}
+	for _, s := range []Sink{exported, evaluated} {
+		sink, err := QueueSink("cycles", s)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		sink.ConsumeGC(&gctrace{NumGC: 1, STWSclock: 2})
+		sink.ConsumeGC(&gctrace{NumGC: 2, STWSclock: 0.5})
+		sink.Close()
+	}
+
+	if len(exported.cycles) != 1 || exported.cycles[0] != 1 {
+		t.Errorf("Expected the cycle pausing for more than 1ms only. Got %v instead.", exported.cycles)
+	}
+	if len(evaluated.cycles) != 2 {
+		t.Errorf("Expected the evaluator to get every cycle. Got %v instead.", evaluated.cycles)
+	}
+}
+
+// evaluatingSink is a cyclesSink evaluating the events, like the alerts.
+type evaluatingSink struct {
+	cyclesSink
+}
+
+func (s *evaluatingSink) evaluates() {}
+
+// cyclesSink keeps the number of the GC cycles it consumes.
+type cyclesSink struct {
+	fakeSink
+	cycles []int64
+}
+
+func (s *cyclesSink) ConsumeGC(t *gctrace) error {
+	s.cycles = append(s.cycles, t.NumGC)
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+var fluentdAddr = flag.String("fluentd", "", "host:port of a Fluentd or Fluent Bit forward input to send events to, or unix:///path of its socket")
+var fluentdTag = flag.String("fluentd-tag", "gcvis", "Fluentd tag of the events")
+var fluentdBatchWait = flag.Duration("fluentd-batch-wait", time.Second, "maximum time an event waits before being forwarded to Fluentd")
+
+// fluentdBatchSize bounds the number of entries of a forwarded message.
+const fluentdBatchSize = 500
+
+func init() {
+	RegisterSink("fluentd", newFluentdSink)
+}
+
+// fluentdSink forwards the log lines with the Fluentd forward protocol, in
+// Forward mode messages: [tag, [[time, record], ...], {"size": n}]. Like
+// the Graphite sink, the connection is opened lazily and opened again after
+// a failure.
+type fluentdSink struct {
+	network string
+	addr    string
+	tag     string
+
+	conn    net.Conn
+	batcher *batcher
+}
+
+func newFluentdSink() (Sink, error) {
+	if *fluentdAddr == "" {
+		return nil, nil
+	}
+
+	s := &fluentdSink{network: "tcp", addr: *fluentdAddr, tag: *fluentdTag}
+	if strings.HasPrefix(s.addr, "unix://") {
+		s.network, s.addr = "unix", strings.TrimPrefix(s.addr, "unix://")
+	} else {
+		s.addr = strings.TrimPrefix(s.addr, "tcp://")
+		if _, _, err := net.SplitHostPort(s.addr); err != nil {
+			s.addr = net.JoinHostPort(s.addr, "24224")
+		}
+	}
+	s.batcher = newBatcher(fluentdBatchSize, *fluentdBatchWait, s.send)
+
+	return s, nil
+}
+
+func (s *fluentdSink) ConsumeGC(t *gctrace) error {
+	return s.add(newGCLogLine(t))
+}
+
+func (s *fluentdSink) ConsumeScvg(t *scvgtrace) error {
+	return s.add(newScvgLogLine(t))
+}
+
+// add encodes l as an entry, [time, record], its record being its JSON
+// fields.
+func (s *fluentdSink) add(l *logLine) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(b, &record); err != nil {
+		return err
+	}
+
+	entry := appendMsgpackArrayHeader(nil, 2)
+	entry = appendMsgpackEventTime(entry, l.Time)
+	entry, err = appendMsgpack(entry, record)
+	if err != nil {
+		return err
+	}
+
+	s.batcher.Add(entry)
+	return nil
+}
+
+func (s *fluentdSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *fluentdSink) Close() error {
+	s.batcher.Close()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// send forwards batch as a single message, dialing again after a failure.
+func (s *fluentdSink) send(batch []interface{}) {
+	msg := appendMsgpackArrayHeader(nil, 3)
+	msg = appendMsgpackString(msg, s.tag)
+	msg = appendMsgpackArrayHeader(msg, len(batch))
+	for _, entry := range batch {
+		msg = append(msg, entry.([]byte)...)
+	}
+	msg = appendMsgpackMapHeader(msg, 1)
+	msg = appendMsgpackString(msg, "size")
+	msg = appendMsgpackInt(msg, int64(len(batch)))
+
+	retryWithBackoff(fmt.Sprintf("%d Fluentd events", len(batch)), func() (bool, error) {
+		if s.conn == nil {
+			conn, err := net.DialTimeout(s.network, s.addr, pushTimeout)
+			if err != nil {
+				return true, err
+			}
+			s.conn = conn
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(pushTimeout))
+		if _, err := s.conn.Write(msg); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+// appendMsgpack encodes v, as decoded from JSON, with MessagePack. Integral
+// numbers are encoded as integers.
+func appendMsgpack(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return appendMsgpackInt(b, int64(v)), nil
+		}
+		b = append(b, 0xcb)
+		return appendUint64BE(b, math.Float64bits(v)), nil
+	case string:
+		return appendMsgpackString(b, v), nil
+	case []interface{}:
+		b = appendMsgpackArrayHeader(b, len(v))
+		for _, e := range v {
+			var err error
+			if b, err = appendMsgpack(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b = appendMsgpackMapHeader(b, len(v))
+		for _, k := range keys {
+			b = appendMsgpackString(b, k)
+			var err error
+			if b, err = appendMsgpack(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("cannot encode %T with MessagePack", v)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v < 128:
+		return append(b, byte(v))
+	case v < 0 && v >= -32:
+		return append(b, byte(v))
+	}
+	b = append(b, 0xd3)
+	return appendUint64BE(b, uint64(v))
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n < 1<<8:
+		b = append(b, 0xd9, byte(n))
+	case n < 1<<16:
+		b = append(b, 0xda, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0xdb, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	if n < 16 {
+		return append(b, 0x90|byte(n))
+	}
+	return append(b, 0xdd, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	if n < 16 {
+		return append(b, 0x80|byte(n))
+	}
+	return append(b, 0xdf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// appendMsgpackEventTime encodes t as a Fluentd EventTime: the extension
+// type 0 of seconds and nanoseconds.
+func appendMsgpackEventTime(b []byte, t time.Time) []byte {
+	b = append(b, 0xd7, 0x00)
+	var buf [8]byte
+	binary.BigEndian.PutUint32(buf[:4], uint32(t.Unix()))
+	binary.BigEndian.PutUint32(buf[4:], uint32(t.Nanosecond()))
+	return append(b, buf[:]...)
+}
+
+func appendUint64BE(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
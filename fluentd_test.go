@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAppendMsgpack(t *testing.T) {
+	b, err := appendMsgpack(nil, map[string]interface{}{
+		"a": float64(1),
+		"b": 0.5,
+		"c": []interface{}{"x", nil, true, float64(-300)},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []byte{
+		0x83,
+		0xa1, 'a', 0x01,
+		0xa1, 'b', 0xcb, 0x3f, 0xe0, 0, 0, 0, 0, 0, 0,
+		0xa1, 'c', 0x94, 0xa1, 'x', 0xc0, 0xc3, 0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe, 0xd4,
+	}
+	if !bytes.Equal(b, expected) {
+		t.Errorf("Expected %x. Got %x instead.", expected, b)
+	}
+}
+
+func TestFluentdSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []byte)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		content, _ := ioutil.ReadAll(conn)
+		received <- content
+	}()
+
+	savedAddr, savedTag := *fluentdAddr, *fluentdTag
+	*fluentdAddr, *fluentdTag = listener.Addr().String(), "gc.api"
+	defer func() { *fluentdAddr, *fluentdTag = savedAddr, savedTag }()
+
+	sink, err := newFluentdSink()
+	if err != nil {
+		t.Fatalf("newFluentdSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{Heap1: 33})
+	sink.ConsumeScvg(&scvgtrace{Inuse: 12})
+	sink.Close()
+
+	msg := <-received
+
+	prefix := []byte{0x93, 0xa6, 'g', 'c', '.', 'a', 'p', 'i', 0x92, 0x92, 0xd7, 0x00}
+	if !bytes.HasPrefix(msg, prefix) {
+		t.Fatalf("Expected a Forward mode message of 2 entries. Got %x instead.", msg)
+	}
+
+	sec := time.Unix(int64(msg[12])<<24|int64(msg[13])<<16|int64(msg[14])<<8|int64(msg[15]), 0)
+	if time.Since(sec) > time.Minute {
+		t.Errorf("Expected the event time to be now. Got %v instead.", sec)
+	}
+
+	for _, expected := range [][]byte{
+		append([]byte{0xa8}, "HeapGoal\x21"...),
+		append([]byte{0xa5}, "Inuse\x0c"...),
+		append([]byte{0xa4}, "size\x02"...),
+	} {
+		if !bytes.Contains(msg, expected) {
+			t.Errorf("Expected message to contain %x. Got %x instead.", expected, msg)
+		}
+	}
+}
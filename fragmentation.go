@@ -0,0 +1,32 @@
+package main
+
+import "time"
+
+// The memory the operating system gives the program, its resident memory,
+// is more than the live heap: the heap grows past it until the next cycle,
+// the runtime and the allocator have their overhead, and freed spans may be
+// too fragmented to be returned. The gap between them tells whether the
+// memory of the program goes to the GC or elsewhere.
+
+// AddRSSPoint adds the resident memory of the program sampled at t, in
+// megabytes, to the graph, along with the estimate of its fragmentation and
+// overhead: the resident memory less the live heap after the latest GC
+// cycle. Samples before the first cycle are dropped, the live heap being
+// unknown, and so are those older than the latest one, as the series are
+// kept in chronological order.
+func (g *Graph) AddRSSPoint(t time.Time, mb int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.applyPending()
+
+	if g.gcAdded == 0 {
+		return
+	}
+	elapsed := t.Sub(traceStartTime()).Seconds() + g.offset
+	if n := g.RSS.len; n > 0 && elapsed < g.RSS.at(n - 1)[0] {
+		return
+	}
+	g.RSS.add(graphPoints{elapsed, float64(mb)})
+	g.Fragmentation.add(graphPoints{elapsed, float64(mb - g.lastLive)})
+	g.rssAdded++
+}
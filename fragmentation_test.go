@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGraphAddRSSPoint(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	start := traceStartTime()
+
+	graph.AddRSSPoint(start.Add(time.Second), 80)
+	if s := graph.Snapshot(); s.RSS.len != 0 {
+		t.Errorf("Expected the sample before the first cycle to be dropped. Got %v instead.", s.RSS.Points())
+	}
+
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 2, Heap0: 60, Heap3: 30})
+	graph.AddRSSPoint(start.Add(3*time.Second), 100)
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 2, ElapsedTime: 4, Heap0: 70, Heap3: 50})
+	graph.AddRSSPoint(start.Add(5*time.Second), 110)
+	graph.AddRSSPoint(start.Add(4*time.Second), 120)
+
+	s := graph.Snapshot()
+	expectedRSS := []graphPoints{{3, 100}, {5, 110}}
+	if got := s.RSS.Points(); !reflect.DeepEqual(got, expectedRSS) {
+		t.Errorf("Expected the samples in order, the late one dropped, %v. Got %v instead.", expectedRSS, got)
+	}
+	expectedFragmentation := []graphPoints{{3, 70}, {5, 60}}
+	if got := s.Fragmentation.Points(); !reflect.DeepEqual(got, expectedFragmentation) {
+		t.Errorf("Expected the resident memory less the live heap of the cycle before, %v. Got %v instead.", expectedFragmentation, got)
+	}
+
+	if c := graph.Cursor(); c != "2.0.0.2" {
+		t.Errorf("Expected the cursor to count the resident memory samples. Got %s instead.", c)
+	}
+	update, ok := graph.Since("2.0")
+	if !ok || len(update.RSS.Points()) != 2 || len(update.HeapUse.Points()) != 0 {
+		t.Errorf("Expected a cursor without resident memory samples to get them all. Got %v, %v instead.", ok, update)
+	}
+	update, ok = graph.Since("2.0.0.1")
+	if !ok || !reflect.DeepEqual(update.Fragmentation.Points(), []graphPoints{{5, 60}}) {
+		t.Errorf("Expected the sample after the cursor. Got %v, %v instead.", ok, update)
+	}
+	if _, ok := graph.Since("2.0.0.3"); ok {
+		t.Errorf("Expected a cursor past the samples to be stale.")
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+var gelfAddr = flag.String("gelf", "", "udp:// or tcp:// host:port URL of a Graylog GELF input to send events to")
+var gelfChunkSize = flag.Int("gelf-chunk-size", 1420, "maximum size of the GELF UDP datagrams, larger messages are chunked")
+
+// gelfMaxChunks is the maximum number of chunks of a GELF message.
+const gelfMaxChunks = 128
+
+// gelfBatchSize bounds the number of messages written at once.
+const gelfBatchSize = 100
+
+var gelfInvalidFieldChars = regexp.MustCompile(`[^\w.\-]`)
+
+func init() {
+	RegisterSink("gelf", newGELFSink)
+}
+
+// gelfSink sends every event as a GELF 1.1 message, with its fields and
+// labels as additional fields. UDP messages larger than a datagram are
+// chunked, TCP messages are null byte delimited. Like the Graphite sink,
+// the connection is opened lazily and opened again after a failure.
+type gelfSink struct {
+	network   string
+	addr      string
+	chunkSize int
+
+	conn    net.Conn
+	batcher *batcher
+}
+
+func newGELFSink() (Sink, error) {
+	if *gelfAddr == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(*gelfAddr)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+		return nil, fmt.Errorf("invalid GELF address %q, expected a udp:// or tcp:// URL", *gelfAddr)
+	}
+	// A chunk has a 12 bytes header.
+	if *gelfChunkSize <= 12 {
+		return nil, fmt.Errorf("invalid GELF chunk size %d", *gelfChunkSize)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "12201")
+	}
+
+	s := &gelfSink{network: u.Scheme, addr: addr, chunkSize: *gelfChunkSize}
+	s.batcher = newBatcher(gelfBatchSize, time.Second, s.send)
+
+	return s, nil
+}
+
+func (s *gelfSink) ConsumeGC(t *gctrace) error {
+	return s.add(newGCLogLine(t))
+}
+
+func (s *gelfSink) ConsumeScvg(t *scvgtrace) error {
+	return s.add(newScvgLogLine(t))
+}
+
+func (s *gelfSink) add(l *logLine) error {
+	msg, err := gelfMessage(l)
+	if err != nil {
+		return err
+	}
+
+	s.batcher.Add(msg)
+	return nil
+}
+
+// gelfMessage encodes l as a GELF message. Its gc or scvg fields become
+// additional fields named like them, e.g. _HeapGoal.
+func gelfMessage(l *logLine) ([]byte, error) {
+	kind, fields, err := eventFields(l)
+	if err != nil {
+		return nil, err
+	}
+
+	host := l.Host
+	if host == "" {
+		host = "unknown"
+	}
+
+	msg := map[string]interface{}{}
+	for k, v := range metricLabels() {
+		msg["_"+gelfInvalidFieldChars.ReplaceAllString(k, "_")] = v
+	}
+	for k, v := range fields {
+		msg["_"+k] = v
+	}
+	// _id is reserved.
+	delete(msg, "_id")
+
+	msg["version"] = "1.1"
+	msg["host"] = host
+	msg["short_message"] = l.Message
+	msg["timestamp"] = float64(l.Time.UnixNano()) / float64(time.Second)
+	msg["level"] = 6 // informational
+	msg["_event"] = kind
+
+	return json.Marshal(msg)
+}
+
+func (s *gelfSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *gelfSink) Close() error {
+	s.batcher.Close()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// send writes the messages of batch, dialing again after a failure.
+func (s *gelfSink) send(batch []interface{}) {
+	retryWithBackoff(fmt.Sprintf("%d GELF messages", len(batch)), func() (bool, error) {
+		if s.conn == nil {
+			conn, err := net.DialTimeout(s.network, s.addr, pushTimeout)
+			if err != nil {
+				return true, err
+			}
+			s.conn = conn
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(pushTimeout))
+		if err := s.write(batch); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+func (s *gelfSink) write(batch []interface{}) error {
+	if s.network == "tcp" {
+		var body bytes.Buffer
+		for _, msg := range batch {
+			body.Write(msg.([]byte))
+			body.WriteByte(0)
+		}
+		_, err := s.conn.Write(body.Bytes())
+		return err
+	}
+
+	for _, msg := range batch {
+		chunks, err := gelfChunks(msg.([]byte), s.chunkSize)
+		if err != nil {
+			return err
+		}
+		for _, chunk := range chunks {
+			if _, err := s.conn.Write(chunk); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gelfChunks splits msg in datagrams of at most size bytes. Chunks start
+// with the 0x1e 0x0f magic bytes, a message id, and their sequence number
+// and count.
+func gelfChunks(msg []byte, size int) ([][]byte, error) {
+	if len(msg) <= size {
+		return [][]byte{msg}, nil
+	}
+
+	dataSize := size - 12
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("GELF message of %d bytes needs more than %d chunks", len(msg), gelfMaxChunks)
+	}
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+
+		chunk := append([]byte{0x1e, 0x0f}, id[:]...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunks = append(chunks, append(chunk, msg[i*dataSize:end]...))
+	}
+
+	return chunks, nil
+}
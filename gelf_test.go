@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGELFSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket returned an error: %v", err)
+	}
+	defer conn.Close()
+
+	saved := *gelfAddr
+	*gelfAddr = "udp://" + conn.LocalAddr().String()
+	defer func() { *gelfAddr = saved }()
+
+	sink, err := newGELFSink()
+	if err != nil {
+		t.Fatalf("newGELFSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{Heap1: 33})
+	sink.Close()
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom returned an error: %v", err)
+	}
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal(buf[:n], &msg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if msg["version"] != "1.1" || msg["short_message"] != gcMessage || msg["_HeapGoal"] != float64(33) ||
+		msg["_event"] != "gc" || msg["_srv"] != *serviceName {
+		t.Errorf("Expected a GELF message of a GC event. Got %s instead.", buf[:n])
+	}
+}
+
+func TestGELFChunks(t *testing.T) {
+	msg := bytes.Repeat([]byte("x"), 25)
+
+	chunks, err := gelfChunks(msg, 22)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks. Got %d instead.", len(chunks))
+	}
+
+	var data []byte
+	for i, chunk := range chunks {
+		if chunk[0] != 0x1e || chunk[1] != 0x0f || chunk[10] != byte(i) || chunk[11] != 3 {
+			t.Errorf("Expected chunk %d of 3. Got header %x instead.", i, chunk[:12])
+		}
+		if !bytes.Equal(chunk[2:10], chunks[0][2:10]) {
+			t.Errorf("Expected the same message id in every chunk.")
+		}
+		data = append(data, chunk[12:]...)
+	}
+	if !bytes.Equal(data, msg) {
+		t.Errorf("Expected chunks to hold %q. Got %q instead.", msg, data)
+	}
+
+	if chunks, _ := gelfChunks(msg, 25); len(chunks) != 1 || !bytes.Equal(chunks[0], msg) {
+		t.Errorf("Expected a small message not to be chunked. Got %q instead.", chunks)
+	}
+
+	if _, err := gelfChunks(bytes.Repeat([]byte("x"), 129), 13); err == nil {
+		t.Errorf("Expected an error for more than 128 chunks.")
+	}
+}
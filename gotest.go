@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	// benchmarkResultRe matches the result of a benchmark of go test -bench,
+	// whose name may have been printed before GC traces written meanwhile:
+	// BenchmarkDecode-8   	   20000	     61520 ns/op	  2048 B/op
+	benchmarkResultRe = regexp.MustCompile(`^(Benchmark\S*)?\s+(\d+)\s+([\d.]+) ns/op`)
+
+	// benchmarkNameRe matches a line starting with the name of a benchmark.
+	benchmarkNameRe = regexp.MustCompile(`^(Benchmark\S*)(\s|$)`)
+)
+
+// isGoTest tells whether args run go test.
+func isGoTest(args []string) bool {
+	return len(args) > 1 && filepath.Base(args[0]) == "go" && args[1] == "test"
+}
+
+// goBenchmark is the GC activity during a benchmark of go test -bench.
+type goBenchmark struct {
+	Name       string  `json:"name"`
+	Iterations int64   `json:"iterations"`
+	NsPerOp    float64 `json:"ns_per_op"`
+	NumGC      int64   `json:"gc_count"`
+	PauseMs    float64 `json:"total_pause_ms"`
+}
+
+// benchmarkTracker correlates the results of go test -bench with the GC
+// cycles traced since the result before, or since the benchmark was named.
+type benchmarkTracker struct {
+	name    string // of the benchmark running, if printed yet
+	numGC   int64
+	pauseMs float64
+
+	done []goBenchmark
+}
+
+// observeGC counts t for the benchmark running. The name of a benchmark is
+// printed without a newline before it runs, so that a GC trace written
+// meanwhile may follow it on the same line.
+func (b *benchmarkTracker) observeGC(t *gctrace) {
+	if i := strings.Index(t.Raw, "gc "); i > 0 {
+		b.observeLine(t.Raw[:i])
+	}
+	b.numGC++
+	b.pauseMs += t.STWSclock + t.STWMclock
+}
+
+// observeLine completes the benchmark whose result is line, or starts the
+// one it names.
+func (b *benchmarkTracker) observeLine(line string) {
+	if m := benchmarkResultRe.FindStringSubmatch(line); m != nil {
+		name := m[1]
+		if name == "" {
+			name = b.name
+		}
+		iterations, _ := strconv.ParseInt(m[2], 10, 64)
+		nsPerOp, _ := strconv.ParseFloat(m[3], 64)
+		b.done = append(b.done, goBenchmark{
+			Name:       name,
+			Iterations: iterations,
+			NsPerOp:    nsPerOp,
+			NumGC:      b.numGC,
+			PauseMs:    b.pauseMs,
+		})
+		b.reset("")
+		return
+	}
+
+	switch {
+	case benchmarkNameRe.MatchString(line):
+		b.reset(benchmarkNameRe.FindStringSubmatch(line)[1])
+	case strings.HasPrefix(line, "pkg: "):
+		// The cycles of the tests of a package are not of its benchmarks.
+		b.reset("")
+	}
+}
+
+func (b *benchmarkTracker) reset(name string) {
+	b.name, b.numGC, b.pauseMs = name, 0, 0
+}
+
+// writeBenchmarks writes a table of the GC activity of each benchmark.
+func writeBenchmarks(w io.Writer, benchmarks []goBenchmark) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\nbenchmark\titerations\tns/op\tGC cycles\ttotal pause\t\n")
+	for _, b := range benchmarks {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%d\t%.3fms\t\n", b.Name, b.Iterations, b.NsPerOp, b.NumGC, b.PauseMs)
+	}
+	return tw.Flush()
+}
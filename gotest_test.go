@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// goTestOutput is the output of go test -bench, the GC traces of the
+// benchmarks interleaved with their results.
+const goTestOutput = `gc 1 @0.001s 1%: 0.5+1+0.5 ms clock, 0+0/0/0+0 ms cpu, 4->4->2 MB, 5 MB goal, 4 P
+goos: linux
+goarch: amd64
+pkg: example.com/codec
+BenchmarkEncode-8   	 1000000	      1052 ns/op
+BenchmarkDecode-8   	gc 2 @1.000s 1%: 0.5+1+1.5 ms clock, 0+0/0/0+0 ms cpu, 4->4->2 MB, 5 MB goal, 4 P
+gc 3 @1.500s 1%: 1.0+1+1.0 ms clock, 0+0/0/0+0 ms cpu, 4->4->2 MB, 5 MB goal, 4 P
+   20000	     61520 ns/op	    2048 B/op
+BenchmarkParse
+gc 4 @2.000s 1%: 0.5+1+0.5 ms clock, 0+0/0/0+0 ms cpu, 4->4->2 MB, 5 MB goal, 4 P
+BenchmarkParse-8    	     500	   2400000 ns/op
+PASS
+ok  	example.com/codec	3.012s
+`
+
+func TestBenchmarkTracker(t *testing.T) {
+	stats := newRunStats()
+	p := NewParser(strings.NewReader(""))
+	for _, line := range strings.Split(goTestOutput, "\n") {
+		if gc, _ := p.ParseLine(line); gc != nil {
+			stats.addGC(gc)
+		} else {
+			stats.addOutput(line)
+		}
+	}
+
+	expected := []goBenchmark{
+		{Name: "BenchmarkEncode-8", Iterations: 1000000, NsPerOp: 1052},
+		{Name: "BenchmarkDecode-8", Iterations: 20000, NsPerOp: 61520, NumGC: 2, PauseMs: 4},
+		{Name: "BenchmarkParse-8", Iterations: 500, NsPerOp: 2400000, NumGC: 1, PauseMs: 1},
+	}
+	done := stats.benchmarks.done
+	if len(done) != len(expected) {
+		t.Fatalf("Expected %d benchmarks. Got %+v instead.", len(expected), done)
+	}
+	for i, b := range expected {
+		if done[i] != b {
+			t.Errorf("Expected %+v. Got %+v instead.", b, done[i])
+		}
+	}
+
+	if s := newExitSummary(stats); len(s.Benchmarks) != 3 {
+		t.Errorf("Expected the benchmarks in the summary. Got %+v instead.", s.Benchmarks)
+	}
+
+	var b bytes.Buffer
+	if err := writeBenchmarks(&b, done); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "BenchmarkDecode-8") || !strings.Contains(b.String(), "4.000ms") {
+		t.Errorf("Expected a row of BenchmarkDecode-8. Got:\n%s", b.String())
+	}
+}
+
+func TestIsGoTest(t *testing.T) {
+	for args, expected := range map[string]bool{
+		"go test -bench .":          true,
+		"/usr/local/go/bin/go test": true,
+		"go build":                  false,
+		"./gotest":                  false,
+	} {
+		if isGoTest(strings.Fields(args)) != expected {
+			t.Errorf("Expected isGoTest(%s) to be %v.", args, expected)
+		}
+	}
+}
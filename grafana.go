@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// grafanaPanel describes one time series panel of the generated dashboard.
+type grafanaPanel struct {
+	title  string
+	unit   string
+	field  string // field of the emitted log lines, as flattened by the json parser
+	msg    string // message of the log lines carrying field
+	metric string // name of the exported Prometheus metric
+}
+
+var grafanaPanels = []grafanaPanel{
+	{"GC overhead", "percent", "gc_GCOverhead", gcMessage, "gcvis_gc_overhead_percent"},
+	{"Heap in use", "decmbytes", "gc_HeapUse", gcMessage, "gcvis_heap_goal_megabytes"},
+	{"STW sweep clock", "ms", "gc_STWSclock", gcMessage, "gcvis_stw_sweep_clock_milliseconds"},
+	{"Concurrent mark and scan clock", "ms", "gc_MASclock", gcMessage, "gcvis_mark_scan_clock_milliseconds"},
+	{"STW mark clock", "ms", "gc_STWMclock", gcMessage, "gcvis_stw_mark_clock_milliseconds"},
+	{"STW sweep cpu", "ms", "gc_STWScpu", gcMessage, "gcvis_stw_sweep_cpu_milliseconds"},
+	{"Concurrent mark and scan assist cpu", "ms", "gc_MASAssistcpu", gcMessage, "gcvis_mark_scan_assist_cpu_milliseconds"},
+	{"Concurrent mark and scan background cpu", "ms", "gc_MASBGcpu", gcMessage, "gcvis_mark_scan_background_cpu_milliseconds"},
+	{"Concurrent mark and scan idle cpu", "ms", "gc_MASIdlecpu", gcMessage, "gcvis_mark_scan_idle_cpu_milliseconds"},
+	{"STW mark cpu", "ms", "gc_STWMcpu", gcMessage, "gcvis_stw_mark_cpu_milliseconds"},
+	{"Scavenger in use", "decmbytes", "scvg_Inuse", scvgMessage, "gcvis_scvg_inuse_megabytes"},
+	{"Scavenger idle", "decmbytes", "scvg_Idle", scvgMessage, "gcvis_scvg_idle_megabytes"},
+	{"Scavenger sys", "decmbytes", "scvg_Sys", scvgMessage, "gcvis_scvg_sys_megabytes"},
+	{"Scavenger released", "decmbytes", "scvg_Released", scvgMessage, "gcvis_scvg_released_megabytes"},
+	{"Scavenger consumed", "decmbytes", "scvg_Consumed", scvgMessage, "gcvis_scvg_consumed_megabytes"},
+}
+
+// runGrafanaDashboard implements the grafana-dashboard command, which prints
+// a dashboard that can be imported in Grafana as is.
+func runGrafanaDashboard(args []string) {
+	fs := flag.NewFlagSet("grafana-dashboard", flag.ExitOnError)
+	datasource := fs.String("datasource", "loki", "type of the datasource the dashboard queries: loki or prometheus")
+	title := fs.String("title", "gcvis", "title of the dashboard")
+	fs.Parse(args)
+
+	if err := writeGrafanaDashboard(os.Stdout, *datasource, *title); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func writeGrafanaDashboard(w io.Writer, datasource, title string) error {
+	var serviceQuery string
+	var panelQuery func(p grafanaPanel) string
+	switch datasource {
+	case "loki":
+		serviceQuery = `label_values({component="gcvis"}, srv)`
+		panelQuery = func(p grafanaPanel) string {
+			return fmt.Sprintf(
+				`max_over_time({component="gcvis", srv=~"$service"} | json | msg=%q | unwrap %s [$__interval]) by (host, srv)`,
+				p.msg, p.field,
+			)
+		}
+	case "prometheus":
+		serviceQuery = `label_values(gcvis_gc_cycle, srv)`
+		panelQuery = func(p grafanaPanel) string {
+			return fmt.Sprintf(`max by (host, srv) (%s{srv=~"$service"})`, p.metric)
+		}
+	default:
+		return fmt.Errorf("unsupported datasource %q", datasource)
+	}
+
+	ds := map[string]string{"type": datasource, "uid": "${datasource}"}
+
+	var panels []interface{}
+	for i, p := range grafanaPanels {
+		query := panelQuery(p)
+		panels = append(panels, map[string]interface{}{
+			"id":         i + 1,
+			"type":       "timeseries",
+			"title":      p.title,
+			"datasource": ds,
+			"gridPos":    map[string]int{"h": 8, "w": 12, "x": (i % 2) * 12, "y": (i / 2) * 8},
+			"fieldConfig": map[string]interface{}{
+				"defaults":  map[string]string{"unit": p.unit},
+				"overrides": []interface{}{},
+			},
+			"targets": []interface{}{
+				map[string]interface{}{
+					"refId":        "A",
+					"datasource":   ds,
+					"expr":         query,
+					"legendFormat": "{{srv}} {{host}}",
+				},
+			},
+		})
+	}
+
+	dashboard := map[string]interface{}{
+		"title":         title,
+		"uid":           "gcvis",
+		"schemaVersion": 36,
+		"editable":      true,
+		"refresh":       "10s",
+		"time":          map[string]string{"from": "now-1h", "to": "now"},
+		"tags":          []string{"gcvis", "go", "gc"},
+		"templating": map[string]interface{}{
+			"list": []interface{}{
+				map[string]interface{}{
+					"name":  "datasource",
+					"label": "Data source",
+					"type":  "datasource",
+					"query": datasource,
+				},
+				map[string]interface{}{
+					"name":       "service",
+					"label":      "Service",
+					"type":       "query",
+					"datasource": ds,
+					"query":      serviceQuery,
+					"includeAll": true,
+					"multi":      true,
+					"refresh":    2,
+				},
+			},
+		},
+		"panels": panels,
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dashboard)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestGrafanaDashboardLoki(t *testing.T) {
+	testGrafanaDashboard(t, "loki")
+}
+
+func TestGrafanaDashboardPrometheus(t *testing.T) {
+	testGrafanaDashboard(t, "prometheus")
+}
+
+func testGrafanaDashboard(t *testing.T, datasource string) {
+	w := &bytes.Buffer{}
+	if err := writeGrafanaDashboard(w, datasource, "fake title"); err != nil {
+		t.Fatalf("writeGrafanaDashboard returned an error: %v", err)
+	}
+
+	var dashboard struct {
+		Title  string
+		Panels []json.RawMessage
+	}
+	if err := json.Unmarshal(w.Bytes(), &dashboard); err != nil {
+		t.Fatalf("Dashboard is not valid JSON: %v", err)
+	}
+
+	if dashboard.Title != "fake title" {
+		t.Errorf("Expected title to be 'fake title'. Got %q instead.", dashboard.Title)
+	}
+	if len(dashboard.Panels) != len(grafanaPanels) {
+		t.Errorf("Expected %d panels. Got %d instead.", len(grafanaPanels), len(dashboard.Panels))
+	}
+}
+
+func TestGrafanaDashboardUnsupportedDatasource(t *testing.T) {
+	if err := writeGrafanaDashboard(&bytes.Buffer{}, "graphite", "fake title"); err == nil {
+		t.Errorf("Expected an error for an unsupported datasource.")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var grafanaLiveURL = flag.String("grafana-live-url", "", "Grafana to push metrics to Grafana Live channels of, e.g. http://localhost:3000")
+var grafanaLiveToken = flag.String("grafana-live-token", "", "service account token of Grafana, with the permission to push to Grafana Live")
+var grafanaLiveStream = flag.String("grafana-live-stream", "gcvis", "stream the metrics are pushed to, the channels being stream/<stream>/gc and stream/<stream>/scvg")
+var grafanaLiveBatchWait = flag.Duration("grafana-live-batch-wait", 100*time.Millisecond, "maximum time a point waits before being pushed to Grafana Live")
+
+// grafanaLiveBatchSize bounds the number of lines of a push.
+const grafanaLiveBatchSize = 100
+
+func init() {
+	RegisterSink("grafana-live", newGrafanaLiveSink)
+}
+
+// grafanaLiveSink pushes metrics to Grafana Live with the HTTP API of
+// Grafana, as InfluxDB line protocol: the gc and scvg measurements are the
+// channels of the stream, and the metrics, without their gcvis_ prefix,
+// their fields. Dashboards show them as they come, without a data source.
+type grafanaLiveSink struct {
+	url    string
+	header http.Header
+	tags   string
+
+	client  *http.Client
+	batcher *batcher
+}
+
+func newGrafanaLiveSink() (Sink, error) {
+	if *grafanaLiveURL == "" {
+		return nil, nil
+	}
+	if *grafanaLiveStream == "" || strings.Contains(*grafanaLiveStream, "/") {
+		return nil, fmt.Errorf("invalid -grafana-live-stream %q", *grafanaLiveStream)
+	}
+
+	s := &grafanaLiveSink{
+		url:    strings.TrimRight(*grafanaLiveURL, "/") + "/api/live/push/" + *grafanaLiveStream,
+		header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		tags:   influxTags(metricLabels()),
+		client: &http.Client{Timeout: pushTimeout},
+	}
+	if *grafanaLiveToken != "" {
+		s.header.Set("Authorization", "Bearer "+*grafanaLiveToken)
+	}
+	s.batcher = newBatcher(grafanaLiveBatchSize, *grafanaLiveBatchWait, s.send)
+
+	return s, nil
+}
+
+func (s *grafanaLiveSink) ConsumeGC(t *gctrace) error {
+	s.add("gc", gcMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *grafanaLiveSink) ConsumeScvg(t *scvgtrace) error {
+	s.add("scvg", scvgMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *grafanaLiveSink) add(measurement string, metrics []metric, ts time.Time) {
+	if len(metrics) == 0 {
+		return
+	}
+
+	fields := make([]influxField, len(metrics))
+	for i, m := range metrics {
+		fields[i] = influxField{strings.TrimPrefix(m.Name, "gcvis_"), influxFloat(m.Value)}
+	}
+	s.batcher.Add(influxLine(measurement, s.tags, ts, fields))
+}
+
+func (s *grafanaLiveSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *grafanaLiveSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+func (s *grafanaLiveSink) send(batch []interface{}) {
+	var body bytes.Buffer
+	for _, line := range batch {
+		body.WriteString(line.(string))
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d Grafana Live points", len(batch)), func() (bool, error) {
+		return postBody(s.client, s.url, s.header, body.Bytes())
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGrafanaLiveSink(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		path string
+		auth string
+		body string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+
+		mu.Lock()
+		path = req.URL.Path
+		auth = req.Header.Get("Authorization")
+		body += string(b)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	defer func(saved string) { *grafanaLiveURL = saved }(*grafanaLiveURL)
+	defer func(saved string) { *grafanaLiveToken = saved }(*grafanaLiveToken)
+	*grafanaLiveURL = server.URL + "/"
+	*grafanaLiveToken = "glsa_secret"
+
+	sink, err := newGrafanaLiveSink()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{NumGC: 3, Heap1: 8})
+	sink.ConsumeScvg(&scvgtrace{Inuse: 12})
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if path != "/api/live/push/gcvis" || auth != "Bearer glsa_secret" {
+		t.Errorf("Expected an authenticated push to the gcvis stream. Got %s, %q instead.", path, auth)
+	}
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "gc,") || !strings.Contains(lines[0], "heap_goal_megabytes=8") || !strings.HasPrefix(lines[1], "scvg,") {
+		t.Errorf("Expected a gc and a scvg line. Got %q instead.", lines)
+	}
+}
+
+func TestGrafanaLiveSinkInvalidStream(t *testing.T) {
+	defer func(saved string) { *grafanaLiveURL = saved }(*grafanaLiveURL)
+	defer func(saved string) { *grafanaLiveStream = saved }(*grafanaLiveStream)
+	*grafanaLiveURL = "http://localhost:3000"
+	*grafanaLiveStream = "gcvis/gc"
+
+	if _, err := newGrafanaLiveSink(); err == nil {
+		t.Errorf("Expected a stream with a slash to be rejected.")
+	}
+}
+
+func TestGrafanaLiveSinkOff(t *testing.T) {
+	sink, err := newGrafanaLiveSink()
+	if err != nil || sink != nil {
+		t.Errorf("Expected the sink to be off by default. Got %v, %v instead.", sink, err)
+	}
+}
@@ -9,89 +9,447 @@ import (
 
 type graphPoints [2]float64
 
+// GCSummary describes the most recent garbage collection, for the live
+// banner at the top of the page.
+type GCSummary struct {
+	NumGC      int64
+	STW        float64 // total stop-the-world clock time, in milliseconds
+	HeapBefore int64   // in megabytes
+	HeapAfter  int64   // in megabytes
+	HeapGoal   int64   // in megabytes
+	Overhead   float64 // percentage of the wall-clock time spent collecting garbage lately
+	Received   int64   // unix time in milliseconds at which the trace was read
+}
+
 type Graph struct {
 	Title                               string
-	HeapUse, ScvgInuse, ScvgIdle        []graphPoints
-	ScvgSys, ScvgReleased, ScvgConsumed []graphPoints
-	STWSclock                           []graphPoints
-	MASclock                            []graphPoints
-	STWMclock                           []graphPoints
-	STWScpu                             []graphPoints
-	MASAssistcpu                        []graphPoints
-	MASBGcpu                            []graphPoints
-	MASIdlecpu                          []graphPoints
-	STWMcpu                             []graphPoints
+	HeapUse, ScvgInuse, ScvgIdle        pointRing
+	ScvgSys, ScvgReleased, ScvgConsumed pointRing
+	ScvgReleasedPercent, ScvgSysGap     pointRing // derived from the others
+	ScvgTotals                          ScvgTotals
+	STWSclock                           pointRing
+	MASclock                            pointRing
+	STWMclock                           pointRing
+	STWScpu                             pointRing
+	MASAssistcpu                        pointRing
+	MASBGcpu                            pointRing
+	MASIdlecpu                          pointRing
+	STWMcpu                             pointRing
+	Load                                pointRing // request rate of -load-input or -load-scrape
+	GCNumber, Allocated                 pointRing // of the GC cycles, for the x-axis modes of the page
+	RSS, Fragmentation                  pointRing // resident memory of the program sampled, and it less the live heap
+	Epoch                               float64   // unix seconds of the elapsed time 0, if not now that of the traces
+	LastGC                              *GCSummary
+	HighWater                           HighWater
+	Runtime                             *runtimeInfo // of the traced program, nil if unknown
+	Annotations                         []Annotation
+	Anomalies                           []Anomaly          // of -anomalies
+	anomalies                           *anomalyFinder     // nil unless -anomalies
+	SLOViolations                       []SLOViolation     // of -slo
+	slos                                sloTrackers        // nil without -slo
+	restoredViolations                  []SLOViolation     // of the graph restored, before the tracked ones
+	AlertViolations                     []AlertViolation   // of -alert
+	alerts                              alertTrackers      // nil without -alert
+	restoredAlertViolations             []AlertViolation   // of the graph restored, before the tracked ones
+	gcTraces                            traceLog           // for the summaries of windows
+	retention                           float64            // seconds the annotations, anomalies and SLO violations are kept
+	gcAdded, scvgAdded, loadAdded       int64              // traces and load samples added, for the cursors of updates
+	rssAdded                            int64              // resident memory samples added, for the cursors of updates
+	Report                              *exitSummary       `json:"-"` // of a static HTML report, which does not poll for new traces
 	Tmpl                                *template.Template `json:"-"`
-	mu                                  sync.RWMutex       `json:"-"`
+	mu                                  sync.Mutex         // of the series, held by readers only
+	offset                              float64            // seconds added to the elapsed time of traces, past a restored graph
+	gcBase                              float64            // added to the numbers of the GC cycles, past a restart
+	lastLive                            int64              // live heap after the last GC cycle, in megabytes
+
+	// The Add methods only append traces to pending, so that the parser
+	// never waits for readers, which apply them to the series.
+	pending   []pendingTrace
+	pendingMu sync.Mutex
+}
+
+// maxPendingTraces is how many traces may be pending before the Add
+// methods apply them themselves.
+const maxPendingTraces = 1024
+
+// pendingTrace is a trace added to a graph, not applied to its series yet.
+type pendingTrace struct {
+	gc       *gctrace
+	cycles   []*gctrace // summed up into gc, if it is an aggregate of -coalesce
+	scvg     *scvgtrace
+	elapsed  float64 // seconds
+	received int64   // unix time in milliseconds
+}
+
+// Annotation is a note attached to a point in time of the graph, e.g. a
+// deploy.
+type Annotation struct {
+	Time float64 // seconds
+	Text string
+	URL  string `json:",omitempty"` // of what it links to, e.g. a profile
 }
 
 var StartTime = time.Now()
 
-func NewGraph(title, tmpl string) Graph {
-	g := Graph{
-		Title:        title,
-		HeapUse:      []graphPoints{},
-		ScvgInuse:    []graphPoints{},
-		ScvgIdle:     []graphPoints{},
-		ScvgSys:      []graphPoints{},
-		ScvgReleased: []graphPoints{},
-		ScvgConsumed: []graphPoints{},
-		STWSclock:    []graphPoints{},
-		MASclock:     []graphPoints{},
-		STWMclock:    []graphPoints{},
-		STWScpu:      []graphPoints{},
-		MASAssistcpu: []graphPoints{},
-		MASBGcpu:     []graphPoints{},
-		MASIdlecpu:   []graphPoints{},
-		STWMcpu:      []graphPoints{},
+// NewGraph returns a graph keeping the points of -max-points and
+// -retention.
+func NewGraph(title, tmpl string) *Graph {
+	ring := newPointRing(*maxPoints, *retention)
+	g := &Graph{
+		Title:               title,
+		HeapUse:             ring,
+		ScvgInuse:           ring,
+		ScvgIdle:            ring,
+		ScvgSys:             ring,
+		ScvgReleased:        ring,
+		ScvgConsumed:        ring,
+		ScvgReleasedPercent: ring,
+		ScvgSysGap:          ring,
+		STWSclock:           ring,
+		MASclock:            ring,
+		STWMclock:           ring,
+		STWScpu:             ring,
+		MASAssistcpu:        ring,
+		MASBGcpu:            ring,
+		MASIdlecpu:          ring,
+		STWMcpu:             ring,
+		Load:                ring,
+		GCNumber:            ring,
+		Allocated:           ring,
+		RSS:                 ring,
+		Fragmentation:       ring,
+		anomalies:           newAnomalyFinder(),
+		slos:                newSLOTrackers(sloObjectives),
+		alerts:              newAlertTrackers(alertRules),
+		gcTraces:            newTraceLog(*maxPoints, *retention),
+		retention:           retention.Seconds(),
 	}
 	g.setTmpl(tmpl)
 
 	return g
 }
 
+// tmplFuncs are the functions of the templates of the pages.
+var tmplFuncs = template.FuncMap{
+	"version": func() string { return buildVersion().String() },
+}
+
 func (g *Graph) setTmpl(tmplStr string) {
-	g.Tmpl = template.Must(template.New("vis").Parse(tmplStr))
+	g.Tmpl = template.Must(template.New("vis").Funcs(tmplFuncs).Parse(tmplStr))
+}
+
+// WriteReport writes the page as a static HTML report, showing the
+// statistics of summary.
+func (g *Graph) WriteReport(w io.Writer, summary *exitSummary) error {
+	s := g.Snapshot()
+	s.Report = summary
+	return s.Tmpl.Execute(w, s)
 }
 
 func (g *Graph) Write(w io.Writer) error {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-	return g.Tmpl.Execute(w, g)
+	s := g.Snapshot()
+	return s.Tmpl.Execute(w, s)
+}
+
+// Snapshot returns a copy of the graph with the traces added so far, which
+// may be read without holding any lock.
+func (g *Graph) Snapshot() *Graph {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.applyPending()
+
+	s := g.emptyCopy()
+	s.Tmpl = g.Tmpl
+	dst := s.series()
+	for i, r := range g.series() {
+		*dst[i].pointRing = r.clone()
+	}
+	return s
+}
+
+// emptyCopy returns a copy of the graph without its points or template.
+// Its caller holds mu.
+func (g *Graph) emptyCopy() *Graph {
+	return &Graph{
+		Title:           g.Title,
+		Epoch:           g.epoch(),
+		LastGC:          g.LastGC,
+		HighWater:       g.HighWater,
+		ScvgTotals:      g.ScvgTotals,
+		Runtime:         g.Runtime.clone(),
+		Annotations:     append([]Annotation(nil), g.Annotations...),
+		Anomalies:       append([]Anomaly(nil), g.Anomalies...),
+		SLOViolations:   append([]SLOViolation(nil), g.SLOViolations...),
+		AlertViolations: append([]AlertViolation(nil), g.AlertViolations...),
+		gcAdded:         g.gcAdded,
+		scvgAdded:       g.scvgAdded,
+		loadAdded:       g.loadAdded,
+		rssAdded:        g.rssAdded,
+	}
+}
+
+// Annotate attaches text to the current time of the graph.
+func (g *Graph) Annotate(text string) {
+	g.AnnotateLink(text, "")
+}
+
+// AnnotateLink attaches text to the current time of the graph, linking to
+// url.
+func (g *Graph) AnnotateLink(text, url string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.Annotations = append(g.Annotations, Annotation{
+		Time: time.Now().Sub(traceStartTime()).Seconds() + g.offset,
+		Text: text,
+		URL:  url,
+	})
+}
+
+// Restore adds the points, annotations, anomalies and SLO violations of
+// saved, a graph of an earlier run, before those of the traces to come,
+// which are shifted past them.
+func (g *Graph) Restore(saved *Graph) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.applyPending()
+
+	dst := g.series()
+	for i, r := range saved.series() {
+		for _, p := range r.Points() {
+			dst[i].add(p)
+			if p[0] > g.offset {
+				g.offset = p[0]
+			}
+			added := g.added(r.kind)
+			*added = maxInt64(*added, dst[i].total)
+		}
+	}
+	for _, a := range saved.Annotations {
+		g.Annotations = append(g.Annotations, a)
+		if a.Time > g.offset {
+			g.offset = a.Time
+		}
+	}
+	for _, a := range saved.Anomalies {
+		g.Anomalies = append(g.Anomalies, a)
+		if a.Time > g.offset {
+			g.offset = a.Time
+		}
+	}
+	for _, v := range saved.SLOViolations {
+		// The violations of the traces to come are tracked anew.
+		v.Ongoing = false
+		g.SLOViolations = append(g.SLOViolations, v)
+		g.restoredViolations = append(g.restoredViolations, v)
+		if v.To > g.offset {
+			g.offset = v.To
+		}
+	}
+	for _, v := range saved.AlertViolations {
+		// The rules fire anew on the traces to come.
+		v.Ongoing = false
+		g.AlertViolations = append(g.AlertViolations, v)
+		g.restoredAlertViolations = append(g.restoredAlertViolations, v)
+		if v.To > g.offset {
+			g.offset = v.To
+		}
+	}
+	if g.LastGC == nil {
+		g.LastGC = saved.LastGC
+	}
+	if g.HighWater.Cycles == 0 {
+		g.HighWater = saved.HighWater
+	}
+	if g.ScvgTotals.Cycles == 0 {
+		g.ScvgTotals = saved.ScvgTotals
+	}
+	if g.Runtime == nil {
+		g.Runtime = saved.Runtime.clone()
+	}
 }
 
 func (g *Graph) AddGCTraceGraphPoint(gcTrace *gctrace) {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-	var elapsedTime float64
-	if gcTrace.ElapsedTime == 0 {
-		elapsedTime = time.Now().Sub(StartTime).Seconds()
-	} else {
-		elapsedTime = gcTrace.ElapsedTime
-	}
-	g.HeapUse = append(g.HeapUse, graphPoints{elapsedTime, float64(gcTrace.Heap1)})
-	g.STWSclock = append(g.STWSclock, graphPoints{elapsedTime, float64(gcTrace.STWSclock)})
-	g.MASclock = append(g.MASclock, graphPoints{elapsedTime, float64(gcTrace.MASclock)})
-	g.STWMclock = append(g.STWMclock, graphPoints{elapsedTime, float64(gcTrace.STWMclock)})
-	g.STWScpu = append(g.STWScpu, graphPoints{elapsedTime, float64(gcTrace.STWScpu)})
-	g.MASAssistcpu = append(g.MASAssistcpu, graphPoints{elapsedTime, float64(gcTrace.MASAssistcpu)})
-	g.MASBGcpu = append(g.MASBGcpu, graphPoints{elapsedTime, float64(gcTrace.MASBGcpu)})
-	g.MASIdlecpu = append(g.MASIdlecpu, graphPoints{elapsedTime, float64(gcTrace.MASIdlecpu)})
-	g.STWMcpu = append(g.STWMcpu, graphPoints{elapsedTime, float64(gcTrace.STWMcpu)})
+	g.AddCoalescedGraphPoint(gcTrace, nil)
+}
+
+// AddCoalescedGraphPoint adds sum, the aggregate of cycles, as a single
+// point of the series. The anomalies, SLOs and alerts are still evaluated
+// on every cycle. cycles is nil if sum is a single cycle.
+func (g *Graph) AddCoalescedGraphPoint(sum *gctrace, cycles []*gctrace) {
+	g.addPending(pendingTrace{gc: sum, cycles: cycles, elapsed: sum.ElapsedTime})
 }
 
 func (g *Graph) AddScavengerGraphPoint(scvg *scvgtrace) {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
-	var elapsedTime float64
-	if scvg.ElapsedTime == 0 {
-		elapsedTime = time.Now().Sub(StartTime).Seconds()
-	} else {
-		elapsedTime = scvg.ElapsedTime
-	}
-	g.ScvgInuse = append(g.ScvgInuse, graphPoints{elapsedTime, float64(scvg.inuse)})
-	g.ScvgIdle = append(g.ScvgIdle, graphPoints{elapsedTime, float64(scvg.idle)})
-	g.ScvgSys = append(g.ScvgSys, graphPoints{elapsedTime, float64(scvg.sys)})
-	g.ScvgReleased = append(g.ScvgReleased, graphPoints{elapsedTime, float64(scvg.released)})
-	g.ScvgConsumed = append(g.ScvgConsumed, graphPoints{elapsedTime, float64(scvg.consumed)})
+	if !enabledSeries["scvg"] {
+		return
+	}
+	g.addPending(pendingTrace{scvg: scvg, elapsed: scvg.ElapsedTime})
+}
+
+func (g *Graph) addPending(t pendingTrace) {
+	if t.elapsed == 0 {
+		t.elapsed = time.Now().Sub(traceStartTime()).Seconds()
+	}
+	t.received = time.Now().UnixNano() / int64(time.Millisecond)
+
+	g.pendingMu.Lock()
+	g.pending = append(g.pending, t)
+	n := len(g.pending)
+	g.pendingMu.Unlock()
+
+	// Without readers, the pending traces would pile up.
+	if n >= maxPendingTraces {
+		g.mu.Lock()
+		g.applyPending()
+		g.mu.Unlock()
+	}
+}
+
+// applyPending adds the pending traces to the series. Its caller holds mu.
+func (g *Graph) applyPending() {
+	g.pendingMu.Lock()
+	pending := g.pending
+	g.pending = nil
+	g.pendingMu.Unlock()
+
+	for _, t := range pending {
+		if t.gc != nil {
+			g.applyGCTrace(t.gc, t.cycles, t.elapsed+g.offset, t.received)
+		} else {
+			g.applyScvgTrace(t.scvg, t.elapsed+g.offset)
+		}
+	}
+}
+
+func (g *Graph) applyGCTrace(gcTrace *gctrace, cycles []*gctrace, elapsedTime float64, received int64) {
+	if enabledSeries["heap"] {
+		g.HeapUse.add(graphPoints{elapsedTime, float64(gcTrace.Heap1)})
+	}
+	if enabledSeries["stw"] {
+		g.STWSclock.add(graphPoints{elapsedTime, float64(gcTrace.STWSclock)})
+		g.STWMclock.add(graphPoints{elapsedTime, float64(gcTrace.STWMclock)})
+		g.STWScpu.add(graphPoints{elapsedTime, float64(gcTrace.STWScpu)})
+		g.STWMcpu.add(graphPoints{elapsedTime, float64(gcTrace.STWMcpu)})
+	}
+	if enabledSeries["mas"] {
+		g.MASclock.add(graphPoints{elapsedTime, float64(gcTrace.MASclock)})
+		g.MASAssistcpu.add(graphPoints{elapsedTime, float64(gcTrace.MASAssistcpu)})
+		g.MASBGcpu.add(graphPoints{elapsedTime, float64(gcTrace.MASBGcpu)})
+		g.MASIdlecpu.add(graphPoints{elapsedTime, float64(gcTrace.MASIdlecpu)})
+	}
+
+	// The cycles of an aggregate are observed one by one.
+	observed := []timedTrace{{elapsedTime, gcTrace}}
+	if len(cycles) > 0 {
+		observed = observed[:0]
+		for _, c := range cycles {
+			observed = append(observed, timedTrace{c.ElapsedTime + g.offset, c})
+		}
+	}
+	for _, t := range observed {
+		if g.anomalies != nil {
+			for _, c := range g.anomalies.check(t.gc, t.elapsed) {
+				if c.Anomalous {
+					g.Anomalies = append(g.Anomalies, c.Anomaly)
+				}
+			}
+		}
+		if g.slos != nil {
+			g.slos.observe(t.gc, t.elapsed)
+		}
+		if g.alerts != nil {
+			g.alerts.observeGC(t.gc, t.elapsed)
+		}
+		g.gcTraces.add(t)
+		g.HighWater.observe(t.gc, t.elapsed)
+	}
+	if g.slos != nil {
+		g.SLOViolations = append(append([]SLOViolation(nil), g.restoredViolations...), g.slos.violations()...)
+	}
+	if g.alerts != nil {
+		g.AlertViolations = append(append([]AlertViolation(nil), g.restoredAlertViolations...), g.alerts.violations()...)
+	}
+
+	g.pruneMarks(elapsedTime)
+	if g.Runtime != nil {
+		*g.Runtime = g.Runtime.withProcs(gcTrace.Nproc)
+	}
+	g.observeXAxes(gcTrace, elapsedTime)
+	g.gcAdded++
+	g.LastGC = &GCSummary{
+		NumGC:      gcTrace.NumGC,
+		STW:        gcTrace.STWSclock + gcTrace.STWMclock,
+		HeapBefore: gcTrace.Heap0,
+		HeapAfter:  gcTrace.Heap3,
+		HeapGoal:   gcTrace.Heap1,
+		Overhead:   gcTrace.GCOverhead,
+		Received:   received,
+	}
+}
+
+// pruneMarks drops the annotations, anomalies, SLO and alert violations
+// that ended more than -retention before elapsed, like the points of the
+// series are. Its caller holds mu.
+func (g *Graph) pruneMarks(elapsed float64) {
+	if g.retention <= 0 {
+		return
+	}
+	cutoff := elapsed - g.retention
+
+	annotations := g.Annotations[:0]
+	for _, a := range g.Annotations {
+		if a.Time >= cutoff {
+			annotations = append(annotations, a)
+		}
+	}
+	g.Annotations = annotations
+
+	anomalies := g.Anomalies[:0]
+	for _, a := range g.Anomalies {
+		if a.Time >= cutoff {
+			anomalies = append(anomalies, a)
+		}
+	}
+	g.Anomalies = anomalies
+
+	violations := g.SLOViolations[:0]
+	for _, v := range g.SLOViolations {
+		if v.Ongoing || v.To >= cutoff {
+			violations = append(violations, v)
+		}
+	}
+	g.SLOViolations = violations
+
+	alertViolations := g.AlertViolations[:0]
+	for _, v := range g.AlertViolations {
+		if v.Ongoing || v.To >= cutoff {
+			alertViolations = append(alertViolations, v)
+		}
+	}
+	g.AlertViolations = alertViolations
+}
+
+func (g *Graph) applyScvgTrace(scvg *scvgtrace, elapsedTime float64) {
+	g.scvgAdded++
+	g.ScvgInuse.add(graphPoints{elapsedTime, float64(scvg.Inuse)})
+	g.ScvgIdle.add(graphPoints{elapsedTime, float64(scvg.Idle)})
+	g.ScvgSys.add(graphPoints{elapsedTime, float64(scvg.Sys)})
+	g.ScvgReleased.add(graphPoints{elapsedTime, float64(scvg.Released)})
+	g.ScvgReleasedPercent.add(graphPoints{elapsedTime, scvgReleasedPercent(scvg)})
+	g.ScvgSysGap.add(graphPoints{elapsedTime, float64(scvgSysGap(scvg))})
+	g.ScvgTotals.observe(scvg)
+	if g.alerts != nil {
+		g.alerts.observeScvg(scvg, elapsedTime)
+		g.AlertViolations = append(append([]AlertViolation(nil), g.restoredAlertViolations...), g.alerts.violations()...)
+		g.pruneMarks(elapsedTime)
+	}
+	g.ScvgConsumed.add(graphPoints{elapsedTime, float64(scvg.Consumed)})
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
 }
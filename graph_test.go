@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBenchmarkGraph returns a graph of a long session, n GC traces long.
+func newBenchmarkGraph(n int) *Graph {
+	g := NewGraph("benchmark", GCVIS_TMPL)
+	for i := 0; i < n; i++ {
+		g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: float64(i), Heap1: int64(i % 512), STWSclock: 0.1, STWMclock: 0.2})
+	}
+	return g
+}
+
+// pollGraph serves graph.json, as the web UI polls it, until stop is
+// closed, telling polled when done serving it once.
+func pollGraph(g *Graph, polled chan<- struct{}, stop chan struct{}) {
+	for i := 0; ; i++ {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		req := httptest.NewRequest("GET", "/graph.json?points=1200", nil)
+		graph, err := graphResponse(g, req)
+		if err != nil {
+			panic(err)
+		}
+		json.NewEncoder(ioutil.Discard).Encode(graph)
+		if i == 0 {
+			polled <- struct{}{}
+		}
+	}
+}
+
+// BenchmarkAddGCTraceWhilePolling measures how long the parser waits to add
+// a trace while clients poll the graph, on average and at worst.
+func BenchmarkAddGCTraceWhilePolling(b *testing.B) {
+	g := newBenchmarkGraph(100000)
+	polled, stop := make(chan struct{}), make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go pollGraph(g, polled, stop)
+		<-polled
+	}
+	defer close(stop)
+
+	t := &gctrace{ElapsedTime: 100000, Heap1: 64}
+	var longest time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		g.AddGCTraceGraphPoint(t)
+		if d := time.Since(start); d > longest {
+			longest = d
+		}
+	}
+	b.ReportMetric(float64(longest.Nanoseconds()), "max-ns")
+}
+
+func BenchmarkGraphJSON(b *testing.B) {
+	g := newBenchmarkGraph(100000)
+	req := httptest.NewRequest("GET", "/graph.json?points=1200", nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		graph, _ := graphResponse(g, req)
+		json.NewEncoder(ioutil.Discard).Encode(graph)
+	}
+}
+
+func TestGraphSnapshot(t *testing.T) {
+	g := NewGraph("title", GCVIS_TMPL)
+	g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: 1, NumGC: 1, Heap1: 10})
+	s := g.Snapshot()
+
+	g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: 2, NumGC: 2, Heap1: 20})
+	if s.HeapUse.Len() != 1 || s.LastGC.NumGC != 1 {
+		t.Errorf("Expected the snapshot to keep its single point. Got %v, %+v instead.", s.HeapUse.Points(), s.LastGC)
+	}
+	if s = g.Snapshot(); s.HeapUse.Len() != 2 || s.LastGC.NumGC != 2 || s.Tmpl == nil {
+		t.Errorf("Expected a new snapshot to have both points. Got %v, %+v instead.", s.HeapUse.Points(), s.LastGC)
+	}
+}
+
+func TestGraphAppliesPendingTraces(t *testing.T) {
+	g := NewGraph("title", GCVIS_TMPL)
+	for i := 0; i < maxPendingTraces; i++ {
+		g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: float64(i + 1)})
+	}
+
+	g.pendingMu.Lock()
+	defer g.pendingMu.Unlock()
+	if len(g.pending) != 0 || g.HeapUse.Len() != maxPendingTraces {
+		t.Errorf("Expected %d traces to be applied without readers. Got %d pending and %d points instead.", maxPendingTraces, len(g.pending), g.HeapUse.Len())
+	}
+}
+
+// pageStubs stand in for the browser, jQuery and flot, just enough for the
+// script of the page to run under node: the page loads at once, gets
+// graphData and summaryData from the server once its script has run, and
+// every event handler is called once.
+const pageStubs = `
+var handlers = [], queue = [];
+function later(f) {
+	queue.push(f);
+}
+function run() {
+	while (queue.length > 0) {
+		queue.shift()();
+	}
+}
+function chain() {
+	var c = new Proxy(function() {}, {
+		get: function(_, name) {
+			switch (name) {
+			case "ready":
+				return function(f) { f(); return c; };
+			case "bind": case "on": case "click": case "change":
+				return function() {
+					var f = arguments[arguments.length - 1];
+					if (typeof f == "function") {
+						handlers.push(f);
+					}
+					return c;
+				};
+			case "width":
+				return function() { return 1200; };
+			case "val": case "attr":
+				return function(_, v) { return v === undefined ? "" : c; };
+			}
+			return function() { return c; };
+		},
+		apply: function() { return c; }
+	});
+	return c;
+}
+var $ = function() { return chain(); };
+$.each = function(a, f) {
+	Object.keys(a || {}).forEach(function(k) { f(Array.isArray(a) ? +k : k, a[k]); });
+	return a;
+};
+$.map = function(a, f) {
+	var r = [];
+	(a || []).forEach(function(v, i) { r = r.concat(f(v, i)); });
+	return r;
+};
+$.extend = Object.assign;
+$.color = { parse: function() { return { scale: function() { return this; }, toString: function() { return "#000"; } }; } };
+$.get = $.getJSON = function(url, f) {
+	var data = url.indexOf("graph.json") >= 0 ? graphData : url.indexOf("summary") >= 0 ? summaryData : [];
+	if (f) {
+		later(function() { f(JSON.parse(JSON.stringify(data))); });
+	}
+};
+$.post = function() {};
+$.plot = function(_, data, options) {
+	options = JSON.parse(JSON.stringify(options || {}));
+	options.grid = options.grid || {};
+	options.series = options.series || {};
+	options.series.bars = options.series.bars || {};
+	var plot = {
+		getOptions: function() { return options; },
+		getData: function() { return data.map(function(s, i) { return { label: s.label, color: "#" + i }; }); },
+		setData: function(d) { data = d; },
+		getXAxes: function() { return [{ options: {} }]; }
+	};
+	return new Proxy(plot, { get: function(p, name) { return p[name] || function() {}; } });
+};
+var jQuery = $;
+var window = { location: { href: "http://localhost:8080/", search: "", hash: "" }, localStorage: { getItem: function() { return null; }, setItem: function() {} } };
+var localStorage = window.localStorage;
+var document = { createTextNode: function(t) { return t; } };
+var timeouts = 0;
+var setTimeout = function(f) {
+	if (timeouts++ < 2) {
+		later(f);
+	}
+};
+`
+
+func TestGraphPageScript(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	for i := 1; i <= 5; i++ {
+		graph.AddGCTraceGraphPoint(&gctrace{NumGC: int64(i), ElapsedTime: float64(i), Heap0: int64(10 * i), Heap1: 20, Heap3: 5, STWSclock: 0.1, STWMclock: 0.2})
+		graph.AddScavengerGraphPoint(&scvgtrace{ElapsedTime: float64(i), Inuse: 10, Idle: 5, Sys: 20, Released: 2})
+	}
+	graph.Annotate("deploy")
+	graph.SLOViolations = []SLOViolation{{SLO: "p99_stw<5ms over 5m", Unit: "ms", From: 1, To: 2}}
+
+	var page bytes.Buffer
+	if err := graph.Write(&page); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/graph.json?points=1200", nil)
+	graphData, err := graphResponse(graph, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphJSON, _ := json.Marshal(graphData)
+	summaryJSON, _ := json.Marshal(graph.Summary(0, 10))
+
+	script := pageStubs + "var graphData = " + string(graphJSON) + ";\nvar summaryData = " + string(summaryJSON) + ";\n"
+	for _, m := range regexp.MustCompile(`(?s)<script type="text/javascript">(.*?)</script>`).FindAllStringSubmatch(page.String(), -1) {
+		script += m[1]
+	}
+	script += "\nrun();\nhandlers.forEach(function(f) { f.call({}, { preventDefault: function() {}, target: {} }, { xaxis: { from: 1, to: 3 }, yaxis: { from: 0, to: 1 } }); });\nrun();\n"
+
+	cmd := exec.Command(node)
+	cmd.Stdin = strings.NewReader(script)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("Expected the script of the page to run. Got %v instead:\n%s", err, out)
+	}
+}
+
+func TestGraphRetentionPrunesMarks(t *testing.T) {
+	defer func(saved time.Duration) { *retention = saved }(*retention)
+	*retention = 10 * time.Second
+
+	g := NewGraph("retained", GCVIS_TMPL)
+	g.Annotations = []Annotation{{Time: 1, Text: "old"}, {Time: 50, Text: "recent"}}
+	g.Anomalies = []Anomaly{{Time: 2}, {Time: 52}}
+	g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: 55})
+
+	s := g.Snapshot()
+	if len(s.Annotations) != 1 || s.Annotations[0].Text != "recent" {
+		t.Errorf("Expected the annotations of the last 10s only. Got %+v instead.", s.Annotations)
+	}
+	if len(s.Anomalies) != 1 || s.Anomalies[0].Time != 52 {
+		t.Errorf("Expected the anomalies of the last 10s only. Got %+v instead.", s.Anomalies)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var graphiteAddr = flag.String("graphite-addr", "", "host:port of a Graphite/carbon plaintext listener to send metrics to over TCP")
+var graphitePrefix = flag.String("graphite-prefix", "gcvis", "prefix of the Graphite metric paths, followed by the service name")
+var graphiteFlushInterval = flag.Duration("graphite-flush-interval", 10*time.Second, "how often buffered metrics are sent to Graphite")
+
+// graphiteBatchSize bounds the number of lines buffered between flushes.
+const graphiteBatchSize = 1000
+
+func init() {
+	RegisterSink("graphite", newGraphiteSink)
+}
+
+// graphiteSink sends metrics with the carbon plaintext protocol, as
+// <prefix>.<service>.<metric> paths. The connection is opened lazily and
+// opened again after a failure.
+type graphiteSink struct {
+	addr   string
+	prefix string
+
+	conn    net.Conn
+	batcher *batcher
+}
+
+func newGraphiteSink() (Sink, error) {
+	if *graphiteAddr == "" {
+		return nil, nil
+	}
+
+	s := &graphiteSink{
+		addr:   *graphiteAddr,
+		prefix: *graphitePrefix + "." + graphitePathEscaper.Replace(*serviceName) + ".",
+	}
+	s.batcher = newBatcher(graphiteBatchSize, *graphiteFlushInterval, s.send)
+
+	return s, nil
+}
+
+func (s *graphiteSink) ConsumeGC(t *gctrace) error {
+	s.add(gcMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *graphiteSink) ConsumeScvg(t *scvgtrace) error {
+	s.add(scvgMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *graphiteSink) add(metrics []metric, ts time.Time) {
+	for _, m := range metrics {
+		s.batcher.Add(fmt.Sprintf("%s%s %s %d\n",
+			s.prefix,
+			strings.TrimPrefix(m.Name, "gcvis_"),
+			strconv.FormatFloat(m.Value, 'f', -1, 64),
+			ts.Unix(),
+		))
+	}
+}
+
+func (s *graphiteSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *graphiteSink) Close() error {
+	s.batcher.Close()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// send writes the lines of batch, dialing again after a failure.
+func (s *graphiteSink) send(batch []interface{}) {
+	var body bytes.Buffer
+	for _, line := range batch {
+		body.WriteString(line.(string))
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d Graphite metrics", len(batch)), func() (bool, error) {
+		if s.conn == nil {
+			conn, err := net.DialTimeout("tcp", s.addr, pushTimeout)
+			if err != nil {
+				return true, err
+			}
+			s.conn = conn
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(pushTimeout))
+		if _, err := s.conn.Write(body.Bytes()); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+// graphitePathEscaper keeps a service name from adding levels to the
+// metric paths.
+var graphitePathEscaper = strings.NewReplacer(".", "_", " ", "_", "/", "_")
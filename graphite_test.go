@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestGraphiteSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		content, _ := ioutil.ReadAll(conn)
+		received <- string(content)
+	}()
+
+	savedAddr, savedName := *graphiteAddr, *serviceName
+	*graphiteAddr, *serviceName = listener.Addr().String(), "my.service"
+	defer func() { *graphiteAddr, *serviceName = savedAddr, savedName }()
+
+	sink, err := newGraphiteSink()
+	if err != nil {
+		t.Fatalf("newGraphiteSink returned an error: %v", err)
+	}
+
+	sink.ConsumeGC(&gctrace{Heap1: 33})
+	sink.Close()
+
+	content := <-received
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	if len(lines) != len(gcMetrics(&gctrace{})) {
+		t.Errorf("Expected one line per metric. Got %q instead.", content)
+	}
+	if !strings.Contains(content, "gcvis.my_service.heap_goal_megabytes 33 ") {
+		t.Errorf("Expected a heap goal metric. Got %q instead.", content)
+	}
+}
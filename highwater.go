@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// HighWater is the largest heap and the longest stop the world pause of the
+// GC cycles since the start of the session, or since the marks were last
+// reset, as capacity planners record them.
+type HighWater struct {
+	Since   float64 // seconds, of the last reset
+	Cycles  int64   // GC cycles since
+	HeapMB  int64   // largest heap a cycle started at
+	HeapAt  float64 // seconds
+	PauseMs float64 // longest stop the world pause, sweep and mark
+	PauseAt float64 // seconds
+}
+
+// observe accounts for a GC cycle at elapsed seconds.
+func (h *HighWater) observe(t *gctrace, elapsed float64) {
+	if h.Cycles == 0 || t.Heap0 > h.HeapMB {
+		h.HeapMB, h.HeapAt = t.Heap0, elapsed
+	}
+	if pause := t.STWSclock + t.STWMclock; h.Cycles == 0 || pause > h.PauseMs {
+		h.PauseMs, h.PauseAt = pause, elapsed
+	}
+	h.Cycles++
+}
+
+// HighWaterMarks returns the high-water marks of the graph.
+func (g *Graph) HighWaterMarks() HighWater {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.applyPending()
+
+	return g.HighWater
+}
+
+// ResetHighWater starts the high-water marks anew from now on, annotating
+// the graph with it, and returns the marks before the reset.
+func (g *Graph) ResetHighWater() HighWater {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.applyPending()
+
+	old := g.HighWater
+	now := time.Now().Sub(traceStartTime()).Seconds() + g.offset
+	g.HighWater = HighWater{Since: now}
+	g.Annotations = append(g.Annotations, Annotation{Time: now, Text: "high-water marks reset"})
+	return old
+}
+
+// handleHighWater serves the high-water marks of the graph. POST resets
+// them, serving those before the reset.
+func (h *HttpServer) handleHighWater(w http.ResponseWriter, req *http.Request) {
+	var marks HighWater
+	switch req.Method {
+	case "GET":
+		marks = h.graph.HighWaterMarks()
+	case "POST":
+		marks = h.graph.ResetHighWater()
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(marks)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestHighWater(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 1, Heap0: 40, STWSclock: 0.5, STWMclock: 1})
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 2, ElapsedTime: 2, Heap0: 64, STWSclock: 0.5, STWMclock: 0.5})
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 3, ElapsedTime: 3, Heap0: 32, STWSclock: 0.5, STWMclock: 0.5})
+
+	h := graph.HighWaterMarks()
+	if h.Cycles != 3 || h.HeapMB != 64 || h.HeapAt != 2 || h.PauseMs != 1.5 || h.PauseAt != 1 {
+		t.Errorf("Expected a heap of 64MB at 2s and a pause of 1.5ms at 1s over 3 cycles. Got %+v instead.", h)
+	}
+
+	if old := graph.ResetHighWater(); old != h {
+		t.Errorf("Expected the reset to return %+v. Got %+v instead.", h, old)
+	}
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 4, ElapsedTime: 4, Heap0: 16, STWSclock: 0.25, STWMclock: 0.25})
+
+	h = graph.HighWaterMarks()
+	if h.Cycles != 1 || h.HeapMB != 16 || h.PauseMs != 0.5 {
+		t.Errorf("Expected the marks of the cycle after the reset only. Got %+v instead.", h)
+	}
+	if s := graph.Snapshot(); len(s.Annotations) != 1 || s.HighWater != h {
+		t.Errorf("Expected the reset to be annotated, and the marks to be copied. Got %v, %+v instead.", s.Annotations, s.HighWater)
+	}
+}
+
+func TestHttpServerHighWater(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 1, Heap0: 40})
+	server := NewHttpServer("127.0.0.1", "0", graph)
+
+	go server.Start()
+	defer server.Close()
+
+	response, err := http.Post(server.Url()+"api/v1/high-water", "", nil)
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	defer response.Body.Close()
+
+	var h HighWater
+	if err := json.NewDecoder(response.Body).Decode(&h); err != nil {
+		t.Fatalf("Response is not valid JSON: %v", err)
+	}
+	if h.HeapMB != 40 {
+		t.Errorf("Expected the marks before the reset. Got %+v instead.", h)
+	}
+	if h := graph.HighWaterMarks(); h.Cycles != 0 {
+		t.Errorf("Expected the marks to be reset. Got %+v instead.", h)
+	}
+
+	response, err = http.Get(server.Url() + "api/v1/high-water")
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200. Got %d instead.", response.StatusCode)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+var onEvent = flag.String("on-event", "", "shell command run for every event, or only for the events exceeding an -on-event-threshold, with the event as JSON on its standard input, as posted by -webhook")
+var onEventThresholds thresholdsFlag
+var onAlert = flag.String("on-alert", "", "shell command run when an -alert fires or recovers, with the alert as JSON on its standard input, e.g. to snapshot the state of the program")
+
+// hookTimeout is how long a hook command may run before it is killed.
+const hookTimeout = 30 * time.Second
+
+func init() {
+	flag.Var(&onEventThresholds, "on-event-threshold", "metric>value or metric<value, e.g. stw_mark_clock_milliseconds>100, only running -on-event for the events exceeding it. Can be repeated.")
+
+	RegisterSink("on-event", newHookSink)
+	RegisterNotifier("on-alert", newHookNotifier)
+}
+
+// runHook runs command with sh, input on its standard input, and its
+// output passed through to the standard error of gcvis.
+func runHook(command string, input []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%q timed out after %s", command, hookTimeout)
+		}
+		return fmt.Errorf("%q: %v", command, err)
+	}
+	return nil
+}
+
+// hookSink runs a command for the events, one at a time from the goroutine
+// of a batcher, so that a slow command does not hold up the parser.
+type hookSink struct {
+	command    string
+	thresholds []threshold
+	batcher    *batcher
+}
+
+func newHookSink() (Sink, error) {
+	if *onEvent == "" {
+		return nil, nil
+	}
+
+	s := &hookSink{command: *onEvent, thresholds: onEventThresholds}
+	s.batcher = newBatcher(1, time.Second, s.run)
+	return s, nil
+}
+
+func (s *hookSink) ConsumeGC(t *gctrace) error {
+	return s.add(newGCLogLine(t), gcMetrics(t))
+}
+
+func (s *hookSink) ConsumeScvg(t *scvgtrace) error {
+	return s.add(newScvgLogLine(t), scvgMetrics(t))
+}
+
+func (s *hookSink) add(l *logLine, metrics []metric) error {
+	body, ok, err := webhookBody(l, metrics, s.thresholds)
+	if !ok || err != nil {
+		return err
+	}
+
+	s.batcher.Add(body)
+	return nil
+}
+
+func (s *hookSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *hookSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+func (s *hookSink) run(batch []interface{}) {
+	for _, body := range batch {
+		if err := runHook(s.command, body.([]byte)); err != nil {
+			errorf("-on-event: %v", err)
+		}
+	}
+}
+
+// hookNotifier runs a command for every alert firing or recovering.
+type hookNotifier struct {
+	command string
+}
+
+func newHookNotifier() (Notifier, error) {
+	if *onAlert == "" {
+		return nil, nil
+	}
+	return &hookNotifier{command: *onAlert}, nil
+}
+
+func (n *hookNotifier) Notify(a *Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return runHook(n.command, body)
+}
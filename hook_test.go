@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHookSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "events")
+
+	savedCommand, savedThresholds := *onEvent, onEventThresholds
+	*onEvent, onEventThresholds = "cat >> "+out+"; echo >> "+out, nil
+	onEventThresholds.Set("stw_mark_clock_milliseconds>5")
+	defer func() {
+		*onEvent, onEventThresholds = savedCommand, savedThresholds
+	}()
+
+	sink, err := newHookSink()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{NumGC: 1, STWMclock: 1})
+	sink.ConsumeGC(&gctrace{NumGC: 2, STWMclock: 8})
+	sink.Close()
+
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected a single event exceeding the threshold. Got %q instead.", b)
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal([]byte(lines[0]), &payload); err != nil {
+		t.Fatalf("Expected the event as JSON. Got %q instead: %v", lines[0], err)
+	}
+	if payload.Event.GC == nil || payload.Event.GC.NumGC != 2 || len(payload.Exceeded) != 1 || payload.Exceeded[0].Value != 8 {
+		t.Errorf("Expected cycle 2 exceeding the threshold. Got %+v instead.", payload)
+	}
+}
+
+func TestHookNotifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "alert.json")
+
+	n := &hookNotifier{command: "cat > " + out}
+	if err := n.Notify(&Alert{Rule: "stw>10ms", Metric: "stw", Firing: true, Value: 12}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var a Alert
+	b, _ := ioutil.ReadFile(out)
+	if err := json.Unmarshal(b, &a); err != nil || a.Rule != "stw>10ms" || !a.Firing || a.Value != 12 {
+		t.Errorf("Expected the alert as JSON. Got %q, %v instead.", b, err)
+	}
+
+	if err := (&hookNotifier{command: "exit 3"}).Notify(&Alert{}); err == nil {
+		t.Errorf("Expected a failing command to be an error.")
+	}
+}
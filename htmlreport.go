@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+)
+
+var htmlReportOut = flag.String("html-report", "", "path of a standalone HTML report, graphing the whole run with its statistics, to write when gcvis exits")
+
+// writeHTMLReport writes a standalone page graphing the traces of graph,
+// with the statistics of stats, which can be opened without a running
+// gcvis.
+func writeHTMLReport(w io.Writer, graph *Graph, stats *runStats) error {
+	return graph.WriteReport(w, newExitSummary(stats))
+}
+
+func writeHTMLReportFile(path string, graph *Graph, stats *runStats) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = writeHTMLReport(f, graph, stats)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -3,18 +3,30 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"math"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// SessionHistory gives access to the sessions stored by past gcvis runs.
+type SessionHistory interface {
+	Sessions() ([]Session, error)
+	SessionGraph(id int64, tmpl string) (*Graph, error)
+	Annotate(text string) error
+}
+
 type HttpServer struct {
 	graph    *Graph
+	self     *Graph
+	history  SessionHistory
 	listener net.Listener
 	iface    string
 	port     string
+	retries  int
 
 	listenerMtx sync.Mutex
 }
@@ -29,20 +41,52 @@ func NewHttpServer(iface string, port string, graph *Graph) *HttpServer {
 	return h
 }
 
+// SetHistory serves the past sessions of history. It must be called before
+// Start.
+func (h *HttpServer) SetHistory(history SessionHistory) {
+	h.history = history
+}
+
+// SetPortRetry makes the server try up to retries successive ports when its
+// port is already in use. It must be called before Start.
+func (h *HttpServer) SetPortRetry(retries int) {
+	h.retries = retries
+}
+
+// SetSelf serves self, the graph of the GC of gcvis itself, at /self/. It
+// must be called before Start.
+func (h *HttpServer) SetSelf(self *Graph) {
+	h.self = self
+}
+
 func (h *HttpServer) Start() {
 	serveMux := http.NewServeMux()
 
-	serveMux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
-		h.graph.Write(w)
-	})
+	handleGraph(serveMux, "/", h.graph)
+	if h.self != nil {
+		handleGraph(serveMux, "/self/", h.self)
+	}
 
-	serveMux.HandleFunc("/graph.json", func(w http.ResponseWriter, req *http.Request) {
+	if scrapeMetrics != nil {
+		serveMux.Handle("/metrics", scrapeMetrics)
+	}
+
+	serveMux.HandleFunc("/annotations", h.handleAnnotation)
+	serveMux.HandleFunc("/api/v1/summary", h.handleSummary)
+	serveMux.HandleFunc("/api/v1/bundle", h.handleBundle)
+	serveMux.HandleFunc("/api/v1/snapshot", h.handleSnapshot)
+	serveMux.HandleFunc("/api/v1/high-water", h.handleHighWater)
+	serveMux.HandleFunc("/api/v1/events", h.handleEvents)
+	serveMux.HandleFunc("/events", h.handleEventsPage)
+	serveMux.Handle("/profiles/", profiler)
+	serveMux.HandleFunc("/alerts.json", func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		encoder := json.NewEncoder(w)
-		if err := encoder.Encode(h.graph); err != nil {
-			log.Fatalf("An error occurred while serving JSON endpoint: %v", err)
-		}
+		json.NewEncoder(w).Encode(alertBanner.Firing())
 	})
+	if h.history != nil {
+		serveMux.HandleFunc("/sessions.json", h.handleSessions)
+		serveMux.HandleFunc("/sessions/", h.handleSession)
+	}
 
 	server := http.Server{
 		Handler:      serveMux,
@@ -53,6 +97,167 @@ func (h *HttpServer) Start() {
 	server.Serve(h.Listener())
 }
 
+// handleGraph serves the page of graph at prefix, and its data at
+// prefix/graph.json.
+func handleGraph(serveMux *http.ServeMux, prefix string, graph *Graph) {
+	serveMux.HandleFunc(prefix, func(w http.ResponseWriter, req *http.Request) {
+		graph.Write(w)
+	})
+
+	serveMux.HandleFunc(prefix+"graph.json", func(w http.ResponseWriter, req *http.Request) {
+		resp, err := graphResponse(graph, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		if err := encoder.Encode(resp); err != nil {
+			fatalf("An error occurred while serving JSON endpoint: %v", err)
+		}
+	})
+}
+
+// graphUpdate is the response to a request for the points added since a
+// cursor: the new points and the cursor to pull the next ones with, or the
+// whole graph, if Full is set, as clients must then start over.
+type graphUpdate struct {
+	*Graph
+	Cursor string
+	Full   bool
+}
+
+// graphResponse returns what is served at graph.json: with the since query
+// parameter, a cursor of a previous response, the graph update since then;
+// otherwise the graph, or the window of it between the from and to query
+// parameters. Full graphs are downsampled to the points parameter.
+func graphResponse(graph *Graph, req *http.Request) (interface{}, error) {
+	query := req.URL.Query()
+	if _, ok := query["since"]; !ok {
+		return graphWindow(graph, req)
+	}
+
+	if update, ok := graph.Since(query.Get("since")); ok {
+		return &graphUpdate{Graph: update, Cursor: update.Cursor()}, nil
+	}
+
+	points, err := pointsParam(req)
+	if err != nil {
+		return nil, err
+	}
+	full := graph.Window(math.Inf(-1), math.Inf(1), points)
+	return &graphUpdate{Graph: full, Cursor: full.Cursor(), Full: true}, nil
+}
+
+// pointsParam returns the points query parameter, or 0.
+func pointsParam(req *http.Request) (int, error) {
+	v := req.URL.Query().Get("points")
+	if v == "" {
+		return 0, nil
+	}
+
+	points, err := strconv.Atoi(v)
+	if err != nil || points < 0 {
+		return 0, fmt.Errorf("invalid points %q", v)
+	}
+	if points > maxDownsamplePoints {
+		points = maxDownsamplePoints
+	}
+	return points, nil
+}
+
+// graphWindow returns a snapshot of graph, or the window of it between the from and to
+// query parameters, in seconds, downsampled to the points parameter, the
+// number of pixels the client plots it on.
+func graphWindow(graph *Graph, req *http.Request) (*Graph, error) {
+	query := req.URL.Query()
+	if query.Get("points") == "" {
+		return graph.Snapshot(), nil
+	}
+
+	points, err := pointsParam(req)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := math.Inf(-1), math.Inf(1)
+	for name, bound := range map[string]*float64{"from": &from, "to": &to} {
+		if v := query.Get(name); v != "" {
+			if *bound, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("invalid %s %q", name, v)
+			}
+		}
+	}
+
+	return graph.Window(from, to, points), nil
+}
+
+func (h *HttpServer) handleSessions(w http.ResponseWriter, req *http.Request) {
+	sessions, err := h.history.Sessions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// handleSession serves the graph of a past session, at /sessions/<id>/ and
+// /sessions/<id>/graph.json.
+func (h *HttpServer) handleSession(w http.ResponseWriter, req *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/sessions/"), "/", 2)
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) != 2 || (parts[1] != "" && parts[1] != "graph.json") {
+		http.NotFound(w, req)
+		return
+	}
+
+	graph, err := h.history.SessionGraph(id, GCVIS_TMPL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if graph == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	if parts[1] == "" {
+		graph.Write(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(graph)
+}
+
+// handleAnnotation attaches the text form value to the graph, and to the
+// current session if stored.
+func (h *HttpServer) handleAnnotation(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	text := req.FormValue("text")
+	if text == "" {
+		http.Error(w, "missing text", http.StatusBadRequest)
+		return
+	}
+
+	h.graph.Annotate(text)
+	if h.history != nil {
+		if err := h.history.Annotate(text); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *HttpServer) Close() {
 	h.Listener().Close()
 }
@@ -69,12 +274,37 @@ func (h *HttpServer) Listener() net.Listener {
 		return h.listener
 	}
 
-	ifaceAndPort := fmt.Sprintf("%v:%v", h.iface, h.port)
-	listener, err := net.Listen("tcp4", ifaceAndPort)
+	listener, err := listenWithRetry(h.iface, h.port, h.retries)
 	if err != nil {
-		log.Fatal(err)
+		fatalf("%v", err)
 	}
 
 	h.listener = listener
 	return h.listener
 }
+
+// listenWithRetry listens on iface:port or, if it is in use, on up to
+// retries successive ports.
+func listenWithRetry(iface string, port string, retries int) (net.Listener, error) {
+	listener, err := net.Listen("tcp4", fmt.Sprintf("%v:%v", iface, port))
+	first, convErr := strconv.Atoi(port)
+	if err == nil || convErr != nil || first == 0 || !isAddrInUse(err) {
+		return listener, err
+	}
+
+	for p := first + 1; p <= first+retries && p <= 65535; p++ {
+		var retryErr error
+		listener, retryErr = net.Listen("tcp4", fmt.Sprintf("%v:%v", iface, p))
+		if retryErr == nil {
+			infof("port %s is in use, listening on port %d", port, p)
+			return listener, nil
+		}
+		if !isAddrInUse(retryErr) {
+			return nil, retryErr
+		}
+	}
+	if retries > 0 {
+		return nil, fmt.Errorf("%v, and so are the %d ports after it (-port-retry)", err, retries)
+	}
+	return nil, err
+}
@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"testing"
 )
 
 func TestHttpServerListener(t *testing.T) {
 	graph := NewGraph("fake title", GCVIS_TMPL)
-	server := NewHttpServer("127.0.0.1", "0", &graph)
+	server := NewHttpServer("127.0.0.1", "0", graph)
 
 	url := server.Url()
 
@@ -23,7 +25,7 @@ func TestHttpServerListener(t *testing.T) {
 func TestHttpServerResponse(t *testing.T) {
 	graph := NewGraph("fake title", GCVIS_TMPL)
 	graph.AddGCTraceGraphPoint(&gctrace{})
-	server := NewHttpServer("127.0.0.1", "0", &graph)
+	server := NewHttpServer("127.0.0.1", "0", graph)
 
 	go server.Start()
 	defer server.Close()
@@ -62,7 +64,7 @@ func TestHttpServerResponse(t *testing.T) {
 func TestHttpServerJsonEndpoint(t *testing.T) {
 	graph := NewGraph("fake title", GCVIS_TMPL)
 	graph.AddGCTraceGraphPoint(&gctrace{Heap1: 10})
-	server := NewHttpServer("127.0.0.1", "0", &graph)
+	server := NewHttpServer("127.0.0.1", "0", graph)
 
 	go server.Start()
 	defer server.Close()
@@ -78,7 +80,7 @@ func TestHttpServerJsonEndpoint(t *testing.T) {
 		t.Errorf("Error while reading response body: %v", err)
 	}
 
-	result, err := json.Marshal(graph)
+	result, err := json.Marshal(graph.Snapshot())
 	if err != nil {
 		t.Errorf("Error marshalling graph: %v", err)
 	}
@@ -87,3 +89,98 @@ func TestHttpServerJsonEndpoint(t *testing.T) {
 		t.Errorf("Expected graph to be a json string.\nExpected: %v\nGot: %v", string(result), string(body))
 	}
 }
+
+func TestHttpServerJsonEndpointLastGC(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 3, Heap0: 412, Heap1: 512, Heap3: 389, STWSclock: 0.2, STWMclock: 1.0})
+	server := NewHttpServer("127.0.0.1", "0", graph)
+
+	go server.Start()
+	defer server.Close()
+
+	response, err := http.Get(server.Url() + "graph.json")
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	defer response.Body.Close()
+
+	var result struct{ LastGC *GCSummary }
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding response body: %v", err)
+	}
+
+	if result.LastGC == nil {
+		t.Fatalf("Expected LastGC to be set.")
+	}
+	if result.LastGC.NumGC != 3 || result.LastGC.HeapBefore != 412 || result.LastGC.HeapAfter != 389 || result.LastGC.HeapGoal != 512 {
+		t.Errorf("Unexpected LastGC summary: %+v", result.LastGC)
+	}
+}
+
+func TestHttpServerAnnotations(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	server := NewHttpServer("127.0.0.1", "0", graph)
+
+	go server.Start()
+	defer server.Close()
+
+	response, err := http.PostForm(server.Url()+"annotations", map[string][]string{"text": {"deploy"}})
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status 204 without a database. Got %d instead.", response.StatusCode)
+	}
+
+	if s := graph.Snapshot(); len(s.Annotations) != 1 || s.Annotations[0].Text != "deploy" {
+		t.Errorf("Expected the graph to be annotated. Got %v instead.", s.Annotations)
+	}
+}
+
+func TestHttpServerSelf(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	self := NewGraph("gcvis itself", GCVIS_TMPL)
+	self.AddGCTraceGraphPoint(&gctrace{NumGC: 1, Heap1: 4})
+	server := NewHttpServer("127.0.0.1", "0", graph)
+	server.SetSelf(self)
+
+	go server.Start()
+	defer server.Close()
+
+	response, err := http.Get(server.Url() + "self/graph.json")
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	defer response.Body.Close()
+
+	var result struct{ LastGC *GCSummary }
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		t.Fatalf("Response is not valid JSON: %v", err)
+	}
+	if result.LastGC == nil || result.LastGC.HeapGoal != 4 {
+		t.Errorf("Expected the graph of gcvis itself. Got %+v instead.", result.LastGC)
+	}
+}
+
+func TestHttpServerPortRetry(t *testing.T) {
+	taken, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	port := strconv.Itoa(taken.Addr().(*net.TCPAddr).Port)
+
+	if _, err := listenWithRetry("127.0.0.1", port, 0); err == nil {
+		t.Fatalf("Expected port %s to be in use.", port)
+	}
+
+	listener, err := listenWithRetry("127.0.0.1", port, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer listener.Close()
+	if got := listener.Addr().(*net.TCPAddr).Port; strconv.Itoa(got) == port {
+		t.Errorf("Expected another port than %s. Got %d instead.", port, got)
+	}
+}
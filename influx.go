@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var influxOut = flag.String("influx-out", "off", "where to write InfluxDB line protocol: stderr, stdout, a file path, udp://host:port, an InfluxDB v2 http(s):// URL or off")
+var influxOrg = flag.String("influx-org", "", "organization written to, with the InfluxDB v2 HTTP API")
+var influxBucket = flag.String("influx-bucket", "", "bucket written to, with the InfluxDB v2 HTTP API")
+var influxToken = flag.String("influx-token", "", "API token used with the InfluxDB v2 HTTP API")
+var influxBatchSize = flag.Int("influx-batch-size", 500, "maximum number of lines sent in a single InfluxDB v2 HTTP API request")
+var influxBatchWait = flag.Duration("influx-batch-wait", time.Second, "maximum time a line waits before being sent to the InfluxDB v2 HTTP API")
+
+func init() {
+	RegisterSink("influx", newInfluxSink)
+}
+
+// influxSink writes traces as InfluxDB line protocol, in the gc and scvg
+// measurements. Every line is written on its own, except with the HTTP API
+// where lines are batched.
+type influxSink struct {
+	tags string
+	w    io.WriteCloser
+	api  *influxWriteAPI
+}
+
+func newInfluxSink() (Sink, error) {
+	s := &influxSink{tags: influxTags(metricLabels())}
+
+	var err error
+	switch {
+	case strings.HasPrefix(*influxOut, "http://"), strings.HasPrefix(*influxOut, "https://"):
+		s.api, err = newInfluxWriteAPI(*influxOut, *influxOrg, *influxBucket, *influxToken)
+	case strings.HasPrefix(*influxOut, "udp://"):
+		s.w, err = net.Dial("udp", strings.TrimPrefix(*influxOut, "udp://"))
+	default:
+		s.w, err = openOutput(*influxOut)
+		if s.w == nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *influxSink) ConsumeGC(t *gctrace) error {
+	return s.write(influxLine("gc", s.tags, traceTime(t.ElapsedTime), []influxField{
+		{"ElapsedTime", influxFloat(t.ElapsedTime)},
+		{"NumGC", influxInt(t.NumGC)},
+		{"Heap0", influxInt(t.Heap0)},
+		{"Heap1", influxInt(t.Heap1)},
+		{"Heap2", influxInt(t.Heap2)},
+		{"Heap3", influxInt(t.Heap3)},
+		{"STWSclock", influxFloat(t.STWSclock)},
+		{"MASclock", influxFloat(t.MASclock)},
+		{"STWMclock", influxFloat(t.STWMclock)},
+		{"STWScpu", influxFloat(t.STWScpu)},
+		{"MASAssistcpu", influxFloat(t.MASAssistcpu)},
+		{"MASBGcpu", influxFloat(t.MASBGcpu)},
+		{"MASIdlecpu", influxFloat(t.MASIdlecpu)},
+		{"STWMcpu", influxFloat(t.STWMcpu)},
+	}))
+}
+
+func (s *influxSink) ConsumeScvg(t *scvgtrace) error {
+	return s.write(influxLine("scvg", s.tags, traceTime(t.ElapsedTime), []influxField{
+		{"inuse", influxInt(t.Inuse)},
+		{"idle", influxInt(t.Idle)},
+		{"sys", influxInt(t.Sys)},
+		{"released", influxInt(t.Released)},
+		{"consumed", influxInt(t.Consumed)},
+	}))
+}
+
+func (s *influxSink) write(line string) error {
+	if s.api != nil {
+		s.api.batcher.Add(line)
+		return nil
+	}
+
+	_, err := io.WriteString(s.w, line)
+	return err
+}
+
+func (s *influxSink) Flush() error {
+	if s.api != nil {
+		s.api.batcher.Flush()
+	}
+	return nil
+}
+
+func (s *influxSink) Close() error {
+	if s.api != nil {
+		s.api.batcher.Close()
+		return nil
+	}
+	return s.w.Close()
+}
+
+// influxWriteAPI sends batches of lines to the InfluxDB v2 write endpoint.
+type influxWriteAPI struct {
+	url    string
+	header http.Header
+
+	client  *http.Client
+	batcher *batcher
+}
+
+func newInfluxWriteAPI(base, org, bucket, token string) (*influxWriteAPI, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("-influx-bucket is required with the HTTP API")
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/api/v2/write"
+	}
+	q := u.Query()
+	q.Set("bucket", bucket)
+	q.Set("precision", "ns")
+	if org != "" {
+		q.Set("org", org)
+	}
+	u.RawQuery = q.Encode()
+
+	api := &influxWriteAPI{
+		url:    u.String(),
+		header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		client: &http.Client{Timeout: pushTimeout},
+	}
+	if token != "" {
+		api.header.Set("Authorization", "Token "+token)
+	}
+	api.batcher = newBatcher(*influxBatchSize, *influxBatchWait, api.send)
+
+	return api, nil
+}
+
+func (api *influxWriteAPI) send(batch []interface{}) {
+	var body bytes.Buffer
+	for _, line := range batch {
+		body.WriteString(line.(string))
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d InfluxDB lines", len(batch)), func() (bool, error) {
+		return postBody(api.client, api.url, api.header, body.Bytes())
+	})
+}
+
+type influxField struct {
+	key, value string
+}
+
+// influxLine formats a line of InfluxDB line protocol, terminated by a
+// newline. tags must already be escaped, as returned by influxTags.
+func influxLine(measurement, tags string, ts time.Time, fields []influxField) string {
+	var b strings.Builder
+	b.WriteString(influxMeasurementEscaper.Replace(measurement))
+	b.WriteString(tags)
+	for i, f := range fields {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(influxKeyEscaper.Replace(f.key))
+		b.WriteByte('=')
+		b.WriteString(f.value)
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(ts.UnixNano(), 10))
+	b.WriteByte('\n')
+
+	return b.String()
+}
+
+// influxTags formats labels as the tag set of a line, sorted by key as
+// InfluxDB recommends.
+func influxTags(labels map[string]string) string {
+	var b strings.Builder
+	for _, name := range sortedLabelNames(labels) {
+		if labels[name] == "" {
+			continue
+		}
+		b.WriteByte(',')
+		b.WriteString(influxKeyEscaper.Replace(name))
+		b.WriteByte('=')
+		b.WriteString(influxKeyEscaper.Replace(labels[name]))
+	}
+
+	return b.String()
+}
+
+func influxInt(v int64) string {
+	return strconv.FormatInt(v, 10) + "i"
+}
+
+func influxFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxKeyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
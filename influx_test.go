@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInfluxLine(t *testing.T) {
+	tags := influxTags(map[string]string{"srv": "my service", "host": "a,b", "empty": ""})
+	line := influxLine("gc", tags, time.Unix(1, 5), []influxField{
+		{"NumGC", influxInt(3)},
+		{"STWSclock", influxFloat(0.25)},
+	})
+
+	expected := `gc,host=a\,b,srv=my\ service NumGC=3i,STWSclock=0.25 1000000005` + "\n"
+	if line != expected {
+		t.Errorf("Expected line to equal %q. Got %q instead.", expected, line)
+	}
+}
+
+func TestInfluxSinkWriteAPI(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		body  string
+		query string
+		auth  string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+
+		mu.Lock()
+		body += string(b)
+		query = req.URL.Path + "?" + req.URL.RawQuery
+		auth = req.Header.Get("Authorization")
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	saved := []string{*influxOut, *influxBucket, *influxToken}
+	*influxOut, *influxBucket, *influxToken = server.URL, "gc", "secret"
+	defer func() { *influxOut, *influxBucket, *influxToken = saved[0], saved[1], saved[2] }()
+
+	sink, err := newInfluxSink()
+	if err != nil {
+		t.Fatalf("newInfluxSink returned an error: %v", err)
+	}
+
+	sink.ConsumeGC(&gctrace{NumGC: 7})
+	sink.ConsumeScvg(&scvgtrace{Inuse: 12})
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if query != "/api/v2/write?bucket=gc&precision=ns" {
+		t.Errorf("Unexpected request URL %q", query)
+	}
+	if auth != "Token secret" {
+		t.Errorf("Unexpected Authorization header %q", auth)
+	}
+
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "gc,") || !strings.Contains(lines[0], "NumGC=7i") || !strings.HasPrefix(lines[1], "scvg,") {
+		t.Errorf("Unexpected lines: %q", lines)
+	}
+}
+
+func TestInfluxSinkOff(t *testing.T) {
+	sink, err := newInfluxSink()
+	if err != nil || sink != nil {
+		t.Errorf("Expected the sink to be off by default. Got %v, %v instead.", sink, err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var journald = flag.Bool("journald", false, "write every event to the systemd journal, with its metrics as fields")
+
+// journaldSocket is where journald listens for native protocol datagrams.
+var journaldSocket = "/run/systemd/journal/socket"
+
+func init() {
+	RegisterSink("journald", newJournaldSink)
+}
+
+// journaldSink writes events with the journald native protocol, so they
+// can be filtered by field, e.g. journalctl GCVIS_EVENT=gc. Metrics become
+// fields named after them, e.g. GCVIS_HEAP_GOAL_MEGABYTES, and labels
+// GCVIS_LABEL_<name> fields.
+type journaldSink struct {
+	conn   net.Conn
+	common []byte // fields sent with every event
+}
+
+func newJournaldSink() (Sink, error) {
+	if !*journald {
+		return nil, nil
+	}
+
+	conn, err := net.Dial("unixgram", journaldSocket)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to journald: %v", err)
+	}
+
+	var common bytes.Buffer
+	writeJournaldField(&common, "PRIORITY", "6") // informational
+	writeJournaldField(&common, "SYSLOG_IDENTIFIER", "gcvis")
+	writeJournaldField(&common, "GCVIS_SERVICE", *serviceName)
+	for k, v := range extraLabels {
+		writeJournaldField(&common, "GCVIS_LABEL_"+journaldFieldName(k), v)
+	}
+
+	return &journaldSink{conn: conn, common: common.Bytes()}, nil
+}
+
+func (s *journaldSink) ConsumeGC(t *gctrace) error {
+	return s.send("gc", gcMessage, gcMetrics(t))
+}
+
+func (s *journaldSink) ConsumeScvg(t *scvgtrace) error {
+	return s.send("scvg", scvgMessage, scvgMetrics(t))
+}
+
+func (s *journaldSink) send(event, msg string, metrics []metric) error {
+	var entry bytes.Buffer
+	entry.Write(s.common)
+	writeJournaldField(&entry, "MESSAGE", msg)
+	writeJournaldField(&entry, "GCVIS_EVENT", event)
+	for _, m := range metrics {
+		writeJournaldField(&entry, journaldFieldName(m.Name), strconv.FormatFloat(m.Value, 'f', -1, 64))
+	}
+
+	_, err := s.conn.Write(entry.Bytes())
+	return err
+}
+
+// writeJournaldField writes name=value, or the binary safe form when value
+// spans several lines.
+func writeJournaldField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	b.WriteString(name + "\n")
+	b.Write(size[:])
+	b.WriteString(value + "\n")
+}
+
+// journaldFieldName turns name into a valid journal field name: upper case
+// letters, digits and underscores, not starting with an underscore.
+func journaldFieldName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+
+	return strings.TrimLeft(name, "_")
+}
+
+func (s *journaldSink) Flush() error {
+	return nil
+}
+
+func (s *journaldSink) Close() error {
+	return s.conn.Close()
+}
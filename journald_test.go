@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournaldSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	conn, err := net.ListenPacket("unixgram", socket)
+	if err != nil {
+		t.Fatalf("ListenPacket returned an error: %v", err)
+	}
+	defer conn.Close()
+
+	savedSocket, savedOn := journaldSocket, *journald
+	journaldSocket, *journald = socket, true
+	defer func() { journaldSocket, *journald = savedSocket, savedOn }()
+
+	extraLabels["team"] = "gc\nteam"
+	defer delete(extraLabels, "team")
+
+	sink, err := newJournaldSink()
+	if err != nil {
+		t.Fatalf("newJournaldSink returned an error: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.ConsumeGC(&gctrace{Heap1: 33, STWMclock: 0.5}); err != nil {
+		t.Fatalf("ConsumeGC returned an error: %v", err)
+	}
+
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom returned an error: %v", err)
+	}
+	entry := string(buf[:n])
+
+	for _, expected := range []string{
+		"PRIORITY=6\n",
+		"SYSLOG_IDENTIFIER=gcvis\n",
+		"GCVIS_LABEL_TEAM\n\x07\x00\x00\x00\x00\x00\x00\x00gc\nteam\n",
+		"MESSAGE=" + gcMessage + "\n",
+		"GCVIS_EVENT=gc\n",
+		"GCVIS_HEAP_GOAL_MEGABYTES=33\n",
+		"GCVIS_STW_MARK_CLOCK_MILLISECONDS=0.5\n",
+	} {
+		if !strings.Contains(entry, expected) {
+			t.Errorf("Expected entry to contain %q. Got %q instead.", expected, entry)
+		}
+	}
+}
+
+func TestJournaldFieldName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"gcvis_heap_goal_megabytes": "GCVIS_HEAP_GOAL_MEGABYTES",
+		"_private":                  "PRIVATE",
+		"k8s.pod-name":              "K8S_POD_NAME",
+	} {
+		if got := journaldFieldName(name); got != expected {
+			t.Errorf("Expected %s for %s. Got %s instead.", expected, name, got)
+		}
+	}
+}
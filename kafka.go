@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+var kafkaBrokers = flag.String("kafka-brokers", "", "comma separated host:port addresses of Kafka brokers to publish events to")
+var kafkaTopic = flag.String("kafka-topic", "gcvis", "Kafka topic events are published to")
+var kafkaAvroSchema = flag.String("kafka-avro-schema", "", "path of an Avro record schema to encode events with, instead of JSON")
+var kafkaBatchWait = flag.Duration("kafka-batch-wait", time.Second, "maximum time an event waits before being published to Kafka")
+
+func init() {
+	RegisterSink("kafka", newKafkaSink)
+}
+
+// kafkaSink publishes every event to a Kafka topic, keyed by service so the
+// events of a service stay ordered. Values are the log lines as JSON, or
+// Avro records when a schema is given.
+type kafkaSink struct {
+	writer *kafka.Writer
+	avro   *avroSchema
+}
+
+func newKafkaSink() (Sink, error) {
+	if *kafkaBrokers == "" {
+		return nil, nil
+	}
+
+	s := &kafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(strings.Split(*kafkaBrokers, ",")...),
+			Topic:        *kafkaTopic,
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: *kafkaBatchWait,
+			WriteTimeout: pushTimeout,
+			MaxAttempts:  pushMaxRetries,
+			Async:        true,
+			Completion: func(messages []kafka.Message, err error) {
+				if err != nil {
+					errorf("cannot publish %d events to Kafka: %v", len(messages), err)
+				}
+			},
+		},
+	}
+
+	if *kafkaAvroSchema != "" {
+		b, err := ioutil.ReadFile(*kafkaAvroSchema)
+		if err != nil {
+			return nil, err
+		}
+		if s.avro, err = parseAvroSchema(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *kafkaSink) ConsumeGC(t *gctrace) error {
+	return s.publish(newGCLogLine(t))
+}
+
+func (s *kafkaSink) ConsumeScvg(t *scvgtrace) error {
+	return s.publish(newScvgLogLine(t))
+}
+
+func (s *kafkaSink) publish(l *logLine) error {
+	value, err := s.encode(l)
+	if err != nil {
+		return err
+	}
+
+	// The writer being asynchronous, this only queues the message.
+	return s.writer.WriteMessages(context.Background(), kafka.Message{
+		Key:   []byte(l.Service),
+		Value: value,
+	})
+}
+
+// encode encodes l as JSON, or as an Avro record whose fields are looked up
+// among the log line fields and its gc or scvg fields, e.g. srv or
+// HeapGoal. The time field is in milliseconds since the epoch.
+func (s *kafkaSink) encode(l *logLine) ([]byte, error) {
+	b, err := json.Marshal(l)
+	if err != nil || s.avro == nil {
+		return b, err
+	}
+
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, err
+	}
+	for _, nested := range []string{"gc", "scvg"} {
+		if fields, ok := values[nested].(map[string]interface{}); ok {
+			delete(values, nested)
+			for k, v := range fields {
+				values[k] = v
+			}
+		}
+	}
+	values["time"] = float64(timestampMillis(l.Time))
+
+	return s.avro.encode(values)
+}
+
+// Flush does nothing: the writer publishes batches on its own, every
+// -kafka-batch-wait.
+func (s *kafkaSink) Flush() error {
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestKafkaSinkEncode(t *testing.T) {
+	trace := &gctrace{NumGC: 3, Heap1: 8}
+
+	s := &kafkaSink{}
+	b, err := s.encode(newGCLogLine(trace))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var l logLine
+	if err := json.Unmarshal(b, &l); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if l.Message != gcMessage || l.GC == nil || l.GC.HeapGoal != 8 {
+		t.Errorf("Expected a GC log line with a heap goal of 8. Got %s instead.", b)
+	}
+
+	s.avro, err = parseAvroSchema([]byte(`{"type": "record", "fields": [
+		{"name": "msg", "type": "string"},
+		{"name": "NumGC", "type": "long"},
+		{"name": "Inuse", "type": ["null", "long"]}
+	]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	b, err = s.encode(newGCLogLine(trace))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := string(append(append([]byte{byte(len(gcMessage) * 2)}, gcMessage...), 0x06, 0x00))
+	if string(b) != expected {
+		t.Errorf("Expected %x. Got %x instead.", expected, b)
+	}
+}
+
+func TestNewKafkaSinkOff(t *testing.T) {
+	sink, err := newKafkaSink()
+	if sink != nil || err != nil {
+		t.Errorf("Expected no sink without brokers. Got %v, %v instead.", sink, err)
+	}
+}
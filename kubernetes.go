@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	kubernetesLabels = flag.Bool("kubernetes", false, "Add the pod name, namespace and node, and the labels of the pod, told by the Kubernetes downward API, to every log line and metric, as -label does")
+	podInfoDir       = flag.String("kubernetes-podinfo", "/etc/podinfo", "Directory of the downward API volume of the pod, whose labels, name, namespace and node files are read if present")
+)
+
+// kubernetesEnv are the environment variables the downward API is
+// conventionally mapped to, by label.
+var kubernetesEnv = map[string]string{
+	"pod":       "POD_NAME",
+	"namespace": "POD_NAMESPACE",
+	"node":      "NODE_NAME",
+}
+
+// applyKubernetes adds the labels of the pod gcvis runs in to the -label
+// ones, when -kubernetes is set. Labels set with -label are kept.
+func applyKubernetes() error {
+	if !*kubernetesLabels {
+		return nil
+	}
+
+	labels, err := podLabels(*podInfoDir, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		infof("-kubernetes: no pod name, namespace, node or labels in the environment or %s", *podInfoDir)
+	}
+	for k, v := range labels {
+		if _, ok := extraLabels[k]; !ok {
+			extraLabels[k] = v
+		}
+	}
+	return nil
+}
+
+// podLabels returns the labels of the pod told by the downward API, as
+// environment variables looked up by getenv or as files of dir: pod,
+// namespace and node, and label_<name> for each label of the pod, its
+// name sanitized as kube-state-metrics does, e.g. label_app_kubernetes_io_name.
+// Files take precedence over the environment.
+func podLabels(dir string, getenv func(string) string) (map[string]string, error) {
+	labels := map[string]string{}
+	for label, env := range kubernetesEnv {
+		if v := getenv(env); v != "" {
+			labels[label] = v
+		}
+	}
+
+	files := map[string]string{"pod": "name", "namespace": "namespace", "node": "node"}
+	for label, name := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the downward API: %v", err)
+		}
+		if v := strings.TrimSpace(string(b)); v != "" {
+			labels[label] = v
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, "labels"))
+	if os.IsNotExist(err) {
+		return labels, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the downward API: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		// key="value", the value quoted as Go does.
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid pod label %q in %s", line, f.Name())
+		}
+		v, err := strconv.Unquote(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod label %q in %s", line, f.Name())
+		}
+		labels["label_"+sanitizeLabelName(kv[0])] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read the downward API: %v", err)
+	}
+	return labels, nil
+}
+
+// sanitizeLabelName replaces the characters of name that metric label
+// names cannot have, such as the dots and slashes of Kubernetes labels,
+// with underscores.
+func sanitizeLabelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPodLabels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := map[string]string{"POD_NAME": "web-7d9f", "POD_NAMESPACE": "shop"}
+	getenv := func(key string) string { return env[key] }
+
+	labels, err := podLabels(dir, getenv)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := map[string]string{"pod": "web-7d9f", "namespace": "shop"}; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected the labels of the environment %v. Got %v instead.", expected, labels)
+	}
+
+	files := map[string]string{
+		"labels":    "app.kubernetes.io/name=\"web\"\npod-template-hash=\"7d9f\"\n",
+		"namespace": "shop-canary\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	labels, err = podLabels(dir, getenv)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"pod":                          "web-7d9f",
+		"namespace":                    "shop-canary",
+		"label_app_kubernetes_io_name": "web",
+		"label_pod_template_hash":      "7d9f",
+	}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected the labels of the files %v. Got %v instead.", expected, labels)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "labels"), []byte("app=web\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := podLabels(dir, getenv); err == nil {
+		t.Errorf("Expected an unquoted label value to be an error.")
+	}
+}
+
+func TestApplyKubernetes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "labels"), []byte("team=\"core\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "name"), []byte("web-7d9f"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(saved labelsFlag) { extraLabels = saved }(extraLabels)
+	defer func(saved bool) { *kubernetesLabels = saved }(*kubernetesLabels)
+	defer func(saved string) { *podInfoDir = saved }(*podInfoDir)
+
+	extraLabels = labelsFlag{"pod": "overridden"}
+	*podInfoDir = dir
+	*kubernetesLabels = true
+	if err := applyKubernetes(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if extraLabels["pod"] != "overridden" || extraLabels["label_team"] != "core" {
+		t.Errorf("Expected the pod labels added, without overriding -label. Got %v instead.", extraLabels)
+	}
+	if labels := metricLabels(); labels["label_team"] != "core" {
+		t.Errorf("Expected the pod labels on the metrics. Got %v instead.", labels)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var leakAfter = flag.Duration("leak-after", 0, "warn of a leak once the live heap has kept growing for this long, e.g. 30m, with the time left before it reaches the memory limit. 0 turns it off")
+var memoryLimit = flag.String("memory-limit", "", "memory limit of the program, e.g. 2GiB, the growth of the live heap is projected against. Defaults to GOMEMLIMIT, or the limit of the cgroup of gcvis")
+
+// leakMinPoints is the number of points the growth of the live heap is
+// fitted from, at least.
+const leakMinPoints = 5
+
+// cgroupMemoryLimits are the files the memory limit of the cgroup is read
+// from, with cgroup v2 and v1.
+var cgroupMemoryLimits = []string{
+	"/sys/fs/cgroup/memory.max",
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes",
+}
+
+// heapPoint is the live heap after a GC cycle, in megabytes, at t seconds.
+type heapPoint struct {
+	t, mb float64
+}
+
+// leakDetector fits a linear regression to the live heap of the cycles of
+// the last after seconds, telling a leak once its slope has stayed
+// positive for after seconds.
+type leakDetector struct {
+	after  float64 // seconds
+	limit  float64 // megabytes, 0 if unknown
+	points []heapPoint
+
+	growingSince float64 // seconds, -1 if the heap is not growing
+}
+
+// newLeakDetector returns the detector of -leak-after, or nil if it is
+// turned off.
+func newLeakDetector() (*leakDetector, error) {
+	if *leakAfter <= 0 {
+		return nil, nil
+	}
+
+	limit, err := detectMemoryLimit(*memoryLimit, os.Getenv("GOMEMLIMIT"), cgroupMemoryLimits)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		debugf("projecting the live heap against a memory limit of %.0fMB", limit)
+	}
+	return &leakDetector{after: leakAfter.Seconds(), limit: limit, growingSince: -1}, nil
+}
+
+// observe adds the live heap at t seconds, returning its growth over the
+// last after seconds, in megabytes per second, and whether it is leaking.
+func (d *leakDetector) observe(t, mb float64) (float64, bool) {
+	d.points = append(d.points, heapPoint{t, mb})
+	start := 0
+	for start < len(d.points) && d.points[start].t < t-d.after {
+		start++
+	}
+	d.points = append(d.points[:0], d.points[start:]...)
+
+	slope, ok := regressionSlope(d.points)
+	if !ok || slope <= 0 {
+		d.growingSince = -1
+		return slope, false
+	}
+	if d.growingSince < 0 {
+		d.growingSince = t
+	}
+	return slope, t-d.growingSince >= d.after
+}
+
+// growingFor returns how long the heap has been growing at t seconds.
+func (d *leakDetector) growingFor(t float64) time.Duration {
+	if d.growingSince < 0 {
+		return 0
+	}
+	return time.Duration((t - d.growingSince) * float64(time.Second))
+}
+
+// timeToLimit returns when a heap of mb megabytes growing by slope
+// megabytes per second reaches the memory limit, and false if it is
+// unknown.
+func (d *leakDetector) timeToLimit(mb, slope float64) (time.Duration, bool) {
+	if d.limit <= 0 || slope <= 0 {
+		return 0, false
+	}
+	left := math.Max(0, (d.limit-mb)/slope)
+	return time.Duration(left * float64(time.Second)), true
+}
+
+// detail describes the growth of a heap of mb megabytes, at t seconds.
+func (d *leakDetector) detail(t, mb, slope float64) string {
+	s := fmt.Sprintf("live heap of %.0fMB", mb)
+	if d.growingSince < 0 {
+		return s
+	}
+	s += fmt.Sprintf(" growing for %s", d.growingFor(t).Round(time.Second))
+	if left, ok := d.timeToLimit(mb, slope); ok {
+		s += fmt.Sprintf(", reaching the %.0fMB memory limit in about %s", d.limit, left.Round(time.Minute))
+	}
+	return s
+}
+
+// regressionSlope returns the slope of the least squares line through
+// points, and false if there are too few of them, or all at the same time.
+func regressionSlope(points []heapPoint) (float64, bool) {
+	if len(points) < leakMinPoints {
+		return 0, false
+	}
+
+	var meanT, meanMB float64
+	for _, p := range points {
+		meanT += p.t
+		meanMB += p.mb
+	}
+	meanT /= float64(len(points))
+	meanMB /= float64(len(points))
+
+	var cov, variance float64
+	for _, p := range points {
+		cov += (p.t - meanT) * (p.mb - meanMB)
+		variance += (p.t - meanT) * (p.t - meanT)
+	}
+	if variance == 0 {
+		return 0, false
+	}
+	return cov / variance, true
+}
+
+// detectMemoryLimit returns the memory limit of the program, in megabytes,
+// of -memory-limit, GOMEMLIMIT, or the first of cgroupFiles setting one,
+// and 0 if none does.
+func detectMemoryLimit(flagValue, gomemlimit string, cgroupFiles []string) (float64, error) {
+	if flagValue != "" {
+		limit, err := parseStatValue(flagValue, "MB")
+		if err != nil {
+			return 0, fmt.Errorf("invalid -memory-limit: %v", err)
+		}
+		return limit, nil
+	}
+
+	if gomemlimit != "" && gomemlimit != "off" {
+		// Like in the runtime, a number without unit is in bytes.
+		if b, err := strconv.ParseInt(gomemlimit, 10, 64); err == nil {
+			return float64(b) / (1 << 20), nil
+		}
+		limit, err := parseStatValue(gomemlimit, "MB")
+		if err != nil {
+			return 0, fmt.Errorf("invalid GOMEMLIMIT: %v", err)
+		}
+		return limit, nil
+	}
+
+	for _, path := range cgroupFiles {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		bytes, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+		// cgroup v2 writes max, and v1 a huge number, without a limit.
+		if err != nil || bytes >= 1<<60 {
+			continue
+		}
+		return float64(bytes) / (1 << 20), nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegressionSlope(t *testing.T) {
+	points := []heapPoint{{0, 10}, {1, 12}, {2, 14}, {3, 16}, {4, 18}}
+	if slope, ok := regressionSlope(points); !ok || slope != 2 {
+		t.Errorf("Expected a slope of 2. Got %v, %v instead.", slope, ok)
+	}
+
+	if _, ok := regressionSlope(points[:leakMinPoints-1]); ok {
+		t.Errorf("Expected no slope under %d points.", leakMinPoints)
+	}
+	if _, ok := regressionSlope([]heapPoint{{1, 1}, {1, 2}, {1, 3}, {1, 4}, {1, 5}}); ok {
+		t.Errorf("Expected no slope of points all at the same time.")
+	}
+}
+
+func TestLeakDetector(t *testing.T) {
+	d := &leakDetector{after: 60, limit: 1000, growingSince: -1}
+
+	// The heap grows by 1MB every 10 seconds from 10 seconds on.
+	var leaking bool
+	var slope float64
+	for s := 10.0; s <= 110; s += 10 {
+		slope, leaking = d.observe(s, 100+s/10)
+		if leaking && s < 110 {
+			t.Fatalf("Expected no leak before growing for 60s. Got one at %vs instead.", s)
+		}
+	}
+	if !leaking || slope < 0.099 || slope > 0.101 {
+		t.Fatalf("Expected a leak of 0.1MB/s at 110s. Got %v, %v instead.", slope, leaking)
+	}
+	if len(d.points) != 7 {
+		t.Errorf("Expected the points of the last 60s to be kept. Got %d instead.", len(d.points))
+	}
+
+	if left, ok := d.timeToLimit(111, slope); !ok || left.Round(time.Second) != 8890*time.Second {
+		t.Errorf("Expected the limit to be reached in 8890s. Got %v, %v instead.", left, ok)
+	}
+	if detail := d.detail(110, 111, slope); detail != "live heap of 111MB growing for 1m0s, reaching the 1000MB memory limit in about 2h28m0s" {
+		t.Errorf("Unexpected detail %q.", detail)
+	}
+
+	for s := 120.0; s <= 200; s += 10 {
+		slope, leaking = d.observe(s, 111)
+	}
+	if leaking || d.growingSince >= 0 {
+		t.Errorf("Expected a steady heap not to be leaking. Got a slope of %v instead.", slope)
+	}
+}
+
+func TestDetectMemoryLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	unlimited, limited := filepath.Join(dir, "memory.max"), filepath.Join(dir, "memory.limit_in_bytes")
+	ioutil.WriteFile(unlimited, []byte("max\n"), 0644)
+	ioutil.WriteFile(limited, []byte("536870912\n"), 0644)
+	cgroupFiles := []string{filepath.Join(dir, "missing"), unlimited, limited}
+
+	for _, test := range []struct {
+		flag, gomemlimit string
+		files            []string
+		want             float64
+	}{
+		{"2GiB", "1GiB", cgroupFiles, 2048},
+		{"", "1GiB", cgroupFiles, 1024},
+		{"", "268435456", cgroupFiles, 256},
+		{"", "off", cgroupFiles, 512},
+		{"", "", cgroupFiles[:2], 0},
+	} {
+		limit, err := detectMemoryLimit(test.flag, test.gomemlimit, test.files)
+		if err != nil || limit != test.want {
+			t.Errorf("Expected a limit of %vMB for %+v. Got %v, %v instead.", test.want, test, limit, err)
+		}
+	}
+
+	if _, err := detectMemoryLimit("lots", "", nil); err == nil || !strings.Contains(err.Error(), "-memory-limit") {
+		t.Errorf("Expected an invalid -memory-limit to be rejected. Got %v instead.", err)
+	}
+}
+
+func TestAlertSinkLeak(t *testing.T) {
+	notifier := &fakeNotifier{}
+	s := newAlerter(nil, nil, []Notifier{notifier})
+	s.leak = &leakDetector{after: 60, growingSince: -1}
+
+	for i := int64(1); i <= 11; i++ {
+		s.ConsumeGC(&gctrace{ElapsedTime: float64(10 * i), Heap3: 100 + i})
+	}
+	s.ConsumeGC(&gctrace{ElapsedTime: 120, Heap3: 50})
+
+	if len(notifier.alerts) != 2 {
+		t.Fatalf("Expected the leak to fire and recover. Got %d alerts instead.", len(notifier.alerts))
+	}
+	if a := notifier.alerts[0]; a.Rule != "heap leak" || !a.Firing || a.Value != 6 || a.Unit != "MB/min" || a.Detail != "live heap of 111MB growing for 1m0s" {
+		t.Errorf("Expected a heap leak of 6MB/min firing. Got %+v instead.", a)
+	}
+	if a := notifier.alerts[1]; a.Rule != "heap leak" || a.Firing {
+		t.Errorf("Expected the heap leak to recover. Got %+v instead.", a)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gmaz42/gcvis/parse"
+)
+
+var loadInput = flag.String("load-input", "", "file or named pipe of request rate samples, one 'unix-seconds value' line each, graphed over the pauses to tell whether the GC spikes with the load")
+var loadScrape = flag.String("load-scrape", "", "Prometheus /metrics URL of the program to scrape the -load-metric counter from, its rate being graphed over the pauses")
+var loadMetric = flag.String("load-metric", "http_requests_total", "counter scraped from -load-scrape, summed over its labels, e.g. of the requests served")
+var loadScrapeEvery = flag.Duration("load-scrape-every", time.Second, "how often -load-scrape is scraped")
+
+// AddLoadPoint adds the request rate of the program at t to the graph.
+// Samples older than the latest one are dropped, as the series is kept in
+// chronological order.
+func (g *Graph) AddLoadPoint(t time.Time, rate float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	elapsed := t.Sub(traceStartTime()).Seconds() + g.offset
+	if n := g.Load.len; n > 0 && elapsed < g.Load.at(n - 1)[0] {
+		return
+	}
+	g.Load.add(graphPoints{elapsed, rate})
+	g.loadAdded++
+}
+
+// monitorLoad adds to g the request rate read from -load-input, and that
+// scraped from -load-scrape, until stop is closed.
+func monitorLoad(g *Graph, stop <-chan struct{}) {
+	if *loadInput != "" {
+		go func() {
+			// Opening a named pipe waits for a writer.
+			f, err := os.Open(*loadInput)
+			if err != nil {
+				errorf("-load-input: %v", err)
+				return
+			}
+			defer f.Close()
+			readLoad(g, f)
+		}()
+	}
+
+	if *loadScrape == "" {
+		return
+	}
+	client := &http.Client{Timeout: *loadScrapeEvery}
+	ticker := time.NewTicker(*loadScrapeEvery)
+	defer ticker.Stop()
+
+	var prev float64
+	var prevTime time.Time
+	for {
+		select {
+		case now := <-ticker.C:
+			total, err := scrapeCounter(client, *loadScrape, *loadMetric)
+			if err != nil {
+				debugf("cannot scrape the load: %v", err)
+				prevTime = time.Time{}
+				continue
+			}
+			// A counter going down was reset, e.g. by a restart.
+			if !prevTime.IsZero() && total >= prev {
+				g.AddLoadPoint(now, (total-prev)/now.Sub(prevTime).Seconds())
+			}
+			prev, prevTime = total, now
+		case <-stop:
+			return
+		}
+	}
+}
+
+// readLoad adds the samples read from r to g, until it ends.
+func readLoad(g *Graph, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(parse.ScanLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		t, rate, err := parseLoadSample(line)
+		if err != nil {
+			errorf("-load-input: %v", err)
+			continue
+		}
+		g.AddLoadPoint(t, rate)
+	}
+	if err := scanner.Err(); err != nil {
+		errorf("-load-input: %v", err)
+	}
+}
+
+// parseLoadSample parses a 'unix-seconds value' line.
+func parseLoadSample(line string) (time.Time, float64, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return time.Time{}, 0, fmt.Errorf("invalid sample %q, expected 'unix-seconds value'", line)
+	}
+	ts, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid time of sample %q", line)
+	}
+	rate, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid value of sample %q", line)
+	}
+
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(frac*1e9)), rate, nil
+}
+
+// scrapeCounter returns the sum of the samples of metric, over all its
+// labels, in the Prometheus text exposition served at url.
+func scrapeCounter(client *http.Client, url, metric string) (float64, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return 0, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+
+	var total float64
+	found := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// name{labels} value [timestamp], the labels being optional.
+		name, rest := line, ""
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name, rest = line[:i], line[i:]
+		}
+		if name != metric {
+			continue
+		}
+		if i := strings.LastIndex(rest, "}"); i >= 0 {
+			rest = rest[i+1:]
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid sample %q", line)
+		}
+		total += v
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("no %s at %s", metric, url)
+	}
+	return total, nil
+}
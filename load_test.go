@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLoadSample(t *testing.T) {
+	ts, rate, err := parseLoadSample("1700000000.5 120")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !ts.Equal(time.Unix(1700000000, 5e8)) || rate != 120 {
+		t.Errorf("Expected 120 at 1700000000.5. Got %v at %v instead.", rate, ts)
+	}
+
+	for _, line := range []string{"120", "now 120", "1700000000 many", "1 2 3"} {
+		if _, _, err := parseLoadSample(line); err == nil {
+			t.Errorf("Expected %q to be rejected.", line)
+		}
+	}
+}
+
+func TestReadLoad(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	start := traceStartTime().Unix()
+	input := fmt.Sprintf("# ts rate\n%d 10\r\n%d 20\nbad\n%d 5\n", start+1, start+3, start+2)
+	readLoad(graph, strings.NewReader(input))
+
+	points := graph.Snapshot().Load.Points()
+	if len(points) != 2 || points[0][1] != 10 || points[1][1] != 20 {
+		t.Errorf("Expected the samples in order, the late one dropped. Got %v instead.", points)
+	}
+	if c := graph.Cursor(); c != "0.0.2" {
+		t.Errorf("Expected the cursor to count the load samples. Got %s instead.", c)
+	}
+
+	update, ok := graph.Since("0.0")
+	if !ok || len(update.Load.Points()) != 2 {
+		t.Errorf("Expected a cursor without load samples to get them all. Got %v, %v instead.", ok, update)
+	}
+	update, ok = graph.Since("0.0.1")
+	if !ok || len(update.Load.Points()) != 1 {
+		t.Errorf("Expected the sample after the cursor. Got %v, %v instead.", ok, update)
+	}
+}
+
+func TestScrapeCounter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{code="200",path="/a b"} 100
+http_requests_total{code="500"} 5 1700000000000
+http_requests_total_created 1700000000
+go_goroutines 12
+`)
+	}))
+	defer server.Close()
+
+	total, err := scrapeCounter(server.Client(), server.URL, "http_requests_total")
+	if err != nil || total != 105 {
+		t.Errorf("Expected 105 requests. Got %v, %v instead.", total, err)
+	}
+	if _, err := scrapeCounter(server.Client(), server.URL, "missing_total"); err == nil {
+		t.Errorf("Expected a missing metric to be an error.")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+var quiet = flag.Bool("q", false, "quiet: neither pass through the output of the program that is not GC traces, nor log anything but errors")
+var verbose = flag.Bool("v", false, "verbose: also log what the sinks send")
+var veryVerbose = flag.Bool("vv", false, "very verbose: also log how every line is parsed, and every event delivered to the sinks")
+var logLevelName = flag.String("log-level", "", "level of the messages gcvis logs about itself: error, info, debug or trace. Defaults to info, or to the level of -q, -v or -vv")
+var logJSON = flag.Bool("log-json", false, "log the messages of gcvis about itself as JSON objects rather than logfmt lines, both with component=gcvis to tell them from the output of the program")
+
+// logLevel is how much gcvis logs about itself, set by -log-level, or -q,
+// -v and -vv.
+type logLevel int
+
+const (
+	levelError logLevel = iota
+	levelInfo
+	levelDebug
+	levelTrace
+)
+
+// logLevels are the levels of -log-level, by name.
+var logLevels = map[string]logLevel{
+	"error": levelError,
+	"info":  levelInfo,
+	"debug": levelDebug,
+	"trace": levelTrace,
+}
+
+// slogLevelTrace is the slog level of levelTrace, below slog.LevelDebug.
+const slogLevelTrace = slog.LevelDebug - 4
+
+func currentLogLevel() logLevel {
+	if level, ok := logLevels[*logLevelName]; ok {
+		return level
+	}
+	switch {
+	case *veryVerbose:
+		return levelTrace
+	case *verbose:
+		return levelDebug
+	case *quiet:
+		return levelError
+	}
+	return levelInfo
+}
+
+// applyLogLevel checks -log-level.
+func applyLogLevel() error {
+	if _, ok := logLevels[*logLevelName]; !ok && *logLevelName != "" {
+		return fmt.Errorf("invalid -log-level %q, expected error, info, debug or trace", *logLevelName)
+	}
+	return nil
+}
+
+func (l logLevel) slog() slog.Level {
+	switch l {
+	case levelError:
+		return slog.LevelError
+	case levelInfo:
+		return slog.LevelInfo
+	case levelDebug:
+		return slog.LevelDebug
+	}
+	return slogLevelTrace
+}
+
+// flagsLeveler is the minimum level of the messages logged, as the flags
+// currently set it.
+type flagsLeveler struct{}
+
+func (flagsLeveler) Level() slog.Level {
+	return currentLogLevel().slog()
+}
+
+var (
+	logOutputMu sync.Mutex
+	logOutput   io.Writer = os.Stderr
+)
+
+// setLogOutput makes gcvis log to w, e.g. the terminal UI, instead of the
+// standard error.
+func setLogOutput(w io.Writer) {
+	logOutputMu.Lock()
+	defer logOutputMu.Unlock()
+	logOutput = w
+}
+
+// logWriter writes to the current log output.
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	logOutputMu.Lock()
+	defer logOutputMu.Unlock()
+	return logOutput.Write(p)
+}
+
+var logHandlerOptions = &slog.HandlerOptions{
+	Level: flagsLeveler{},
+	ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.LevelKey && len(groups) == 0 && a.Value.Any() == slogLevelTrace {
+			a.Value = slog.StringValue("TRACE")
+		}
+		return a
+	},
+}
+
+var (
+	textLogger = slog.New(slog.NewTextHandler(logWriter{}, logHandlerOptions)).With("component", "gcvis")
+	jsonLogger = slog.New(slog.NewJSONHandler(logWriter{}, logHandlerOptions)).With("component", "gcvis")
+)
+
+// logf logs a message if level is enabled.
+func logf(level logLevel, format string, args ...interface{}) {
+	logger := textLogger
+	if *logJSON {
+		logger = jsonLogger
+	}
+	logger.Log(context.Background(), level.slog(), fmt.Sprintf(format, args...))
+}
+
+// errorf logs failures, e.g. events a sink could not send. They are logged
+// even with -q.
+func errorf(format string, args ...interface{}) {
+	logf(levelError, format, args...)
+}
+
+// fatalf logs a failure gcvis cannot go on after, and exits.
+func fatalf(format string, args ...interface{}) {
+	errorf(format, args...)
+	removePIDFile()
+	os.Exit(1)
+}
+
+// infof logs what gcvis does, unless -q is set.
+func infof(format string, args ...interface{}) {
+	logf(levelInfo, format, args...)
+}
+
+// debugf logs the activity of the sinks, with -v or -vv.
+func debugf(format string, args ...interface{}) {
+	logf(levelDebug, format, args...)
+}
+
+// tracef logs how each line and event is handled, with -vv.
+func tracef(format string, args ...interface{}) {
+	logf(levelTrace, format, args...)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLogLevels(t *testing.T) {
+	defer func(q, v, vv bool) { *quiet, *verbose, *veryVerbose = q, v, vv }(*quiet, *verbose, *veryVerbose)
+	defer func(saved string) { *logLevelName = saved }(*logLevelName)
+
+	var b bytes.Buffer
+	setLogOutput(&b)
+	defer setLogOutput(os.Stderr)
+
+	logAll := func() string {
+		b.Reset()
+		errorf("error")
+		infof("info")
+		debugf("debug")
+		tracef("trace")
+		return b.String()
+	}
+
+	for _, c := range []struct {
+		quiet, verbose, veryVerbose bool
+		level                       string
+		expected                    []string
+	}{
+		{quiet: true, expected: []string{"ERROR msg=error"}},
+		{expected: []string{"ERROR msg=error", "INFO msg=info"}},
+		{verbose: true, expected: []string{"ERROR msg=error", "INFO msg=info", "DEBUG msg=debug"}},
+		{veryVerbose: true, expected: []string{"ERROR msg=error", "INFO msg=info", "DEBUG msg=debug", "TRACE msg=trace"}},
+		{quiet: true, level: "debug", expected: []string{"ERROR msg=error", "INFO msg=info", "DEBUG msg=debug"}},
+	} {
+		*quiet, *verbose, *veryVerbose, *logLevelName = c.quiet, c.verbose, c.veryVerbose, c.level
+
+		logged := logAll()
+		if n := strings.Count(logged, " component=gcvis\n"); n != len(c.expected) {
+			t.Errorf("Expected %v to be logged with -q=%v -v=%v -vv=%v -log-level=%q. Got %q instead.", c.expected, c.quiet, c.verbose, c.veryVerbose, c.level, logged)
+		}
+		for _, msg := range c.expected {
+			if !strings.Contains(logged, "level="+msg+" component=gcvis\n") {
+				t.Errorf("Expected %q to be logged with -q=%v -v=%v -vv=%v -log-level=%q. Got %q instead.", msg, c.quiet, c.verbose, c.veryVerbose, c.level, logged)
+			}
+		}
+	}
+}
+
+func TestLogJSON(t *testing.T) {
+	defer func(saved bool) { *logJSON = saved }(*logJSON)
+
+	var b bytes.Buffer
+	setLogOutput(&b)
+	defer setLogOutput(os.Stderr)
+
+	*logJSON = true
+	errorf("cannot send %d events", 3)
+
+	var logged struct {
+		Level     string
+		Msg       string
+		Component string
+	}
+	if err := json.Unmarshal(b.Bytes(), &logged); err != nil {
+		t.Fatalf("Expected a JSON object. Got %q instead: %v", b.String(), err)
+	}
+	if logged.Level != "ERROR" || logged.Msg != "cannot send 3 events" || logged.Component != "gcvis" {
+		t.Errorf("Expected the error of gcvis. Got %+v instead.", logged)
+	}
+}
+
+func TestApplyLogLevel(t *testing.T) {
+	defer func(saved string) { *logLevelName = saved }(*logLevelName)
+
+	*logLevelName = "verbose"
+	if err := applyLogLevel(); err == nil {
+		t.Errorf("Expected an unknown -log-level to be rejected.")
+	}
+}
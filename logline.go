@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Messages of the log lines, telling which kind of event they describe.
+const (
+	gcMessage   = "garbage collection event"
+	scvgMessage = "scavenger event"
+)
+
+// `{"lvl":"info","host":%q,"srv":"some-service-name","component":"gcvis","time":"%s","msg":%q}`, host, "2021-11-03T14:21:38.783992927Z", msg
+type logLine struct {
+	Level     string `json:"lvl"`
+	Host      string `json:"host"`
+	Service   string `json:"srv"`
+	Component string `json:"component"`
+	// Time is overriden with the calculated time, formatted as RFC3339 in -time-zone
+	Time    time.Time `json:"time"`
+	Message string    `json:"msg"`
+
+	// Labels are added as extra top level fields. They never override
+	// the fields above.
+	Labels map[string]string `json:"-"`
+
+	GC       *gcFields          `json:"gc,omitempty"`
+	Scvg     *scvgFields        `json:"scvg,omitempty"`
+	Summary  *summaryFields     `json:"summary,omitempty"`
+	Computed map[string]float64 `json:"computed,omitempty"` // by -compute
+	Runtime  *runtimeInfo       `json:"runtime,omitempty"`  // of the traced program
+}
+
+type gcFields struct {
+	NumGC                                                                                int64
+	ElapsedTime                                                                          float64 // in seconds since the process started
+	HeapUse                                                                              int64
+	Heap0, Heap2, Heap3, HeapGoal                                                        int64
+	STWSclock, MASclock, STWMclock, STWScpu, MASAssistcpu, MASBGcpu, MASIdlecpu, STWMcpu float64
+	GCOverhead                                                                           float64 // percentage of the wall-clock time spent collecting garbage lately
+}
+
+// gcFieldSeries are the series of the gc fields left out of log lines
+// when -series turns them off. The others are always written.
+var gcFieldSeries = map[string]string{
+	"HeapUse": "heap", "Heap0": "heap", "Heap2": "heap", "Heap3": "heap", "HeapGoal": "heap",
+	"STWSclock": "stw", "STWMclock": "stw", "STWScpu": "stw", "STWMcpu": "stw",
+	"MASclock": "mas", "MASAssistcpu": "mas", "MASBGcpu": "mas", "MASIdlecpu": "mas",
+}
+
+func (f *gcFields) MarshalJSON() ([]byte, error) {
+	type plainGCFields gcFields
+	b, err := json.Marshal((*plainGCFields)(f))
+	if err != nil || (enabledSeries["heap"] && enabledSeries["stw"] && enabledSeries["mas"]) {
+		return b, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for name, series := range gcFieldSeries {
+		if !enabledSeries[series] {
+			delete(fields, name)
+		}
+	}
+	return json.Marshal(fields)
+}
+
+type scvgFields struct {
+	Inuse, Idle, Sys, Released, Consumed int64 // in megabytes
+}
+
+func (l *logLine) MarshalJSON() ([]byte, error) {
+	type plainLogLine logLine
+	b, err := json.Marshal((*plainLogLine)(l))
+	if err != nil || len(l.Labels) == 0 {
+		return b, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range l.Labels {
+		if _, ok := fields[k]; ok {
+			continue
+		}
+		fields[k], _ = json.Marshal(v)
+	}
+
+	return json.Marshal(fields)
+}
+
+// labelsFlag collects repeated key=value flags.
+type labelsFlag map[string]string
+
+func (f labelsFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f labelsFlag) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("invalid label %q, expected key=value", value)
+	}
+	f[kv[0]] = kv[1]
+	return nil
+}
+
+func (f labelsFlag) repeatable() {}
+
+var ownHost string
+
+func init() {
+	ownHost, _ = os.Hostname()
+}
+
+var lokiOut = flag.String("loki-out", "off", "where to write Loki-compatible log lines: stderr, stdout, a file path or off")
+var logFormat = flag.String("log-format", "", "Go text/template log lines are written with instead of JSON, e.g. 'ts={{.Time.Unix}} heap={{.GC.HeapGoal}}'. See the README for its fields")
+var extraLabels = labelsFlag{}
+
+func init() {
+	flag.Var(extraLabels, "label", "key=value field added to every log line and Loki stream. Can be repeated.")
+
+	RegisterSink("loki-out", newLogLineSink)
+}
+
+// logLineSink writes Loki-compatible log lines to a stream or a file, or
+// lines formatted with the -log-format template.
+type logLineSink struct {
+	w    io.WriteCloser
+	tmpl *template.Template
+}
+
+func newLogLineSink() (Sink, error) {
+	var tmpl *template.Template
+	if *logFormat != "" {
+		var err error
+		if tmpl, err = parseLogFormat(*logFormat); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := openOutput(*lokiOut)
+	if err != nil || w == nil {
+		return nil, err
+	}
+
+	return &logLineSink{w: w, tmpl: tmpl}, nil
+}
+
+func (s *logLineSink) ConsumeGC(t *gctrace) error {
+	return s.write(newGCLogLine(t))
+}
+
+func (s *logLineSink) ConsumeScvg(t *scvgtrace) error {
+	return s.write(newScvgLogLine(t))
+}
+
+func (s *logLineSink) write(l *logLine) error {
+	if s.tmpl == nil {
+		return writeLogLine(s.w, l)
+	}
+	return writeLogLineTemplate(s.w, s.tmpl, l)
+}
+
+func (s *logLineSink) Flush() error {
+	return nil
+}
+
+func (s *logLineSink) Close() error {
+	return s.w.Close()
+}
+
+func writeLogLine(w io.Writer, l *logLine) error {
+	if err := json.NewEncoder(w).Encode(l); err != nil {
+		return fmt.Errorf("cannot encode log line: %v", err)
+	}
+	return nil
+}
+
+// logFormatFuncs are the functions of -log-format templates, to quote
+// values as JSON or logfmt expect.
+var logFormatFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"quote": strconv.Quote,
+	"rfc3339": func(t time.Time) string {
+		return t.Format(time.RFC3339Nano)
+	},
+}
+
+func parseLogFormat(format string) (*template.Template, error) {
+	tmpl, err := template.New("log-format").Funcs(logFormatFuncs).Option("missingkey=zero").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -log-format: %v", err)
+	}
+	return tmpl, nil
+}
+
+// logFormatData is what -log-format templates are executed with: the
+// fields of the log line, e.g. .Time or .GC.HeapGoal, plus the kind of
+// event, gc, scvg or summary, and its fields named as in JSON, for
+// templates formatting every kind alike.
+type logFormatData struct {
+	*logLine
+	Event  string
+	Fields map[string]float64
+}
+
+// writeLogLineTemplate writes l formatted with tmpl, followed by a newline
+// unless the template ends with one.
+func writeLogLineTemplate(w io.Writer, tmpl *template.Template, l *logLine) error {
+	event, fields, err := eventFields(l)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, logFormatData{logLine: l, Event: event, Fields: fields}); err != nil {
+		return fmt.Errorf("cannot format log line: %v", err)
+	}
+	if !strings.HasSuffix(b.String(), "\n") {
+		b.WriteByte('\n')
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// newLogLine returns a log line with the common fields set, timestamped
+// elapsed seconds after the program started, or now if elapsed is unknown.
+func newLogLine(msg string, elapsed float64) *logLine {
+	var l logLine
+	l.Level = "info"
+	l.Host = ownHost
+	l.Service = *serviceName
+	l.Component = "gcvis"
+	l.Message = msg
+	l.Labels = extraLabels
+	l.Runtime = targetRuntime.orNil()
+
+	l.Time = exportTime(traceTime(elapsed))
+
+	return &l
+}
+
+// traceTime returns the time of a trace read elapsed seconds after the
+// program started, or now if elapsed is unknown.
+func traceTime(elapsed float64) time.Time {
+	if elapsed == 0 {
+		return time.Now()
+	}
+
+	// precision is milliseconds thus we can use this conversion here
+	deltaMs := time.Millisecond * time.Duration(int64(elapsed*1000))
+
+	return traceStartTime().Add(deltaMs)
+}
+
+func newGCLogLine(t *gctrace) *logLine {
+	l := newLogLine(gcMessage, t.ElapsedTime)
+
+	// add harvested fields
+	l.GC = &gcFields{
+		NumGC:        t.NumGC,
+		ElapsedTime:  t.ElapsedTime,
+		HeapUse:      t.Heap1,
+		Heap0:        t.Heap0,
+		Heap2:        t.Heap2,
+		Heap3:        t.Heap3,
+		HeapGoal:     t.Heap1,
+		MASAssistcpu: t.MASAssistcpu,
+		MASBGcpu:     t.MASBGcpu,
+		MASIdlecpu:   t.MASIdlecpu,
+		MASclock:     t.MASclock,
+		STWMclock:    t.STWMclock,
+		STWMcpu:      t.STWMcpu,
+		STWSclock:    t.STWSclock,
+		STWScpu:      t.STWScpu,
+		GCOverhead:   t.GCOverhead,
+	}
+	l.Computed = t.Computed
+	l.Runtime = targetRuntime.withProcs(t.Nproc).orNil()
+
+	return l
+}
+
+func newScvgLogLine(s *scvgtrace) *logLine {
+	l := newLogLine(scvgMessage, s.ElapsedTime)
+
+	l.Scvg = &scvgFields{
+		Inuse:    s.Inuse,
+		Idle:     s.Idle,
+		Sys:      s.Sys,
+		Released: s.Released,
+		Consumed: s.Consumed,
+	}
+	l.Computed = s.Computed
+
+	return l
+}
+
+// eventFields returns the kind of event described by l, gc, scvg or
+// summary, and the fields of its gc, scvg or summary object, named as in
+// JSON.
+func eventFields(l *logLine) (string, map[string]float64, error) {
+	kind, fields := "gc", interface{}(l.GC)
+	if l.Scvg != nil {
+		kind, fields = "scvg", l.Scvg
+	}
+	if l.Summary != nil {
+		kind, fields = "summary", l.Summary
+	}
+
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return "", nil, err
+	}
+	values := map[string]float64{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return "", nil, err
+	}
+
+	return kind, values, nil
+}
+
+func sortedFieldNames(fields map[string]float64) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
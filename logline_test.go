@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenLokiOutOff(t *testing.T) {
+	w, err := openOutput("off")
+	if err != nil {
+		t.Fatalf("openOutput returned an error: %v", err)
+	}
+	if w != nil {
+		t.Errorf("Expected no writer when Loki output is off.")
+	}
+}
+
+func TestOpenLokiOutFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "loki.log")
+	w, err := openOutput(path)
+	if err != nil {
+		t.Fatalf("openOutput returned an error: %v", err)
+	}
+
+	writeLogLine(w, newGCLogLine(&gctrace{Heap1: 10}))
+	w.Close()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile returned an error: %v", err)
+	}
+
+	var l logLine
+	if err := json.Unmarshal(content, &l); err != nil {
+		t.Fatalf("Log line is not valid JSON: %v", err)
+	}
+	if l.GC.HeapUse != 10 {
+		t.Errorf("Expected HeapUse to equal 10. Got %d instead.", l.GC.HeapUse)
+	}
+}
+
+func TestWriteLogLine(t *testing.T) {
+	w := &bytes.Buffer{}
+	writeLogLine(w, newGCLogLine(&gctrace{ElapsedTime: 1.5, STWSclock: 0.25}))
+
+	var l logLine
+	if err := json.Unmarshal(w.Bytes(), &l); err != nil {
+		t.Fatalf("Log line is not valid JSON: %v", err)
+	}
+	if l.Component != "gcvis" || l.GC.STWSclock != 0.25 {
+		t.Errorf("Unexpected log line: %+v", l)
+	}
+	if !l.Time.Equal(traceStartTime().Add(1500 * 1e6).UTC()) {
+		t.Errorf("Expected time to be offset from the start of the program. Got %v instead.", l.Time)
+	}
+}
+
+func TestLogLineLabels(t *testing.T) {
+	l := newGCLogLine(&gctrace{})
+	l.Labels = map[string]string{"env": "prod", "host": "ignored"}
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("Log line is not valid JSON: %v", err)
+	}
+	if fields["env"] != "prod" {
+		t.Errorf("Expected env field to equal 'prod'. Got %v instead.", fields["env"])
+	}
+	if fields["host"] != ownHost {
+		t.Errorf("Expected labels not to override the host field. Got %v instead.", fields["host"])
+	}
+}
+
+func TestLabelsFlag(t *testing.T) {
+	f := labelsFlag{}
+	if err := f.Set("env=prod"); err != nil {
+		t.Fatalf("Set returned an error: %v", err)
+	}
+	if err := f.Set("sha=abc=def"); err != nil {
+		t.Fatalf("Set returned an error: %v", err)
+	}
+	if f.String() != "env=prod,sha=abc=def" {
+		t.Errorf("Unexpected flag value: %q", f.String())
+	}
+	if err := f.Set("invalid"); err == nil {
+		t.Errorf("Expected an error for a label without value.")
+	}
+}
+
+func TestLogLineGCFields(t *testing.T) {
+	l := newGCLogLine(&gctrace{NumGC: 763, ElapsedTime: 77536.239, Heap0: 6370, Heap1: 6533, Heap2: 6390, Heap3: 3298})
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		t.Fatalf("Marshal returned an error: %v", err)
+	}
+
+	var fields struct {
+		GC map[string]float64 `json:"gc"`
+	}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatalf("Log line is not valid JSON: %v", err)
+	}
+
+	expected := map[string]float64{
+		"NumGC":       763,
+		"ElapsedTime": 77536.239,
+		"HeapUse":     6533,
+		"Heap0":       6370,
+		"Heap2":       6390,
+		"Heap3":       3298,
+		"HeapGoal":    6533,
+	}
+	for k, v := range expected {
+		if fields.GC[k] != v {
+			t.Errorf("Expected gc.%s to equal %v. Got %v instead.", k, v, fields.GC[k])
+		}
+	}
+}
+
+func TestScvgLogLine(t *testing.T) {
+	w := &bytes.Buffer{}
+	writeLogLine(w, newScvgLogLine(&scvgtrace{Inuse: 12, Idle: 13, Sys: 14, Released: 15, Consumed: 16}))
+
+	var l logLine
+	if err := json.Unmarshal(w.Bytes(), &l); err != nil {
+		t.Fatalf("Log line is not valid JSON: %v", err)
+	}
+	if l.GC != nil {
+		t.Errorf("Expected scavenger log line not to contain gc fields.")
+	}
+	if l.Message != "scavenger event" {
+		t.Errorf("Unexpected message %q", l.Message)
+	}
+
+	expected := scvgFields{Inuse: 12, Idle: 13, Sys: 14, Released: 15, Consumed: 16}
+	if l.Scvg == nil || *l.Scvg != expected {
+		t.Errorf("Expected scvg fields to equal %+v. Got %+v instead.", expected, l.Scvg)
+	}
+}
+
+func TestWriteLogLineTemplate(t *testing.T) {
+	tmpl, err := parseLogFormat(`ts={{rfc3339 .Time}} event={{.Event}}{{range $k, $v := .Fields}} {{$k}}={{$v}}{{end}}`)
+	if err != nil {
+		t.Fatalf("parseLogFormat returned an error: %v", err)
+	}
+
+	w := &bytes.Buffer{}
+	l := newScvgLogLine(&scvgtrace{Inuse: 12, Released: 3})
+	if err := writeLogLineTemplate(w, tmpl, l); err != nil {
+		t.Fatalf("writeLogLineTemplate returned an error: %v", err)
+	}
+
+	expected := "ts=" + l.Time.Format(time.RFC3339Nano) + " event=scvg Consumed=0 Idle=0 Inuse=12 Released=3 Sys=0\n"
+	if w.String() != expected {
+		t.Errorf("Expected %q. Got %q instead.", expected, w.String())
+	}
+}
+
+func TestWriteLogLineTemplateJSON(t *testing.T) {
+	tmpl, err := parseLogFormat(`{"heap":{{.GC.HeapGoal}},"service":{{json .Service}},"team":{{quote (index .Labels "team")}}}` + "\n")
+	if err != nil {
+		t.Fatalf("parseLogFormat returned an error: %v", err)
+	}
+
+	savedName := *serviceName
+	*serviceName = `"api"`
+	defer func() { *serviceName = savedName }()
+	extraLabels["team"] = "core"
+	defer delete(extraLabels, "team")
+
+	w := &bytes.Buffer{}
+	if err := writeLogLineTemplate(w, tmpl, newGCLogLine(&gctrace{Heap1: 33})); err != nil {
+		t.Fatalf("writeLogLineTemplate returned an error: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(w.Bytes(), &fields); err != nil {
+		t.Fatalf("Expected valid JSON. Got %q instead.", w.String())
+	}
+	if fields["heap"] != 33.0 || fields["service"] != `"api"` || fields["team"] != "core" {
+		t.Errorf("Unexpected fields %v.", fields)
+	}
+	if bytes.Count(w.Bytes(), []byte("\n")) != 1 {
+		t.Errorf("Expected a single newline. Got %q instead.", w.String())
+	}
+}
+
+func TestParseLogFormatInvalid(t *testing.T) {
+	if _, err := parseLogFormat("{{.GC.HeapGoal"); err == nil {
+		t.Errorf("Expected an invalid template to be rejected.")
+	}
+}
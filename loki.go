@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lokiPushPath = "/loki/api/v1/push"
+
+var lokiURL = flag.String("loki-url", "", "base URL of a Loki server to push log lines to, e.g. http://localhost:3100")
+var lokiTenant = flag.String("loki-tenant", "", "tenant ID sent to Loki in the X-Scope-OrgID header")
+var lokiLabels = flag.String("loki-labels", "", "comma separated key=value stream labels added to the pushed log lines")
+var lokiBatchSize = flag.Int("loki-batch-size", 100, "maximum number of log lines pushed to Loki in a single request")
+var lokiBatchWait = flag.Duration("loki-batch-wait", time.Second, "maximum time a log line waits before being pushed to Loki")
+
+func init() {
+	RegisterSink("loki", newLokiSink)
+}
+
+// lokiSink pushes Loki-compatible log lines with a LokiClient.
+type lokiSink struct {
+	client *LokiClient
+}
+
+func newLokiSink() (Sink, error) {
+	if *lokiURL == "" {
+		return nil, nil
+	}
+
+	labels, err := parseLabels(*lokiLabels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -loki-labels: %v", err)
+	}
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
+	labels["component"] = "gcvis"
+	labels["srv"] = *serviceName
+	labels["host"] = ownHost
+
+	return &lokiSink{
+		client: NewLokiClient(*lokiURL, *lokiTenant, labels, *lokiBatchSize, *lokiBatchWait),
+	}, nil
+}
+
+func (s *lokiSink) ConsumeGC(t *gctrace) error {
+	return s.push(newGCLogLine(t))
+}
+
+func (s *lokiSink) ConsumeScvg(t *scvgtrace) error {
+	return s.push(newScvgLogLine(t))
+}
+
+func (s *lokiSink) push(l *logLine) error {
+	line, err := json.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("cannot encode log line: %v", err)
+	}
+
+	s.client.Push(l.Time, string(line))
+	return nil
+}
+
+func (s *lokiSink) Flush() error {
+	s.client.Flush()
+	return nil
+}
+
+func (s *lokiSink) Close() error {
+	s.client.Close()
+	return nil
+}
+
+type lokiEntry struct {
+	ts   time.Time
+	line string
+}
+
+// LokiClient pushes log lines to the Loki push API. Lines are sent in
+// batches of at most batchSize entries, or every batchWait, whichever
+// comes first.
+type LokiClient struct {
+	url    string
+	header http.Header
+	labels map[string]string
+
+	client  *http.Client
+	batcher *batcher
+}
+
+func NewLokiClient(url, tenantID string, labels map[string]string, batchSize int, batchWait time.Duration) *LokiClient {
+	l := &LokiClient{
+		url:    strings.TrimRight(url, "/") + lokiPushPath,
+		header: http.Header{"Content-Type": {"application/json"}},
+		labels: labels,
+		client: &http.Client{Timeout: pushTimeout},
+	}
+	if tenantID != "" {
+		l.header.Set("X-Scope-OrgID", tenantID)
+	}
+	l.batcher = newBatcher(batchSize, batchWait, l.send)
+
+	return l
+}
+
+// Push queues line to be sent with timestamp ts.
+func (l *LokiClient) Push(ts time.Time, line string) {
+	l.batcher.Add(lokiEntry{ts, line})
+}
+
+// Flush sends the lines pushed so far and waits for them to be sent.
+func (l *LokiClient) Flush() {
+	l.batcher.Flush()
+}
+
+// Close sends any pending lines and waits for them to be pushed.
+func (l *LokiClient) Close() {
+	l.batcher.Close()
+}
+
+// send pushes batch, retrying with an exponential backoff on network
+// errors, rate limiting and server errors.
+func (l *LokiClient) send(batch []interface{}) {
+	body, err := l.encode(batch)
+	if err != nil {
+		errorf("cannot encode Loki push request: %v", err)
+		return
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d Loki log lines", len(batch)), func() (bool, error) {
+		return postBody(l.client, l.url, l.header, body)
+	})
+}
+
+func (l *LokiClient) encode(batch []interface{}) ([]byte, error) {
+	values := make([][2]string, len(batch))
+	for i, e := range batch {
+		entry := e.(lokiEntry)
+		values[i] = [2]string{strconv.FormatInt(entry.ts.UnixNano(), 10), entry.line}
+	}
+
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+
+	return json.Marshal(struct {
+		Streams []stream `json:"streams"`
+	}{
+		Streams: []stream{{Stream: l.labels, Values: values}},
+	})
+}
+
+// parseLabels parses a comma separated list of key=value pairs.
+func parseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	if s == "" {
+		return labels, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		labels[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return labels, nil
+}
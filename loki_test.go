@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lokiPushRequest struct {
+	Streams []struct {
+		Stream map[string]string
+		Values [][2]string
+	}
+}
+
+func TestLokiClientPush(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []lokiPushRequest
+		tenant   string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != lokiPushPath {
+			t.Errorf("Unexpected request path %q", req.URL.Path)
+		}
+
+		var pr lokiPushRequest
+		if err := json.NewDecoder(req.Body).Decode(&pr); err != nil {
+			t.Errorf("Error decoding push request: %v", err)
+		}
+
+		mu.Lock()
+		requests = append(requests, pr)
+		tenant = req.Header.Get("X-Scope-OrgID")
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewLokiClient(server.URL, "tenant-1", map[string]string{"srv": "test"}, 2, time.Hour)
+	client.Push(time.Unix(1, 0), "first")
+	client.Push(time.Unix(2, 0), "second")
+	client.Push(time.Unix(3, 0), "third")
+	client.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 push requests. Got %d instead.", len(requests))
+	}
+	if tenant != "tenant-1" {
+		t.Errorf("Expected tenant header to equal 'tenant-1'. Got %q instead.", tenant)
+	}
+
+	stream := requests[0].Streams[0]
+	if stream.Stream["srv"] != "test" {
+		t.Errorf("Unexpected stream labels: %v", stream.Stream)
+	}
+	if len(stream.Values) != 2 || stream.Values[0] != [2]string{"1000000000", "first"} {
+		t.Errorf("Unexpected stream values: %v", stream.Values)
+	}
+	if v := requests[1].Streams[0].Values; len(v) != 1 || v[0][1] != "third" {
+		t.Errorf("Expected pending line to be flushed on Close. Got %v instead.", v)
+	}
+}
+
+func TestLokiClientRetry(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewLokiClient(server.URL, "", map[string]string{}, 1, time.Hour)
+	client.Push(time.Now(), "line")
+	client.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if attempts != 2 {
+		t.Errorf("Expected the push to be retried once. Got %d attempts instead.", attempts)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := parseLabels("env=prod, region=eu-west-1")
+	if err != nil {
+		t.Fatalf("parseLabels returned an error: %v", err)
+	}
+	if labels["env"] != "prod" || labels["region"] != "eu-west-1" {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+
+	if _, err := parseLabels("env"); err == nil {
+		t.Errorf("Expected an error for a label without value.")
+	}
+}
+
+func TestLokiClientFlush(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		lines int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var pr lokiPushRequest
+		json.NewDecoder(req.Body).Decode(&pr)
+
+		mu.Lock()
+		lines += len(pr.Streams[0].Values)
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewLokiClient(server.URL, "", map[string]string{}, 100, time.Hour)
+	defer client.Close()
+
+	client.Push(time.Now(), "first")
+	client.Push(time.Now(), "second")
+	client.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if lines != 2 {
+		t.Errorf("Expected 2 lines to be pushed on Flush. Got %d instead.", lines)
+	}
+}
@@ -3,16 +3,24 @@
 //
 // usage:
 //
-//     gcvis program [arguments]...
+//     gcvis [flags] [run] program [arguments]...
+//     gcvis replay [flags] file [-speed 10x|max]
+//     gcvis serve [flags] -db file
+//     gcvis export -db file [-session id] [-o file]
+//     gcvis import [flags] file.gcvis
+//     gcvis report [-format text|json|html] [-o file] [file]
+//     gcvis diff [-format text|json|html] [-o file] baseline candidate
+//     gcvis bench [-n runs] [-dir dir] [-format text|json] -- program [arguments]...
+//     gcvis grafana-dashboard [-datasource loki|prometheus] [-title title]
+//     gcvis completion bash|zsh|fish
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"os/signal"
 	"strings"
 	"time"
 
@@ -21,125 +29,353 @@ import (
 
 var iface = flag.String("i", "127.0.0.1", "specify interface to use. defaults to 127.0.0.1.")
 var port = flag.String("p", "4500", "specify port to use.")
+var portRetry = flag.Int("port-retry", 0, "number of successive ports to try when the port is already in use, e.g. by another gcvis")
 var serviceName = flag.String("s", "example", "specify service name to include in generated log lines")
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage of %s: command <args>...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, `Usage of %s:
+  %[1]s [flags] [run] program [arguments]...
+	run program, visualising its garbage collections
+  %[1]s [flags] < trace.log
+	visualise the garbage collections traced in a log
+  %[1]s [flags] -serial device[@baud]
+	visualise the garbage collections traced by a program writing to a serial port
+  %[1]s replay [flags] file [-speed 10x|max]
+	visualise the garbage collections traced in a log file, as fast as they were traced or faster, and keep serving them
+  %[1]s serve [flags] -db file
+	serve the sessions stored in a database
+  %[1]s export -db file [-session id] [-o file]
+	write the graph of a stored session as JSON, or as a bundle to import if file ends with .gcvis
+  %[1]s import [flags] file.gcvis
+	serve the session of a bundle exported by gcvis export or downloaded from /api/v1/bundle
+  %[1]s snapshot [-o file] [url]
+	write the current state of a running gcvis as JSON, without interrupting it
+  %[1]s check [flags]
+	check the flags and the -config file, and that the configured sinks are reachable, without running anything
+  %[1]s report [-format text|json|html] [-o file] [file]
+	sum up the garbage collections traced in a log file, or graph them in a standalone HTML page
+  %[1]s diff [-format text|json|html] [-o file] baseline candidate
+	compare the garbage collections of two recordings or log files, e.g. of a benchmark before and after a change
+  %[1]s grafana-dashboard [-datasource loki|prometheus] [-title title]
+	write a Grafana dashboard of the exported metrics
+  %[1]s completion bash|zsh|fish
+	write a shell completion script
+
+Flags:
+`, os.Args[0])
 		flag.PrintDefaults()
 	}
 
-	var pipeRead io.ReadCloser
-	var subcommand *SubCommand
-
 	flag.Parse()
-	if len(flag.Args()) < 1 {
+
+	if *showVersion {
+		printVersion(os.Stdout)
+		return
+	}
+
+	command := flag.Arg(0)
+	switch command {
+	case "run", "replay", "serve", "import", "check":
+		// The flags of gcvis can also follow the command name.
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
+	if err := loadEnv(flag.CommandLine, os.Getenv); err != nil {
+		fatalf("%v", err)
+	}
+	if *configPath != "" {
+		if err := loadConfig(flag.CommandLine, *configPath); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	if err := applyLogLevel(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := applyStartTime(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := applyTimeZone(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := applyKubernetes(); err != nil {
+		fatalf("%v", err)
+	}
+
+	if command == "check" {
+		// Checking the configuration starts nothing, not even a pid file.
+		if flag.NArg() > 0 {
+			fmt.Fprintln(os.Stderr, "check: expected flags only")
+			os.Exit(2)
+		}
+		if !runCheck(os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	series, err := parseSeries(*seriesFlag)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	enabledSeries = series
+
+	switch command {
+	case "grafana-dashboard", "completion", "export", "snapshot", "report", "diff", "bench":
+		// These commands do their job and exit, as check does: only
+		// the long running ones start a daemon and a pid file.
+	default:
+		daemonize()
+		if err := writePIDFile(); err != nil {
+			fatalf("%v", err)
+		}
+		defer removePIDFile()
+	}
+
+	switch command {
+	case "grafana-dashboard":
+		runGrafanaDashboard(flag.Args()[1:])
+	case "completion":
+		runCompletion(flag.Args()[1:])
+	case "export":
+		runExport(flag.Args()[1:])
+	case "snapshot":
+		runSnapshot(flag.Args()[1:])
+	case "import":
+		runImport(flag.Args())
+	case "report":
+		runReport(flag.Args()[1:])
+	case "diff":
+		runDiff(flag.Args()[1:])
+	case "bench":
+		runBench(flag.Args()[1:])
+	case "replay":
+		runReplay(flag.Args())
+	case "serve":
+		runServe()
+	case "run":
+		if flag.NArg() < 1 {
+			flag.Usage()
+			removePIDFile()
+			os.Exit(2)
+		}
+		runProgram(flag.Args())
+	default:
+		if flag.NArg() > 0 {
+			runProgram(flag.Args())
+			return
+		}
+		if *serialPort != "" {
+			port, err := openSerial(*serialPort)
+			if err != nil {
+				fatalf("%v", err)
+			}
+			defer port.Close()
+			runSession(*serialPort, port, nil, false)
+			return
+		}
 		if terminal.IsTerminal(int(os.Stdin.Fd())) {
 			flag.Usage()
 			return
-		} else {
-			pipeRead = os.Stdin
 		}
-	} else {
-		subcommand = NewSubCommand(flag.Args())
-		pipeRead = subcommand.PipeRead
-		go subcommand.Run()
+		runSession("", os.Stdin, nil, false)
+	}
+}
+
+// runProgram runs args with GC traces turned on, visualising them.
+func runProgram(args []string) {
+	sessionCommand = args
+	targetRuntime = detectRuntime(args, os.Getenv)
+	subcommand := NewSubCommand(args)
+	if *tuiMode || isGoTest(args) {
+		// go test writes the GC traces of the tests to its standard
+		// output, along with the results of the benchmarks.
+		subcommand.CaptureStdout()
+	}
+	go subcommand.Run()
+
+	runSession(strings.Join(args, " "), subcommand.PipeRead, subcommand, false)
+}
+
+// runSession visualises and exports the traces read from r, which is the
+// output of subcommand if not nil, until it ends. If keepServing is set,
+// the graph is then served until gcvis is interrupted.
+func runSession(title string, r io.Reader, subcommand *SubCommand, keepServing bool) {
+	sinks, err := NewSinks()
+	if err != nil {
+		fatalf("%v", err)
 	}
 
-	parser := NewParser(pipeRead)
+	parser := NewParser(r)
 
-	title := strings.Join(flag.Args(), " ")
-	if len(title) == 0 {
+	defaultTitle := len(title) == 0
+	if defaultTitle {
 		title = fmt.Sprintf("%s:%s", *iface, *port)
 	}
 
 	gcvisGraph := NewGraph(title, GCVIS_TMPL)
-	server := NewHttpServer(*iface, *port, &gcvisGraph)
+	gcvisGraph.Runtime = targetRuntime.clone()
+	if *resume {
+		if *checkpointPath == "" {
+			fatalf("-resume requires -checkpoint")
+		}
+		if err := restoreCheckpoint(gcvisGraph); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	server := NewHttpServer(*iface, *port, gcvisGraph)
+	server.SetPortRetry(*portRetry)
+	if defaultTitle {
+		// Named after the port actually listened on, with -port-retry.
+		title = server.Listener().Addr().String()
+		gcvisGraph.Title = title
+	}
+	stopCheckpoints := startCheckpoints(gcvisGraph)
+	profiler.SetGraph(gcvisGraph)
+	stats := newRunStats()
+
+	if *dbPath != "" {
+		store, err := OpenSQLiteStore(*dbPath, title)
+		if err != nil {
+			fatalf("cannot open database: %v", err)
+		}
+		queued, err := QueueSink("sqlite", store)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		sinks = append(sinks, queued)
+		server.SetHistory(store)
+	}
+
+	if *selfMonitor {
+		self := NewGraph("gcvis itself", GCVIS_TMPL)
+		stopSelf := make(chan struct{})
+		defer close(stopSelf)
+		go monitorSelf(self, stopSelf)
+		server.SetSelf(self)
+	}
+
+	if *loadInput != "" || *loadScrape != "" {
+		stopLoad := make(chan struct{})
+		defer close(stopLoad)
+		go monitorLoad(gcvisGraph, stopLoad)
+	}
 
 	go parser.Run()
 	go server.Start()
 
 	url := server.Url()
+	gcvisURL = url
+
+	infof("server started on %s", url)
+	notifyReady()
+	if activeTUI != nil {
+		activeTUI.SetTitle(title, url)
+	}
+
+	var rssSamples chan int64
+	pid := func() int { return *rssPid }
+	if subcommand != nil {
+		pid = subcommand.Pid
+	}
+	if subcommand != nil || *rssPid > 0 {
+		rssSamples = make(chan int64, 1)
+		stopRSS := make(chan struct{})
+		defer close(stopRSS)
+		go sampleRSS(pid, rssSampleInterval, rssSamples, stopRSS)
+	}
 
-	log.Printf("server started on %s", url)
+	dedupe := newDeduper(*dedupeWindow)
+	var overhead overheadMeter
+	coalesce := newCoalescer(*coalesceWindow, gcvisGraph.AddCoalescedGraphPoint)
 
 	for {
 		select {
 		case gcTrace := <-parser.GcChan:
-			// generate a Loki-compatible JSON output line using this trace
-			generateLokiLogLine(gcTrace)
+			if dedupe.Duplicate(gcTrace, time.Now()) {
+				tracef("dropped the duplicate trace of GC %d", gcTrace.NumGC)
+				continue
+			}
+			gcTrace.GCOverhead = overhead.add(gcTrace)
+			ComputeGC(gcTrace)
+			if err := sinks.ConsumeGC(gcTrace); err != nil {
+				errorf("%v", err)
+			}
 
-			gcvisGraph.AddGCTraceGraphPoint(gcTrace)
+			coalesce.Add(gcTrace)
+			stats.addGC(gcTrace)
+		case <-coalesce.C():
+			coalesce.Flush()
 		case scvgTrace := <-parser.ScvgChan:
-			// we do not ingest these for Prometheus
+			ComputeScvg(scvgTrace)
+			if err := sinks.ConsumeScvg(scvgTrace); err != nil {
+				errorf("%v", err)
+			}
+
 			gcvisGraph.AddScavengerGraphPoint(scvgTrace)
+			stats.addScvg(scvgTrace)
+		case rss := <-rssSamples:
+			stats.addRSS(rss)
+			gcvisGraph.AddRSSPoint(time.Now(), rss)
 		case output := <-parser.NoMatchChan:
-			fmt.Fprintln(os.Stderr, output)
-		case <-parser.done:
-			if parser.Err != nil {
-				fmt.Fprintf(os.Stderr, parser.Err.Error())
-				os.Exit(1)
+			stats.addOutput(output)
+			if activeTUI != nil {
+				activeTUI.AddOutput(output)
+			} else if !*quiet {
+				fmt.Fprintln(os.Stderr, output)
 			}
-
+		case <-parser.Done():
 			goto out
 		}
 	}
 out:
+	coalesce.Flush()
 
-	if subcommand != nil && subcommand.Err() != nil {
-		fmt.Fprintf(os.Stderr, subcommand.Err().Error())
-		os.Exit(1)
+	if err := sinks.Close(); err != nil {
+		errorf("%v", err)
 	}
-}
+	stopCheckpoints()
 
-// `{"lvl":"info","host":%q,"srv":"some-service-name","component":"gcvis","time":"%s","msg":%q}`, host, "2021-11-03T14:21:38.783992927Z", msg
-type logLine struct {
-	Level     string `json:"lvl"`
-	Host      string `json:"host"`
-	Service   string `json:"srv"`
-	Component string `json:"component"`
-	// Time is overriden with the calculated time. This timestamp must be formatted as UTC RFC3339
-	Time    time.Time `json:"time"`
-	Message string    `json:"msg"`
-
-	GC struct {
-		HeapUse                                                                              int64
-		STWSclock, MASclock, STWMclock, STWScpu, MASAssistcpu, MASBGcpu, MASIdlecpu, STWMcpu float64
-	} `json:"gc"`
-}
+	if *exitSummaryOut != "" {
+		if err := writeExitSummary(*exitSummaryOut, stats); err != nil {
+			errorf("cannot write the exit summary: %v", err)
+		}
+	}
 
-var ownHost string
+	printExitTable(stats)
 
-func init() {
-	ownHost, _ = os.Hostname()
-}
+	if *htmlReportOut != "" {
+		if err := writeHTMLReportFile(*htmlReportOut, gcvisGraph, stats); err != nil {
+			errorf("cannot write the HTML report: %v", err)
+		}
+	}
 
-func generateLokiLogLine(t *gctrace) {
-	var l logLine
-	l.Level = "info"
-	l.Host = ownHost
-	l.Service = *serviceName
-	l.Component = "gcvis"
-	l.Message = "garbage collection event"
-
-	// precision is milliseconds thus we can use this conversion here
-	deltaMs := time.Millisecond * time.Duration(int64(t.ElapsedTime*1000))
-
-	l.Time = StartTime.Add(deltaMs).UTC()
-
-	// add harvested fields
-	l.GC.HeapUse = t.Heap1
-	l.GC.MASAssistcpu = t.MASAssistcpu
-	l.GC.MASBGcpu = t.MASBGcpu
-	l.GC.MASIdlecpu = t.MASIdlecpu
-	l.GC.MASclock = t.MASclock
-	l.GC.STWMclock = t.STWMclock
-	l.GC.STWMcpu = t.STWMcpu
-	l.GC.STWSclock = t.STWSclock
-	l.GC.STWScpu = t.STWScpu
-
-	err := json.NewEncoder(os.Stderr).Encode(&l)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: cannot encode log line: %v\n", err)
+	if parser.Err != nil {
+		fatalf("%v", parser.Err)
 	}
+
+	if subcommand != nil && subcommand.Err() != nil {
+		fatalf("%v", subcommand.Err())
+	}
+
+	if failed := failedAssertions(stats, failIfs); len(failed) > 0 {
+		for _, msg := range failed {
+			errorf("%s", msg)
+		}
+		removePIDFile()
+		os.Exit(failIfExitCode)
+	}
+
+	if keepServing {
+		infof("end of the traces, still serving on %s until interrupted", url)
+		waitForInterrupt()
+	}
+}
+
+// waitForInterrupt blocks until gcvis is interrupted.
+func waitForInterrupt() {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
 }
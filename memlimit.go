@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var rssPid = flag.Int("pid", 0, "process id of the program whose traces are read, to sample its resident memory for the GOMEMLIMIT advice and the rss graphs. The program gcvis runs is sampled without it")
+
+const (
+	// rssSampleInterval is how often the resident memory of the program
+	// is read.
+	rssSampleInterval = time.Second
+
+	// gomemlimitHeadroom is the share of the container limit left out of
+	// the recommended GOMEMLIMIT, at least, for the memory the runtime
+	// does not account for, e.g. of cgo.
+	gomemlimitHeadroom = 0.1
+
+	// oomRiskShare is the share of the container limit the heap or the
+	// resident memory may reach, without a GOMEMLIMIT under the limit,
+	// before risking being killed.
+	oomRiskShare = 0.9
+
+	// thrashingRiskShare is the share of GOMEMLIMIT the live heap may
+	// reach before the GC runs nearly continuously to stay under it.
+	thrashingRiskShare = 0.8
+)
+
+// memLimitAdvice recommends a GOMEMLIMIT under the memory limit of the
+// container, telling the risks of the current one.
+type memLimitAdvice struct {
+	ContainerLimitMB float64  `json:"container_limit_mb"`
+	CurrentMB        float64  `json:"current_gomemlimit_mb,omitempty"`
+	RecommendedMB    float64  `json:"recommended_gomemlimit_mb"`
+	Recommended      string   `json:"recommended_gomemlimit"` // as GOMEMLIMIT takes it
+	HeapHighWaterMB  int64    `json:"heap_high_water_mb"`
+	LiveHighWaterMB  int64    `json:"live_heap_high_water_mb"`
+	RSSHighWaterMB   int64    `json:"rss_high_water_mb,omitempty"`
+	Risks            []string `json:"risks,omitempty"`
+}
+
+// adviseMemoryLimit recommends a GOMEMLIMIT for the run of s under the
+// container limit, in megabytes, with current the GOMEMLIMIT in use, 0 if
+// none, or returns nil without a container limit or cycles.
+//
+// The recommendation leaves gomemlimitHeadroom of the container limit, or
+// the resident memory outside of the heap if it is more, out.
+func adviseMemoryLimit(s *runStats, containerMB, currentMB float64) *memLimitAdvice {
+	if containerMB <= 0 || s.NumGC == 0 {
+		return nil
+	}
+
+	a := &memLimitAdvice{
+		ContainerLimitMB: containerMB,
+		CurrentMB:        currentMB,
+		HeapHighWaterMB:  s.HeapMax,
+		LiveHighWaterMB:  s.liveMax,
+		RSSHighWaterMB:   s.RSSMax,
+	}
+
+	headroom := gomemlimitHeadroom * containerMB
+	if outside := float64(s.RSSMax - s.HeapMax); outside > headroom {
+		headroom = outside
+	}
+	a.RecommendedMB = math.Max(0, math.Floor(containerMB-headroom))
+	a.Recommended = fmt.Sprintf("%.0fMiB", a.RecommendedMB)
+
+	if currentMB <= 0 || currentMB >= containerMB {
+		if used := math.Max(float64(s.HeapMax), float64(s.RSSMax)); used >= oomRiskShare*containerMB {
+			a.Risks = append(a.Risks, fmt.Sprintf("OOM kill: the memory reached %.0fMB of the %.0fMB container limit, without a GOMEMLIMIT under it", used, containerMB))
+		}
+	}
+
+	limit, name := a.RecommendedMB, "the recommended GOMEMLIMIT"
+	if currentMB > 0 {
+		limit, name = currentMB, "GOMEMLIMIT"
+	}
+	if float64(s.liveMax) >= thrashingRiskShare*limit {
+		a.Risks = append(a.Risks, fmt.Sprintf("GC thrashing: the live heap reached %dMB, %.0f%% of %s of %.0fMB", s.liveMax, 100*float64(s.liveMax)/limit, name, limit))
+	}
+	return a
+}
+
+// String sums up the advice in a line, e.g. for the exit table.
+func (a *memLimitAdvice) String() string {
+	s := fmt.Sprintf("GOMEMLIMIT=%s for a container limit of %.0fMB", a.Recommended, a.ContainerLimitMB)
+	if a.CurrentMB > 0 {
+		s += fmt.Sprintf(", instead of %.0fMB", a.CurrentMB)
+	}
+	for _, risk := range a.Risks {
+		s += "; risk of " + risk
+	}
+	return s
+}
+
+// memLimitAdvice returns the GOMEMLIMIT advice for the run, or nil without a
+// container limit.
+func (s *runStats) memLimitAdvice() *memLimitAdvice {
+	// The runtime rejects an invalid GOMEMLIMIT, there is none then.
+	current, _ := detectMemoryLimit("", os.Getenv("GOMEMLIMIT"), nil)
+	return adviseMemoryLimit(s, containerMemoryLimit(), current)
+}
+
+// containerMemoryLimit returns the memory limit of the container in
+// megabytes, -memory-limit or else the limit of the cgroup, or 0 if it is
+// unknown.
+func containerMemoryLimit() float64 {
+	limit, err := detectMemoryLimit(*memoryLimit, "", cgroupMemoryLimits)
+	if err != nil {
+		debugf("advising GOMEMLIMIT without a container limit: %v", err)
+	}
+	return limit
+}
+
+// sampleRSS sends the resident memory of the process of pid, in megabytes,
+// every interval until stop is closed. pid returns 0 until the process has
+// started. Sampling stops if the memory cannot be read, e.g. outside of
+// Linux.
+func sampleRSS(pid func() int, interval time.Duration, samples chan<- int64, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p := pid()
+			if p == 0 {
+				continue
+			}
+			mb, err := readRSS(fmt.Sprintf("/proc/%d/statm", p))
+			if err != nil {
+				debugf("cannot sample the resident memory of %d: %v", p, err)
+				return
+			}
+			select {
+			case samples <- mb:
+			case <-stop:
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// readRSS returns the resident memory in megabytes of a statm file, whose
+// second field is the number of resident pages.
+func readRSS(path string) (int64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("%s: unexpected %q", path, b)
+	}
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", path, err)
+	}
+	return pages * int64(os.Getpagesize()) >> 20, nil
+}
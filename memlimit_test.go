@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newMemLimitStats(live, heap, rss int64) *runStats {
+	s := newRunStats()
+	s.addGC(&gctrace{ElapsedTime: 1, Heap0: heap, Heap3: live})
+	s.addRSS(rss)
+	return s
+}
+
+func TestAdviseMemoryLimit(t *testing.T) {
+	if a := adviseMemoryLimit(newMemLimitStats(100, 200, 250), 0, 0); a != nil {
+		t.Errorf("Expected no advice without a container limit. Got %+v instead.", a)
+	}
+
+	a := adviseMemoryLimit(newMemLimitStats(100, 200, 250), 1000, 0)
+	if a.RecommendedMB != 900 || a.Recommended != "900MiB" || len(a.Risks) != 0 {
+		t.Errorf("Expected GOMEMLIMIT=900MiB without risks. Got %+v instead.", a)
+	}
+
+	// 300MB resident outside of the heap.
+	a = adviseMemoryLimit(newMemLimitStats(100, 200, 500), 1000, 0)
+	if a.RecommendedMB != 700 {
+		t.Errorf("Expected the memory outside of the heap to be left out. Got %+v instead.", a)
+	}
+
+	a = adviseMemoryLimit(newMemLimitStats(400, 920, 950), 1000, 0)
+	if len(a.Risks) != 1 || !strings.HasPrefix(a.Risks[0], "OOM kill: the memory reached 950MB") {
+		t.Errorf("Expected a risk of OOM kill. Got %+v instead.", a.Risks)
+	}
+	if a = adviseMemoryLimit(newMemLimitStats(400, 920, 950), 1000, 900); len(a.Risks) != 0 {
+		t.Errorf("Expected no risk with GOMEMLIMIT under the container limit. Got %+v instead.", a.Risks)
+	}
+
+	a = adviseMemoryLimit(newMemLimitStats(450, 600, 650), 1000, 500)
+	if len(a.Risks) != 1 || !strings.HasPrefix(a.Risks[0], "GC thrashing: the live heap reached 450MB, 90% of GOMEMLIMIT") {
+		t.Errorf("Expected a risk of GC thrashing. Got %+v instead.", a.Risks)
+	}
+	if !strings.Contains(a.String(), "GOMEMLIMIT=900MiB for a container limit of 1000MB, instead of 500MB; risk of GC thrashing") {
+		t.Errorf("Expected the advice to tell the risk. Got %q instead.", a.String())
+	}
+}
+
+func TestReadRSS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "statm")
+	pages := (64 << 20) / os.Getpagesize()
+	if err := ioutil.WriteFile(path, []byte("100000 "+strconv.Itoa(pages)+" 500 1 0 2000 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if mb, err := readRSS(path); err != nil || mb != 64 {
+		t.Errorf("Expected 64MB resident. Got %d, %v instead.", mb, err)
+	}
+
+	if _, err := readRSS(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("Expected an error for a missing file.")
+	}
+}
+
+func TestSampleRSS(t *testing.T) {
+	samples := make(chan int64)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go sampleRSS(os.Getpid, time.Millisecond, samples, stop)
+
+	select {
+	case mb := <-samples:
+		if mb <= 0 {
+			t.Errorf("Expected the resident memory of the test. Got %dMB instead.", mb)
+		}
+	case <-time.After(time.Second):
+		if _, err := os.Stat("/proc/self/statm"); err == nil {
+			t.Fatalf("Expected a sample.")
+		}
+	}
+}
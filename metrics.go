@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// metric is a single sample of a gcvis metric, named after the Prometheus
+// conventions. Metric based sinks all export the same names so dashboards
+// work with any of them.
+type metric struct {
+	Name  string
+	Value float64
+}
+
+// gcMetrics returns the metrics of t in the series selected by -series.
+func gcMetrics(t *gctrace) []metric {
+	return append(selectMetrics(allGCMetrics(t)), computedMetrics(t.Computed)...)
+}
+
+// scvgMetrics returns the metrics of s in the series selected by -series.
+func scvgMetrics(s *scvgtrace) []metric {
+	return append(selectMetrics(allScvgMetrics(s)), computedMetrics(s.Computed)...)
+}
+
+func allGCMetrics(t *gctrace) []metric {
+	return []metric{
+		{"gcvis_gc_cycle", float64(t.NumGC)},
+		{"gcvis_gc_overhead_percent", t.GCOverhead},
+		{"gcvis_heap_start_megabytes", float64(t.Heap0)},
+		{"gcvis_heap_end_megabytes", float64(t.Heap2)},
+		{"gcvis_heap_live_megabytes", float64(t.Heap3)},
+		{"gcvis_heap_goal_megabytes", float64(t.Heap1)},
+		{"gcvis_stw_sweep_clock_milliseconds", t.STWSclock},
+		{"gcvis_mark_scan_clock_milliseconds", t.MASclock},
+		{"gcvis_stw_mark_clock_milliseconds", t.STWMclock},
+		{"gcvis_stw_sweep_cpu_milliseconds", t.STWScpu},
+		{"gcvis_mark_scan_assist_cpu_milliseconds", t.MASAssistcpu},
+		{"gcvis_mark_scan_background_cpu_milliseconds", t.MASBGcpu},
+		{"gcvis_mark_scan_idle_cpu_milliseconds", t.MASIdlecpu},
+		{"gcvis_stw_mark_cpu_milliseconds", t.STWMcpu},
+	}
+}
+
+func allScvgMetrics(s *scvgtrace) []metric {
+	return []metric{
+		{"gcvis_scvg_inuse_megabytes", float64(s.Inuse)},
+		{"gcvis_scvg_idle_megabytes", float64(s.Idle)},
+		{"gcvis_scvg_sys_megabytes", float64(s.Sys)},
+		{"gcvis_scvg_released_megabytes", float64(s.Released)},
+		{"gcvis_scvg_consumed_megabytes", float64(s.Consumed)},
+		{"gcvis_scvg_released_idle_percent", scvgReleasedPercent(s)},
+		{"gcvis_scvg_sys_gap_megabytes", float64(scvgSysGap(s))},
+	}
+}
+
+// metricLabels returns the labels attached to every exported metric.
+func metricLabels() map[string]string {
+	labels := map[string]string{}
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
+	labels["srv"] = *serviceName
+	labels["host"] = ownHost
+
+	return labels
+}
+
+// sortedLabelNames returns the names of labels in lexicographic order, as
+// most metric protocols expect.
+func sortedLabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// timestampMillis converts t to milliseconds since the unix epoch.
+func timestampMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
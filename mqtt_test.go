@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+)
+
+// readMQTTPacket reads a packet, returning its type and flags byte and its
+// body.
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func TestMQTTSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer listener.Close()
+
+	type packet struct {
+		header byte
+		body   []byte
+	}
+	received := make(chan []packet)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var packets []packet
+		r := bufio.NewReader(conn)
+		for {
+			header, body, err := readMQTTPacket(r)
+			if err != nil {
+				break
+			}
+			packets = append(packets, packet{header, body})
+			if header == 0x10 {
+				conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+			}
+		}
+		received <- packets
+	}()
+
+	savedURL, savedTopic, savedName := *mqttURL, *mqttTopic, *serviceName
+	*mqttURL, *mqttTopic, *serviceName = "tcp://device:secret@"+listener.Addr().String(), "", "sensor"
+	defer func() { *mqttURL, *mqttTopic, *serviceName = savedURL, savedTopic, savedName }()
+
+	sink, err := newMQTTSink()
+	if err != nil {
+		t.Fatalf("newMQTTSink returned an error: %v", err)
+	}
+
+	sink.ConsumeGC(&gctrace{Heap1: 33})
+	sink.Close()
+
+	packets := <-received
+	if len(packets) != 3 {
+		t.Fatalf("Expected CONNECT, PUBLISH and DISCONNECT. Got %d packets instead.", len(packets))
+	}
+
+	connect := packets[0]
+	if connect.header != 0x10 || string(connect.body[2:6]) != "MQTT" || connect.body[6] != 4 {
+		t.Errorf("Expected an MQTT 3.1.1 CONNECT. Got %x %x instead.", connect.header, connect.body)
+	}
+	if flags := connect.body[7]; flags != 0xc2 {
+		t.Errorf("Expected a clean session with a user name and password. Got flags %x instead.", flags)
+	}
+
+	publish := packets[1]
+	if publish.header != 0x30 {
+		t.Errorf("Expected a QoS 0 PUBLISH. Got %x instead.", publish.header)
+	}
+	topicLength := int(publish.body[0])<<8 | int(publish.body[1])
+	if topic := string(publish.body[2 : 2+topicLength]); topic != "gcvis/sensor" {
+		t.Errorf("Expected topic gcvis/sensor. Got %q instead.", topic)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(publish.body[2+topicLength:], &payload); err != nil {
+		t.Fatalf("Expected a JSON payload. Got %q instead.", publish.body[2+topicLength:])
+	}
+	if payload["srv"] != "sensor" {
+		t.Errorf("Expected srv sensor. Got %v instead.", payload["srv"])
+	}
+
+	if packets[2].header != 0xe0 {
+		t.Errorf("Expected DISCONNECT. Got %x instead.", packets[2].header)
+	}
+}
+
+func TestMQTTSinkRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		readMQTTPacket(bufio.NewReader(conn))
+		conn.Write([]byte{0x20, 0x02, 0x00, 0x05})
+	}()
+
+	savedURL := *mqttURL
+	*mqttURL = "tcp://" + listener.Addr().String()
+	defer func() { *mqttURL = savedURL }()
+
+	sink, err := newMQTTSink()
+	if err != nil {
+		t.Fatalf("newMQTTSink returned an error: %v", err)
+	}
+	defer sink.Close()
+
+	err = sink.(*mqttSink).dial()
+	if err == nil || err.Error() != "MQTT broker refused the connection with return code 5" {
+		t.Errorf("Expected the connection to be refused. Got %v instead.", err)
+	}
+}
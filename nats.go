@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var natsURL = flag.String("nats", "", "URL of a NATS server to publish events to, e.g. nats://localhost:4222")
+var natsSubject = flag.String("nats-subject", "gcvis", "NATS subject events are published to")
+var natsBatchWait = flag.Duration("nats-batch-wait", 100*time.Millisecond, "maximum time an event waits before being published to NATS")
+
+// natsBatchSize bounds the number of messages written at once.
+const natsBatchSize = 100
+
+func init() {
+	RegisterSink("nats", newNATSSink)
+}
+
+// natsSink publishes every event as a JSON log line with the NATS client
+// protocol. Like the Graphite sink, the connection is opened lazily and
+// opened again after a failure. Delivery is at most once, as NATS core.
+type natsSink struct {
+	addr    string
+	connect []byte
+	subject string
+
+	mu      sync.Mutex // serializes writes, from send and the PING handler
+	conn    net.Conn
+	batcher *batcher
+}
+
+func newNATSSink() (Sink, error) {
+	if *natsURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(*natsURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" || u.Host == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q, expected nats://host:port", *natsURL)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "gcvis",
+		"lang":     "go",
+	}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options["user"], options["pass"] = u.User.Username(), password
+		} else {
+			options["auth_token"] = u.User.Username()
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &natsSink{
+		addr:    addr,
+		connect: []byte("CONNECT " + string(connect) + "\r\n"),
+		subject: *natsSubject,
+	}
+	s.batcher = newBatcher(natsBatchSize, *natsBatchWait, s.send)
+
+	return s, nil
+}
+
+func (s *natsSink) ConsumeGC(t *gctrace) error {
+	return s.publish(newGCLogLine(t))
+}
+
+func (s *natsSink) ConsumeScvg(t *scvgtrace) error {
+	return s.publish(newScvgLogLine(t))
+}
+
+func (s *natsSink) publish(l *logLine) error {
+	payload, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	s.batcher.Add(fmt.Sprintf("PUB %s %d\r\n%s\r\n", s.subject, len(payload), payload))
+	return nil
+}
+
+func (s *natsSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *natsSink) Close() error {
+	s.batcher.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// send publishes batch. It is the only one opening and closing conn, which
+// the PING handler also writes to, holding mu.
+func (s *natsSink) send(batch []interface{}) {
+	var body bytes.Buffer
+	for _, msg := range batch {
+		body.WriteString(msg.(string))
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d NATS messages", len(batch)), func() (bool, error) {
+		if s.conn == nil {
+			if err := s.dial(); err != nil {
+				return true, err
+			}
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.conn.SetWriteDeadline(time.Now().Add(pushTimeout))
+		if _, err := s.conn.Write(body.Bytes()); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+// dial opens a connection: the server greets with an INFO line, which is
+// answered with CONNECT.
+func (s *natsSink) dial() error {
+	conn, err := net.DialTimeout("tcp", s.addr, pushTimeout)
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(pushTimeout))
+	info, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected NATS greeting %q", strings.TrimSpace(info))
+	}
+	if _, err := conn.Write(s.connect); err != nil {
+		conn.Close()
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+
+	go s.readLoop(conn, r)
+
+	return nil
+}
+
+// readLoop answers the PINGs of the server, which otherwise drops the
+// connection, and logs its errors, until conn is closed.
+func (s *natsSink) readLoop(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PING":
+			s.mu.Lock()
+			conn.Write([]byte("PONG\r\n"))
+			s.mu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			errorf("NATS server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestNATSSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+
+		var lines []string
+		r := bufio.NewReader(conn)
+		for len(lines) < 3 {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			lines = append(lines, strings.TrimSpace(line))
+		}
+		received <- lines
+	}()
+
+	savedURL, savedSubject := *natsURL, *natsSubject
+	*natsURL, *natsSubject = "nats://secret@"+listener.Addr().String(), "gc.events"
+	defer func() { *natsURL, *natsSubject = savedURL, savedSubject }()
+
+	sink, err := newNATSSink()
+	if err != nil {
+		t.Fatalf("newNATSSink returned an error: %v", err)
+	}
+
+	sink.ConsumeGC(&gctrace{Heap1: 33})
+	sink.Close()
+
+	lines := <-received
+	if len(lines) != 3 {
+		t.Fatalf("Expected CONNECT, PUB and a payload. Got %q instead.", lines)
+	}
+
+	if !strings.HasPrefix(lines[0], "CONNECT ") || !strings.Contains(lines[0], `"auth_token":"secret"`) {
+		t.Errorf("Expected CONNECT with a token. Got %q instead.", lines[0])
+	}
+
+	if !strings.HasPrefix(lines[1], "PUB gc.events ") {
+		t.Errorf("Expected PUB on gc.events. Got %q instead.", lines[1])
+	}
+
+	var l logLine
+	if err := json.Unmarshal([]byte(lines[2]), &l); err != nil || l.GC == nil || l.GC.HeapGoal != 33 {
+		t.Errorf("Expected a GC log line with a heap goal of 33. Got %q instead.", lines[2])
+	}
+}
+
+func TestNewNATSSinkInvalidURL(t *testing.T) {
+	saved := *natsURL
+	*natsURL = "http://localhost:4222"
+	defer func() { *natsURL = saved }()
+
+	if _, err := newNATSSink(); err == nil {
+		t.Errorf("Expected an error for a non nats:// URL.")
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var otlpEndpoint = flag.String("otlp-endpoint", "", "base URL of an OTLP/HTTP receiver to export metrics to, e.g. http://localhost:4318")
+var otlpHeaders = labelsFlag{}
+var otlpInterval = flag.Duration("otlp-interval", 10*time.Second, "how often metrics and logs are exported over OTLP")
+var otlpMetrics = flag.Bool("otlp-metrics", true, "export metrics to the OTLP endpoint")
+var otlpLogs = flag.Bool("otlp-logs", false, "export every GC and scavenger event as a log record to the OTLP endpoint")
+
+// otlpPauseBounds are the explicit bucket bounds, in milliseconds, of the
+// GC pause histogram.
+var otlpPauseBounds = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+// otlpPausePhases maps the metrics exported as the GC pause histogram to
+// the value of its phase attribute. The other metrics are exported as
+// gauges.
+var otlpPausePhases = map[string]string{
+	"gcvis_stw_sweep_clock_milliseconds": "sweep_termination",
+	"gcvis_stw_mark_clock_milliseconds":  "mark_termination",
+}
+
+// otlpMaxBatch bounds the number of traces buffered between exports.
+const otlpMaxBatch = 10000
+
+func init() {
+	flag.Var(otlpHeaders, "otlp-header", "key=value header sent with OTLP requests, e.g. for authentication. Can be repeated.")
+
+	RegisterSink("otlp-metrics", newOTLPMetricsSink)
+	RegisterSink("otlp-logs", newOTLPLogsSink)
+}
+
+// otlpMetricsSink exports metrics with OTLP/HTTP, using the JSON encoding.
+// Every export carries the GC pauses seen since the previous one as a delta
+// histogram, and every other metric as gauge data points.
+type otlpMetricsSink struct {
+	url    string
+	header http.Header
+
+	client  *http.Client
+	batcher *batcher
+	start   time.Time // start of the current histogram interval
+}
+
+type otlpMetricsEntry struct {
+	ts      time.Time
+	metrics []metric
+}
+
+func newOTLPMetricsSink() (Sink, error) {
+	if *otlpEndpoint == "" || !*otlpMetrics {
+		return nil, nil
+	}
+
+	s := &otlpMetricsSink{
+		url:    strings.TrimRight(*otlpEndpoint, "/") + "/v1/metrics",
+		header: otlpHeader(),
+		client: &http.Client{Timeout: pushTimeout},
+		start:  time.Now(),
+	}
+	s.batcher = newBatcher(otlpMaxBatch, *otlpInterval, s.send)
+
+	return s, nil
+}
+
+func (s *otlpMetricsSink) ConsumeGC(t *gctrace) error {
+	s.batcher.Add(otlpMetricsEntry{traceTime(t.ElapsedTime), gcMetrics(t)})
+	return nil
+}
+
+func (s *otlpMetricsSink) ConsumeScvg(t *scvgtrace) error {
+	s.batcher.Add(otlpMetricsEntry{traceTime(t.ElapsedTime), scvgMetrics(t)})
+	return nil
+}
+
+func (s *otlpMetricsSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *otlpMetricsSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+func (s *otlpMetricsSink) send(batch []interface{}) {
+	now := time.Now()
+	body, err := json.Marshal(s.encode(batch, now))
+	s.start = now
+	if err != nil {
+		errorf("cannot encode OTLP metrics: %v", err)
+		return
+	}
+
+	retryWithBackoff(fmt.Sprintf("OTLP metrics of %d traces", len(batch)), func() (bool, error) {
+		return postBody(s.client, s.url, s.header, body)
+	})
+}
+
+// encode builds an ExportMetricsServiceRequest, following the OTLP JSON
+// mapping of the protobuf messages.
+func (s *otlpMetricsSink) encode(batch []interface{}, now time.Time) map[string]interface{} {
+	gauges := map[string][]interface{}{}
+	var names []string
+	pauses := map[string][]float64{}
+
+	for _, e := range batch {
+		entry := e.(otlpMetricsEntry)
+		for _, m := range entry.metrics {
+			if phase, ok := otlpPausePhases[m.Name]; ok {
+				pauses[phase] = append(pauses[phase], m.Value)
+				continue
+			}
+
+			if _, ok := gauges[m.Name]; !ok {
+				names = append(names, m.Name)
+			}
+			gauges[m.Name] = append(gauges[m.Name], map[string]interface{}{
+				"timeUnixNano": otlpTime(entry.ts),
+				"asDouble":     m.Value,
+			})
+		}
+	}
+
+	var metrics []interface{}
+	for _, name := range names {
+		otelName, unit := otlpMetricName(name)
+		metrics = append(metrics, map[string]interface{}{
+			"name":  otelName,
+			"unit":  unit,
+			"gauge": map[string]interface{}{"dataPoints": gauges[name]},
+		})
+	}
+
+	if len(pauses) > 0 {
+		var points []interface{}
+		phases := make([]string, 0, len(pauses))
+		for phase := range pauses {
+			phases = append(phases, phase)
+		}
+		sort.Strings(phases)
+
+		for _, phase := range phases {
+			points = append(points, s.histogramPoint(phase, pauses[phase], now))
+		}
+		metrics = append(metrics, map[string]interface{}{
+			"name": "gcvis.gc.pause",
+			"unit": "ms",
+			"histogram": map[string]interface{}{
+				"aggregationTemporality": 1, // AGGREGATION_TEMPORALITY_DELTA
+				"dataPoints":             points,
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"resourceMetrics": []interface{}{
+			map[string]interface{}{
+				"resource": otlpResource(),
+				"scopeMetrics": []interface{}{
+					map[string]interface{}{
+						"scope":   map[string]string{"name": "gcvis"},
+						"metrics": metrics,
+					},
+				},
+			},
+		},
+	}
+}
+
+func (s *otlpMetricsSink) histogramPoint(phase string, values []float64, now time.Time) map[string]interface{} {
+	counts := make([]uint64, len(otlpPauseBounds)+1)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+
+		i := 0
+		for i < len(otlpPauseBounds) && v > otlpPauseBounds[i] {
+			i++
+		}
+		counts[i]++
+	}
+
+	bucketCounts := make([]string, len(counts))
+	for i, c := range counts {
+		bucketCounts[i] = strconv.FormatUint(c, 10)
+	}
+
+	return map[string]interface{}{
+		"attributes":        otlpAttributes(map[string]string{"phase": phase}),
+		"startTimeUnixNano": otlpTime(s.start),
+		"timeUnixNano":      otlpTime(now),
+		"count":             strconv.Itoa(len(values)),
+		"sum":               sum,
+		"bucketCounts":      bucketCounts,
+		"explicitBounds":    otlpPauseBounds,
+	}
+}
+
+// otlpLogsSink exports the log lines as OTLP log records, with their gc or
+// scvg fields as attributes, leaving their routing to the collector.
+type otlpLogsSink struct {
+	url    string
+	header http.Header
+
+	client  *http.Client
+	batcher *batcher
+}
+
+func newOTLPLogsSink() (Sink, error) {
+	if *otlpEndpoint == "" || !*otlpLogs {
+		return nil, nil
+	}
+
+	s := &otlpLogsSink{
+		url:    strings.TrimRight(*otlpEndpoint, "/") + "/v1/logs",
+		header: otlpHeader(),
+		client: &http.Client{Timeout: pushTimeout},
+	}
+	s.batcher = newBatcher(otlpMaxBatch, *otlpInterval, s.send)
+
+	return s, nil
+}
+
+func (s *otlpLogsSink) ConsumeGC(t *gctrace) error {
+	return s.add(newGCLogLine(t))
+}
+
+func (s *otlpLogsSink) ConsumeScvg(t *scvgtrace) error {
+	return s.add(newScvgLogLine(t))
+}
+
+func (s *otlpLogsSink) add(l *logLine) error {
+	record, err := otlpLogRecord(l)
+	if err != nil {
+		return err
+	}
+
+	s.batcher.Add(record)
+	return nil
+}
+
+func (s *otlpLogsSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *otlpLogsSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+func (s *otlpLogsSink) send(batch []interface{}) {
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceLogs": []interface{}{
+			map[string]interface{}{
+				"resource": otlpResource(),
+				"scopeLogs": []interface{}{
+					map[string]interface{}{
+						"scope":      map[string]string{"name": "gcvis"},
+						"logRecords": batch,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		errorf("cannot encode OTLP logs: %v", err)
+		return
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d OTLP log records", len(batch)), func() (bool, error) {
+		return postBody(s.client, s.url, s.header, body)
+	})
+}
+
+// otlpLogRecord converts l to a LogRecord. The fields of its gc or scvg
+// object become attributes named like in Loki, e.g. gc.HeapGoal.
+func otlpLogRecord(l *logLine) (map[string]interface{}, error) {
+	prefix, values, err := eventFields(l)
+	if err != nil {
+		return nil, err
+	}
+
+	var attrs []interface{}
+	for _, name := range sortedFieldNames(values) {
+		attrs = append(attrs, map[string]interface{}{
+			"key":   prefix + "." + name,
+			"value": map[string]float64{"doubleValue": values[name]},
+		})
+	}
+
+	return map[string]interface{}{
+		"timeUnixNano":         otlpTime(l.Time),
+		"observedTimeUnixNano": otlpTime(time.Now()),
+		"severityNumber":       9, // SEVERITY_NUMBER_INFO
+		"severityText":         "INFO",
+		"body":                 map[string]string{"stringValue": l.Message},
+		"attributes":           attrs,
+	}, nil
+}
+
+// otlpMetricName turns a gcvis metric name into an OpenTelemetry one and
+// its unit, e.g. gcvis_heap_goal_megabytes into gcvis.heap_goal in MBy.
+func otlpMetricName(name string) (string, string) {
+	unit := "1"
+	switch {
+	case strings.HasSuffix(name, "_megabytes"):
+		name, unit = strings.TrimSuffix(name, "_megabytes"), "MBy"
+	case strings.HasSuffix(name, "_milliseconds"):
+		name, unit = strings.TrimSuffix(name, "_milliseconds"), "ms"
+	}
+
+	return strings.Replace(name, "gcvis_", "gcvis.", 1), unit
+}
+
+// otlpResource describes the monitored process: its service and host, and
+// the -label ones.
+func otlpResource() map[string]interface{} {
+	attrs := map[string]string{}
+	for k, v := range extraLabels {
+		attrs[k] = v
+	}
+	attrs["service.name"] = *serviceName
+	attrs["host.name"] = ownHost
+
+	return map[string]interface{}{"attributes": otlpAttributes(attrs)}
+}
+
+func otlpAttributes(attrs map[string]string) []interface{} {
+	var kvs []interface{}
+	for _, k := range sortedLabelNames(attrs) {
+		kvs = append(kvs, map[string]interface{}{
+			"key":   k,
+			"value": map[string]string{"stringValue": attrs[k]},
+		})
+	}
+	return kvs
+}
+
+func otlpHeader() http.Header {
+	header := http.Header{"Content-Type": {"application/json"}}
+	for k, v := range otlpHeaders {
+		header.Set(k, v)
+	}
+	return header
+}
+
+// otlpTime formats t as the OTLP JSON mapping expects 64 bit integers.
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []struct {
+		Resource struct {
+			Attributes []struct {
+				Key   string
+				Value struct{ StringValue string }
+			}
+		}
+		ScopeMetrics []struct {
+			Metrics []struct {
+				Name      string
+				Unit      string
+				Gauge     *struct{ DataPoints []struct{ AsDouble float64 } }
+				Histogram *struct {
+					AggregationTemporality int
+					DataPoints             []struct {
+						Count        string
+						Sum          float64
+						BucketCounts []string
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestOTLPMetricsSink(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []otlpMetricsRequest
+		path     string
+		auth     string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var r otlpMetricsRequest
+		if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+			t.Errorf("Error decoding request: %v", err)
+		}
+
+		mu.Lock()
+		requests = append(requests, r)
+		path = req.URL.Path
+		auth = req.Header.Get("Authorization")
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	savedEndpoint, savedInterval := *otlpEndpoint, *otlpInterval
+	*otlpEndpoint, *otlpInterval = server.URL, time.Hour
+	otlpHeaders["Authorization"] = "Bearer secret"
+	defer func() {
+		*otlpEndpoint, *otlpInterval = savedEndpoint, savedInterval
+		delete(otlpHeaders, "Authorization")
+	}()
+
+	sink, err := newOTLPMetricsSink()
+	if err != nil {
+		t.Fatalf("newOTLPMetricsSink returned an error: %v", err)
+	}
+
+	sink.ConsumeGC(&gctrace{Heap1: 33, STWSclock: 0.02, STWMclock: 0.3})
+	sink.ConsumeGC(&gctrace{Heap1: 34, STWSclock: 0.04, STWMclock: 2})
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(requests) != 1 {
+		t.Fatalf("Expected a single export. Got %d instead.", len(requests))
+	}
+	if path != "/v1/metrics" || auth != "Bearer secret" {
+		t.Errorf("Unexpected request path %q or Authorization header %q", path, auth)
+	}
+
+	rm := requests[0].ResourceMetrics[0]
+	attrs := map[string]string{}
+	for _, a := range rm.Resource.Attributes {
+		attrs[a.Key] = a.Value.StringValue
+	}
+	if attrs["service.name"] != *serviceName || attrs["host.name"] != ownHost {
+		t.Errorf("Unexpected resource attributes: %v", attrs)
+	}
+
+	var sawGoal, sawPause bool
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch m.Name {
+		case "gcvis.heap_goal":
+			sawGoal = true
+			if m.Unit != "MBy" || m.Gauge == nil || len(m.Gauge.DataPoints) != 2 || m.Gauge.DataPoints[1].AsDouble != 34 {
+				t.Errorf("Unexpected heap goal gauge: %+v", m)
+			}
+		case "gcvis.gc.pause":
+			sawPause = true
+			if m.Histogram == nil || m.Histogram.AggregationTemporality != 1 || len(m.Histogram.DataPoints) != 2 {
+				t.Fatalf("Unexpected pause histogram: %+v", m)
+			}
+			// data points are sorted by phase: mark then sweep termination
+			mark := m.Histogram.DataPoints[0]
+			if mark.Count != "2" || mark.Sum != 2.3 || len(mark.BucketCounts) != len(otlpPauseBounds)+1 {
+				t.Errorf("Unexpected mark termination data point: %+v", mark)
+			}
+		case "gcvis.stw_sweep_clock", "gcvis.stw_mark_clock":
+			t.Errorf("Expected pauses to be exported as a histogram only. Got gauge %s.", m.Name)
+		}
+	}
+	if !sawGoal || !sawPause {
+		t.Errorf("Expected both the heap goal gauge and the pause histogram.")
+	}
+}
+
+func TestOTLPMetricName(t *testing.T) {
+	name, unit := otlpMetricName("gcvis_heap_goal_megabytes")
+	if name != "gcvis.heap_goal" || unit != "MBy" {
+		t.Errorf("Unexpected name %q and unit %q", name, unit)
+	}
+
+	name, unit = otlpMetricName("gcvis_gc_cycle")
+	if name != "gcvis.gc_cycle" || unit != "1" {
+		t.Errorf("Unexpected name %q and unit %q", name, unit)
+	}
+}
+
+func TestOTLPLogsSink(t *testing.T) {
+	type logsRequest struct {
+		ResourceLogs []struct {
+			ScopeLogs []struct {
+				LogRecords []struct {
+					SeverityText string
+					Body         struct{ StringValue string }
+					Attributes   []struct {
+						Key   string
+						Value struct{ DoubleValue float64 }
+					}
+				}
+			}
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		requests []logsRequest
+		paths    []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var r logsRequest
+		if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+			t.Errorf("Error decoding request: %v", err)
+		}
+
+		mu.Lock()
+		requests = append(requests, r)
+		paths = append(paths, req.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	savedEndpoint, savedLogs, savedMetrics := *otlpEndpoint, *otlpLogs, *otlpMetrics
+	*otlpEndpoint, *otlpLogs, *otlpMetrics = server.URL, true, false
+	defer func() { *otlpEndpoint, *otlpLogs, *otlpMetrics = savedEndpoint, savedLogs, savedMetrics }()
+
+	if sink, _ := newOTLPMetricsSink(); sink != nil {
+		t.Errorf("Expected metrics to be turned off by -otlp-metrics=false.")
+	}
+
+	sink, err := newOTLPLogsSink()
+	if err != nil {
+		t.Fatalf("newOTLPLogsSink returned an error: %v", err)
+	}
+
+	sink.ConsumeGC(&gctrace{NumGC: 7})
+	sink.ConsumeScvg(&scvgtrace{Inuse: 12})
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(requests) != 1 || paths[0] != "/v1/logs" {
+		t.Fatalf("Expected a single export to /v1/logs. Got %v instead.", paths)
+	}
+
+	records := requests[0].ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 log records. Got %d instead.", len(records))
+	}
+	if records[0].Body.StringValue != gcMessage || records[1].Body.StringValue != scvgMessage {
+		t.Errorf("Unexpected log record bodies: %+v", records)
+	}
+
+	attrs := map[string]float64{}
+	for _, a := range records[0].Attributes {
+		attrs[a.Key] = a.Value.DoubleValue
+	}
+	if attrs["gc.NumGC"] != 7 {
+		t.Errorf("Expected gc.NumGC attribute to equal 7. Got %v instead.", attrs)
+	}
+}
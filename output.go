@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var rotateSize = flag.Int("rotate-size", 0, "size in megabytes past which -loki-out, -influx-out and -record files are rotated. 0 never rotates them on size")
+var rotateEvery = flag.Duration("rotate-every", 0, "how often -loki-out, -influx-out and -record files are rotated, e.g. 24h. 0 never rotates them on time")
+var rotateGzip = flag.Bool("rotate-gzip", false, "gzip rotated files")
+var rotateKeep = flag.Int("rotate-keep", 0, "number of rotated files kept, the older ones being removed. 0 keeps them all")
+
+// rotatedTimeFormat is the format of the time rotated files are suffixed
+// with, in UTC.
+const rotatedTimeFormat = "20060102T150405.000"
+
+// rotateFlushInterval is how often the writes to a rotating file, which
+// are buffered, are flushed.
+const rotateFlushInterval = time.Second
+
+// openOutput opens the destination of a file based sink described by spec:
+// stderr, stdout or the path of a file to append to. It returns nil if spec
+// turns the sink off. Files are rotated if -rotate-size, -rotate-every or
+// -retention are set.
+func openOutput(spec string) (io.WriteCloser, error) {
+	switch spec {
+	case "off", "":
+		return nil, nil
+	case "stderr":
+		return nopWriteCloser{os.Stderr}, nil
+	case "stdout":
+		return nopWriteCloser{os.Stdout}, nil
+	}
+
+	interval := *rotateEvery
+	if interval == 0 && *rotateSize == 0 && *retention > 0 {
+		// Rotated files are removed once older than -retention, so that
+		// events are kept a sixth of it longer at most.
+		interval = *retention / 6
+	}
+	if *rotateSize > 0 || interval > 0 {
+		return openRotatingFile(spec, int64(*rotateSize)<<20, interval, *rotateGzip, *rotateKeep, *retention)
+	}
+	return os.OpenFile(spec, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+}
+
+// nopWriteCloser keeps the standard streams open when an output is closed.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// rotatingFile is a file renamed to path.<time it was rotated> once it
+// grows past maxSize bytes, or every interval, a new file being opened at
+// path. Rotated files are optionally gzipped, and only the keep most recent
+// ones, rotated less than retention ago, are kept. Writes are buffered, and
+// flushed every rotateFlushInterval, as files are rotated and when closed.
+type rotatingFile struct {
+	path      string
+	maxSize   int64
+	interval  time.Duration
+	compress  bool
+	keep      int
+	retention time.Duration
+
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	size   int64
+	opened time.Time
+
+	archiving sync.Mutex     // serializes the compression and removal of rotated files
+	archives  sync.WaitGroup // of the goroutines archiving rotated files
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func openRotatingFile(path string, maxSize int64, interval time.Duration, compress bool, keep int, retention time.Duration) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:      path,
+		maxSize:   maxSize,
+		interval:  interval,
+		compress:  compress,
+		keep:      keep,
+		retention: retention,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	go r.flushPeriodically()
+
+	return r, nil
+}
+
+// open opens the file at path, appending to it if it already exists.
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	r.f, r.w, r.size, r.opened = f, bufio.NewWriter(f), info.Size(), time.Now()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && (r.maxSize > 0 && r.size+int64(len(p)) > r.maxSize || r.interval > 0 && time.Since(r.opened) >= r.interval) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.w.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file and opens a new one. Its caller holds mu.
+func (r *rotatingFile) rotate() error {
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+
+	rotated := r.path + "." + time.Now().UTC().Format(rotatedTimeFormat)
+	if err := os.Rename(r.path, rotated); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.archives.Add(1)
+	go r.archive(rotated)
+
+	return nil
+}
+
+// archive compresses a rotated file if needed, then removes the rotated
+// files past the keep most recent ones, or rotated before retention.
+func (r *rotatingFile) archive(rotated string) {
+	defer r.archives.Done()
+
+	r.archiving.Lock()
+	defer r.archiving.Unlock()
+
+	if r.compress {
+		if err := gzipFile(rotated); err != nil {
+			errorf("cannot gzip %s: %v", rotated, err)
+		}
+	}
+
+	if r.keep <= 0 && r.retention <= 0 {
+		return
+	}
+	// Rotation times sort chronologically.
+	rotatedFiles, err := filepath.Glob(r.path + ".[0-9]*")
+	if err != nil {
+		return
+	}
+	sort.Strings(rotatedFiles)
+
+	expired := 0
+	if r.keep > 0 && len(rotatedFiles) > r.keep {
+		expired = len(rotatedFiles) - r.keep
+	}
+	if r.retention > 0 {
+		cutoff := time.Now().Add(-r.retention)
+		for expired < len(rotatedFiles) && r.rotatedAt(rotatedFiles[expired]).Before(cutoff) {
+			expired++
+		}
+	}
+	for _, name := range rotatedFiles[:expired] {
+		if err := os.Remove(name); err != nil {
+			errorf("cannot remove %s: %v", name, err)
+		}
+	}
+}
+
+// rotatedAt returns the time the file name was rotated at, or now if its
+// name does not tell.
+func (r *rotatingFile) rotatedAt(name string) time.Time {
+	suffix := strings.TrimPrefix(name, r.path+".")
+	if len(suffix) < len(rotatedTimeFormat) {
+		return time.Now()
+	}
+	t, err := time.Parse(rotatedTimeFormat, suffix[:len(rotatedTimeFormat)])
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
+
+// gzipFile replaces the file at path with path.gz.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func (r *rotatingFile) flushPeriodically() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(rotateFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.Lock()
+			if err := r.w.Flush(); err != nil {
+				errorf("cannot write %s: %v", r.path, err)
+			}
+			r.mu.Unlock()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Close flushes and closes the current file, and waits for rotated files
+// to be archived.
+func (r *rotatingFile) Close() error {
+	close(r.stop)
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.w.Flush()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	r.archives.Wait()
+
+	return err
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gc.log")
+	r, err := openRotatingFile(path, 10, 0, true, 2, 0)
+	if err != nil {
+		t.Fatalf("openRotatingFile returned an error: %v", err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write returned an error: %v", err)
+		}
+		// Rotated files are named after the millisecond they were rotated.
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil || string(content) != "fourth\n" {
+		t.Errorf("Expected the current file to hold the last line. Got %q instead.", content)
+	}
+
+	rotated, _ := filepath.Glob(path + ".*")
+	sort.Strings(rotated)
+	if len(rotated) != 2 {
+		t.Fatalf("Expected 2 rotated files to be kept. Got %v instead.", rotated)
+	}
+
+	var lines []string
+	for _, name := range rotated {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Fatalf("Expected %s to be gzipped.", name)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatalf("Open returned an error: %v", err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("%s is not gzipped: %v", name, err)
+		}
+		content, _ := ioutil.ReadAll(zr)
+		f.Close()
+		lines = append(lines, string(content))
+	}
+	if lines[0] != "second\n" || lines[1] != "third\n" {
+		t.Errorf("Expected the second and third lines to be kept. Got %q instead.", lines)
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gc.log")
+	r, err := openRotatingFile(path, 0, 10*time.Millisecond, false, 0, 0)
+	if err != nil {
+		t.Fatalf("openRotatingFile returned an error: %v", err)
+	}
+	r.Write([]byte("first\n"))
+	r.Write([]byte("second\n"))
+	time.Sleep(20 * time.Millisecond)
+	r.Write([]byte("third\n"))
+	r.Close()
+
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 1 {
+		t.Fatalf("Expected a rotated file. Got %v instead.", rotated)
+	}
+	content, _ := ioutil.ReadFile(rotated[0])
+	if string(content) != "first\nsecond\n" {
+		t.Errorf("Expected the rotated file to hold the first two lines. Got %q instead.", content)
+	}
+	content, _ = ioutil.ReadFile(path)
+	if string(content) != "third\n" {
+		t.Errorf("Expected the current file to hold the last line. Got %q instead.", content)
+	}
+}
+
+func TestOpenOutputRotating(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	savedEvery := *rotateEvery
+	*rotateEvery = time.Hour
+	defer func() { *rotateEvery = savedEvery }()
+
+	w, err := openOutput(filepath.Join(dir, "gc.log"))
+	if err != nil {
+		t.Fatalf("openOutput returned an error: %v", err)
+	}
+	defer w.Close()
+
+	if _, ok := w.(*rotatingFile); !ok {
+		t.Errorf("Expected a rotating file. Got %T instead.", w)
+	}
+}
+
+func TestRotatingFileRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gc.log")
+	old := path + "." + time.Now().Add(-2*time.Hour).UTC().Format(rotatedTimeFormat)
+	recent := path + "." + time.Now().Add(-time.Minute).UTC().Format(rotatedTimeFormat)
+	for _, name := range []string{old, recent} {
+		if err := ioutil.WriteFile(name, []byte("rotated\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := openRotatingFile(path, 10, 0, false, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("openRotatingFile returned an error: %v", err)
+	}
+	r.Write([]byte("first line\n"))
+	r.Write([]byte("second line\n"))
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	rotated, _ := filepath.Glob(path + ".*")
+	sort.Strings(rotated)
+	if len(rotated) != 2 || rotated[0] != recent {
+		t.Errorf("Expected the files rotated over an hour ago to be removed. Got %v instead.", rotated)
+	}
+}
+
+func TestOpenOutputRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(saved time.Duration) { *retention = saved }(*retention)
+	*retention = 6 * time.Hour
+
+	w, err := openOutput(filepath.Join(dir, "gc.log"))
+	if err != nil {
+		t.Fatalf("openOutput returned an error: %v", err)
+	}
+	defer w.Close()
+
+	if r, ok := w.(*rotatingFile); !ok || r.interval != time.Hour || r.retention != 6*time.Hour {
+		t.Errorf("Expected a file rotated every hour, kept 6 hours. Got %+v instead.", w)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"time"
+)
+
+// overheadWindow is the time the GC overhead is computed over.
+const overheadWindow = time.Minute
+
+// overheadCycle is a GC cycle at t seconds, whose phases took clock
+// milliseconds.
+type overheadCycle struct {
+	t, clock float64
+}
+
+// overheadMeter tells the percentage of the wall-clock time spent
+// collecting garbage over the last overheadWindow: the clock time of the
+// cycles, over the time since the cycle before the first of them.
+type overheadMeter struct {
+	cycles []overheadCycle // the first one only starts the window
+}
+
+// add adds the cycle of t, returning the overhead as of it, or 0 for the
+// first cycle.
+func (m *overheadMeter) add(t *gctrace) float64 {
+	elapsed := traceTime(t.ElapsedTime).Sub(traceStartTime()).Seconds()
+	m.cycles = append(m.cycles, overheadCycle{elapsed, t.STWSclock + t.MASclock + t.STWMclock})
+
+	// Keep one cycle older than the window, the window starting with it.
+	start := 0
+	for start+1 < len(m.cycles) && m.cycles[start+1].t <= elapsed-overheadWindow.Seconds() {
+		start++
+	}
+	m.cycles = m.cycles[start:]
+
+	d := (elapsed - m.cycles[0].t) * 1000
+	if d <= 0 {
+		return 0
+	}
+	var clock float64
+	for _, c := range m.cycles[1:] {
+		clock += c.clock
+	}
+	return 100 * clock / d
+}
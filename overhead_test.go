@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOverheadMeter(t *testing.T) {
+	var m overheadMeter
+	if o := m.add(&gctrace{ElapsedTime: 10, MASclock: 100}); o != 0 {
+		t.Errorf("Expected no overhead for the first cycle. Got %v instead.", o)
+	}
+
+	// 50ms of garbage collection in the 10s since the first cycle.
+	if o := m.add(&gctrace{ElapsedTime: 20, STWSclock: 10, MASclock: 30, STWMclock: 10}); math.Abs(o-0.5) > 1e-9 {
+		t.Errorf("Expected an overhead of 0.5%%. Got %v instead.", o)
+	}
+	if o := m.add(&gctrace{ElapsedTime: 30, MASclock: 250}); math.Abs(o-1.5) > 1e-9 {
+		t.Errorf("Expected an overhead of 1.5%%. Got %v instead.", o)
+	}
+
+	// The window starts with the last cycle at least a minute old.
+	if o := m.add(&gctrace{ElapsedTime: 80, MASclock: 0}); m.cycles[0].t != 20 || math.Abs(o-250.0/60000*100) > 1e-9 {
+		t.Errorf("Expected the window to start at 20s. Got %v, %+v instead.", o, m.cycles)
+	}
+	if o := m.add(&gctrace{ElapsedTime: 90, MASclock: 60}); m.cycles[0].t != 30 || math.Abs(o-0.1) > 1e-9 {
+		t.Errorf("Expected the window to start at 30s. Got %v, %+v instead.", o, m.cycles)
+	}
+}
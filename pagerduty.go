@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var pagerDutyRoutingKey = flag.String("pagerduty-routing-key", "", "integration key of a PagerDuty service to send -alert trigger and resolve events to")
+var pagerDutySeverity = flag.String("pagerduty-severity", "error", "severity of the PagerDuty events: critical, error, warning or info")
+var pagerDutyURL = flag.String("pagerduty-url", "https://events.pagerduty.com/v2/enqueue", "URL of the PagerDuty Events API v2, e.g. https://events.eu.pagerduty.com/v2/enqueue for the EU service region")
+
+func init() {
+	RegisterNotifier("pagerduty", newPagerDutyNotifier)
+}
+
+// pagerDutyNotifier sends a trigger event when an alert fires, and a
+// resolve event when it recovers, both deduplicated by rule and host so
+// that PagerDuty opens a single incident per rule.
+type pagerDutyNotifier struct {
+	url        string
+	routingKey string
+	severity   string
+	client     *http.Client
+}
+
+func newPagerDutyNotifier() (Notifier, error) {
+	if *pagerDutyRoutingKey == "" {
+		return nil, nil
+	}
+
+	switch *pagerDutySeverity {
+	case "critical", "error", "warning", "info":
+	default:
+		return nil, fmt.Errorf("invalid -pagerduty-severity %q, expected critical, error, warning or info", *pagerDutySeverity)
+	}
+
+	return &pagerDutyNotifier{
+		url:        *pagerDutyURL,
+		routingKey: *pagerDutyRoutingKey,
+		severity:   *pagerDutySeverity,
+		client:     &http.Client{Timeout: pushTimeout},
+	}, nil
+}
+
+func (n *pagerDutyNotifier) Notify(a *Alert) error {
+	body, err := json.Marshal(n.event(a))
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	return sendWithBackoff("PagerDuty event", func() (bool, error) {
+		return postBody(n.client, n.url, header, body)
+	})
+}
+
+// event returns the Events API v2 event of a.
+func (n *pagerDutyNotifier) event(a *Alert) map[string]interface{} {
+	event := map[string]interface{}{
+		"routing_key":  n.routingKey,
+		"event_action": "resolve",
+		"dedup_key":    fmt.Sprintf("gcvis/%s/%s", ownHost, a.Rule),
+	}
+	if !a.Firing {
+		return event
+	}
+
+	event["event_action"] = "trigger"
+	details := map[string]interface{}{"value": a.valueString(), "trace": a.Trace}
+	if a.Detail != "" {
+		details["detail"] = a.Detail
+	}
+	if a.Recent != nil {
+		details["recent"] = a.Recent
+	}
+	event["payload"] = map[string]interface{}{
+		"summary":        fmt.Sprintf("%s on %s: %s", a.Rule, ownHost, a.valueString()),
+		"source":         ownHost,
+		"severity":       n.severity,
+		"timestamp":      a.Since.UTC().Format(time.RFC3339),
+		"component":      *serviceName,
+		"group":          "gcvis",
+		"custom_details": details,
+	}
+	if a.URL != "" {
+		event["links"] = []map[string]string{{"href": a.URL, "text": "gcvis"}}
+	}
+	return event
+}
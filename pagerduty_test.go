@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPagerDutyNotifier(t *testing.T) {
+	received := make(chan map[string]interface{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&event)
+		received <- event
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	n := &pagerDutyNotifier{url: server.URL, routingKey: "key", severity: "critical", client: server.Client()}
+	since := time.Now()
+	n.Notify(&Alert{Rule: "heap>1GiB", Firing: true, Value: 2048, Unit: "MB", Since: since, URL: "http://127.0.0.1:4500/"})
+	n.Notify(&Alert{Rule: "heap>1GiB", Value: 512, Unit: "MB", Since: since})
+
+	trigger, resolve := <-received, <-received
+	if trigger["event_action"] != "trigger" || trigger["routing_key"] != "key" {
+		t.Errorf("Expected a trigger event. Got %v instead.", trigger)
+	}
+	payload, _ := trigger["payload"].(map[string]interface{})
+	if payload["severity"] != "critical" || payload["source"] != ownHost || payload["summary"] != "heap>1GiB on "+ownHost+": 2048MB" {
+		t.Errorf("Unexpected trigger payload: %v", payload)
+	}
+	if links, _ := trigger["links"].([]interface{}); len(links) != 1 {
+		t.Errorf("Expected a link to gcvis. Got %v instead.", trigger["links"])
+	}
+
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != trigger["dedup_key"] || resolve["payload"] != nil {
+		t.Errorf("Expected a resolve event of the same incident. Got %v instead.", resolve)
+	}
+}
+
+func TestPagerDutyNotifierFailure(t *testing.T) {
+	defer func(backoff time.Duration) { pushMinBackoff = backoff }(pushMinBackoff)
+	pushMinBackoff = time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n := &pagerDutyNotifier{url: server.URL, routingKey: "key", severity: "error", client: server.Client()}
+	if err := n.Notify(&Alert{Rule: "heap>1GiB", Firing: true}); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected the failure to be returned. Got %v instead.", err)
+	}
+}
+
+func TestNewPagerDutyNotifierSeverity(t *testing.T) {
+	defer func(key, severity string) { *pagerDutyRoutingKey, *pagerDutySeverity = key, severity }(*pagerDutyRoutingKey, *pagerDutySeverity)
+	*pagerDutyRoutingKey, *pagerDutySeverity = "key", "dire"
+
+	if _, err := newPagerDutyNotifier(); err == nil {
+		t.Errorf("Expected an unknown severity to be rejected.")
+	}
+}
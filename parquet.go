@@ -0,0 +1,430 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+var parquetOut = flag.String("parquet", "", "path of a Parquet file to write GC events to. The file is complete once gcvis exits")
+var parquetScvgOut = flag.String("parquet-scvg", "", "path of a Parquet file to write scavenger events to")
+var parquetRowGroupSize = flag.Int("parquet-row-group-size", 50000, "number of events buffered in memory before being written as a Parquet row group")
+
+var (
+	parquetGCColumns = []parquetColumn{
+		{"time", parquetInt64, parquetTimestampMillis},
+		{"elapsed", parquetDouble, parquetNoConvertedType},
+		{"num_gc", parquetInt64, parquetNoConvertedType},
+		{"heap0", parquetInt64, parquetNoConvertedType},
+		{"heap1", parquetInt64, parquetNoConvertedType},
+		{"heap2", parquetInt64, parquetNoConvertedType},
+		{"heap3", parquetInt64, parquetNoConvertedType},
+		{"stws_clock", parquetDouble, parquetNoConvertedType},
+		{"mas_clock", parquetDouble, parquetNoConvertedType},
+		{"stwm_clock", parquetDouble, parquetNoConvertedType},
+		{"stws_cpu", parquetDouble, parquetNoConvertedType},
+		{"mas_assist_cpu", parquetDouble, parquetNoConvertedType},
+		{"mas_bg_cpu", parquetDouble, parquetNoConvertedType},
+		{"mas_idle_cpu", parquetDouble, parquetNoConvertedType},
+		{"stwm_cpu", parquetDouble, parquetNoConvertedType},
+	}
+	parquetScvgColumns = []parquetColumn{
+		{"time", parquetInt64, parquetTimestampMillis},
+		{"elapsed", parquetDouble, parquetNoConvertedType},
+		{"inuse", parquetInt64, parquetNoConvertedType},
+		{"idle", parquetInt64, parquetNoConvertedType},
+		{"sys", parquetInt64, parquetNoConvertedType},
+		{"released", parquetInt64, parquetNoConvertedType},
+		{"consumed", parquetInt64, parquetNoConvertedType},
+	}
+)
+
+func init() {
+	RegisterSink("parquet", newParquetSink)
+}
+
+// parquetSink writes GC events, and optionally scavenger events, to Parquet
+// files, for analysis of long runs with DuckDB, Spark, etc. Columns are
+// named like in the SQLite database.
+type parquetSink struct {
+	gc   *parquetFile
+	scvg *parquetFile
+}
+
+func newParquetSink() (Sink, error) {
+	if *parquetOut == "" && *parquetScvgOut == "" {
+		return nil, nil
+	}
+
+	s := &parquetSink{}
+
+	var err error
+	if *parquetOut != "" {
+		if s.gc, err = createParquetFile(*parquetOut, parquetGCColumns, *parquetRowGroupSize); err != nil {
+			return nil, err
+		}
+	}
+	if *parquetScvgOut != "" {
+		if s.scvg, err = createParquetFile(*parquetScvgOut, parquetScvgColumns, *parquetRowGroupSize); err != nil {
+			s.gc.close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *parquetSink) ConsumeGC(t *gctrace) error {
+	if s.gc == nil {
+		return nil
+	}
+
+	return s.gc.writeRow(
+		traceTime(t.ElapsedTime),
+		t.ElapsedTime,
+		t.NumGC,
+		t.Heap0,
+		t.Heap1,
+		t.Heap2,
+		t.Heap3,
+		t.STWSclock,
+		t.MASclock,
+		t.STWMclock,
+		t.STWScpu,
+		t.MASAssistcpu,
+		t.MASBGcpu,
+		t.MASIdlecpu,
+		t.STWMcpu,
+	)
+}
+
+func (s *parquetSink) ConsumeScvg(t *scvgtrace) error {
+	if s.scvg == nil {
+		return nil
+	}
+
+	return s.scvg.writeRow(
+		traceTime(t.ElapsedTime),
+		t.ElapsedTime,
+		t.Inuse,
+		t.Idle,
+		t.Sys,
+		t.Released,
+		t.Consumed,
+	)
+}
+
+// Flush writes the buffered events as a row group. Row groups being the unit
+// of parallelism of Parquet readers, flushing often makes files slower to
+// read.
+func (s *parquetSink) Flush() error {
+	if err := s.gc.flush(); err != nil {
+		return err
+	}
+	return s.scvg.flush()
+}
+
+func (s *parquetSink) Close() error {
+	var errs sinkErrors
+	errs.Add(s.gc.close())
+	errs.Add(s.scvg.close())
+	return errs.Err()
+}
+
+// Parquet physical types.
+const (
+	parquetInt64  = 2
+	parquetDouble = 5
+)
+
+// Parquet converted types.
+const (
+	parquetNoConvertedType = -1
+	parquetTimestampMillis = 9
+)
+
+const (
+	parquetMagic         = "PAR1"
+	parquetEncodingRLE   = 3
+	parquetCodecSnappy   = 1
+	parquetDataPage      = 0
+	parquetRequired      = 0
+	parquetPlain         = 0
+	parquetCreatedBy     = "gcvis"
+	parquetFormatVersion = 1
+)
+
+// parquetColumn is a required, flat column of 8 byte values.
+type parquetColumn struct {
+	name          string
+	typ           int32
+	convertedType int32
+}
+
+// parquetFile writes rows to a Parquet file, in row groups of a single
+// snappy compressed, PLAIN encoded data page per column. The file footer is
+// only written by close.
+type parquetFile struct {
+	w       io.WriteCloser
+	offset  int64
+	columns []parquetColumn
+
+	values       [][]byte // PLAIN encoded values of the current row group, by column
+	rows         int64    // in the current row group
+	rowGroupSize int64
+
+	rowGroups [][]byte // encoded RowGroup structs
+	numRows   int64
+}
+
+// createParquetFile creates the file at path, truncating it if it exists:
+// Parquet files cannot be appended to.
+func createParquetFile(path string, columns []parquetColumn, rowGroupSize int) (*parquetFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parquetFile{
+		w:            f,
+		columns:      columns,
+		values:       make([][]byte, len(columns)),
+		rowGroupSize: int64(rowGroupSize),
+	}
+	if err := p.write([]byte(parquetMagic)); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *parquetFile) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
+
+// writeRow buffers a row, made of an int64, float64 or time.Time value per
+// column.
+func (p *parquetFile) writeRow(values ...interface{}) error {
+	if len(values) != len(p.columns) {
+		return fmt.Errorf("parquet: got %d values for %d columns", len(values), len(p.columns))
+	}
+
+	for i, v := range values {
+		var bits uint64
+		switch v := v.(type) {
+		case int64:
+			bits = uint64(v)
+		case float64:
+			bits = math.Float64bits(v)
+		case time.Time:
+			bits = uint64(timestampMillis(v))
+		default:
+			return fmt.Errorf("parquet: unsupported value %T for column %s", v, p.columns[i].name)
+		}
+		p.values[i] = appendFixed64(p.values[i], bits)
+	}
+
+	p.rows++
+	if p.rows >= p.rowGroupSize {
+		return p.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows as a row group.
+func (p *parquetFile) flush() error {
+	if p == nil || p.rows == 0 {
+		return nil
+	}
+
+	var chunks [][]byte
+	var size int64
+	for i, column := range p.columns {
+		data := snappy.Encode(nil, p.values[i])
+
+		var dataHeader thriftWriter
+		dataHeader.i32(1, int32(p.rows))
+		dataHeader.i32(2, parquetPlain)
+		dataHeader.i32(3, parquetEncodingRLE)
+		dataHeader.i32(4, parquetEncodingRLE)
+
+		var pageHeader thriftWriter
+		pageHeader.i32(1, parquetDataPage)
+		pageHeader.i32(2, int32(len(p.values[i])))
+		pageHeader.i32(3, int32(len(data)))
+		pageHeader.structField(5, &dataHeader)
+		header := pageHeader.end()
+
+		start := p.offset
+		if err := p.write(header); err != nil {
+			return err
+		}
+		if err := p.write(data); err != nil {
+			return err
+		}
+
+		var meta thriftWriter
+		meta.i32(1, column.typ)
+		meta.list(2, thriftI32, thriftI32List(parquetPlain, parquetEncodingRLE))
+		meta.list(3, thriftBinary, thriftBinaryList(column.name))
+		meta.i32(4, parquetCodecSnappy)
+		meta.i64(5, p.rows)
+		meta.i64(6, int64(len(header)+len(p.values[i])))
+		meta.i64(7, int64(len(header)+len(data)))
+		meta.i64(9, start)
+
+		var chunk thriftWriter
+		chunk.i64(2, start)
+		chunk.structField(3, &meta)
+		chunks = append(chunks, chunk.end())
+
+		size += int64(len(header) + len(p.values[i]))
+		p.values[i] = p.values[i][:0]
+	}
+
+	var rowGroup thriftWriter
+	rowGroup.list(1, thriftStruct, chunks)
+	rowGroup.i64(2, size)
+	rowGroup.i64(3, p.rows)
+	p.rowGroups = append(p.rowGroups, rowGroup.end())
+
+	p.numRows += p.rows
+	p.rows = 0
+	return nil
+}
+
+// close writes the remaining rows and the file footer.
+func (p *parquetFile) close() error {
+	if p == nil {
+		return nil
+	}
+
+	if err := p.flush(); err != nil {
+		p.w.Close()
+		return err
+	}
+
+	var root thriftWriter
+	root.binary(4, "schema")
+	root.i32(5, int32(len(p.columns)))
+	schema := [][]byte{root.end()}
+	for _, column := range p.columns {
+		var element thriftWriter
+		element.i32(1, column.typ)
+		element.i32(3, parquetRequired)
+		element.binary(4, column.name)
+		if column.convertedType != parquetNoConvertedType {
+			element.i32(6, column.convertedType)
+		}
+		schema = append(schema, element.end())
+	}
+
+	var meta thriftWriter
+	meta.i32(1, parquetFormatVersion)
+	meta.list(2, thriftStruct, schema)
+	meta.i64(3, p.numRows)
+	meta.list(4, thriftStruct, p.rowGroups)
+	meta.binary(6, parquetCreatedBy)
+	footer := meta.end()
+
+	footer = append(footer, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(footer[len(footer)-4:], uint32(len(footer)-4))
+	footer = append(footer, parquetMagic...)
+
+	if err := p.write(footer); err != nil {
+		p.w.Close()
+		return err
+	}
+	return p.w.Close()
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes a struct with the Thrift compact protocol, which
+// Parquet uses for its metadata. Fields must be added by increasing id, at
+// most 15 apart.
+type thriftWriter struct {
+	b    []byte
+	last int
+}
+
+func (w *thriftWriter) field(id, typ int) {
+	w.b = append(w.b, byte((id-w.last)<<4|typ))
+	w.last = id
+}
+
+func (w *thriftWriter) i32(id int, v int32) {
+	w.field(id, thriftI32)
+	w.b = appendZigzag(w.b, int64(v))
+}
+
+func (w *thriftWriter) i64(id int, v int64) {
+	w.field(id, thriftI64)
+	w.b = appendZigzag(w.b, v)
+}
+
+func (w *thriftWriter) binary(id int, v string) {
+	w.field(id, thriftBinary)
+	w.b = appendUvarint(w.b, uint64(len(v)))
+	w.b = append(w.b, v...)
+}
+
+func (w *thriftWriter) structField(id int, v *thriftWriter) {
+	w.field(id, thriftStruct)
+	w.b = append(w.b, v.end()...)
+}
+
+// list adds a list of already encoded elements of type elemType.
+func (w *thriftWriter) list(id, elemType int, elems [][]byte) {
+	w.field(id, thriftList)
+	if len(elems) < 15 {
+		w.b = append(w.b, byte(len(elems)<<4|elemType))
+	} else {
+		w.b = append(w.b, byte(0xf0|elemType))
+		w.b = appendUvarint(w.b, uint64(len(elems)))
+	}
+	for _, elem := range elems {
+		w.b = append(w.b, elem...)
+	}
+}
+
+// end returns the encoded struct.
+func (w *thriftWriter) end() []byte {
+	return append(w.b, 0)
+}
+
+func thriftI32List(values ...int32) [][]byte {
+	elems := make([][]byte, len(values))
+	for i, v := range values {
+		elems[i] = appendZigzag(nil, int64(v))
+	}
+	return elems
+}
+
+func thriftBinaryList(values ...string) [][]byte {
+	elems := make([][]byte, len(values))
+	for i, v := range values {
+		elems[i] = append(appendUvarint(nil, uint64(len(v))), v...)
+	}
+	return elems
+}
+
+func appendZigzag(b []byte, v int64) []byte {
+	return appendUvarint(b, uint64(v<<1)^uint64(v>>63))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/snappy"
+)
+
+// thriftReader decodes the Thrift compact structs written by thriftWriter,
+// keeping only what the tests look at.
+type thriftReader struct {
+	b []byte
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Uvarint(r.b)
+	r.b = r.b[n:]
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n, l := binary.Uvarint(r.b)
+		s := string(r.b[l : l+int(n)])
+		r.b = r.b[l+int(n):]
+		return s
+	case thriftList:
+		header := r.b[0]
+		r.b = r.b[1:]
+		n := int(header >> 4)
+		if n == 15 {
+			v, l := binary.Uvarint(r.b)
+			n, r.b = int(v), r.b[l:]
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		fields := map[int]interface{}{}
+		id := 0
+		for {
+			header := r.b[0]
+			r.b = r.b[1:]
+			if header == 0 {
+				return fields
+			}
+			id += int(header >> 4)
+			fields[id] = r.value(header & 0x0f)
+		}
+	}
+	panic("unexpected thrift type")
+}
+
+func TestParquetFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gc.parquet")
+
+	p, err := createParquetFile(path, []parquetColumn{
+		{"num_gc", parquetInt64, parquetNoConvertedType},
+		{"stw", parquetDouble, parquetNoConvertedType},
+	}, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := int64(1); i <= 3; i++ {
+		if err := p.writeRow(i, float64(i)/2); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := p.writeRow("1", 0.5); err == nil {
+		t.Errorf("Expected an error for a string value.")
+	}
+	if err := p.close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("PAR1")) || !bytes.HasSuffix(b, []byte("PAR1")) {
+		t.Fatalf("Expected the file to start and end with PAR1. Got %q instead.", b)
+	}
+
+	footerLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	footer := &thriftReader{b[len(b)-8-footerLen : len(b)-8]}
+	meta := footer.value(thriftStruct).(map[int]interface{})
+
+	if meta[3] != int64(3) {
+		t.Errorf("Expected 3 rows. Got %v instead.", meta[3])
+	}
+
+	schema := meta[2].([]interface{})
+	if len(schema) != 3 || schema[0].(map[int]interface{})[5] != int64(2) || schema[2].(map[int]interface{})[4] != "stw" {
+		t.Errorf("Expected a schema of 2 columns. Got %v instead.", schema)
+	}
+
+	rowGroups := meta[4].([]interface{})
+	if len(rowGroups) != 2 {
+		t.Fatalf("Expected 2 row groups. Got %d instead.", len(rowGroups))
+	}
+
+	// The second column of the first row group holds 0.5 and 1.
+	chunk := rowGroups[0].(map[int]interface{})[1].([]interface{})[1].(map[int]interface{})
+	chunkMeta := chunk[3].(map[int]interface{})
+	page := &thriftReader{b[chunkMeta[9].(int64):]}
+	header := page.value(thriftStruct).(map[int]interface{})
+	values, err := snappy.Decode(nil, page.b[:header[3].(int64)])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(values) != 16 ||
+		math.Float64frombits(binary.LittleEndian.Uint64(values)) != 0.5 ||
+		math.Float64frombits(binary.LittleEndian.Uint64(values[8:])) != 1 {
+		t.Errorf("Expected values 0.5 and 1. Got %v instead.", values)
+	}
+}
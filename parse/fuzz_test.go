@@ -0,0 +1,42 @@
+package parse
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// FuzzParseLine checks that the parser never panics, and keeps the line a
+// trace was parsed from, seeded with the corpus of testdata:
+//
+//	go test -fuzz=FuzzParseLine ./parse
+func FuzzParseLine(f *testing.F) {
+	logs, _ := filepath.Glob(filepath.Join("testdata", "*.log"))
+	for _, log := range logs {
+		b, err := ioutil.ReadFile(log)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			f.Add(line)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		var p Parser
+		gc, scvg := p.ParseLine(line)
+		if gc != nil && scvg != nil {
+			t.Fatalf("Expected a single trace. Got %+v and %+v.", gc, scvg)
+		}
+		if gc != nil && gc.Raw != line || scvg != nil && scvg.Raw != line {
+			t.Fatalf("Expected the trace to keep its line %q.", line)
+		}
+
+		again, _ := p.ParseLine(line)
+		if !reflect.DeepEqual(gc, again) {
+			t.Fatalf("Expected %q to be parsed the same twice. Got %+v and %+v.", line, gc, again)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata with the current parser output")
+
+// goldenLine is what the parser makes of a line of the corpus.
+type goldenLine struct {
+	Line string
+	GC   *GCTrace   `json:",omitempty"`
+	Scvg *ScvgTrace `json:",omitempty"`
+}
+
+// parseCorpus parses the lines of a log of the corpus.
+func parseCorpus(t *testing.T, path string) []goldenLine {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var p Parser
+	var lines []goldenLine
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		gc, scvg := p.ParseLine(sc.Text())
+		lines = append(lines, goldenLine{Line: sc.Text(), GC: gc, Scvg: scvg})
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+// TestGoldenCorpus parses the traces of every Go version in testdata, and
+// compares them to their golden output. Run go test -update to accept a
+// change of the parser.
+func TestGoldenCorpus(t *testing.T) {
+	logs, err := filepath.Glob(filepath.Join("testdata", "*.log"))
+	if err != nil || len(logs) == 0 {
+		t.Fatalf("Expected a corpus in testdata. Got %v, %v instead.", logs, err)
+	}
+
+	for _, log := range logs {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(parseCorpus(t, log)); err != nil {
+			t.Fatal(err)
+		}
+		got := buf.Bytes()
+
+		golden := strings.TrimSuffix(log, ".log") + ".golden"
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("Cannot read %s, run go test -update to create it: %v", golden, err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Errorf("Expected %s to be parsed as in %s. Got instead:\n%s", log, golden, got)
+		}
+	}
+}
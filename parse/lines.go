@@ -0,0 +1,37 @@
+package parse
+
+import "bytes"
+
+// ScanLines is a bufio.SplitFunc returning the lines of a trace log, like
+// bufio.ScanLines, whatever they end with: \n, \r\n as written on Windows, a
+// lone \r, or several \r before a \n, as left by tools converting the
+// endings of lines already ending with \r\n. Lines never end with \r.
+func ScanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	i := bytes.IndexAny(data, "\r\n")
+	if i < 0 {
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+	if data[i] == '\n' {
+		return i + 1, data[:i], nil
+	}
+
+	end := i
+	for end < len(data) && data[end] == '\r' {
+		end++
+	}
+	switch {
+	case end < len(data) && data[end] == '\n':
+		return end + 1, data[:i], nil
+	case end < len(data) || atEOF:
+		return end, data[:i], nil
+	}
+	// The \n of a \r\n may not be read yet.
+	return 0, nil, nil
+}
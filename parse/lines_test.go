@@ -0,0 +1,55 @@
+package parse
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestScanLines(t *testing.T) {
+	input := "unix\nwindows\r\nmac\rdouble\r\r\nlast"
+	expected := []string{"unix", "windows", "mac", "double", "last"}
+
+	// Read one byte at a time too, so that the \n of a \r\n comes after the
+	// \r.
+	for _, r := range []io.Reader{strings.NewReader(input), iotest.OneByteReader(strings.NewReader(input))} {
+		sc := bufio.NewScanner(r)
+		sc.Split(ScanLines)
+
+		var lines []string
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		if !reflect.DeepEqual(lines, expected) {
+			t.Errorf("Expected the lines %q. Got %q instead.", expected, lines)
+		}
+	}
+}
+
+func TestParserWithCRLF(t *testing.T) {
+	line := "gc 12 @4.105s 0%: 0.021+0.84+0.005 ms clock, 0.17+0.11/0.61/0.30+0.043 ms cpu, 3->3->0 MB, 4 MB goal, 8 P (forced)"
+
+	runParserWith("INFO: started\r\n" + line + "\r\n")
+
+	select {
+	case output := <-parser.NoMatchChan:
+		if output != "INFO: started" {
+			t.Errorf("Expected the output without its \\r. Got %q instead.", output)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Execution timed out.")
+	}
+
+	select {
+	case gctrace := <-parser.GcChan:
+		if !gctrace.Forced || gctrace.Raw != line {
+			t.Errorf("Expected forced GC cycle 12 without a \\r. Got %+v instead.", gctrace)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Execution timed out.")
+	}
+}
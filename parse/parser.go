@@ -0,0 +1,198 @@
+package parse
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	GCRegexpGo14 = `gc(?P<NumGC>\d+)\((?P<Nproc>\d+)\): ([\d.]+\+?)+ us, (?P<Heap0>\d+) -> (?P<Heap1>\d+) MB, \d+ \(\d+-\d+\) objects,( \d+ goroutines,)? \d+\/\d+\/\d+ sweeps, \d+\(\d+\) handoff, \d+\(\d+\) steal, \d+\/\d+\/\d+ yields`
+	GCRegexpGo15 = `gc #?(?P<NumGC>\d+) @(?P<ElapsedTime>[\d.]+)s \d+%: [\d.+/]+ ms clock, [\d.+/]+ ms cpu, (?P<Heap0>\d+)->(?P<Heap2>\d+)->(?P<Heap3>\d+) MB, (?P<Heap1>\d+) MB goal, (?P<Nproc>\d+) P`
+	GCRegexpGo16 = `gc #?(?P<NumGC>\d+) @(?P<ElapsedTime>[\d.]+)s \d+%: (?P<STWSclock>[^+]+)\+(?P<MASclock>[^+]+)\+(?P<STWMclock>[^+]+) ms clock, (?P<STWScpu>[^+]+)\+(?P<MASAssistcpu>[^+]+)/(?P<MASBGcpu>[^+]+)/(?P<MASIdlecpu>[^+]+)\+(?P<STWMcpu>[^+]+) ms cpu, (?P<Heap0>\d+)->(?P<Heap2>\d+)->(?P<Heap3>\d+) MB, (?P<Heap1>\d+) MB goal,( \d+ MB stacks,)?( \d+ MB globals,)? (?P<Nproc>\d+) P`
+
+	SCVGRegexp = `scvg\d+: inuse: (?P<inuse>\d+), idle: (?P<idle>\d+), sys: (?P<sys>\d+), released: (?P<released>\d+), consumed: (?P<consumed>\d+) \(MB\)`
+)
+
+var (
+	gcrego14 = regexp.MustCompile(GCRegexpGo14)
+	gcrego15 = regexp.MustCompile(GCRegexpGo15)
+	gcrego16 = regexp.MustCompile(GCRegexpGo16)
+	scvgre   = regexp.MustCompile(SCVGRegexp)
+)
+
+// Parser reads traces line by line, sending them on GcChan and ScvgChan,
+// and the other lines on NoMatchChan.
+type Parser struct {
+	reader      io.Reader
+	GcChan      chan *GCTrace
+	ScvgChan    chan *ScvgTrace
+	NoMatchChan chan string
+	done        chan struct{}
+
+	// Err is the error reading the lines, if any, once Done is closed.
+	Err error
+
+	// Tracef, if set, logs every line read, and Debugf the values of the
+	// traces that cannot be parsed.
+	Tracef, Debugf func(format string, args ...interface{})
+}
+
+func NewParser(r io.Reader) *Parser {
+	return &Parser{
+		reader:      r,
+		GcChan:      make(chan *GCTrace, 1),
+		ScvgChan:    make(chan *ScvgTrace, 1),
+		NoMatchChan: make(chan string, 1),
+		done:        make(chan struct{}),
+	}
+}
+
+// Done is closed once every line is read.
+func (p *Parser) Done() <-chan struct{} {
+	return p.done
+}
+
+// Run reads the lines until the end of the input. It is meant to run in its
+// own goroutine, while the channels are received from.
+func (p *Parser) Run() {
+	sc := bufio.NewScanner(p.reader)
+	sc.Split(ScanLines)
+
+	for sc.Scan() {
+		line := sc.Text()
+		gc, scvg := p.ParseLine(line)
+		switch {
+		case gc != nil:
+			p.GcChan <- gc
+		case scvg != nil:
+			p.ScvgChan <- scvg
+		default:
+			p.NoMatchChan <- line
+		}
+	}
+
+	p.Err = sc.Err()
+
+	close(p.done)
+}
+
+// ParseLine returns the GC or scavenger trace of line, or neither if line
+// is not a trace.
+func (p *Parser) ParseLine(line string) (*GCTrace, *ScvgTrace) {
+	if result := gcrego16.FindStringSubmatch(line); result != nil {
+		p.tracef("parsed a go1.6+ GC trace: %s", line)
+		t := p.parseGCTrace(gcrego16, result)
+		t.Raw = line
+		t.Forced = strings.HasSuffix(line, " (forced)")
+		return t, nil
+	}
+
+	if result := gcrego15.FindStringSubmatch(line); result != nil {
+		p.tracef("parsed a go1.5 GC trace: %s", line)
+		t := p.parseGCTrace(gcrego15, result)
+		t.Raw = line
+		t.Forced = strings.HasSuffix(line, " (forced)")
+		return t, nil
+	}
+
+	if result := gcrego14.FindStringSubmatch(line); result != nil {
+		p.tracef("parsed a go1.4 GC trace: %s", line)
+		t := p.parseGCTrace(gcrego14, result)
+		t.Raw = line
+		return t, nil
+	}
+
+	if result := scvgre.FindStringSubmatch(line); result != nil {
+		p.tracef("parsed a scavenger trace: %s", line)
+		t := p.parseSCVGTrace(result)
+		t.Raw = line
+		return nil, t
+	}
+
+	p.tracef("passing through a line that is not a trace: %s", line)
+	return nil, nil
+}
+
+func (p *Parser) parseGCTrace(gcre *regexp.Regexp, matches []string) *GCTrace {
+	matchMap := getMatchMap(gcre, matches)
+
+	return &GCTrace{
+		NumGC:        p.silentParseInt(matchMap["NumGC"]),
+		Nproc:        p.silentParseInt(matchMap["Nproc"]),
+		Heap0:        p.silentParseInt(matchMap["Heap0"]),
+		Heap1:        p.silentParseInt(matchMap["Heap1"]),
+		Heap2:        p.silentParseInt(matchMap["Heap2"]),
+		Heap3:        p.silentParseInt(matchMap["Heap3"]),
+		ElapsedTime:  p.silentParseFloat(matchMap["ElapsedTime"]),
+		STWSclock:    p.silentParseFloat(matchMap["STWSclock"]),
+		MASclock:     p.silentParseFloat(matchMap["MASclock"]),
+		STWMclock:    p.silentParseFloat(matchMap["STWMclock"]),
+		STWScpu:      p.silentParseFloat(matchMap["STWScpu"]),
+		MASAssistcpu: p.silentParseFloat(matchMap["MASAssistcpu"]),
+		MASBGcpu:     p.silentParseFloat(matchMap["MASBGcpu"]),
+		MASIdlecpu:   p.silentParseFloat(matchMap["MASIdlecpu"]),
+		STWMcpu:      p.silentParseFloat(matchMap["STWMcpu"]),
+	}
+}
+
+func (p *Parser) parseSCVGTrace(matches []string) *ScvgTrace {
+	matchMap := getMatchMap(scvgre, matches)
+
+	return &ScvgTrace{
+		Inuse:    p.silentParseInt(matchMap["inuse"]),
+		Idle:     p.silentParseInt(matchMap["idle"]),
+		Sys:      p.silentParseInt(matchMap["sys"]),
+		Released: p.silentParseInt(matchMap["released"]),
+		Consumed: p.silentParseInt(matchMap["consumed"]),
+	}
+}
+
+// Transform our matches in a readable hash map.
+//
+// The resulting hash map will be something like { "Heap1": 123 }
+func getMatchMap(re *regexp.Regexp, matches []string) map[string]string {
+	matchingNames := re.SubexpNames()[1:]
+	matchMap := map[string]string{}
+	for i, value := range matches[1:] {
+		if matchingNames[i] == "" {
+			continue
+		}
+		matchMap[matchingNames[i]] = value
+	}
+	return matchMap
+}
+
+func (p *Parser) silentParseInt(value string) int64 {
+	intVal, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		p.debugf("could not parse %q as integer: %v", value, err)
+		return 0
+	}
+
+	return intVal
+}
+
+func (p *Parser) silentParseFloat(value string) float64 {
+	floatVal, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		p.debugf("could not parse %q as float: %v", value, err)
+		return float64(0)
+	}
+
+	return float64(floatVal)
+}
+
+func (p *Parser) tracef(format string, args ...interface{}) {
+	if p.Tracef != nil {
+		p.Tracef(format, args...)
+	}
+}
+
+func (p *Parser) debugf(format string, args ...interface{}) {
+	if p.Debugf != nil {
+		p.Debugf(format, args...)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package parse
 
 import (
 	"bytes"
@@ -21,8 +21,14 @@ func TestParserWithMatchingInputGo16(t *testing.T) {
 
 	runParserWith(line)
 
-	expectedGCTrace := &gctrace{
+	expectedGCTrace := &GCTrace{
+		Raw:          line,
+		NumGC:        763,
+		Nproc:        8,
+		Heap0:        6370,
 		Heap1:        6533,
+		Heap2:        6390,
+		Heap3:        3298,
 		ElapsedTime:  77536.239,
 		STWSclock:    0.11,
 		MASclock:     2192,
@@ -44,13 +50,68 @@ func TestParserWithMatchingInputGo16(t *testing.T) {
 	}
 }
 
+func TestParserWithMatchingInputGo119(t *testing.T) {
+	line := "gc 12 @3.517s 2%: 0.026+1.4+0.010 ms clock, 0.21+0.32/2.3/0.40+0.081 ms cpu, 14->15->7 MB, 15 MB goal, 1 MB stacks, 2 MB globals, 8 P"
+
+	runParserWith(line)
+
+	expectedGCTrace := &GCTrace{
+		Raw:          line,
+		NumGC:        12,
+		Nproc:        8,
+		Heap0:        14,
+		Heap1:        15,
+		Heap2:        15,
+		Heap3:        7,
+		ElapsedTime:  3.517,
+		STWSclock:    0.026,
+		MASclock:     1.4,
+		STWMclock:    0.010,
+		STWScpu:      0.21,
+		MASAssistcpu: 0.32,
+		MASBGcpu:     2.3,
+		MASIdlecpu:   0.40,
+		STWMcpu:      0.081,
+	}
+
+	select {
+	case gctrace := <-parser.GcChan:
+		if !reflect.DeepEqual(gctrace, expectedGCTrace) {
+			t.Errorf("Expected gctrace to equal %+v. Got %+v instead.", expectedGCTrace, gctrace)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Execution timed out.")
+	}
+}
+
+func TestParserWithForcedGC(t *testing.T) {
+	line := "gc 12 @4.105s 0%: 0.021+0.84+0.005 ms clock, 0.17+0.11/0.61/0.30+0.043 ms cpu, 3->3->0 MB, 4 MB goal, 8 P (forced)"
+
+	runParserWith(line)
+
+	select {
+	case gctrace := <-parser.GcChan:
+		if !gctrace.Forced || gctrace.NumGC != 12 {
+			t.Errorf("Expected forced GC cycle 12. Got %+v instead.", gctrace)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Execution timed out.")
+	}
+}
+
 func TestParserWithMatchingInputGo15(t *testing.T) {
 	line := "gc 88 @3.243s 9%: 0.040+16+1.0+5.9+0.34 ms clock, 0.16+16+0+18/5.7/11+1.3 ms cpu, 32->33->19 MB, 33 MB goal, 4 P"
 
 	runParserWith(line)
 
-	expectedGCTrace := &gctrace{
+	expectedGCTrace := &GCTrace{
+		Raw:         line,
+		NumGC:       88,
+		Nproc:       4,
+		Heap0:       32,
 		Heap1:       33,
+		Heap2:       33,
+		Heap3:       19,
 		ElapsedTime: 3.243,
 	}
 
@@ -69,7 +130,11 @@ func TestParserWithMatchingInputGo14(t *testing.T) {
 
 	runParserWith(line)
 
-	expectedGCTrace := &gctrace{
+	expectedGCTrace := &GCTrace{
+		Raw:   line,
+		NumGC: 76,
+		Nproc: 1,
+		Heap0: 1,
 		Heap1: 3,
 	}
 
@@ -88,7 +153,11 @@ func TestParserGoRoutinesInputGo14(t *testing.T) {
 
 	runParserWith(line)
 
-	expectedGCTrace := &gctrace{
+	expectedGCTrace := &GCTrace{
+		Raw:   line,
+		NumGC: 76,
+		Nproc: 1,
+		Heap0: 1,
 		Heap1: 3,
 	}
 
@@ -107,12 +176,13 @@ func TestParserWithScvgLine(t *testing.T) {
 
 	runParserWith(line)
 
-	expectedScvgTrace := &scvgtrace{
-		inuse:    12,
-		idle:     13,
-		sys:      14,
-		released: 15,
-		consumed: 16,
+	expectedScvgTrace := &ScvgTrace{
+		Raw:      line,
+		Inuse:    12,
+		Idle:     13,
+		Sys:      14,
+		Released: 15,
+		Consumed: 16,
 	}
 
 	select {
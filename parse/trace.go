@@ -0,0 +1,68 @@
+// Package parse reads the traces printed by Go programs run with
+// GODEBUG=gctrace=1: one per garbage collection, and, up to go1.11, one per
+// scavenger run.
+package parse
+
+// ScvgTrace is a scavenger trace. Sizes are in megabytes.
+type ScvgTrace struct {
+	ElapsedTime float64 // in seconds
+	Inuse       int64
+	Idle        int64
+	Sys         int64
+	Released    int64
+	Consumed    int64
+	Raw         string `json:"-"` // line the trace was parsed from
+
+	// Computed is not parsed but left to the reader of the traces to set:
+	// fields computed from the others, by name.
+	Computed map[string]float64 `json:"-"`
+}
+
+// GCTrace is a garbage collection trace. Fields missing from the traces of
+// a Go version are zero.
+type GCTrace struct {
+	ElapsedTime  float64 // in seconds
+	NumGC        int64
+	Nproc        int64
+	t1           int64
+	t2           int64
+	t3           int64
+	t4           int64
+	Heap0        int64 // heap size before, in megabytes
+	Heap1        int64 // heap size after, in megabytes (heap goal since go 1.5)
+	Heap2        int64 // heap size at the end of the cycle, in megabytes
+	Heap3        int64 // live heap after marking, in megabytes
+	Obj          int64
+	NMalloc      int64
+	NFree        int64
+	NSpan        int64
+	NGoRoutines  int64
+	NBGSweep     int64
+	NPauseSweep  int64
+	NHandoff     int64
+	NHandoffCnt  int64
+	NSteal       int64
+	NStealCnt    int64
+	NProcYield   int64
+	NOsYield     int64
+	NSleep       int64
+	STWSclock    float64
+	MASclock     float64
+	STWMclock    float64
+	STWScpu      float64
+	MASAssistcpu float64
+	MASBGcpu     float64
+	MASIdlecpu   float64
+	STWMcpu      float64
+	Forced       bool   // the cycle was forced, e.g. by runtime.GC, since go 1.5
+	Raw          string `json:"-"` // line the trace was parsed from
+
+	// GCOverhead is not parsed but left to the reader of the traces to
+	// set, e.g. from the traces before: the percentage of the wall-clock
+	// time spent collecting garbage lately.
+	GCOverhead float64 `json:"-"`
+
+	// Computed is not parsed but left to the reader of the traces to set:
+	// fields computed from the others, by name.
+	Computed map[string]float64 `json:"-"`
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+)
+
+var sinkQueue = flag.Int("sink-queue", 1024, "number of events queued for each sink, delivered by a goroutine of its own. 0 delivers events synchronously, as they are parsed")
+var sinkOverflow = flag.String("sink-overflow", "drop", "what to do with events when the queue of a slow sink is full: drop them, spill them to a temporary file until the sink catches up, or block parsing")
+
+// Overflow policies of the sink queues.
+const (
+	overflowDrop  = "drop"
+	overflowSpill = "spill"
+	overflowBlock = "block"
+)
+
+// queuedSink delivers events to a sink from a goroutine of its own, so a
+// slow sink, e.g. a Loki endpoint timing out, never stalls parsing nor the
+// other sinks. When its queue is full, events are dropped, spilled to a
+// temporary file, or the caller blocks, depending on policy. Errors of the
+// sink are logged.
+type queuedSink struct {
+	name   string
+	sink   Sink
+	policy string
+
+	queue chan interface{} // *gctrace, *scvgtrace or flushRequest
+	done  chan struct{}
+
+	mu      sync.Mutex // guards dropped and spill
+	dropped int        // events dropped since the queue was last full
+	spill   *spillFile // created on the first spilled event
+}
+
+// flushRequest asks the goroutine of a queued sink to flush it once the
+// events queued before have been delivered.
+type flushRequest chan error
+
+func newQueuedSink(name string, sink Sink, length int, policy string) (*queuedSink, error) {
+	switch policy {
+	case overflowDrop, overflowSpill, overflowBlock:
+	default:
+		return nil, fmt.Errorf("invalid -sink-overflow %q, expected drop, spill or block", policy)
+	}
+
+	s := &queuedSink{
+		name:   name,
+		sink:   sink,
+		policy: policy,
+		queue:  make(chan interface{}, length),
+		done:   make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+func (s *queuedSink) ConsumeGC(t *gctrace) error {
+	return s.enqueue(t)
+}
+
+func (s *queuedSink) ConsumeScvg(t *scvgtrace) error {
+	return s.enqueue(t)
+}
+
+func (s *queuedSink) enqueue(event interface{}) error {
+	if s.policy == overflowBlock {
+		s.queue <- event
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Once spilling, events keep being spilled until the spill file is
+	// read back, so that they are delivered in order.
+	if s.spill != nil && s.spill.pending > 0 {
+		return s.spill.write(event)
+	}
+
+	select {
+	case s.queue <- event:
+		if s.dropped > 0 {
+			infof("%s sink caught up, %d events dropped", s.name, s.dropped)
+			s.dropped = 0
+		}
+		return nil
+	default:
+	}
+
+	if s.policy == overflowDrop {
+		if s.dropped == 0 {
+			errorf("%s sink queue is full, dropping events", s.name)
+		}
+		s.dropped++
+		return nil
+	}
+
+	if s.spill == nil {
+		spill, err := newSpillFile(s.name)
+		if err != nil {
+			return err
+		}
+		s.spill = spill
+		errorf("%s sink queue is full, spilling events to %s", s.name, spill.f.Name())
+	}
+	return s.spill.write(event)
+}
+
+// Flush waits for the events queued so far to be delivered, and flushes
+// the sink.
+func (s *queuedSink) Flush() error {
+	req := make(flushRequest, 1)
+	s.queue <- req
+	return <-req
+}
+
+// Close delivers the queued events, and closes the sink.
+func (s *queuedSink) Close() error {
+	close(s.queue)
+	<-s.done
+
+	if s.spill != nil {
+		s.spill.remove()
+	}
+	if s.dropped > 0 {
+		errorf("%s sink dropped %d events", s.name, s.dropped)
+	}
+	return s.sink.Close()
+}
+
+func (s *queuedSink) run() {
+	defer close(s.done)
+
+	for {
+		var event interface{}
+		var ok bool
+		select {
+		case event, ok = <-s.queue:
+		default:
+			// The queue is empty: deliver the spilled events, if any,
+			// before waiting for new ones.
+			if s.deliverSpilled() {
+				continue
+			}
+			event, ok = <-s.queue
+		}
+		if !ok {
+			s.deliverSpilled()
+			return
+		}
+
+		if req, isFlush := event.(flushRequest); isFlush {
+			s.deliverSpilled()
+			req <- s.sink.Flush()
+			continue
+		}
+		s.deliver(event)
+	}
+}
+
+func (s *queuedSink) deliver(event interface{}) {
+	var err error
+	switch event := event.(type) {
+	case *gctrace:
+		tracef("delivering GC cycle %d to the %s sink", event.NumGC, s.name)
+		err = s.sink.ConsumeGC(event)
+	case *scvgtrace:
+		tracef("delivering a scavenger event to the %s sink", s.name)
+		err = s.sink.ConsumeScvg(event)
+	}
+	if err != nil {
+		errorf("%s sink: %v", s.name, err)
+	}
+}
+
+// deliverSpilled delivers the spilled events, reporting whether there
+// were any.
+func (s *queuedSink) deliverSpilled() bool {
+	s.mu.Lock()
+	if s.spill == nil || s.spill.pending == 0 {
+		s.mu.Unlock()
+		return false
+	}
+	events, err := s.spill.readAll()
+	s.mu.Unlock()
+
+	if err != nil {
+		errorf("%s sink: cannot read spilled events: %v", s.name, err)
+	}
+	for _, event := range events {
+		s.deliver(event)
+	}
+	return true
+}
+
+// spillFile is a temporary file holding the events a sink could not keep
+// up with, as JSON lines.
+type spillFile struct {
+	f       *os.File
+	enc     *json.Encoder
+	pending int // events written and not read back yet
+}
+
+// spilledEvent is a line of a spill file. Unlike a recording, it keeps the
+// elapsed time of scavenger events, which sinks timestamp them with, and
+// what gcvis adds to the parsed events. The -compute fields that are not
+// finite, which JSON cannot hold, are left out, as if unknown.
+type spilledEvent struct {
+	GC   *gctrace    `json:"gc,omitempty"`
+	Scvg *scvgFields `json:"scvg,omitempty"`
+
+	ElapsedTime float64            `json:"elapsed"`
+	Raw         string             `json:"raw"`
+	GCOverhead  float64            `json:"gc_overhead,omitempty"`
+	Computed    map[string]float64 `json:"computed,omitempty"`
+}
+
+func newSpillFile(name string) (*spillFile, error) {
+	f, err := ioutil.TempFile("", "gcvis-spill-"+name+"-")
+	if err != nil {
+		return nil, err
+	}
+	return &spillFile{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (s *spillFile) write(event interface{}) error {
+	var e spilledEvent
+	switch event := event.(type) {
+	case *gctrace:
+		e = spilledEvent{
+			GC:          event,
+			ElapsedTime: event.ElapsedTime,
+			Raw:         event.Raw,
+			GCOverhead:  event.GCOverhead,
+			Computed:    finiteFields(event.Computed),
+		}
+	case *scvgtrace:
+		e = spilledEvent{
+			Scvg: &scvgFields{
+				Inuse:    event.Inuse,
+				Idle:     event.Idle,
+				Sys:      event.Sys,
+				Released: event.Released,
+				Consumed: event.Consumed,
+			},
+			ElapsedTime: event.ElapsedTime,
+			Raw:         event.Raw,
+			Computed:    finiteFields(event.Computed),
+		}
+	}
+
+	if err := s.enc.Encode(e); err != nil {
+		return fmt.Errorf("cannot spill event: %v", err)
+	}
+	s.pending++
+	return nil
+}
+
+// readAll reads the spilled events back, and empties the file.
+func (s *spillFile) readAll() ([]interface{}, error) {
+	defer func() {
+		s.pending = 0
+		s.f.Truncate(0)
+		s.f.Seek(0, io.SeekStart)
+	}()
+
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	events := make([]interface{}, 0, s.pending)
+	dec := json.NewDecoder(s.f)
+	for {
+		var e spilledEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			return events, nil
+		} else if err != nil {
+			return events, err
+		}
+
+		if e.GC != nil {
+			e.GC.Raw, e.GC.GCOverhead, e.GC.Computed = e.Raw, e.GCOverhead, e.Computed
+			events = append(events, e.GC)
+			continue
+		}
+		events = append(events, &scvgtrace{
+			ElapsedTime: e.ElapsedTime,
+			Inuse:       e.Scvg.Inuse,
+			Idle:        e.Scvg.Idle,
+			Sys:         e.Scvg.Sys,
+			Released:    e.Scvg.Released,
+			Consumed:    e.Scvg.Consumed,
+			Raw:         e.Raw,
+			Computed:    e.Computed,
+		})
+	}
+}
+
+// finiteFields returns the fields that are finite numbers, or nil if there
+// is none.
+func finiteFields(fields map[string]float64) map[string]float64 {
+	var finite map[string]float64
+	for name, v := range fields {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		if finite == nil {
+			finite = make(map[string]float64, len(fields))
+		}
+		finite[name] = v
+	}
+	return finite
+}
+
+func (s *spillFile) remove() {
+	s.f.Close()
+	os.Remove(s.f.Name())
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// blockingSink records the events it receives, and blocks until released.
+type blockingSink struct {
+	release chan struct{}
+
+	mu     sync.Mutex
+	events []int64 // NumGC of gc events, -inuse of scvg ones
+	closed bool
+}
+
+func (s *blockingSink) ConsumeGC(t *gctrace) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, t.NumGC)
+	return nil
+}
+
+func (s *blockingSink) ConsumeScvg(t *scvgtrace) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, -t.Inuse)
+	return nil
+}
+
+func (s *blockingSink) Flush() error {
+	return nil
+}
+
+func (s *blockingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestQueuedSinkDrop(t *testing.T) {
+	slow := &blockingSink{release: make(chan struct{})}
+	s, err := newQueuedSink("slow", slow, 2, overflowDrop)
+	if err != nil {
+		t.Fatalf("newQueuedSink returned an error: %v", err)
+	}
+
+	// The first event is taken by the goroutine, which blocks delivering
+	// it, the next two are queued, and the others dropped.
+	for i := int64(1); i <= 5; i++ {
+		if err := s.ConsumeGC(&gctrace{NumGC: i}); err != nil {
+			t.Fatalf("ConsumeGC returned an error: %v", err)
+		}
+		if i == 1 {
+			waitForQueue(s, 0)
+		}
+	}
+
+	close(slow.release)
+	s.Close()
+
+	if len(slow.events) != 3 || slow.events[2] != 3 {
+		t.Errorf("Expected the first 3 events to be delivered. Got %v instead.", slow.events)
+	}
+	if !slow.closed {
+		t.Errorf("Expected the sink to be closed.")
+	}
+}
+
+func TestQueuedSinkSpill(t *testing.T) {
+	slow := &blockingSink{release: make(chan struct{})}
+	s, err := newQueuedSink("slow", slow, 1, overflowSpill)
+	if err != nil {
+		t.Fatalf("newQueuedSink returned an error: %v", err)
+	}
+
+	s.ConsumeGC(&gctrace{NumGC: 1})
+	waitForQueue(s, 0)
+	s.ConsumeGC(&gctrace{NumGC: 2})
+	s.ConsumeScvg(&scvgtrace{Inuse: 3, ElapsedTime: 1.5})
+	s.ConsumeGC(&gctrace{NumGC: 4})
+
+	if s.spill == nil || s.spill.pending != 2 {
+		t.Fatalf("Expected 2 events to be spilled.")
+	}
+
+	close(slow.release)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush returned an error: %v", err)
+	}
+
+	expected := []int64{1, 2, -3, 4}
+	if len(slow.events) != len(expected) {
+		t.Fatalf("Expected events %v. Got %v instead.", expected, slow.events)
+	}
+	for i := range expected {
+		if slow.events[i] != expected[i] {
+			t.Errorf("Expected events %v in order. Got %v instead.", expected, slow.events)
+			break
+		}
+	}
+
+	s.Close()
+}
+
+func TestSpillFile(t *testing.T) {
+	f, err := newSpillFile("test")
+	if err != nil {
+		t.Fatalf("newSpillFile returned an error: %v", err)
+	}
+	defer f.remove()
+
+	gc := &gctrace{NumGC: 1, ElapsedTime: 1, GCOverhead: 12.5, Computed: map[string]float64{"headroom": 40, "ratio": math.NaN()}, Raw: "gc 1"}
+	scvg := &scvgtrace{Inuse: 3, ElapsedTime: 1.5, Computed: map[string]float64{"idle": 2}, Raw: "scvg0"}
+	for _, event := range []interface{}{gc, scvg} {
+		if err := f.write(event); err != nil {
+			t.Fatalf("write returned an error: %v", err)
+		}
+	}
+
+	events, err := f.readAll()
+	if err != nil {
+		t.Fatalf("readAll returned an error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events. Got %d instead.", len(events))
+	}
+
+	got := events[0].(*gctrace)
+	if got.NumGC != 1 || got.GCOverhead != 12.5 || got.Raw != "gc 1" {
+		t.Errorf("Expected %+v. Got %+v instead.", gc, got)
+	}
+	if expected := map[string]float64{"headroom": 40}; !reflect.DeepEqual(got.Computed, expected) {
+		t.Errorf("Expected the finite computed fields %v. Got %v instead.", expected, got.Computed)
+	}
+	if got := events[1].(*scvgtrace); got.Inuse != 3 || got.ElapsedTime != 1.5 || !reflect.DeepEqual(got.Computed, scvg.Computed) {
+		t.Errorf("Expected %+v. Got %+v instead.", scvg, got)
+	}
+}
+
+func TestQueuedSinkInvalidPolicy(t *testing.T) {
+	if _, err := newQueuedSink("slow", &fakeSink{}, 1, "discard"); err == nil {
+		t.Errorf("Expected an invalid policy to be rejected.")
+	}
+}
+
+// waitForQueue waits for the queue of s to hold n events.
+func waitForQueue(s *queuedSink, n int) {
+	for len(s.queue) != n {
+		runtime.Gosched()
+	}
+}
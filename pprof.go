@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var pprofAddr = flag.String("pprof-addr", "", "host:port of the net/http/pprof handlers of the program, to capture a heap profile from when an -alert on the heap or the pauses fires. Profiles are saved next to the -record recording, and linked from an annotation of the graph")
+var traceSeconds = flag.Int("trace-seconds", 0, "seconds of runtime execution trace to capture from -pprof-addr when an -alert fires, for go tool trace. 0 turns it off")
+
+const (
+	// pprofMinInterval is the time between two captures of a kind, at
+	// least, so that alerts firing together do not capture as many.
+	pprofMinInterval = time.Minute
+
+	// pprofTimeout is how long the program has to write a profile, on
+	// top of the seconds of an execution trace.
+	pprofTimeout = 30 * time.Second
+)
+
+// profiledMetrics are the metrics of the alerts a heap profile is captured
+// for.
+var profiledMetrics = map[string]bool{
+	"stw":       true,
+	"stw_sweep": true,
+	"stw_mark":  true,
+	"mark":      true,
+	"heap":      true,
+	"heap_live": true,
+	"heap_goal": true,
+}
+
+func init() {
+	RegisterNotifier("pprof", newPprofNotifier)
+}
+
+// profiler is the notifier capturing profiles, serving them at /profiles/.
+var profiler = &profileCapturer{files: map[string]string{}, last: map[string]time.Time{}}
+
+// profileKind is what can be captured from the pprof handlers.
+type profileKind struct {
+	name     string // prefixing the files
+	endpoint string
+	ext      string
+	what     string // told by the annotations
+}
+
+var (
+	heapProfile    = profileKind{"heap", "/debug/pprof/heap", ".pb.gz", "heap profile"}
+	executionTrace = profileKind{"trace", "/debug/pprof/trace", ".out", "execution trace"}
+)
+
+// profileCapturer captures the profiles and execution traces of the
+// program from its pprof handlers when alerts fire, annotating the graph
+// with links to them.
+type profileCapturer struct {
+	addr         string // host:port
+	dir          string // the profiles are saved to
+	traceSeconds int    // 0 not to capture execution traces
+	client       *http.Client
+
+	mu    sync.Mutex
+	graph *Graph
+	files map[string]string    // paths of the profiles captured, by name
+	last  map[string]time.Time // of the latest capture of each kind
+
+	wg sync.WaitGroup // of the captures in progress
+}
+
+func newPprofNotifier() (Notifier, error) {
+	if *pprofAddr == "" {
+		if *traceSeconds > 0 {
+			return nil, fmt.Errorf("-trace-seconds requires -pprof-addr")
+		}
+		return nil, nil
+	}
+
+	profiler.addr = *pprofAddr
+	profiler.dir = profileDir(*recordOut)
+	profiler.traceSeconds = *traceSeconds
+	profiler.client = &http.Client{Timeout: pprofTimeout + time.Duration(*traceSeconds)*time.Second}
+	return profiler, nil
+}
+
+// profileDir returns the directory of the recording, or the working
+// directory if events are not recorded to a file.
+func profileDir(record string) string {
+	switch record {
+	case "", "off", "stdout", "stderr":
+		return "."
+	}
+	return filepath.Dir(record)
+}
+
+// SetGraph annotates graph with the profiles captured.
+func (p *profileCapturer) SetGraph(g *Graph) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.graph = g
+}
+
+// Notify captures, in the background, a heap profile when an alert on the
+// heap or the pauses fires, and an execution trace when any alert fires if
+// turned on.
+func (p *profileCapturer) Notify(a *Alert) error {
+	if !a.Firing {
+		return nil
+	}
+	if profiledMetrics[a.Metric] && p.due(heapProfile) {
+		p.start(heapProfile, heapProfile.endpoint, a)
+	}
+	if p.traceSeconds > 0 && p.due(executionTrace) {
+		p.start(executionTrace, fmt.Sprintf("%s?seconds=%d", executionTrace.endpoint, p.traceSeconds), a)
+	}
+	return nil
+}
+
+// due tells whether pprofMinInterval has passed since the latest capture
+// of kind, starting a new one if so.
+func (p *profileCapturer) due(kind profileKind) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if now := time.Now(); now.Sub(p.last[kind.name]) >= pprofMinInterval {
+		p.last[kind.name] = now
+		return true
+	}
+	debugf("not capturing a %s, the latest one is less than %s old", kind.what, pprofMinInterval)
+	return false
+}
+
+func (p *profileCapturer) start(kind profileKind, url string, a *Alert) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if err := p.capture(kind, url, a); err != nil {
+			errorf("cannot capture a %s: %v", kind.what, err)
+		}
+	}()
+}
+
+// Close waits for the captures in progress, for as long as one may take,
+// so that they are not cut off when gcvis exits.
+func (p *profileCapturer) Close() error {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	timeout := pprofTimeout + time.Duration(p.traceSeconds)*time.Second
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("gave up waiting for the profiles being captured after %v", timeout)
+	}
+}
+
+// capture saves the kind of profile served at url, a path of the pprof
+// handlers of the program, annotating the graph with the alert it was
+// captured for.
+func (p *profileCapturer) capture(kind profileKind, url string, a *Alert) error {
+	resp, err := p.client.Get("http://" + p.addr + url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", kind.endpoint, resp.Status)
+	}
+
+	name := kind.name + "-" + time.Now().UTC().Format("20060102T150405.000Z") + kind.ext
+	file := filepath.Join(p.dir, name)
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	infof("captured a %s to %s for alert %s", kind.what, file, a.Rule)
+
+	p.mu.Lock()
+	p.files[name] = file
+	g := p.graph
+	p.mu.Unlock()
+
+	if g != nil {
+		g.AnnotateLink(kind.what+", "+a.Rule, "/profiles/"+name)
+	}
+	return nil
+}
+
+// ServeHTTP serves the profiles captured, at /profiles/name.
+func (p *profileCapturer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name := path.Base(req.URL.Path)
+
+	p.mu.Lock()
+	file, ok := p.files[name]
+	p.mu.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeFile(w, req, file)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestProfiler(t *testing.T) (*profileCapturer, *Graph, func()) {
+	program := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/debug/pprof/heap":
+			w.Write([]byte("heap profile"))
+		case "/debug/pprof/trace":
+			w.Write([]byte("trace of " + req.URL.Query().Get("seconds") + "s"))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGraph("profiled", GCVIS_TMPL)
+	p := &profileCapturer{
+		addr:   strings.TrimPrefix(program.URL, "http://"),
+		dir:    dir,
+		client: program.Client(),
+		files:  map[string]string{},
+		last:   map[string]time.Time{},
+	}
+	p.SetGraph(g)
+	return p, g, func() {
+		program.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestProfileCapturer(t *testing.T) {
+	p, g, cleanup := newTestProfiler(t)
+	defer cleanup()
+
+	p.Notify(&Alert{Rule: "scvg_sys>1GiB", Metric: "scvg_sys", Firing: true})
+	p.Notify(&Alert{Rule: "heap>2GiB", Metric: "heap", Firing: false})
+	p.wg.Wait()
+	if len(p.files) != 0 {
+		t.Fatalf("Expected no profile for a scavenger or recovering alert. Got %v instead.", p.files)
+	}
+
+	p.Notify(&Alert{Rule: "heap>2GiB", Metric: "heap", Firing: true})
+	p.Notify(&Alert{Rule: "stw>10ms", Metric: "stw", Firing: true})
+	p.wg.Wait()
+	if len(p.files) != 1 {
+		t.Fatalf("Expected a single profile within a minute. Got %v instead.", p.files)
+	}
+
+	var name, file string
+	for name, file = range p.files {
+	}
+	if b, err := ioutil.ReadFile(file); err != nil || string(b) != "heap profile" || filepath.Dir(file) != p.dir {
+		t.Errorf("Expected the profile saved in %s. Got %s, %q, %v instead.", p.dir, file, b, err)
+	}
+
+	annotations := g.Snapshot().Annotations
+	if len(annotations) != 1 || annotations[0].Text != "heap profile, heap>2GiB" || annotations[0].URL != "/profiles/"+name {
+		t.Errorf("Expected an annotation linking to the profile. Got %+v instead.", annotations)
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/profiles/"+name, nil))
+	if w.Code != http.StatusOK || w.Body.String() != "heap profile" {
+		t.Errorf("Expected the profile to be served. Got %d, %q instead.", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/profiles/..%2fsecret", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a file not captured. Got %d instead.", w.Code)
+	}
+}
+
+func TestProfileCapturerTrace(t *testing.T) {
+	p, g, cleanup := newTestProfiler(t)
+	defer cleanup()
+	p.traceSeconds = 5
+
+	p.Notify(&Alert{Rule: "scvg_sys>1GiB", Metric: "scvg_sys", Firing: true})
+	p.wg.Wait()
+	if len(p.files) != 1 {
+		t.Fatalf("Expected an execution trace only. Got %v instead.", p.files)
+	}
+	for name, file := range p.files {
+		b, err := ioutil.ReadFile(file)
+		if !strings.HasPrefix(name, "trace-") || !strings.HasSuffix(name, ".out") || string(b) != "trace of 5s" || err != nil {
+			t.Errorf("Expected a trace of 5s. Got %s, %q, %v instead.", name, b, err)
+		}
+	}
+	if a := g.Snapshot().Annotations; len(a) != 1 || a[0].Text != "execution trace, scvg_sys>1GiB" {
+		t.Errorf("Expected an annotation linking to the trace. Got %+v instead.", a)
+	}
+
+	p.Notify(&Alert{Rule: "heap>2GiB", Metric: "heap", Firing: true})
+	p.wg.Wait()
+	if len(p.files) != 2 {
+		t.Errorf("Expected a heap profile, but no other trace within a minute. Got %v instead.", p.files)
+	}
+}
+
+func TestAlertSinkCloseWaitsForProfiles(t *testing.T) {
+	p, _, cleanup := newTestProfiler(t)
+	defer cleanup()
+
+	s := &alertSink{notifiers: []Notifier{p}}
+	p.Notify(&Alert{Rule: "heap>2GiB", Metric: "heap", Firing: true})
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.files) != 1 {
+		t.Errorf("Expected the profile to be captured before Close returns. Got %v instead.", p.files)
+	}
+}
+
+func TestProfileDir(t *testing.T) {
+	for record, expected := range map[string]string{
+		"":                         ".",
+		"stdout":                   ".",
+		"/var/log/gcvis/run.jsonl": "/var/log/gcvis",
+	} {
+		if dir := profileDir(record); dir != expected {
+			t.Errorf("Expected the profiles of -record %q in %s. Got %s instead.", record, expected, dir)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var metricsEndpoint = flag.Bool("metrics", false, "serve Prometheus metrics at /metrics, with GC pause histograms carrying exemplars of the GC cycles in the OpenMetrics format")
+
+// promPauseMetric is the name of the GC pause histogram. Unlike the gauges,
+// which keep the units of the traces, it follows the Prometheus convention
+// of seconds.
+const promPauseMetric = "gcvis_gc_pause_seconds"
+
+// The SLO metrics: how many times each -slo was violated, and whether it is
+// met, as of the latest event.
+const (
+	promSLOViolationsMetric = "gcvis_slo_violations"
+	promSLOCompliantMetric  = "gcvis_slo_compliant"
+)
+
+// scrapeMetrics is the metrics served at /metrics, when -metrics is set.
+var scrapeMetrics *promMetrics
+
+func init() {
+	RegisterSink("prometheus", newPromMetricsSink)
+}
+
+// promMetrics keeps the latest value of every metric, and a cumulative
+// histogram of the pauses of each stop the world phase, to be scraped.
+// Each histogram bucket has an exemplar, the latest pause it counted,
+// labelled with its GC cycle and timestamped with its trace, so Grafana can
+// link a spike to the trace line.
+type promMetrics struct {
+	labels string // {k="v",...} without braces, sorted
+
+	mu     sync.Mutex
+	names  []string // of gauges, in the order they were first seen
+	gauges map[string]float64
+	pauses map[string]*promHistogram // by phase
+	slos   sloTrackers
+}
+
+type promHistogram struct {
+	counts    []uint64 // by bucket, the last one being +Inf
+	exemplars []*promExemplar
+	count     uint64
+	sum       float64
+}
+
+type promExemplar struct {
+	cycle int64
+	value float64
+	ts    time.Time
+}
+
+func newPromMetricsSink() (Sink, error) {
+	if !*metricsEndpoint {
+		return nil, nil
+	}
+
+	scrapeMetrics = newPromMetrics(metricLabels())
+	return scrapeMetrics, nil
+}
+
+func newPromMetrics(labels map[string]string) *promMetrics {
+	pairs := make([]string, 0, len(labels))
+	for _, name := range sortedLabelNames(labels) {
+		pairs = append(pairs, name+`="`+promEscape(labels[name])+`"`)
+	}
+
+	return &promMetrics{
+		labels: strings.Join(pairs, ","),
+		gauges: map[string]float64{},
+		pauses: map[string]*promHistogram{},
+		slos:   newSLOTrackers(sloObjectives),
+	}
+}
+
+func (m *promMetrics) ConsumeGC(t *gctrace) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(gcMetrics(t))
+	m.slos.observe(t, traceTime(t.ElapsedTime).Sub(traceStartTime()).Seconds())
+
+	if enabledSeries["stw"] {
+		ts := traceTime(t.ElapsedTime)
+		m.observe("sweep_termination", &promExemplar{cycle: t.NumGC, value: t.STWSclock / 1000, ts: ts})
+		m.observe("mark_termination", &promExemplar{cycle: t.NumGC, value: t.STWMclock / 1000, ts: ts})
+	}
+
+	return nil
+}
+
+func (m *promMetrics) ConsumeScvg(t *scvgtrace) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(scvgMetrics(t))
+	return nil
+}
+
+func (m *promMetrics) set(metrics []metric) {
+	for _, metric := range metrics {
+		if _, ok := m.gauges[metric.Name]; !ok {
+			m.names = append(m.names, metric.Name)
+		}
+		m.gauges[metric.Name] = metric.Value
+	}
+}
+
+func (m *promMetrics) observe(phase string, e *promExemplar) {
+	h := m.pauses[phase]
+	if h == nil {
+		h = &promHistogram{
+			counts:    make([]uint64, len(otlpPauseBounds)+1),
+			exemplars: make([]*promExemplar, len(otlpPauseBounds)+1),
+		}
+		m.pauses[phase] = h
+	}
+
+	i := 0
+	for i < len(otlpPauseBounds) && e.value > otlpPauseBounds[i]/1000 {
+		i++
+	}
+	h.counts[i]++
+	h.exemplars[i] = e
+	h.count++
+	h.sum += e.value
+}
+
+func (m *promMetrics) Flush() error {
+	return nil
+}
+
+func (m *promMetrics) Close() error {
+	return nil
+}
+
+// ServeHTTP writes the metrics in the OpenMetrics format when the scraper
+// accepts it, and in the Prometheus text format, which has no exemplars,
+// otherwise.
+func (m *promMetrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	openMetrics := strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text")
+
+	var b bytes.Buffer
+	m.write(&b, openMetrics)
+
+	if openMetrics {
+		w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	}
+	w.Write(b.Bytes())
+}
+
+func (m *promMetrics) write(b *bytes.Buffer, openMetrics bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, name := range m.names {
+		fmt.Fprintf(b, "# TYPE %s gauge\n", name)
+		fmt.Fprintf(b, "%s{%s} %s\n", name, m.labels, promFloat(m.gauges[name]))
+	}
+
+	if len(m.pauses) > 0 {
+		fmt.Fprintf(b, "# TYPE %s histogram\n", promPauseMetric)
+	}
+	for _, phase := range []string{"sweep_termination", "mark_termination"} {
+		h := m.pauses[phase]
+		if h == nil {
+			continue
+		}
+
+		labels := m.labels + `,phase="` + phase + `"`
+		var cumulative uint64
+		for i, count := range h.counts {
+			cumulative += count
+
+			le := "+Inf"
+			if i < len(otlpPauseBounds) {
+				le = promFloat(otlpPauseBounds[i] / 1000)
+			}
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d", promPauseMetric, labels, le, cumulative)
+			if e := h.exemplars[i]; openMetrics && e != nil {
+				fmt.Fprintf(b, " # {gc_cycle=\"%d\"} %s %s", e.cycle, promFloat(e.value), promFloat(float64(e.ts.UnixNano())/float64(time.Second)))
+			}
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(b, "%s_count{%s} %d\n", promPauseMetric, labels, h.count)
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", promPauseMetric, labels, promFloat(h.sum))
+	}
+
+	m.writeSLOs(b, openMetrics)
+
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+}
+
+// writeSLOs writes the number of times every -slo was violated, and
+// whether it is met.
+func (m *promMetrics) writeSLOs(b *bytes.Buffer, openMetrics bool) {
+	if len(m.slos) == 0 {
+		return
+	}
+
+	// OpenMetrics names counters without their _total suffix.
+	violations := promSLOViolationsMetric + "_total"
+	if openMetrics {
+		violations = promSLOViolationsMetric
+	}
+	fmt.Fprintf(b, "# TYPE %s counter\n", violations)
+	for _, t := range m.slos {
+		fmt.Fprintf(b, "%s_total{%s,slo=\"%s\"} %d\n", promSLOViolationsMetric, m.labels, promEscape(t.objective.Expr), len(t.violations))
+	}
+	fmt.Fprintf(b, "# TYPE %s gauge\n", promSLOCompliantMetric)
+	for _, t := range m.slos {
+		compliant := 1
+		if t.violating() {
+			compliant = 0
+		}
+		fmt.Fprintf(b, "%s{%s,slo=\"%s\"} %d\n", promSLOCompliantMetric, m.labels, promEscape(t.objective.Expr), compliant)
+	}
+}
+
+func promFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// promEscape escapes a label value for the text formats.
+func promEscape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPromMetrics(t *testing.T) {
+	saved, savedName := *metricsEndpoint, *serviceName
+	*metricsEndpoint, *serviceName = true, `my"service`
+	defer func() { *metricsEndpoint, *serviceName, scrapeMetrics = saved, savedName, nil }()
+
+	sink, err := newPromMetricsSink()
+	if err != nil {
+		t.Fatalf("newPromMetricsSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{NumGC: 41, Heap1: 33, STWSclock: 0.02, STWMclock: 3})
+	sink.ConsumeGC(&gctrace{NumGC: 42, Heap1: 34, STWSclock: 0.02, STWMclock: 0.3})
+	sink.ConsumeScvg(&scvgtrace{Inuse: 12})
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+	w := httptest.NewRecorder()
+	scrapeMetrics.ServeHTTP(w, req)
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("Expected the OpenMetrics format. Got %q instead.", ct)
+	}
+
+	body := w.Body.String()
+	labels := `host="` + ownHost + `",srv="my\"service"`
+	for _, expected := range []string{
+		"gcvis_heap_goal_megabytes{" + labels + "} 34\n",
+		"gcvis_scvg_inuse_megabytes{" + labels + "} 12\n",
+		"# TYPE gcvis_gc_pause_seconds histogram\n",
+		`gcvis_gc_pause_seconds_bucket{` + labels + `,phase="mark_termination",le="0.0005"} 1 # {gc_cycle="42"} 0.0003 `,
+		`gcvis_gc_pause_seconds_bucket{` + labels + `,phase="mark_termination",le="0.005"} 2 # {gc_cycle="41"} 0.003 `,
+		`gcvis_gc_pause_seconds_bucket{` + labels + `,phase="mark_termination",le="+Inf"} 2` + "\n",
+		`gcvis_gc_pause_seconds_count{` + labels + `,phase="sweep_termination"} 2` + "\n",
+		"# EOF\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metrics to contain %q. Got:\n%s", expected, body)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	scrapeMetrics.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(w.Body.String(), " # {") || strings.Contains(w.Body.String(), "# EOF") {
+		t.Errorf("Expected no exemplars in the Prometheus text format. Got:\n%s", w.Body.String())
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200. Got %d instead.", w.Code)
+	}
+}
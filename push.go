@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	pushMaxRetries  = 5
+	pushMaxBackoff  = 30 * time.Second
+	pushQueueLength = 1024
+	pushTimeout     = 10 * time.Second
+)
+
+// pushMinBackoff is the delay before the first retry of a failed request.
+var pushMinBackoff = 500 * time.Millisecond
+
+// batcher groups entries added from one goroutine and hands them to send
+// from another one, in batches of at most size entries, or every wait,
+// whichever comes first. send is only ever called from that goroutine, one
+// batch at a time, until Close returns, so that what it alone uses, such as
+// the connection of a sink, needs no locking.
+type batcher struct {
+	size int
+	wait time.Duration
+	send func(batch []interface{})
+
+	entries chan interface{}
+	flushes chan chan struct{}
+	done    chan struct{}
+
+	closeOnce sync.Once
+}
+
+func newBatcher(size int, wait time.Duration, send func(batch []interface{})) *batcher {
+	b := &batcher{
+		size:    size,
+		wait:    wait,
+		send:    send,
+		entries: make(chan interface{}, pushQueueLength),
+		flushes: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go b.run()
+
+	return b
+}
+
+// Add queues entry for the next batch.
+func (b *batcher) Add(entry interface{}) {
+	b.entries <- entry
+}
+
+// Flush sends the entries added so far and waits for them to be sent.
+func (b *batcher) Flush() {
+	ack := make(chan struct{})
+	b.flushes <- ack
+	<-ack
+}
+
+// Close sends any pending entries and waits for them to be sent.
+func (b *batcher) Close() {
+	b.closeOnce.Do(func() {
+		close(b.entries)
+		<-b.done
+	})
+}
+
+func (b *batcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.wait)
+	defer ticker.Stop()
+
+	batch := make([]interface{}, 0, b.size)
+	for {
+		select {
+		case entry, ok := <-b.entries:
+			if !ok {
+				b.sendBatch(batch)
+				return
+			}
+
+			batch = b.add(batch, entry)
+		case <-ticker.C:
+			batch = b.sendBatch(batch)
+		case ack := <-b.flushes:
+			batch = b.sendBatch(b.drain(batch))
+			close(ack)
+		}
+	}
+}
+
+func (b *batcher) add(batch []interface{}, entry interface{}) []interface{} {
+	batch = append(batch, entry)
+	if len(batch) >= b.size {
+		return b.sendBatch(batch)
+	}
+	return batch
+}
+
+// drain appends to batch the entries already queued, sending full batches
+// on the way.
+func (b *batcher) drain(batch []interface{}) []interface{} {
+	for {
+		select {
+		case entry, ok := <-b.entries:
+			if !ok {
+				return batch
+			}
+
+			batch = b.add(batch, entry)
+		default:
+			return batch
+		}
+	}
+}
+
+func (b *batcher) sendBatch(batch []interface{}) []interface{} {
+	if len(batch) > 0 {
+		b.send(batch)
+	}
+	return batch[:0]
+}
+
+// retryWithBackoff sends with sendWithBackoff, logging a final failure.
+func retryWithBackoff(what string, post func() (retry bool, err error)) {
+	if err := sendWithBackoff(what, post); err != nil {
+		errorf("%v", err)
+	}
+}
+
+// sendWithBackoff calls post until it succeeds, it reports that the error
+// is not worth retrying, or pushMaxRetries attempts failed, returning the
+// error of the last one. The delay between attempts doubles every time, up
+// to pushMaxBackoff. what describes the data sent in the log messages and
+// the error.
+func sendWithBackoff(what string, post func() (retry bool, err error)) error {
+	backoff := pushMinBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := post()
+		if err == nil {
+			debugf("sent %s", what)
+			return nil
+		}
+
+		if !retry || attempt == pushMaxRetries {
+			return fmt.Errorf("dropping %s: %v", what, err)
+		}
+		debugf("cannot send %s, retrying in %v: %v", what, backoff, err)
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > pushMaxBackoff {
+			backoff = pushMaxBackoff
+		}
+	}
+}
+
+// postBody sends body to url with the given headers. It reports whether a
+// failed request is worth retrying: network errors, rate limiting and
+// server errors are.
+func postBody(client *http.Client, url string, header http.Header, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5, err
+}
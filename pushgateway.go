@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var pushgatewayURL = flag.String("pushgateway", "", "URL of a Prometheus Pushgateway to push the final metrics to when the program exits, e.g. http://pushgateway:9091")
+var pushgatewayJob = flag.String("pushgateway-job", "", "job label of the metrics pushed to the Pushgateway. Defaults to the service name")
+
+func init() {
+	RegisterSink("pushgateway", newPushgatewaySink)
+}
+
+// pushgatewaySink keeps the same metrics as /metrics, and pushes them to a
+// Pushgateway when closed, for batch jobs which exit before they could be
+// scraped. They are grouped by job and instance, the host.
+type pushgatewaySink struct {
+	*promMetrics
+
+	url    string
+	client *http.Client
+}
+
+func newPushgatewaySink() (Sink, error) {
+	if *pushgatewayURL == "" {
+		return nil, nil
+	}
+
+	job := *pushgatewayJob
+	if job == "" {
+		job = *serviceName
+	}
+
+	// The grouping labels are added by the Pushgateway.
+	labels := metricLabels()
+	delete(labels, "host")
+
+	return &pushgatewaySink{
+		promMetrics: newPromMetrics(labels),
+		url:         strings.TrimRight(*pushgatewayURL, "/") + "/metrics" + pushgatewayGroupPath("job", job) + pushgatewayGroupPath("instance", ownHost),
+		client:      &http.Client{Timeout: pushTimeout},
+	}, nil
+}
+
+// pushgatewayGroupPath returns the URL path of a grouping label, encoding
+// values the Pushgateway could not tell from the path otherwise in base64.
+func pushgatewayGroupPath(name, value string) string {
+	if value == "" || strings.Contains(value, "/") {
+		return "/" + name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return "/" + name + "/" + url.PathEscape(value)
+}
+
+// Close pushes the metrics, replacing the ones of the same names previously
+// pushed for the job and instance.
+func (s *pushgatewaySink) Close() error {
+	var body bytes.Buffer
+	s.write(&body, false)
+	if body.Len() == 0 {
+		return nil
+	}
+
+	header := http.Header{"Content-Type": {"text/plain; version=0.0.4"}}
+	retryWithBackoff("metrics pushed to the Pushgateway", func() (bool, error) {
+		return postBody(s.client, s.url, header, body.Bytes())
+	})
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPushgatewaySink(t *testing.T) {
+	var path, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		path, contentType, body = req.URL.Path, req.Header.Get("Content-Type"), string(b)
+	}))
+	defer server.Close()
+
+	savedURL, savedJob, savedName := *pushgatewayURL, *pushgatewayJob, *serviceName
+	*pushgatewayURL, *pushgatewayJob, *serviceName = server.URL+"/", "", "nightly/import"
+	defer func() { *pushgatewayURL, *pushgatewayJob, *serviceName = savedURL, savedJob, savedName }()
+
+	sink, err := newPushgatewaySink()
+	if err != nil {
+		t.Fatalf("newPushgatewaySink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{NumGC: 7, Heap1: 33, STWMclock: 2})
+
+	if path != "" {
+		t.Errorf("Expected metrics to be pushed on exit only.")
+	}
+	sink.Close()
+
+	expectedPath := "/metrics/job@base64/bmlnaHRseS9pbXBvcnQ/instance/" + ownHost
+	if path != expectedPath {
+		t.Errorf("Expected metrics to be pushed to %s. Got %s instead.", expectedPath, path)
+	}
+	if !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus text format. Got %q instead.", contentType)
+	}
+	for _, expected := range []string{
+		`gcvis_heap_goal_megabytes{srv="nightly/import"} 33` + "\n",
+		`gcvis_gc_pause_seconds_count{srv="nightly/import",phase="mark_termination"} 1` + "\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected pushed metrics to contain %q. Got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestPushgatewaySinkEmpty(t *testing.T) {
+	pushed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		pushed = true
+	}))
+	defer server.Close()
+
+	savedURL := *pushgatewayURL
+	*pushgatewayURL = server.URL
+	defer func() { *pushgatewayURL = savedURL }()
+
+	sink, err := newPushgatewaySink()
+	if err != nil {
+		t.Fatalf("newPushgatewaySink returned an error: %v", err)
+	}
+	sink.Close()
+
+	if pushed {
+		t.Errorf("Expected nothing to be pushed without events.")
+	}
+}
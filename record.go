@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"time"
+)
+
+var recordOut = flag.String("record", "", "path of a JSONL file to append every parsed event to, for archival and replay")
+
+func init() {
+	RegisterSink("record", newRecordSink)
+}
+
+// recordEvent is a line of a recording. It keeps every parsed field, and
+// the raw line so a recording can be parsed again by later gcvis versions.
+type recordEvent struct {
+	Type string      `json:"type"` // gc or scvg
+	Time time.Time   `json:"time"`
+	GC   *gctrace    `json:"gc,omitempty"`
+	Scvg *scvgFields `json:"scvg,omitempty"`
+	Raw  string      `json:"raw"`
+}
+
+// recordSink writes every event as a line of JSON. Lines are not buffered,
+// unless the file is rotated, so a recording survives gcvis being killed.
+type recordSink struct {
+	w   io.WriteCloser
+	enc *json.Encoder
+}
+
+func newRecordSink() (Sink, error) {
+	w, err := openOutput(*recordOut)
+	if err != nil || w == nil {
+		return nil, err
+	}
+
+	return &recordSink{w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (s *recordSink) ConsumeGC(t *gctrace) error {
+	return s.recordGC(t, traceTime(t.ElapsedTime))
+}
+
+func (s *recordSink) ConsumeScvg(t *scvgtrace) error {
+	return s.recordScvg(t, traceTime(t.ElapsedTime))
+}
+
+// recordGC writes the event of t, traced at at.
+func (s *recordSink) recordGC(t *gctrace, at time.Time) error {
+	return s.enc.Encode(recordEvent{
+		Type: "gc",
+		Time: at.UTC(),
+		GC:   t,
+		Raw:  t.Raw,
+	})
+}
+
+// recordScvg writes the event of t, traced at at.
+func (s *recordSink) recordScvg(t *scvgtrace, at time.Time) error {
+	return s.enc.Encode(recordEvent{
+		Type: "scvg",
+		Time: at.UTC(),
+		Scvg: &scvgFields{
+			Inuse:    t.Inuse,
+			Idle:     t.Idle,
+			Sys:      t.Sys,
+			Released: t.Released,
+			Consumed: t.Consumed,
+		},
+		Raw: t.Raw,
+	})
+}
+
+func (s *recordSink) Flush() error {
+	return nil
+}
+
+func (s *recordSink) Close() error {
+	return s.w.Close()
+}
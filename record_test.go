@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "run.jsonl")
+	saved := *recordOut
+	*recordOut = path
+	defer func() { *recordOut = saved }()
+
+	sink, err := newRecordSink()
+	if err != nil {
+		t.Fatalf("newRecordSink returned an error: %v", err)
+	}
+
+	gcLine := "gc 88 @3.243s 9%: 0.040+16+1.0+5.9+0.34 ms clock, 0.16+16+0+18/5.7/11+1.3 ms cpu, 32->33->19 MB, 33 MB goal, 4 P"
+	sink.ConsumeGC(&gctrace{NumGC: 88, Heap1: 33, ElapsedTime: 3.243, Raw: gcLine})
+	sink.ConsumeScvg(&scvgtrace{Inuse: 12, Raw: "scvg1: inuse: 12, idle: 13, sys: 14, released: 15, consumed: 16 (MB)"})
+	sink.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open returned an error: %v", err)
+	}
+	defer f.Close()
+
+	var events []recordEvent
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e recordEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("Line is not valid JSON: %v", err)
+		}
+		events = append(events, e)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events. Got %d instead.", len(events))
+	}
+	if events[0].Type != "gc" || events[0].Raw != gcLine || events[0].GC.NumGC != 88 || events[0].GC.ElapsedTime != 3.243 {
+		t.Errorf("Unexpected GC event: %+v", events[0])
+	}
+	if events[1].Type != "scvg" || events[1].Scvg.Inuse != 12 || events[1].GC != nil {
+		t.Errorf("Unexpected scavenger event: %+v", events[1])
+	}
+}
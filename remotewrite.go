@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+var remoteWriteURL = flag.String("remote-write-url", "", "Prometheus remote_write endpoint to push metrics to, e.g. http://localhost:9009/api/v1/push")
+var remoteWriteTenant = flag.String("remote-write-tenant", "", "tenant ID sent in the X-Scope-OrgID header, for Cortex and Mimir")
+var remoteWriteToken = flag.String("remote-write-bearer-token", "", "bearer token sent with remote_write requests")
+var remoteWriteBatchSize = flag.Int("remote-write-batch-size", 500, "maximum number of samples sent in a single remote_write request")
+var remoteWriteBatchWait = flag.Duration("remote-write-batch-wait", 5*time.Second, "maximum time a sample waits before being sent")
+
+func init() {
+	RegisterSink("remote-write", newRemoteWriteSink)
+}
+
+// remoteWriteSample is a sample of a single time series, with its labels
+// sorted by name as the protocol requires.
+type remoteWriteSample struct {
+	labels    [][2]string
+	value     float64
+	timestamp int64 // in milliseconds
+}
+
+// remoteWriteSink sends metrics with the Prometheus remote_write protocol:
+// snappy compressed protobuf WriteRequest messages.
+type remoteWriteSink struct {
+	url    string
+	header http.Header
+	labels map[string]string
+
+	client  *http.Client
+	batcher *batcher
+}
+
+func newRemoteWriteSink() (Sink, error) {
+	if *remoteWriteURL == "" {
+		return nil, nil
+	}
+
+	s := &remoteWriteSink{
+		url:    *remoteWriteURL,
+		header: remoteWriteHeader(),
+		labels: metricLabels(),
+		client: &http.Client{Timeout: pushTimeout},
+	}
+	s.batcher = newBatcher(*remoteWriteBatchSize, *remoteWriteBatchWait, s.send)
+
+	return s, nil
+}
+
+// remoteWriteHeader returns the headers of the remote_write requests.
+func remoteWriteHeader() http.Header {
+	header := http.Header{
+		"Content-Type":                      {"application/x-protobuf"},
+		"Content-Encoding":                  {"snappy"},
+		"X-Prometheus-Remote-Write-Version": {"0.1.0"},
+	}
+	if *remoteWriteTenant != "" {
+		header.Set("X-Scope-OrgID", *remoteWriteTenant)
+	}
+	if *remoteWriteToken != "" {
+		header.Set("Authorization", "Bearer "+*remoteWriteToken)
+	}
+	return header
+}
+
+func (s *remoteWriteSink) ConsumeGC(t *gctrace) error {
+	s.add(gcMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *remoteWriteSink) ConsumeScvg(t *scvgtrace) error {
+	s.add(scvgMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *remoteWriteSink) add(metrics []metric, ts time.Time) {
+	for _, m := range metrics {
+		labels := map[string]string{"__name__": m.Name}
+		for k, v := range s.labels {
+			labels[k] = v
+		}
+
+		sample := remoteWriteSample{value: m.Value, timestamp: timestampMillis(ts)}
+		for _, name := range sortedLabelNames(labels) {
+			sample.labels = append(sample.labels, [2]string{name, labels[name]})
+		}
+
+		s.batcher.Add(sample)
+	}
+}
+
+func (s *remoteWriteSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *remoteWriteSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+func (s *remoteWriteSink) send(batch []interface{}) {
+	samples := make([]remoteWriteSample, len(batch))
+	for i, sample := range batch {
+		samples[i] = sample.(remoteWriteSample)
+	}
+
+	body := snappy.Encode(nil, encodeWriteRequest(samples))
+
+	retryWithBackoff(fmt.Sprintf("%d remote_write samples", len(samples)), func() (bool, error) {
+		return postBody(s.client, s.url, s.header, body)
+	})
+}
+
+// encodeWriteRequest encodes samples as a prometheus.WriteRequest
+// protobuf message, one time series per sample:
+//
+//     message WriteRequest { repeated TimeSeries timeseries = 1; }
+//     message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//     message Label { string name = 1; string value = 2; }
+//     message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(samples []remoteWriteSample) []byte {
+	var req []byte
+	for _, sample := range samples {
+		var series []byte
+		for _, label := range sample.labels {
+			var l []byte
+			l = appendProtoBytes(l, 1, []byte(label[0]))
+			l = appendProtoBytes(l, 2, []byte(label[1]))
+			series = appendProtoBytes(series, 1, l)
+		}
+
+		var s []byte
+		s = appendProtoKey(s, 1, protoFixed64)
+		s = appendFixed64(s, math.Float64bits(sample.value))
+		s = appendProtoKey(s, 2, protoVarint)
+		s = appendUvarint(s, uint64(sample.timestamp))
+		series = appendProtoBytes(series, 2, s)
+
+		req = appendProtoBytes(req, 1, series)
+	}
+
+	return req
+}
+
+// Protobuf wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+func appendProtoKey(b []byte, field, wireType int) []byte {
+	return appendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendProtoBytes(b []byte, field int, value []byte) []byte {
+	b = appendProtoKey(b, field, protoBytes)
+	b = appendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/golang/snappy"
+)
+
+type protoField struct {
+	num   int
+	value uint64 // varint and fixed64 fields
+	bytes []byte // length delimited fields
+}
+
+func decodeProto(t *testing.T, b []byte) []protoField {
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case protoVarint:
+			f.value, n = binary.Uvarint(b)
+			b = b[n:]
+		case protoFixed64:
+			f.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case protoBytes:
+			l, n := binary.Uvarint(b)
+			b = b[n:]
+			f.bytes = b[:l]
+			b = b[l:]
+		default:
+			t.Fatalf("Unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	req := encodeWriteRequest([]remoteWriteSample{{
+		labels:    [][2]string{{"__name__", "gcvis_heap_goal_megabytes"}, {"srv", "test"}},
+		value:     33,
+		timestamp: 1500,
+	}})
+
+	series := decodeProto(t, req)
+	if len(series) != 1 || series[0].num != 1 {
+		t.Fatalf("Expected a single time series. Got %+v instead.", series)
+	}
+
+	var labels [][2]string
+	var samples []protoField
+	for _, f := range decodeProto(t, series[0].bytes) {
+		switch f.num {
+		case 1:
+			l := decodeProto(t, f.bytes)
+			labels = append(labels, [2]string{string(l[0].bytes), string(l[1].bytes)})
+		case 2:
+			samples = decodeProto(t, f.bytes)
+		}
+	}
+
+	if len(labels) != 2 || labels[0] != [2]string{"__name__", "gcvis_heap_goal_megabytes"} || labels[1] != [2]string{"srv", "test"} {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+	if len(samples) != 2 || math.Float64frombits(samples[0].value) != 33 || samples[1].value != 1500 {
+		t.Errorf("Unexpected sample: %+v", samples)
+	}
+}
+
+func TestRemoteWriteSink(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		series int
+		header http.Header
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		compressed, _ := ioutil.ReadAll(req.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("Request body is not snappy encoded: %v", err)
+		}
+
+		mu.Lock()
+		series += len(decodeProto(t, body))
+		header = req.Header
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	saved := *remoteWriteURL
+	*remoteWriteURL = server.URL
+	defer func() { *remoteWriteURL = saved }()
+
+	sink, err := newRemoteWriteSink()
+	if err != nil {
+		t.Fatalf("newRemoteWriteSink returned an error: %v", err)
+	}
+
+	sink.ConsumeGC(&gctrace{Heap1: 10})
+	sink.ConsumeScvg(&scvgtrace{Inuse: 12})
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := len(gcMetrics(&gctrace{})) + len(scvgMetrics(&scvgtrace{}))
+	if series != expected {
+		t.Errorf("Expected %d time series to be written. Got %d instead.", expected, series)
+	}
+	if header.Get("Content-Encoding") != "snappy" || header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Errorf("Unexpected request headers: %v", header)
+	}
+}
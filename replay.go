@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gmaz42/gcvis/parse"
+)
+
+var replaySpeed = flag.String("speed", "max", "speed at which replay feeds the traces, relative to the time they were traced at, e.g. 1x, 10x or 0.5x, or max to feed them as fast as they are read")
+
+// replayElapsedRegexp matches the time a trace was written at, in seconds
+// since the program started, in go1.5+ GC traces.
+var replayElapsedRegexp = regexp.MustCompile(`^gc #?\d+ @([\d.]+)s `)
+
+// parseReplaySpeed parses a -speed value, returning 0 for max.
+func parseReplaySpeed(s string) (float64, error) {
+	if s == "max" {
+		return 0, nil
+	}
+
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid -speed %q, expected a positive factor such as 10x, or max", s)
+	}
+	return speed, nil
+}
+
+// pacedReader reads a GC trace log line by line, holding each GC trace back
+// until as much time passed since the first one as between them when they
+// were traced, divided by speed. Other lines, scavenger traces included,
+// follow the line before them.
+type pacedReader struct {
+	sc    *bufio.Scanner
+	speed float64
+	sleep func(time.Duration)
+
+	first   float64 // elapsed time of the first GC trace, or -1
+	started time.Time
+	line    []byte // rest of the current line
+}
+
+func newPacedReader(r io.Reader, speed float64) *pacedReader {
+	sc := bufio.NewScanner(r)
+	sc.Split(parse.ScanLines)
+	return &pacedReader{
+		sc:    sc,
+		speed: speed,
+		sleep: time.Sleep,
+		first: -1,
+	}
+}
+
+func (r *pacedReader) Read(p []byte) (int, error) {
+	for len(r.line) == 0 {
+		if !r.sc.Scan() {
+			if err := r.sc.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+
+		if m := replayElapsedRegexp.FindSubmatch(r.sc.Bytes()); m != nil {
+			elapsed, _ := strconv.ParseFloat(string(m[1]), 64)
+			r.wait(elapsed)
+		}
+		r.line = append(append(r.line[:0], r.sc.Bytes()...), '\n')
+	}
+
+	n := copy(p, r.line)
+	r.line = r.line[n:]
+	return n, nil
+}
+
+// wait sleeps until the trace written elapsed seconds after the program
+// started is due.
+func (r *pacedReader) wait(elapsed float64) {
+	if r.first < 0 {
+		r.first, r.started = elapsed, time.Now()
+		return
+	}
+
+	due := time.Duration((elapsed - r.first) / r.speed * float64(time.Second))
+	if wait := due - time.Since(r.started); wait > 0 {
+		r.sleep(wait)
+	}
+}
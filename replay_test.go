@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReplaySpeed(t *testing.T) {
+	for s, expected := range map[string]float64{"max": 0, "10x": 10, "0.5x": 0.5, "2": 2} {
+		speed, err := parseReplaySpeed(s)
+		if err != nil || speed != expected {
+			t.Errorf("Expected %s to be a speed of %v. Got %v, %v instead.", s, expected, speed, err)
+		}
+	}
+	for _, s := range []string{"fast", "0x", "-2x"} {
+		if _, err := parseReplaySpeed(s); err == nil {
+			t.Errorf("Expected %q to be rejected.", s)
+		}
+	}
+}
+
+func TestPacedReader(t *testing.T) {
+	log := `gc 1 @1.000s 2%: 0.010+1.5+0.020 ms clock, 0.040+0.10/1.0/2.0+0.080 ms cpu, 4->5->1 MB, 5 MB goal, 4 P
+scvg0: inuse: 3, idle: 1, sys: 5, released: 0, consumed: 5 (MB)
+gc 2 @21.000s 1%: 2.5+3.0+0.5 ms clock, 0.040+0.10/1.0/2.0+0.080 ms cpu, 9->10->2 MB, 10 MB goal, 4 P
+gc 3 @41.000s 1%: 2.5+3.0+0.5 ms clock, 0.040+0.10/1.0/2.0+0.080 ms cpu, 9->10->2 MB, 10 MB goal, 4 P
+`
+
+	r := newPacedReader(strings.NewReader(log), 10)
+	var sleeps []time.Duration
+	r.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll returned an error: %v", err)
+	}
+	if string(content) != log {
+		t.Errorf("Expected the log to be read unchanged. Got %q instead.", content)
+	}
+
+	// At 10x, traces 20 and 40 seconds after the first one are due 2 and 4
+	// seconds after it was read.
+	if len(sleeps) != 2 || sleeps[0] > 2*time.Second || sleeps[0] < 1900*time.Millisecond || sleeps[1] > 4*time.Second || sleeps[1] < 3900*time.Millisecond {
+		t.Errorf("Expected to wait 2s then 4s. Got %v instead.", sleeps)
+	}
+}
+
+func TestPacedReaderLineEndings(t *testing.T) {
+	r := newPacedReader(strings.NewReader("INFO: started\r\ngc 1 @1.000s 2%: 0.010+1.5+0.020 ms clock\r\n"), 10)
+	r.sleep = func(time.Duration) {}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll returned an error: %v", err)
+	}
+	if expected := "INFO: started\ngc 1 @1.000s 2%: 0.010+1.5+0.020 ms clock\n"; string(content) != expected {
+		t.Errorf("Expected the lines to end with \\n. Got %q instead.", content)
+	}
+}
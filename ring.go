@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"time"
+)
+
+var maxPoints = flag.Int("max-points", 0, "number of points kept per graph series, the oldest being evicted first. 0 keeps them all")
+var retention = flag.Duration("retention", 0, "how long events are kept, e.g. 6h: the points, annotations, anomalies and SLO violations of the graph, the events of the -db database, and the rotated -record, -loki-out and -influx-out files. 0 keeps them all")
+
+// pointRing is a graph series keeping its latest max points, or all of
+// them if max is 0, and dropping those older than retention seconds
+// before the latest one, if retention is not 0. Points are added in
+// chronological order, and evicted oldest first.
+type pointRing struct {
+	points    []graphPoints // ring buffer, the oldest point at start
+	start     int
+	len       int
+	total     int64 // points ever added, evicted ones included
+	max       int
+	retention float64
+}
+
+func newPointRing(max int, retention time.Duration) pointRing {
+	return pointRing{max: max, retention: retention.Seconds()}
+}
+
+func (r *pointRing) add(p graphPoints) {
+	if r.max > 0 && r.len == r.max {
+		r.evictOldest()
+	}
+	if r.len == len(r.points) {
+		r.grow()
+	}
+	r.points[(r.start+r.len)%len(r.points)] = p
+	r.len++
+	r.total++
+
+	if r.retention > 0 {
+		for r.len > 0 && r.at(0)[0] < p[0]-r.retention {
+			r.evictOldest()
+		}
+	}
+}
+
+func (r *pointRing) evictOldest() {
+	r.start = (r.start + 1) % len(r.points)
+	r.len--
+}
+
+// grow doubles the capacity of the ring, up to max.
+func (r *pointRing) grow() {
+	capacity := 2 * len(r.points)
+	if capacity < 16 {
+		capacity = 16
+	}
+	if r.max > 0 && capacity > r.max {
+		capacity = r.max
+	}
+
+	points := make([]graphPoints, capacity)
+	r.copyTo(points)
+	r.points, r.start = points, 0
+}
+
+// at returns the i-th oldest point.
+func (r *pointRing) at(i int) graphPoints {
+	return r.points[(r.start+i)%len(r.points)]
+}
+
+// Len returns the number of points of the series.
+func (r *pointRing) Len() int {
+	return r.len
+}
+
+// Points returns the points of the series, oldest first.
+func (r *pointRing) Points() []graphPoints {
+	points := make([]graphPoints, r.len)
+	r.copyTo(points)
+	return points
+}
+
+// since returns the points added after the first n ever added, and false
+// if some of them were evicted already.
+func (r *pointRing) since(n int64) ([]graphPoints, bool) {
+	if r.total == 0 {
+		return nil, true
+	}
+	oldest := r.total - int64(r.len)
+	if n < oldest || n > r.total {
+		return nil, false
+	}
+
+	points := make([]graphPoints, r.total-n)
+	for i := range points {
+		points[i] = r.at(int(n-oldest) + i)
+	}
+	return points, true
+}
+
+// clone returns a copy of the series, sharing none of its points.
+func (r *pointRing) clone() pointRing {
+	c := *r
+	c.points, c.start = r.Points(), 0
+	return c
+}
+
+func (r *pointRing) copyTo(points []graphPoints) {
+	n := copy(points, r.points[r.start:minInt(r.start+r.len, len(r.points))])
+	copy(points[n:r.len], r.points[:r.len-n])
+}
+
+// MarshalJSON encodes the points as an array, oldest first, as the
+// graphs plot them.
+func (r pointRing) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Points())
+}
+
+// UnmarshalJSON decodes points encoded by MarshalJSON, keeping them all.
+func (r *pointRing) UnmarshalJSON(b []byte) error {
+	var points []graphPoints
+	if err := json.Unmarshal(b, &points); err != nil {
+		return err
+	}
+
+	*r = pointRing{}
+	for _, p := range points {
+		r.add(p)
+	}
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
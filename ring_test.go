@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func addPoints(r *pointRing, xs ...float64) {
+	for _, x := range xs {
+		r.add(graphPoints{x, x * 10})
+	}
+}
+
+func TestPointRingMaxPoints(t *testing.T) {
+	r := newPointRing(3, 0)
+	addPoints(&r, 1, 2, 3, 4, 5)
+
+	expected := []graphPoints{{3, 30}, {4, 40}, {5, 50}}
+	if points := r.Points(); !reflect.DeepEqual(points, expected) {
+		t.Errorf("Expected the latest 3 points %v. Got %v instead.", expected, points)
+	}
+}
+
+func TestPointRingRetention(t *testing.T) {
+	r := newPointRing(0, 10*time.Second)
+	addPoints(&r, 1, 5, 12, 20)
+
+	expected := []graphPoints{{12, 120}, {20, 200}}
+	if points := r.Points(); !reflect.DeepEqual(points, expected) {
+		t.Errorf("Expected the points of the last 10s %v. Got %v instead.", expected, points)
+	}
+}
+
+func TestPointRingUnbounded(t *testing.T) {
+	r := newPointRing(0, 0)
+	for i := 0; i < 100; i++ {
+		addPoints(&r, float64(i))
+	}
+	if r.Len() != 100 || r.Points()[99][0] != 99 {
+		t.Errorf("Expected to keep 100 points. Got %d instead.", r.Len())
+	}
+}
+
+func TestPointRingJSON(t *testing.T) {
+	r := newPointRing(2, 0)
+	addPoints(&r, 1, 2, 3)
+
+	b, err := json.Marshal(r)
+	if err != nil || string(b) != "[[2,20],[3,30]]" {
+		t.Errorf("Expected [[2,20],[3,30]]. Got %s, %v instead.", b, err)
+	}
+
+	var decoded pointRing
+	if err := json.Unmarshal(b, &decoded); err != nil || !reflect.DeepEqual(decoded.Points(), r.Points()) {
+		t.Errorf("Expected to decode %v. Got %v, %v instead.", r.Points(), decoded.Points(), err)
+	}
+
+	empty, _ := json.Marshal(newPointRing(0, 0))
+	if string(empty) != "[]" {
+		t.Errorf("Expected an empty series to be []. Got %s instead.", empty)
+	}
+}
+
+func TestPointRingSince(t *testing.T) {
+	r := newPointRing(3, 0)
+	addPoints(&r, 1, 2, 3, 4, 5)
+
+	expected := []graphPoints{{4, 40}, {5, 50}}
+	if points, ok := r.since(3); !ok || !reflect.DeepEqual(points, expected) {
+		t.Errorf("Expected the points after the third %v. Got %v, %v instead.", expected, points, ok)
+	}
+	if points, ok := r.since(5); !ok || len(points) != 0 {
+		t.Errorf("Expected no new points. Got %v, %v instead.", points, ok)
+	}
+	if _, ok := r.since(1); ok {
+		t.Errorf("Expected evicted points to invalidate the cursor.")
+	}
+}
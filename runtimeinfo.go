@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runtimeInfo describes the runtime of the traced program, as far as gcvis
+// can tell from the environment it runs in, its binary and its traces.
+// Fields are empty when unknown.
+type runtimeInfo struct {
+	GoVersion  string `json:"go_version,omitempty"`
+	GOMAXPROCS int64  `json:"gomaxprocs,omitempty"`
+	GOGC       string `json:"gogc,omitempty"`
+	GOMEMLIMIT string `json:"gomemlimit,omitempty"`
+}
+
+// targetRuntime is the runtime of the program gcvis runs, detected before
+// it starts. It is empty when reading traces from the standard input or a
+// file, only their GOMAXPROCS being known.
+var targetRuntime runtimeInfo
+
+// detectRuntime returns the runtime of args, run in the environment of
+// getenv: GOGC and GOMEMLIMIT are the defaults of the runtime if unset, and
+// GOMAXPROCS is left to the traces if unset.
+func detectRuntime(args []string, getenv func(string) string) runtimeInfo {
+	r := runtimeInfo{
+		GoVersion:  detectGoVersion(args),
+		GOGC:       getenv("GOGC"),
+		GOMEMLIMIT: getenv("GOMEMLIMIT"),
+	}
+	if r.GOGC == "" {
+		r.GOGC = strconv.Itoa(defaultGOGC)
+	}
+	if r.GOMEMLIMIT == "" {
+		r.GOMEMLIMIT = "off"
+	}
+	if procs, err := strconv.ParseInt(getenv("GOMAXPROCS"), 10, 64); err == nil && procs > 0 {
+		r.GOMAXPROCS = procs
+	}
+	return r
+}
+
+// detectGoVersion returns the Go version the program of args was built
+// with, as told by the go command, or an empty string if it cannot tell,
+// e.g. without a go command or for a program not written in Go. Programs
+// run by the go command, e.g. go run or go test, are built with its own
+// version.
+func detectGoVersion(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	cmd := exec.Command("go", "env", "GOVERSION")
+	if filepath.Base(args[0]) != "go" {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			return ""
+		}
+		cmd = exec.Command("go", "version", path)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		debugf("cannot tell the Go version of %s: %v", args[0], err)
+		return ""
+	}
+	// go version prints "path: go1.21.5", go env "go1.21.5".
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 || !strings.HasPrefix(fields[len(fields)-1], "go") {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// withProcs returns r with the GOMAXPROCS of a trace, nproc, if known.
+func (r runtimeInfo) withProcs(nproc int64) runtimeInfo {
+	if nproc > 0 {
+		r.GOMAXPROCS = nproc
+	}
+	return r
+}
+
+// orNil returns a copy of r, or nil if nothing is known of the runtime.
+func (r runtimeInfo) orNil() *runtimeInfo {
+	if r == (runtimeInfo{}) {
+		return nil
+	}
+	return &r
+}
+
+// clone returns a copy of r, nil if r is.
+func (r *runtimeInfo) clone() *runtimeInfo {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	return &c
+}
+
+func (r runtimeInfo) String() string {
+	var parts []string
+	if r.GoVersion != "" {
+		parts = append(parts, r.GoVersion)
+	}
+	if r.GOMAXPROCS > 0 {
+		parts = append(parts, fmt.Sprintf("GOMAXPROCS=%d", r.GOMAXPROCS))
+	}
+	if r.GOGC != "" {
+		parts = append(parts, "GOGC="+r.GOGC)
+	}
+	if r.GOMEMLIMIT != "" {
+		parts = append(parts, "GOMEMLIMIT="+r.GOMEMLIMIT)
+	}
+	return strings.Join(parts, " ")
+}
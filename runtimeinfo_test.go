@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDetectRuntime(t *testing.T) {
+	env := map[string]string{"GOGC": "200", "GOMAXPROCS": "2"}
+	r := detectRuntime([]string{"go", "test", "./..."}, func(key string) string { return env[key] })
+
+	expected := runtimeInfo{GoVersion: runtime.Version(), GOMAXPROCS: 2, GOGC: "200", GOMEMLIMIT: "off"}
+	if r != expected {
+		t.Errorf("Expected %+v. Got %+v instead.", expected, r)
+	}
+	if s := r.String(); s != runtime.Version()+" GOMAXPROCS=2 GOGC=200 GOMEMLIMIT=off" {
+		t.Errorf("Expected the runtime on a line. Got %q instead.", s)
+	}
+
+	r = detectRuntime([]string{"/usr/bin/env", "true"}, func(string) string { return "" })
+	if r.GoVersion != "" || r.GOMAXPROCS != 0 || r.GOGC != "100" {
+		t.Errorf("Expected no Go version of a program not written in Go, and the default GOGC. Got %+v instead.", r)
+	}
+}
+
+func TestLogLineRuntime(t *testing.T) {
+	defer func(saved runtimeInfo) { targetRuntime = saved }(targetRuntime)
+
+	targetRuntime = runtimeInfo{}
+	if l := newScvgLogLine(&scvgtrace{}); l.Runtime != nil {
+		t.Errorf("Expected no runtime when unknown. Got %+v instead.", l.Runtime)
+	}
+
+	targetRuntime = runtimeInfo{GoVersion: "go1.21.5", GOGC: "100"}
+	b, err := json.Marshal(newGCLogLine(&gctrace{NumGC: 1, Nproc: 8}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"runtime":{"go_version":"go1.21.5","gomaxprocs":8,"gogc":"100"}`) {
+		t.Errorf("Expected the runtime with the GOMAXPROCS of the trace. Got %s instead.", b)
+	}
+}
+
+func TestGraphRuntime(t *testing.T) {
+	g := NewGraph("runtime", GCVIS_TMPL)
+	g.Runtime = &runtimeInfo{GoVersion: "go1.21.5"}
+	g.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 1, Nproc: 4})
+
+	s := g.Snapshot()
+	if s.Runtime == nil || s.Runtime.GOMAXPROCS != 4 || s.Runtime.GoVersion != "go1.21.5" {
+		t.Errorf("Expected the GOMAXPROCS of the traces. Got %+v instead.", s.Runtime)
+	}
+
+	restored := NewGraph("restored", GCVIS_TMPL)
+	restored.Restore(s)
+	if restored.Runtime == nil || *restored.Runtime != *s.Runtime {
+		t.Errorf("Expected the runtime to be restored. Got %+v instead.", restored.Runtime)
+	}
+}
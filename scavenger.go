@@ -0,0 +1,42 @@
+package main
+
+// The raw numbers of the scavenger traces are hard to interpret: idle
+// memory may or may not have been released, and sys includes both. These
+// are derived from them.
+
+// scvgReleasedPercent returns the share of the idle memory of s the
+// scavenger released to the operating system, the rest being kept by the
+// process.
+func scvgReleasedPercent(s *scvgtrace) float64 {
+	if s.Idle <= 0 {
+		return 0
+	}
+	return 100 * float64(s.Released) / float64(s.Idle)
+}
+
+// scvgSysGap returns the memory obtained from the operating system that the
+// heap does not use, in megabytes.
+func scvgSysGap(s *scvgtrace) int64 {
+	return s.Sys - s.Inuse
+}
+
+// ScvgTotals sums up the scavenger traces of a session.
+type ScvgTotals struct {
+	Cycles         int64
+	ReleasedMB     int64 // released to the operating system over the session
+	LastReleasedMB int64 // as last reported
+	MaxSysGapMB    int64 // largest gap between sys and in use
+}
+
+// observe accounts for a scavenger trace. Memory released is told as the
+// amount released so far, which decreases as the heap reuses it.
+func (t *ScvgTotals) observe(s *scvgtrace) {
+	if s.Released > t.LastReleasedMB {
+		t.ReleasedMB += s.Released - t.LastReleasedMB
+	}
+	t.LastReleasedMB = s.Released
+	if gap := scvgSysGap(s); t.Cycles == 0 || gap > t.MaxSysGapMB {
+		t.MaxSysGapMB = gap
+	}
+	t.Cycles++
+}
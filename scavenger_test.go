@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestScvgDerived(t *testing.T) {
+	s := &scvgtrace{Inuse: 30, Idle: 20, Sys: 50, Released: 5}
+	if p := scvgReleasedPercent(s); p != 25 {
+		t.Errorf("Expected 25%% of the idle memory to be released. Got %v instead.", p)
+	}
+	if gap := scvgSysGap(s); gap != 20 {
+		t.Errorf("Expected a gap of 20MB. Got %d instead.", gap)
+	}
+	if p := scvgReleasedPercent(&scvgtrace{}); p != 0 {
+		t.Errorf("Expected 0%% without idle memory. Got %v instead.", p)
+	}
+}
+
+func TestScvgTotals(t *testing.T) {
+	var totals ScvgTotals
+	for _, s := range []*scvgtrace{
+		{Inuse: 10, Sys: 20, Released: 4},
+		{Inuse: 16, Sys: 20, Released: 1}, // reused by the heap
+		{Inuse: 5, Sys: 30, Released: 9},
+	} {
+		totals.observe(s)
+	}
+
+	expected := ScvgTotals{Cycles: 3, ReleasedMB: 12, LastReleasedMB: 9, MaxSysGapMB: 25}
+	if totals != expected {
+		t.Errorf("Expected %+v. Got %+v instead.", expected, totals)
+	}
+}
+
+func TestGraphScvgDerived(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	graph.AddScavengerGraphPoint(&scvgtrace{ElapsedTime: 1, Inuse: 30, Idle: 20, Sys: 50, Released: 10})
+
+	s := graph.Snapshot()
+	if p := s.ScvgReleasedPercent.Points(); len(p) != 1 || p[0][1] != 50 {
+		t.Errorf("Expected a point at 50%%. Got %v instead.", p)
+	}
+	if p := s.ScvgSysGap.Points(); len(p) != 1 || p[0][1] != 20 {
+		t.Errorf("Expected a point at 20MB. Got %v instead.", p)
+	}
+	if s.ScvgTotals.ReleasedMB != 10 {
+		t.Errorf("Expected 10MB released in total. Got %+v instead.", s.ScvgTotals)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"runtime"
+	"time"
+)
+
+var selfMonitor = flag.Bool("self", false, "also graph the garbage collections of gcvis itself at /self/, to confirm it is not the bottleneck of measurements")
+
+// selfSampleInterval is how often the GC statistics of gcvis are read.
+const selfSampleInterval = time.Second
+
+// monitorSelf adds a trace to g for every garbage collection of gcvis
+// itself, until stop is closed. As gcvis does not run with gctrace, the
+// traces are made of its runtime.MemStats, giving the total pause of each
+// cycle as its mark termination, and the heap sizes at the time it was
+// read.
+func monitorSelf(g *Graph, stop <-chan struct{}) {
+	var prev, cur runtime.MemStats
+	runtime.ReadMemStats(&prev)
+
+	ticker := time.NewTicker(selfSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			runtime.ReadMemStats(&cur)
+			for _, t := range selfTraces(&prev, &cur) {
+				g.AddGCTraceGraphPoint(t)
+			}
+			prev = cur
+		case <-stop:
+			return
+		}
+	}
+}
+
+// selfTraces returns the traces of the cycles run between prev and cur,
+// up to the last 256 of them, which MemStats keeps the pauses of.
+func selfTraces(prev, cur *runtime.MemStats) []*gctrace {
+	first := prev.NumGC + 1
+	if cur.NumGC >= 256 && first < cur.NumGC-255 {
+		first = cur.NumGC - 255
+	}
+
+	var traces []*gctrace
+	for n := first; n <= cur.NumGC; n++ {
+		i := (n + 255) % 256
+		traces = append(traces, &gctrace{
+			NumGC:       int64(n),
+			ElapsedTime: time.Unix(0, int64(cur.PauseEnd[i])).Sub(StartTime).Seconds(),
+			STWMclock:   float64(cur.PauseNs[i]) / float64(time.Millisecond),
+			Heap1:       int64(cur.NextGC >> 20),
+			Heap3:       int64(cur.HeapAlloc >> 20),
+		})
+	}
+	return traces
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSelfTraces(t *testing.T) {
+	var prev, cur runtime.MemStats
+	prev.NumGC = 2
+	cur.NumGC = 4
+	cur.NextGC = 8 << 20
+	cur.PauseNs[2], cur.PauseNs[3] = uint64(time.Millisecond), uint64(2*time.Millisecond)
+	cur.PauseEnd[3] = uint64(StartTime.Add(5 * time.Second).UnixNano())
+
+	traces := selfTraces(&prev, &cur)
+	if len(traces) != 2 || traces[0].NumGC != 3 || traces[1].NumGC != 4 {
+		t.Fatalf("Expected cycles 3 and 4. Got %v instead.", traces)
+	}
+	if last := traces[1]; last.STWMclock != 2 || last.Heap1 != 8 || last.ElapsedTime != 5 {
+		t.Errorf("Expected a 2ms pause at 5s with a goal of 8MB. Got %+v instead.", last)
+	}
+
+	prev.NumGC, cur.NumGC = 0, 1000
+	if traces := selfTraces(&prev, &cur); len(traces) != 256 || traces[0].NumGC != 745 {
+		t.Errorf("Expected the last 256 cycles. Got %d from %d instead.", len(traces), traces[0].NumGC)
+	}
+}
+
+func TestSelfTracesNoCycle(t *testing.T) {
+	var stats runtime.MemStats
+	stats.NumGC = 7
+	if traces := selfTraces(&stats, &stats); len(traces) != 0 {
+		t.Errorf("Expected no trace without a new cycle. Got %v instead.", traces)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var serialPort = flag.String("serial", "", "serial port to read GC traces from, with its baud rate, e.g. /dev/ttyUSB0@115200, for programs running on boards connected over UART. Defaults to 115200 baud")
+
+// defaultSerialBaud is the baud rate of -serial ports given without one.
+const defaultSerialBaud = 115200
+
+// parseSerial returns the device and baud rate of a -serial port.
+func parseSerial(spec string) (string, int, error) {
+	device, baud := spec, defaultSerialBaud
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		device = spec[:i]
+		var err error
+		if baud, err = strconv.Atoi(spec[i+1:]); err != nil || baud <= 0 {
+			return "", 0, fmt.Errorf("invalid -serial %q, expected a baud rate after @", spec)
+		}
+	}
+	if device == "" {
+		return "", 0, fmt.Errorf("invalid -serial %q, expected a device", spec)
+	}
+	return device, baud, nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// openSerial fails: serial ports are only supported on Linux.
+func openSerial(spec string) (io.ReadCloser, error) {
+	if _, _, err := parseSerial(spec); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("-serial is only supported on Linux")
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestParseSerial(t *testing.T) {
+	for spec, expected := range map[string]struct {
+		device string
+		baud   int
+	}{
+		"/dev/ttyUSB0@9600": {"/dev/ttyUSB0", 9600},
+		"/dev/ttyAMA0":      {"/dev/ttyAMA0", defaultSerialBaud},
+	} {
+		device, baud, err := parseSerial(spec)
+		if err != nil || device != expected.device || baud != expected.baud {
+			t.Errorf("Expected %s at %d baud for %s. Got %s, %d, %v instead.", expected.device, expected.baud, spec, device, baud, err)
+		}
+	}
+
+	for _, spec := range []string{"@115200", "/dev/ttyUSB0@fast", "/dev/ttyUSB0@0"} {
+		if _, _, err := parseSerial(spec); err == nil {
+			t.Errorf("Expected %q to be rejected.", spec)
+		}
+	}
+}
+
+func TestOpenSerialNotATerminal(t *testing.T) {
+	if port, err := openSerial("/dev/null@115200"); err == nil {
+		port.Close()
+		t.Errorf("Expected /dev/null not to be a serial port.")
+	}
+}
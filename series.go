@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var seriesFlag = flag.String("series", "heap,scvg,stw,mas", "comma separated series to collect, graph and export: heap, scvg (scavenger), stw (stop the world phases) and mas (concurrent mark and scan)")
+
+// seriesNames are the series -series selects from, each grouping the
+// graphs and metrics of a part of the traces.
+var seriesNames = []string{"heap", "scvg", "stw", "mas"}
+
+// enabledSeries are the series selected by -series, all of them until
+// main parses it.
+var enabledSeries = map[string]bool{"heap": true, "scvg": true, "stw": true, "mas": true}
+
+func parseSeries(s string) (map[string]bool, error) {
+	known := map[string]bool{}
+	for _, name := range seriesNames {
+		known[name] = true
+	}
+
+	series := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("invalid -series %q, expected some of %s", name, strings.Join(seriesNames, ","))
+		}
+		series[name] = true
+	}
+	if len(series) == 0 {
+		return nil, fmt.Errorf("-series selects no series, expected some of %s", strings.Join(seriesNames, ","))
+	}
+
+	return series, nil
+}
+
+// metricSeries returns the series a metric belongs to, or "" for metrics
+// always exported, such as the GC cycle.
+func metricSeries(name string) string {
+	switch {
+	case strings.HasPrefix(name, "gcvis_heap_"):
+		return "heap"
+	case strings.HasPrefix(name, "gcvis_scvg_"):
+		return "scvg"
+	case strings.HasPrefix(name, "gcvis_stw_"):
+		return "stw"
+	case strings.HasPrefix(name, "gcvis_mark_scan_"):
+		return "mas"
+	}
+	return ""
+}
+
+// selectMetrics returns the metrics of the enabled series.
+func selectMetrics(metrics []metric) []metric {
+	selected := metrics[:0]
+	for _, m := range metrics {
+		if series := metricSeries(m.Name); series == "" || enabledSeries[series] {
+			selected = append(selected, m)
+		}
+	}
+	return selected
+}
+
+// scvgDroppingSink passes on to a sink the GC events only, when -series
+// leaves the scavenger out.
+type scvgDroppingSink struct {
+	Sink
+}
+
+func (s scvgDroppingSink) ConsumeScvg(t *scvgtrace) error {
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseSeries(t *testing.T) {
+	series, err := parseSeries("heap, stw")
+	if err != nil {
+		t.Fatalf("parseSeries returned an error: %v", err)
+	}
+	if expected := map[string]bool{"heap": true, "stw": true}; !reflect.DeepEqual(series, expected) {
+		t.Errorf("Expected %v. Got %v instead.", expected, series)
+	}
+
+	for _, s := range []string{"heap,gc", ""} {
+		if _, err := parseSeries(s); err == nil {
+			t.Errorf("Expected %q to be rejected.", s)
+		}
+	}
+}
+
+func TestSelectedSeries(t *testing.T) {
+	defer func(series map[string]bool) { enabledSeries = series }(enabledSeries)
+	enabledSeries = map[string]bool{"heap": true}
+
+	var names []string
+	for _, m := range gcMetrics(&gctrace{}) {
+		names = append(names, m.Name)
+	}
+	expected := []string{"gcvis_gc_cycle", "gcvis_gc_overhead_percent", "gcvis_heap_start_megabytes", "gcvis_heap_end_megabytes", "gcvis_heap_live_megabytes", "gcvis_heap_goal_megabytes"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected the metrics %v. Got %v instead.", expected, names)
+	}
+	if metrics := scvgMetrics(&scvgtrace{}); len(metrics) != 0 {
+		t.Errorf("Expected no scavenger metric. Got %v instead.", metrics)
+	}
+
+	g := NewGraph("title", GCVIS_TMPL)
+	g.AddGCTraceGraphPoint(&gctrace{ElapsedTime: 1, Heap1: 5, STWSclock: 2, MASclock: 3})
+	g.AddScavengerGraphPoint(&scvgtrace{ElapsedTime: 1, Inuse: 4})
+	g = g.Snapshot()
+	if g.HeapUse.Len() != 1 || g.STWSclock.Len() != 0 || g.MASclock.Len() != 0 || g.ScvgInuse.Len() != 0 {
+		t.Errorf("Expected only the heap to be graphed. Got %d heap, %d STW, %d MAS and %d scavenger points instead.", g.HeapUse.Len(), g.STWSclock.Len(), g.MASclock.Len(), g.ScvgInuse.Len())
+	}
+}
+
+func TestSelectedSeriesLogLines(t *testing.T) {
+	defer func(series map[string]bool, queue int) { enabledSeries, *sinkQueue = series, queue }(enabledSeries, *sinkQueue)
+	enabledSeries, *sinkQueue = map[string]bool{"heap": true}, 0
+
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "loki.log")
+	w, err := openOutput(path)
+	if err != nil {
+		t.Fatalf("openOutput returned an error: %v", err)
+	}
+	s, err := QueueSink("loki-out", &logLineSink{w: w})
+	if err != nil {
+		t.Fatalf("QueueSink returned an error: %v", err)
+	}
+	s.ConsumeGC(&gctrace{NumGC: 1, Heap1: 5, STWSclock: 2, MASclock: 3})
+	s.ConsumeScvg(&scvgtrace{Inuse: 4})
+	s.Close()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile returned an error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected the GC event only. Got %q instead.", lines)
+	}
+
+	var l struct {
+		GC map[string]float64 `json:"gc"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &l); err != nil {
+		t.Fatalf("Log line is not valid JSON: %v", err)
+	}
+	var names []string
+	for name := range l.GC {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expected := []string{"ElapsedTime", "GCOverhead", "Heap0", "Heap2", "Heap3", "HeapGoal", "HeapUse", "NumGC"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected the fields %v. Got %v instead.", expected, names)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gmaz42/gcvis/sink"
+)
+
+// Sink, FanOut and sinkErrors are those of the sink package, which other
+// tools may import to export traces like gcvis does.
+type (
+	Sink       = sink.Sink
+	FanOut     = sink.FanOut
+	sinkErrors = sink.Errors
+)
+
+// SinkFactory creates a sink from its command line flags. It returns a nil
+// Sink when the flags leave the sink turned off.
+type SinkFactory func() (Sink, error)
+
+var sinkFactories = map[string]SinkFactory{}
+
+var sinkNames sinksFlag
+
+func init() {
+	flag.Var(&sinkNames, "sinks", "name of a sink to turn on, e.g. prometheus or journald, failing if it cannot be. Sinks with an address, like loki, still need it, e.g. -loki-url. Can be repeated")
+}
+
+// sinkSwitches are the boolean flags turning on the sinks that need no
+// other flag, by sink name.
+var sinkSwitches = map[string]string{
+	"gcm":          "gcm",
+	"journald":     "journald",
+	"otlp-logs":    "otlp-logs",
+	"otlp-metrics": "otlp-metrics",
+	"prometheus":   "metrics",
+	"tui":          "tui",
+}
+
+// sinksFlag are the names of the sinks given with -sinks.
+type sinksFlag []string
+
+func (f *sinksFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *sinksFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func (f *sinksFlag) repeatable() {}
+
+// RegisterSink makes a sink available under name. Sinks register
+// themselves from an init function, next to the flags configuring them.
+func RegisterSink(name string, factory SinkFactory) {
+	if _, ok := sinkFactories[name]; ok {
+		panic(fmt.Sprintf("gcvis: sink %q registered twice", name))
+	}
+	sinkFactories[name] = factory
+}
+
+// NewSinks creates every registered sink turned on by the command line
+// flags, or by -sinks, and returns them as a single sink fanning out traces
+// to all of them. Unless -sink-queue is 0, each of them is queued.
+func NewSinks() (FanOut, error) {
+	wanted := map[string]bool{}
+	for _, name := range sinkNames {
+		if _, ok := sinkFactories[name]; !ok {
+			return nil, fmt.Errorf("unknown sink %q in -sinks", name)
+		}
+		if sw, ok := sinkSwitches[name]; ok {
+			if err := flag.Set(sw, "true"); err != nil {
+				return nil, err
+			}
+		}
+		wanted[name] = true
+	}
+
+	names := make([]string, 0, len(sinkFactories))
+	for name := range sinkFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sinks FanOut
+	for _, name := range names {
+		sink, err := sinkFactories[name]()
+		if err != nil {
+			sinks.Close()
+			return nil, fmt.Errorf("%s sink: %v", name, err)
+		}
+		if sink == nil {
+			if wanted[name] {
+				sinks.Close()
+				return nil, fmt.Errorf("%s sink: cannot be turned on without its flags, e.g. its address", name)
+			}
+			continue
+		}
+		debugf("%s sink turned on", name)
+		if sink, err = QueueSink(name, sink); err != nil {
+			sinks.Close()
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+// QueueSink returns sink passed the events matching -filter, unless it is
+// an evaluator, and no scavenger event unless -series has scvg, delivered
+// from a queue of -sink-queue events with the -sink-overflow policy, or
+// synchronously if -sink-queue is 0.
+func QueueSink(name string, sink Sink) (Sink, error) {
+	filter, err := newEventFilter()
+	if err != nil {
+		sink.Close()
+		return nil, err
+	}
+	if _, ok := sink.(evaluator); filter != nil && !ok {
+		sink = &filteredSink{Sink: sink, filter: filter}
+	}
+	if !enabledSeries["scvg"] {
+		sink = scvgDroppingSink{sink}
+	}
+
+	if *sinkQueue <= 0 {
+		return sink, nil
+	}
+	q, err := newQueuedSink(name, sink, *sinkQueue, *sinkOverflow)
+	if err != nil {
+		sink.Close()
+		return nil, err
+	}
+	return q, nil
+}
@@ -0,0 +1,82 @@
+// Package sink defines the destinations of the traces read by the parse
+// package.
+package sink
+
+import (
+	"strings"
+
+	"github.com/gmaz42/gcvis/parse"
+)
+
+// Sink receives every trace read by the parser and exports it somewhere:
+// a log file, a remote service, etc.
+type Sink interface {
+	ConsumeGC(t *parse.GCTrace) error
+	ConsumeScvg(s *parse.ScvgTrace) error
+	// Flush sends any buffered trace.
+	Flush() error
+	// Close flushes the sink and releases its resources.
+	Close() error
+}
+
+// FanOut is a sink forwarding traces to several sinks. A failing sink does
+// not prevent the others from receiving traces.
+type FanOut []Sink
+
+func (f FanOut) ConsumeGC(t *parse.GCTrace) error {
+	var errs Errors
+	for _, sink := range f {
+		errs.Add(sink.ConsumeGC(t))
+	}
+	return errs.Err()
+}
+
+func (f FanOut) ConsumeScvg(s *parse.ScvgTrace) error {
+	var errs Errors
+	for _, sink := range f {
+		errs.Add(sink.ConsumeScvg(s))
+	}
+	return errs.Err()
+}
+
+func (f FanOut) Flush() error {
+	var errs Errors
+	for _, sink := range f {
+		errs.Add(sink.Flush())
+	}
+	return errs.Err()
+}
+
+func (f FanOut) Close() error {
+	var errs Errors
+	for _, sink := range f {
+		errs.Add(sink.Close())
+	}
+	return errs.Err()
+}
+
+// Errors gathers the errors of several sinks.
+type Errors []error
+
+// Add adds err, unless nil.
+func (e *Errors) Add(err error) {
+	if err != nil {
+		*e = append(*e, err)
+	}
+}
+
+// Err returns the errors, or nil if there is none.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,59 @@
+package sink
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gmaz42/gcvis/parse"
+)
+
+type fakeSink struct {
+	gc, scvg, flushes, closes int
+	err                       error
+}
+
+func (s *fakeSink) ConsumeGC(t *parse.GCTrace) error {
+	s.gc++
+	return s.err
+}
+
+func (s *fakeSink) ConsumeScvg(t *parse.ScvgTrace) error {
+	s.scvg++
+	return s.err
+}
+
+func (s *fakeSink) Flush() error {
+	s.flushes++
+	return s.err
+}
+
+func (s *fakeSink) Close() error {
+	s.closes++
+	return s.err
+}
+
+func TestFanOut(t *testing.T) {
+	failing := &fakeSink{err: errors.New("boom")}
+	working := &fakeSink{}
+	sinks := FanOut{failing, working}
+
+	if err := sinks.ConsumeGC(&parse.GCTrace{}); err == nil || err.Error() != "boom" {
+		t.Errorf("Expected the failing sink error to be returned. Got %v instead.", err)
+	}
+	sinks.ConsumeScvg(&parse.ScvgTrace{})
+	sinks.Flush()
+	sinks.Close()
+
+	for _, s := range []*fakeSink{failing, working} {
+		if s.gc != 1 || s.scvg != 1 || s.flushes != 1 || s.closes != 1 {
+			t.Errorf("Expected every sink to receive every call. Got %+v instead.", s)
+		}
+	}
+}
+
+func TestFanOutEmpty(t *testing.T) {
+	var sinks FanOut
+	if err := sinks.ConsumeGC(&parse.GCTrace{}); err != nil {
+		t.Errorf("Expected no error from an empty fan out. Got %v instead.", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakeSink struct {
+	gc, scvg, flushes, closes int
+	err                       error
+}
+
+func (s *fakeSink) ConsumeGC(t *gctrace) error {
+	s.gc++
+	return s.err
+}
+
+func (s *fakeSink) ConsumeScvg(t *scvgtrace) error {
+	s.scvg++
+	return s.err
+}
+
+func (s *fakeSink) Flush() error {
+	s.flushes++
+	return s.err
+}
+
+func (s *fakeSink) Close() error {
+	s.closes++
+	return s.err
+}
+
+func TestNewSinks(t *testing.T) {
+	saved := sinkFactories
+	defer func() { sinkFactories = saved }()
+
+	enabled := &fakeSink{}
+	sinkFactories = map[string]SinkFactory{}
+	RegisterSink("enabled", func() (Sink, error) { return enabled, nil })
+	RegisterSink("disabled", func() (Sink, error) { return nil, nil })
+
+	sinks, err := NewSinks()
+	if err != nil {
+		t.Fatalf("NewSinks returned an error: %v", err)
+	}
+
+	sinks.ConsumeGC(&gctrace{})
+	sinks.Flush()
+	if enabled.gc != 1 {
+		t.Errorf("Expected the enabled sink to receive the trace.")
+	}
+
+	RegisterSink("failing", func() (Sink, error) { return nil, errors.New("boom") })
+	if _, err := NewSinks(); err == nil {
+		t.Errorf("Expected an error from a failing sink factory.")
+	}
+	if enabled.closes != 1 {
+		t.Errorf("Expected sinks created before the failure to be closed.")
+	}
+}
+
+func TestNewSinksByName(t *testing.T) {
+	defer func(factories map[string]SinkFactory, switches map[string]string, names sinksFlag, on bool) {
+		sinkFactories, sinkSwitches, sinkNames, *journald = factories, switches, names, on
+	}(sinkFactories, sinkSwitches, sinkNames, *journald)
+
+	switched := &fakeSink{}
+	sinkFactories = map[string]SinkFactory{}
+	RegisterSink("switched", func() (Sink, error) {
+		if !*journald {
+			return nil, nil
+		}
+		return switched, nil
+	})
+	RegisterSink("unconfigured", func() (Sink, error) { return nil, nil })
+	sinkSwitches = map[string]string{"switched": "journald"}
+	*journald = false
+
+	sinkNames = sinksFlag{"switched"}
+	sinks, err := NewSinks()
+	if err != nil {
+		t.Fatalf("NewSinks returned an error: %v", err)
+	}
+	if len(sinks) != 1 || !*journald {
+		t.Errorf("Expected the sink to be turned on by its switch. Got %d sinks instead.", len(sinks))
+	}
+	sinks.Close()
+
+	for _, name := range []string{"unconfigured", "unknown"} {
+		sinkNames = sinksFlag{name}
+		if _, err := NewSinks(); err == nil {
+			t.Errorf("Expected an error for the %s sink.", name)
+		}
+	}
+}
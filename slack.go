@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var slackWebhookURL = flag.String("slack-webhook", "", "URL of a Slack incoming webhook to post -alert notifications to")
+var slackInterval = flag.Duration("slack-interval", time.Minute, "minimum time between two Slack messages, the alerts in between being counted in the next one")
+
+func init() {
+	RegisterNotifier("slack", newSlackNotifier)
+}
+
+// slackNotifier posts alerts to a Slack incoming webhook, at most one every
+// interval so that a flapping rule does not flood the channel.
+type slackNotifier struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+
+	mu         sync.Mutex
+	last       time.Time // time of the last message
+	suppressed int       // alerts not posted since
+}
+
+func newSlackNotifier() (Notifier, error) {
+	if *slackWebhookURL == "" {
+		return nil, nil
+	}
+	return &slackNotifier{
+		url:      *slackWebhookURL,
+		interval: *slackInterval,
+		client:   &http.Client{Timeout: pushTimeout},
+	}, nil
+}
+
+func (n *slackNotifier) Notify(a *Alert) error {
+	n.mu.Lock()
+	now := time.Now()
+	if !n.last.IsZero() && now.Sub(n.last) < n.interval {
+		n.suppressed++
+		n.mu.Unlock()
+		return nil
+	}
+	suppressed := n.suppressed
+	n.last, n.suppressed = now, 0
+	n.mu.Unlock()
+
+	body, err := json.Marshal(map[string]string{"text": slackMessage(a, suppressed)})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	return sendWithBackoff("Slack message", func() (bool, error) {
+		return postBody(n.client, n.url, header, body)
+	})
+}
+
+// slackMessage formats a with Slack mrkdwn: the rule, the trace of the
+// event, the statistics of the last cycles and a link to the web UI.
+func slackMessage(a *Alert, suppressed int) string {
+	var b strings.Builder
+	if a.Firing {
+		fmt.Fprintf(&b, ":rotating_light: *%s* is firing: %s\n", slackEscape(a.Rule), slackEscape(a.valueString()))
+	} else {
+		fmt.Fprintf(&b, ":white_check_mark: *%s* recovered after %s: %s\n", slackEscape(a.Rule), time.Since(a.Since).Round(time.Second), slackEscape(a.valueString()))
+	}
+	if a.Detail != "" {
+		fmt.Fprintf(&b, "%s\n", slackEscape(a.Detail))
+	}
+	if a.Trace != "" {
+		fmt.Fprintf(&b, "```%s```\n", slackEscape(a.Trace))
+	}
+	if r := a.Recent; r != nil {
+		fmt.Fprintf(&b, "Last %d GC cycles: p50 %.3fms, p99 %.3fms, max %.3fms pauses, heap %d to %dMB\n",
+			r.NumGC, r.PausePercentilesMs["p50"], r.PausePercentilesMs["p99"], r.PausePercentilesMs["max"], r.HeapMinMB, r.HeapHighWaterMB)
+	}
+	if suppressed > 0 {
+		fmt.Fprintf(&b, "%d more alerts since the last message\n", suppressed)
+	}
+	if a.URL != "" {
+		fmt.Fprintf(&b, "<%s|Open gcvis>\n", a.URL)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// slackEscape escapes the characters Slack uses for its control sequences.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlackMessage(t *testing.T) {
+	stats := newRunStats()
+	stats.addGC(&gctrace{ElapsedTime: 1, STWMclock: 2, Heap0: 10, Heap3: 5})
+	a := &Alert{
+		Rule:   "stw>1ms",
+		Firing: true,
+		Value:  2,
+		Unit:   "ms",
+		Trace:  "gc 1 @1s 0%: 0+0+2 ms clock, 0+0/0/0+0 ms cpu, 10->10->5 MB, 11 MB goal, 4 P",
+		Recent: newExitSummary(stats),
+		URL:    "http://127.0.0.1:4500/",
+	}
+
+	msg := slackMessage(a, 3)
+	for _, expected := range []string{
+		"*stw&gt;1ms* is firing: 2ms",
+		"```gc 1 @1s 0%: 0+0+2 ms clock, 0+0/0/0+0 ms cpu, 10-&gt;10-&gt;5 MB, 11 MB goal, 4 P```",
+		"Last 1 GC cycles: p50 2.000ms",
+		"3 more alerts since the last message",
+		"<http://127.0.0.1:4500/|Open gcvis>",
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected the message to contain %q. Got %q instead.", expected, msg)
+		}
+	}
+
+	a.Firing = false
+	if msg := slackMessage(a, 0); !strings.Contains(msg, "recovered") || strings.Contains(msg, "more alerts") {
+		t.Errorf("Expected a recovery message. Got %q instead.", msg)
+	}
+}
+
+func TestSlackNotifierRateLimit(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var payload struct{ Text string }
+		json.NewDecoder(req.Body).Decode(&payload)
+		texts = append(texts, payload.Text)
+	}))
+	defer server.Close()
+
+	n := &slackNotifier{url: server.URL, interval: 50 * time.Millisecond, client: server.Client()}
+	for i := 0; i < 3; i++ {
+		n.Notify(&Alert{Rule: "heap>1GiB", Firing: true})
+	}
+	time.Sleep(60 * time.Millisecond)
+	n.Notify(&Alert{Rule: "heap>1GiB"})
+
+	if len(texts) != 2 {
+		t.Fatalf("Expected 2 messages. Got %q instead.", texts)
+	}
+	if !strings.Contains(texts[1], "2 more alerts") {
+		t.Errorf("Expected the suppressed alerts to be counted. Got %q instead.", texts[1])
+	}
+}
+
+func TestSlackNotifierFailure(t *testing.T) {
+	defer func(backoff time.Duration) { pushMinBackoff = backoff }(pushMinBackoff)
+	pushMinBackoff = time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n := &slackNotifier{url: server.URL, interval: time.Minute, client: server.Client()}
+	if err := n.Notify(&Alert{Rule: "heap>1GiB", Firing: true}); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected the failure to be returned. Got %v instead.", err)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// sloDefaultWindow is the window SLOs are evaluated over, unless told.
+const sloDefaultWindow = 5 * time.Minute
+
+var sloObjectives sloFlag
+
+func init() {
+	flag.Var(&sloObjectives, "slo", "service level objective on the GC events of a sliding window, e.g. 'p99_stw<5ms over 5m', whose violations are shaded on the graphs, summed up by /api/v1/summary and counted by the -metrics. Can be repeated. Takes the statistics of -fail-if")
+}
+
+// sloObjective is met while a statistic of the GC events of the last
+// Window is below, or above, a value.
+type sloObjective struct {
+	Expr   string // as given to -slo
+	Stat   string
+	Below  bool
+	Value  float64 // in the unit of the statistic
+	Window time.Duration
+}
+
+// parseSLO parses stat<value or stat>value, optionally followed by "over"
+// and the duration of the window, value being a number in the unit of the
+// statistic, or a duration or size with its own unit, e.g. 5ms or 1GiB.
+func parseSLO(s string) (sloObjective, error) {
+	o := sloObjective{Expr: s, Window: sloDefaultWindow}
+
+	cond := s
+	if i := strings.Index(s, " over "); i >= 0 {
+		cond = s[:i]
+		var err error
+		if o.Window, err = time.ParseDuration(strings.TrimSpace(s[i+len(" over "):])); err != nil || o.Window <= 0 {
+			return sloObjective{}, fmt.Errorf("invalid SLO %q, expected a duration after over", s)
+		}
+	}
+
+	i := strings.IndexAny(cond, "<>")
+	if i <= 0 {
+		return sloObjective{}, fmt.Errorf("invalid SLO %q, expected statistic<value or statistic>value", s)
+	}
+	o.Stat, o.Below = strings.TrimSpace(cond[:i]), cond[i] == '<'
+	stat, ok := runStatistics[o.Stat]
+	if !ok {
+		return sloObjective{}, fmt.Errorf("invalid SLO %q, unknown statistic %s, expected one of %s", s, o.Stat, strings.Join(runStatisticNames(), ", "))
+	}
+
+	var err error
+	if o.Value, err = parseStatValue(strings.TrimSpace(cond[i+1:]), stat.unit); err != nil {
+		return sloObjective{}, fmt.Errorf("invalid SLO %q: %v", s, err)
+	}
+	return o, nil
+}
+
+// met tells whether the objective is met by the events of s.
+func (o sloObjective) met(s *runStats) bool {
+	v := runStatistics[o.Stat].value(s)
+	if o.Below {
+		return v < o.Value
+	}
+	return v > o.Value
+}
+
+// sloFlag collects repeated -slo flags.
+type sloFlag []sloObjective
+
+func (f *sloFlag) String() string {
+	s := make([]string, len(*f))
+	for i, o := range *f {
+		s[i] = o.Expr
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *sloFlag) Set(value string) error {
+	o, err := parseSLO(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, o)
+	return nil
+}
+
+func (f *sloFlag) repeatable() {}
+
+// SLOViolation is a period of time, in seconds, an SLO was not met. The
+// last one of an SLO is ongoing until it is met again.
+type SLOViolation struct {
+	SLO      string
+	Unit     string // of the statistic, telling the graph to shade
+	From, To float64
+	Ongoing  bool
+}
+
+// sloReport sums up the compliance of an SLO over a window of time.
+type sloReport struct {
+	SLO               string  `json:"slo"`
+	Violations        int     `json:"violations"` // overlapping the window
+	ViolatingSeconds  float64 `json:"violating_seconds"`
+	CompliancePercent float64 `json:"compliance_percent"`
+	Compliant         bool    `json:"compliant"` // as of the latest event
+}
+
+// sloTracker evaluates an SLO on every GC event, over the events of its
+// window, keeping the periods it was violated.
+type sloTracker struct {
+	objective  sloObjective
+	traces     traceLog
+	violations []SLOViolation
+}
+
+func newSLOTracker(o sloObjective) *sloTracker {
+	return &sloTracker{objective: o, traces: newTraceLog(*maxPoints, o.Window)}
+}
+
+// observe evaluates the SLO with the event of t at elapsed seconds.
+func (t *sloTracker) observe(gc *gctrace, elapsed float64) {
+	t.traces.add(timedTrace{elapsed, gc})
+
+	stats := newRunStats()
+	for _, trace := range t.traces.traces {
+		stats.addGC(trace.gc)
+	}
+
+	violating := t.violating()
+	switch met := t.objective.met(stats); {
+	case !met && !violating:
+		t.violations = append(t.violations, SLOViolation{
+			SLO:     t.objective.Expr,
+			Unit:    runStatistics[t.objective.Stat].unit,
+			From:    elapsed,
+			To:      elapsed,
+			Ongoing: true,
+		})
+	case violating:
+		last := &t.violations[len(t.violations)-1]
+		last.To, last.Ongoing = elapsed, !met
+	}
+}
+
+// violating tells whether the SLO was not met as of the latest event.
+func (t *sloTracker) violating() bool {
+	return len(t.violations) > 0 && t.violations[len(t.violations)-1].Ongoing
+}
+
+// report sums up the compliance of the SLO between from and to seconds.
+func (t *sloTracker) report(from, to float64) sloReport {
+	r := sloReport{SLO: t.objective.Expr, Compliant: !t.violating(), CompliancePercent: 100}
+	for _, v := range t.violations {
+		overlap := math.Min(v.To, to) - math.Max(v.From, from)
+		if overlap < 0 {
+			continue
+		}
+		r.Violations++
+		r.ViolatingSeconds += overlap
+	}
+	if to > from {
+		r.CompliancePercent = 100 * (1 - r.ViolatingSeconds/(to-from))
+	} else if r.Violations > 0 {
+		r.CompliancePercent = 0
+	}
+	return r
+}
+
+// sloTrackers tracks every -slo.
+type sloTrackers []*sloTracker
+
+// newSLOTrackers returns the trackers of objectives, or nil without any.
+func newSLOTrackers(objectives []sloObjective) sloTrackers {
+	var trackers sloTrackers
+	for _, o := range objectives {
+		trackers = append(trackers, newSLOTracker(o))
+	}
+	return trackers
+}
+
+func (ts sloTrackers) observe(gc *gctrace, elapsed float64) {
+	for _, t := range ts {
+		t.observe(gc, elapsed)
+	}
+}
+
+// violations returns the periods every SLO was violated.
+func (ts sloTrackers) violations() []SLOViolation {
+	var violations []SLOViolation
+	for _, t := range ts {
+		violations = append(violations, t.violations...)
+	}
+	return violations
+}
+
+func (ts sloTrackers) reports(from, to float64) []sloReport {
+	var reports []sloReport
+	for _, t := range ts {
+		reports = append(reports, t.report(from, to))
+	}
+	return reports
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSLO(t *testing.T) {
+	o, err := parseSLO("p99_stw<5ms over 10m")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if o.Stat != "p99_stw" || !o.Below || o.Value != 5 || o.Window != 10*time.Minute {
+		t.Errorf("Expected p99_stw below 5ms over 10m. Got %+v instead.", o)
+	}
+
+	o, err = parseSLO("heap_max<1GiB")
+	if err != nil || o.Value != 1024 || o.Window != sloDefaultWindow {
+		t.Errorf("Expected heap_max below 1024MB over the default window. Got %+v, %v instead.", o, err)
+	}
+
+	for _, invalid := range []string{"p99_stw", "p42_stw<5ms", "p99_stw<5ms over soon", "p99_stw<fast"} {
+		if _, err := parseSLO(invalid); err == nil {
+			t.Errorf("Expected %q to be invalid.", invalid)
+		}
+	}
+}
+
+// observeSLOPauses observes a cycle a second pausing every one of pauses,
+// in milliseconds, from 1s.
+func observeSLOPauses(t *sloTracker, pauses ...float64) {
+	for i, p := range pauses {
+		t.observe(&gctrace{ElapsedTime: float64(i + 1), STWMclock: p}, float64(i+1))
+	}
+}
+
+func TestSLOTracker(t *testing.T) {
+	o, err := parseSLO("max_stw<5ms over 3s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := newSLOTracker(o)
+
+	// The 8ms pause of 3s is in the windows of 3s to 6s, excluded.
+	observeSLOPauses(tracker, 1, 1, 8, 1, 1, 1, 1)
+	if len(tracker.violations) != 1 || tracker.violating() {
+		t.Fatalf("Expected a violation, over. Got %+v instead.", tracker.violations)
+	}
+	if v := tracker.violations[0]; v.From != 3 || v.To != 7 || v.Unit != "ms" {
+		t.Errorf("Expected a violation from 3s to 7s. Got %+v instead.", v)
+	}
+
+	r := tracker.report(1, 9)
+	if r.Violations != 1 || r.ViolatingSeconds != 4 || r.CompliancePercent != 50 || !r.Compliant {
+		t.Errorf("Expected 4s of violation over 8s. Got %+v instead.", r)
+	}
+	if r := tracker.report(8, 9); r.Violations != 0 || r.CompliancePercent != 100 {
+		t.Errorf("Expected no violation after 7s. Got %+v instead.", r)
+	}
+
+	observeSLOPauses(tracker, 1, 1, 1, 1, 1, 1, 1, 9)
+	if len(tracker.violations) != 2 || !tracker.violating() || !tracker.violations[1].Ongoing {
+		t.Errorf("Expected an ongoing violation. Got %+v instead.", tracker.violations)
+	}
+}
+
+func TestGraphSLOs(t *testing.T) {
+	defer func(objectives sloFlag) { sloObjectives = objectives }(sloObjectives)
+	sloObjectives = nil
+	if err := sloObjectives.Set("max_stw<3ms over 15s"); err != nil {
+		t.Fatal(err)
+	}
+
+	g := newSummaryGraph()
+	s := g.Summary(math.Inf(-1), math.Inf(1))
+	if len(s.SLOs) != 1 || s.SLOs[0].Violations != 1 || s.SLOs[0].Compliant {
+		t.Fatalf("Expected an ongoing violation. Got %+v instead.", s.SLOs)
+	}
+	// Pausing 3ms at 30s, up to 50s, over 40s.
+	if r := s.SLOs[0]; r.ViolatingSeconds != 20 || r.CompliancePercent != 50 {
+		t.Errorf("Expected the SLO to be met half of the time. Got %+v instead.", r)
+	}
+
+	snapshot := g.Snapshot()
+	if len(snapshot.SLOViolations) != 1 || snapshot.SLOViolations[0].From != 30 {
+		t.Errorf("Expected the violation on the graph, from 30s. Got %+v instead.", snapshot.SLOViolations)
+	}
+
+	restored := NewGraph("restored", GCVIS_TMPL)
+	restored.Restore(snapshot)
+	restored.AddGCTraceGraphPoint(&gctrace{ElapsedTime: 1, STWSclock: 1})
+	if v := restored.Snapshot().SLOViolations; len(v) != 1 || v[0].Ongoing {
+		t.Errorf("Expected the violation restored, over. Got %+v instead.", v)
+	}
+}
+
+func TestPromMetricsSLOs(t *testing.T) {
+	defer func(objectives sloFlag) { sloObjectives = objectives }(sloObjectives)
+	sloObjectives = nil
+	if err := sloObjectives.Set(`max_stw<1ms over 1m`); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newPromMetrics(map[string]string{"srv": "s"})
+	m.ConsumeGC(&gctrace{ElapsedTime: 1, STWMclock: 3})
+
+	var b bytes.Buffer
+	m.write(&b, false)
+	for _, expected := range []string{
+		"# TYPE gcvis_slo_violations_total counter\n",
+		`gcvis_slo_violations_total{srv="s",slo="max_stw<1ms over 1m"} 1` + "\n",
+		`gcvis_slo_compliant{srv="s",slo="max_stw<1ms over 1m"} 0` + "\n",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("Expected metrics to contain %q. Got:\n%s", expected, b.String())
+		}
+	}
+
+	b.Reset()
+	m.write(&b, true)
+	if !strings.Contains(b.String(), "# TYPE gcvis_slo_violations counter\n") {
+		t.Errorf("Expected the OpenMetrics counter without its suffix. Got:\n%s", b.String())
+	}
+}
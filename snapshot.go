@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// stateSnapshot is the state of a running gcvis, as served at
+// /api/v1/snapshot: the graph with its points and annotations, the summary
+// of the whole session, the alerts firing and what was run. Unlike a bundle,
+// it is plain JSON, meant to be archived or processed rather than imported.
+type stateSnapshot struct {
+	Metadata bundleMetadata // Exported being the time of the snapshot
+	Graph    *Graph
+	Summary  *windowSummary
+	Alerts   []*Alert // firing
+}
+
+// newStateSnapshot returns the snapshot of g, run with command if not nil.
+// It only holds the lock of g while copying it, so collection goes on.
+func newStateSnapshot(g *Graph, command []string) *stateSnapshot {
+	graph := g.Snapshot()
+	return &stateSnapshot{
+		Metadata: bundleMetadata{
+			Title:       graph.Title,
+			CommandLine: command,
+			Exported:    time.Now().UTC(),
+			Gcvis:       buildVersion(),
+		},
+		Graph:   graph,
+		Summary: g.Summary(math.Inf(-1), math.Inf(1)),
+		Alerts:  alertBanner.Firing(),
+	}
+}
+
+// handleSnapshot serves the snapshot of the session.
+func (h *HttpServer) handleSnapshot(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newStateSnapshot(h.graph, sessionCommand)); err != nil {
+		errorf("cannot write the snapshot: %v", err)
+	}
+}
+
+// runSnapshot writes the snapshot of a running gcvis, that of -i and -p
+// unless its URL is given.
+func runSnapshot(args []string) {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	out := fs.String("o", "", "path of the file to write the snapshot to, instead of the standard output")
+	fs.Parse(args)
+
+	url := fmt.Sprintf("http://%s:%s/", *iface, *port)
+	if fs.NArg() > 0 {
+		url = fs.Arg(0)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := fetchSnapshot(w, url); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// fetchSnapshot writes the snapshot of the gcvis served at url to w.
+func fetchSnapshot(w io.Writer, url string) error {
+	url = strings.TrimRight(url, "/") + "/api/v1/snapshot"
+
+	resp, err := (&http.Client{Timeout: pushTimeout}).Get(url)
+	if err != nil {
+		return fmt.Errorf("snapshot: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("snapshot: %s returned %s", url, resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 1, Heap1: 8})
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 2, ElapsedTime: 2, Heap1: 16})
+	graph.Annotate("deploy")
+	server := NewHttpServer("127.0.0.1", "0", graph)
+
+	go server.Start()
+	defer server.Close()
+
+	defer func(saved []string) { sessionCommand = saved }(sessionCommand)
+	sessionCommand = []string{"./server", "-v"}
+
+	var w bytes.Buffer
+	if err := fetchSnapshot(&w, server.Url()); err != nil {
+		t.Fatalf("fetchSnapshot returned an error: %v", err)
+	}
+
+	var snapshot struct {
+		Metadata bundleMetadata
+		Graph    struct {
+			Title       string
+			Annotations []Annotation
+			LastGC      *GCSummary
+		}
+		Summary struct {
+			NumGC int64 `json:"gc_count"`
+		}
+	}
+	if err := json.Unmarshal(w.Bytes(), &snapshot); err != nil {
+		t.Fatalf("Snapshot is not valid JSON: %v", err)
+	}
+
+	if snapshot.Metadata.Title != "fake title" || len(snapshot.Metadata.CommandLine) != 2 || snapshot.Metadata.Exported.IsZero() {
+		t.Errorf("Expected the metadata of the session. Got %+v instead.", snapshot.Metadata)
+	}
+	if len(snapshot.Graph.Annotations) != 1 || snapshot.Graph.LastGC == nil || snapshot.Graph.LastGC.HeapGoal != 16 {
+		t.Errorf("Expected the graph with its annotation. Got %s instead.", w.String())
+	}
+	if snapshot.Summary.NumGC != 2 {
+		t.Errorf("Expected a summary of 2 GCs. Got %d instead.", snapshot.Summary.NumGC)
+	}
+
+	// Collection goes on after a snapshot.
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 3, ElapsedTime: 3, Heap1: 32})
+	if s := graph.Summary(0, 3); s.NumGC != 3 {
+		t.Errorf("Expected 3 GCs after the snapshot. Got %d instead.", s.NumGC)
+	}
+}
+
+func TestFetchSnapshotError(t *testing.T) {
+	server := NewHttpServer("127.0.0.1", "0", NewGraph("fake title", GCVIS_TMPL))
+	url := server.Url()
+	server.Close()
+
+	if err := fetchSnapshot(&bytes.Buffer{}, url); err == nil {
+		t.Errorf("Expected a snapshot of a gcvis no longer running to fail.")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var splunkURL = flag.String("splunk-url", "", "base URL of a Splunk HTTP Event Collector to send events to, e.g. https://splunk:8088")
+var splunkToken = flag.String("splunk-token", "", "HTTP Event Collector token")
+var splunkSourcetype = flag.String("splunk-sourcetype", "gcvis", "sourcetype of the events sent to Splunk")
+var splunkIndex = flag.String("splunk-index", "", "Splunk index events are written to, instead of the token default")
+var splunkBatchSize = flag.Int("splunk-batch-size", 500, "maximum number of events sent in a single HTTP Event Collector request")
+var splunkBatchWait = flag.Duration("splunk-batch-wait", 5*time.Second, "maximum time an event waits before being sent to Splunk")
+
+func init() {
+	RegisterSink("splunk", newSplunkSink)
+}
+
+// splunkSink sends the log lines to a Splunk HTTP Event Collector, with
+// the labels as indexed fields.
+type splunkSink struct {
+	url    string
+	header http.Header
+	fields map[string]string
+
+	client  *http.Client
+	batcher *batcher
+}
+
+func newSplunkSink() (Sink, error) {
+	if *splunkURL == "" {
+		return nil, nil
+	}
+	if *splunkToken == "" {
+		return nil, fmt.Errorf("-splunk-token is required with -splunk-url")
+	}
+
+	labels := metricLabels()
+	delete(labels, "host")
+
+	s := &splunkSink{
+		url: strings.TrimRight(*splunkURL, "/") + "/services/collector/event",
+		header: http.Header{
+			"Content-Type":  {"application/json"},
+			"Authorization": {"Splunk " + *splunkToken},
+		},
+		fields: labels,
+		client: &http.Client{Timeout: pushTimeout},
+	}
+	s.batcher = newBatcher(*splunkBatchSize, *splunkBatchWait, s.send)
+
+	return s, nil
+}
+
+func (s *splunkSink) ConsumeGC(t *gctrace) error {
+	return s.add(newGCLogLine(t))
+}
+
+func (s *splunkSink) ConsumeScvg(t *scvgtrace) error {
+	return s.add(newScvgLogLine(t))
+}
+
+func (s *splunkSink) add(l *logLine) error {
+	event := map[string]interface{}{
+		"time":       float64(l.Time.UnixNano()) / float64(time.Second),
+		"source":     "gcvis",
+		"sourcetype": *splunkSourcetype,
+		"event":      l,
+		"fields":     s.fields,
+	}
+	if l.Host != "" {
+		event["host"] = l.Host
+	}
+	if *splunkIndex != "" {
+		event["index"] = *splunkIndex
+	}
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.batcher.Add(b)
+	return nil
+}
+
+func (s *splunkSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *splunkSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+// send posts the batch as concatenated JSON events, as the collector
+// expects.
+func (s *splunkSink) send(batch []interface{}) {
+	var body bytes.Buffer
+	for _, event := range batch {
+		body.Write(event.([]byte))
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d Splunk events", len(batch)), func() (bool, error) {
+		return postBody(s.client, s.url, s.header, body.Bytes())
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplunkSink(t *testing.T) {
+	var (
+		path, auth string
+		events     []map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path, auth = req.URL.Path, req.Header.Get("Authorization")
+
+		dec := json.NewDecoder(req.Body)
+		for dec.More() {
+			var event map[string]interface{}
+			if err := dec.Decode(&event); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			events = append(events, event)
+		}
+		w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer server.Close()
+
+	saved := []string{*splunkURL, *splunkToken, *splunkIndex}
+	*splunkURL, *splunkToken, *splunkIndex = server.URL, "secret", "gc"
+	defer func() { *splunkURL, *splunkToken, *splunkIndex = saved[0], saved[1], saved[2] }()
+
+	sink, err := newSplunkSink()
+	if err != nil {
+		t.Fatalf("newSplunkSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{Heap1: 33})
+	sink.ConsumeScvg(&scvgtrace{Inuse: 12})
+	sink.Close()
+
+	if path != "/services/collector/event" || auth != "Splunk secret" {
+		t.Errorf("Expected a request to the collector with the token. Got %s with %q instead.", path, auth)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events. Got %d instead.", len(events))
+	}
+
+	event := events[0]
+	if event["sourcetype"] != "gcvis" || event["index"] != "gc" || event["source"] != "gcvis" {
+		t.Errorf("Expected the gcvis sourcetype in the gc index. Got %v instead.", event)
+	}
+	if fields, _ := event["fields"].(map[string]interface{}); fields["srv"] != *serviceName {
+		t.Errorf("Expected the srv indexed field. Got %v instead.", event["fields"])
+	}
+	if gc, _ := event["event"].(map[string]interface{})["gc"].(map[string]interface{}); gc["HeapGoal"] != float64(33) {
+		t.Errorf("Expected the GC log line as event. Got %v instead.", event["event"])
+	}
+}
+
+func TestNewSplunkSinkWithoutToken(t *testing.T) {
+	saved := *splunkURL
+	*splunkURL = "https://splunk:8088"
+	defer func() { *splunkURL = saved }()
+
+	if _, err := newSplunkSink(); err == nil {
+		t.Errorf("Expected an error without a token.")
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var dbPath = flag.String("db", "", "path of a SQLite database to store every event in, and to serve past sessions from")
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id         INTEGER PRIMARY KEY,
+	title      TEXT NOT NULL,
+	started_at TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS gc (
+	session_id     INTEGER NOT NULL REFERENCES sessions(id),
+	time           TIMESTAMP NOT NULL,
+	elapsed        REAL NOT NULL, -- seconds since the session started
+	num_gc         INTEGER,
+	heap0          INTEGER,
+	heap1          INTEGER,
+	heap2          INTEGER,
+	heap3          INTEGER,
+	stws_clock     REAL,
+	mas_clock      REAL,
+	stwm_clock     REAL,
+	stws_cpu       REAL,
+	mas_assist_cpu REAL,
+	mas_bg_cpu     REAL,
+	mas_idle_cpu   REAL,
+	stwm_cpu       REAL,
+	raw            TEXT
+);
+CREATE INDEX IF NOT EXISTS gc_session ON gc (session_id, elapsed);
+CREATE TABLE IF NOT EXISTS scvg (
+	session_id INTEGER NOT NULL REFERENCES sessions(id),
+	time       TIMESTAMP NOT NULL,
+	elapsed    REAL NOT NULL,
+	inuse      INTEGER,
+	idle       INTEGER,
+	sys        INTEGER,
+	released   INTEGER,
+	consumed   INTEGER,
+	raw        TEXT
+);
+CREATE INDEX IF NOT EXISTS scvg_session ON scvg (session_id, elapsed);
+CREATE TABLE IF NOT EXISTS annotations (
+	session_id INTEGER NOT NULL REFERENCES sessions(id),
+	time       TIMESTAMP NOT NULL,
+	elapsed    REAL NOT NULL,
+	text       TEXT NOT NULL
+);
+`
+
+// sqlitePruneInterval is how often the events older than -retention are
+// deleted from the database.
+const sqlitePruneInterval = time.Minute
+
+// errNoSession is returned when annotating a database opened for reading
+// its past sessions only.
+var errNoSession = errors.New("no current session to annotate")
+
+// Session describes a gcvis run stored in the database.
+type Session struct {
+	ID        int64
+	Title     string
+	StartedAt time.Time
+}
+
+// SQLiteStore is a sink storing the events of the current session in a
+// SQLite database, which also keeps the past sessions, or their events of
+// the last retention if set.
+type SQLiteStore struct {
+	db      *sql.DB
+	session int64
+
+	retention time.Duration
+	pruned    time.Time // last time events were pruned
+}
+
+// OpenSQLiteStore opens the database at path, creating it if needed, and
+// starts a new session named title.
+func OpenSQLiteStore(path, title string) (*SQLiteStore, error) {
+	db, err := openSQLiteDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := db.Exec(`INSERT INTO sessions (title, started_at) VALUES (?, ?)`, title, StartTime.UTC())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	session, err := res.LastInsertId()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SQLiteStore{db: db, session: session, retention: *retention}
+	if err := s.prune(time.Now()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// prune deletes the events and annotations older than the retention of
+// the store, and the past sessions left without events, if it was not done
+// in the last sqlitePruneInterval.
+func (s *SQLiteStore) prune(now time.Time) error {
+	if s.retention <= 0 || now.Sub(s.pruned) < sqlitePruneInterval {
+		return nil
+	}
+	s.pruned = now
+
+	cutoff := now.Add(-s.retention).UTC()
+	for _, table := range []string{"gc", "scvg", "annotations"} {
+		if _, err := s.db.Exec(`DELETE FROM `+table+` WHERE time < ?`, cutoff); err != nil {
+			return err
+		}
+	}
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE id != ? AND started_at < ?
+		AND NOT EXISTS (SELECT 1 FROM gc WHERE session_id = sessions.id)
+		AND NOT EXISTS (SELECT 1 FROM scvg WHERE session_id = sessions.id)
+		AND NOT EXISTS (SELECT 1 FROM annotations WHERE session_id = sessions.id)`, s.session, cutoff)
+	return err
+}
+
+// OpenSQLiteHistory opens the database at path to read its sessions,
+// without starting a new one. Events cannot be stored nor annotated.
+func OpenSQLiteHistory(path string) (*SQLiteStore, error) {
+	db, err := openSQLiteDB(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+func openSQLiteDB(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (s *SQLiteStore) ConsumeGC(t *gctrace) error {
+	ts := traceTime(t.ElapsedTime)
+	_, err := s.db.Exec(`INSERT INTO gc VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.session, ts.UTC(), ts.Sub(StartTime).Seconds(),
+		t.NumGC, t.Heap0, t.Heap1, t.Heap2, t.Heap3,
+		t.STWSclock, t.MASclock, t.STWMclock,
+		t.STWScpu, t.MASAssistcpu, t.MASBGcpu, t.MASIdlecpu, t.STWMcpu,
+		t.Raw,
+	)
+	if err != nil {
+		return err
+	}
+	return s.prune(time.Now())
+}
+
+func (s *SQLiteStore) ConsumeScvg(t *scvgtrace) error {
+	ts := traceTime(t.ElapsedTime)
+	_, err := s.db.Exec(`INSERT INTO scvg VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.session, ts.UTC(), ts.Sub(StartTime).Seconds(),
+		t.Inuse, t.Idle, t.Sys, t.Released, t.Consumed,
+		t.Raw,
+	)
+	if err != nil {
+		return err
+	}
+	return s.prune(time.Now())
+}
+
+// Annotate attaches text to the current time of the current session.
+func (s *SQLiteStore) Annotate(text string) error {
+	if s.session == 0 {
+		return errNoSession
+	}
+	now := time.Now()
+	_, err := s.db.Exec(`INSERT INTO annotations VALUES (?, ?, ?, ?)`,
+		s.session, now.UTC(), now.Sub(StartTime).Seconds(), text,
+	)
+	return err
+}
+
+// Sessions lists the stored sessions, most recent first.
+func (s *SQLiteStore) Sessions() ([]Session, error) {
+	rows, err := s.db.Query(`SELECT id, title, started_at FROM sessions ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.ID, &session.Title, &session.StartedAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// SessionGraph rebuilds the graph of the session id from the stored
+// events. It returns a nil graph if there is no such session.
+func (s *SQLiteStore) SessionGraph(id int64, tmpl string) (*Graph, error) {
+	var title string
+	var started time.Time
+	err := s.db.QueryRow(`SELECT title, started_at FROM sessions WHERE id = ?`, id).Scan(&title, &started)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	graph := NewGraph(title, tmpl)
+	graph.Epoch = float64(started.UnixNano()) / 1e9
+
+	rows, err := s.db.Query(`SELECT elapsed, num_gc, heap0, heap1, heap2, heap3,
+		stws_clock, mas_clock, stwm_clock, stws_cpu, mas_assist_cpu, mas_bg_cpu, mas_idle_cpu, stwm_cpu
+		FROM gc WHERE session_id = ? ORDER BY elapsed`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t gctrace
+		if err := rows.Scan(&t.ElapsedTime, &t.NumGC, &t.Heap0, &t.Heap1, &t.Heap2, &t.Heap3,
+			&t.STWSclock, &t.MASclock, &t.STWMclock, &t.STWScpu, &t.MASAssistcpu, &t.MASBGcpu, &t.MASIdlecpu, &t.STWMcpu); err != nil {
+			return nil, err
+		}
+		graph.AddGCTraceGraphPoint(&t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT elapsed, inuse, idle, sys, released, consumed
+		FROM scvg WHERE session_id = ? ORDER BY elapsed`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t scvgtrace
+		if err := rows.Scan(&t.ElapsedTime, &t.Inuse, &t.Idle, &t.Sys, &t.Released, &t.Consumed); err != nil {
+			return nil, err
+		}
+		graph.AddScavengerGraphPoint(&t)
+	}
+
+	return graph.Snapshot(), rows.Err()
+}
+
+func (s *SQLiteStore) Flush() error {
+	return nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStoreSessionGraph(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gcvis.db")
+
+	store, err := OpenSQLiteStore(path, "first")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := store.ConsumeGC(&gctrace{ElapsedTime: 1, NumGC: 1, Heap0: 4, Heap1: 8, Heap3: 3, Raw: "gc 1"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := store.ConsumeScvg(&scvgtrace{ElapsedTime: 2, Inuse: 5, Raw: "scvg"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := store.Annotate("deploy"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	first := store.session
+	store.Close()
+
+	store, err = OpenSQLiteStore(path, "second")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer store.Close()
+
+	sessions, err := store.Sessions()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Title != "second" || sessions[1].Title != "first" {
+		t.Fatalf("Expected sessions second and first. Got %+v instead.", sessions)
+	}
+
+	graph, err := store.SessionGraph(first, GCVIS_TMPL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if graph.Title != "first" {
+		t.Errorf("Expected title first. Got %q instead.", graph.Title)
+	}
+	if started := time.Unix(0, int64(graph.Epoch*1e9)); started.Sub(StartTime).Abs() > time.Millisecond {
+		t.Errorf("Expected the epoch of the session to be its start, %v. Got %v instead.", StartTime, started)
+	}
+	if points := graph.HeapUse.Points(); len(points) != 1 || points[0][1] != 8 {
+		t.Errorf("Expected one heap goal point of 8. Got %+v instead.", points)
+	}
+	if points := graph.ScvgInuse.Points(); len(points) != 1 || points[0][1] != 5 {
+		t.Errorf("Expected one scavenger inuse point of 5. Got %+v instead.", points)
+	}
+
+	graph, err = store.SessionGraph(first+100, GCVIS_TMPL)
+	if err != nil || graph != nil {
+		t.Errorf("Expected no graph for an unknown session. Got %v, %v instead.", graph, err)
+	}
+}
+
+func TestHttpServerSessions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := OpenSQLiteStore(filepath.Join(dir, "gcvis.db"), "session")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer store.Close()
+	store.ConsumeGC(&gctrace{ElapsedTime: 1, Heap1: 10})
+
+	server := NewHttpServer("127.0.0.1", "0", NewGraph("current", GCVIS_TMPL))
+	server.SetHistory(store)
+
+	go server.Start()
+	defer server.Close()
+
+	response, err := http.Get(server.Url() + "sessions.json")
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	var sessions []Session
+	err = json.NewDecoder(response.Body).Decode(&sessions)
+	response.Body.Close()
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("Expected one session. Got %+v, %v instead.", sessions, err)
+	}
+
+	response, err = http.Get(server.Url() + "sessions/" + strconv.FormatInt(sessions[0].ID, 10) + "/graph.json")
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	var graph Graph
+	err = json.NewDecoder(response.Body).Decode(&graph)
+	response.Body.Close()
+	if err != nil || graph.HeapUse.Len() != 1 || graph.HeapUse.Points()[0][1] != 10 {
+		t.Errorf("Expected one heap goal point of 10. Got %+v, %v instead.", graph.HeapUse.Points(), err)
+	}
+
+	response, err = http.Get(server.Url() + "sessions/42/")
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown session. Got %d instead.", response.StatusCode)
+	}
+
+	response, err = http.PostForm(server.Url()+"annotations", url.Values{"text": {"deploy"}})
+	if err != nil {
+		t.Fatalf("HTTP request returned an error: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status 204. Got %d instead.", response.StatusCode)
+	}
+}
+
+func TestSQLiteStoreRetention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gcvis.db")
+
+	store, err := OpenSQLiteStore(path, "old")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store.ConsumeGC(&gctrace{ElapsedTime: 1, NumGC: 1, Heap1: 8})
+	store.Annotate("deploy")
+	store.Close()
+
+	defer func(saved time.Duration) { *retention = saved }(*retention)
+	*retention = time.Hour
+	store, err = OpenSQLiteStore(path, "current")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer store.Close()
+	store.ConsumeGC(&gctrace{ElapsedTime: 2, NumGC: 1, Heap1: 8})
+	if sessions, _ := store.Sessions(); len(sessions) != 2 {
+		t.Fatalf("Expected the events of the last hour to be kept. Got %+v instead.", sessions)
+	}
+
+	if err := store.prune(time.Now().Add(2 * time.Hour)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sessions, err := store.Sessions()
+	if err != nil || len(sessions) != 1 || sessions[0].Title != "current" {
+		t.Errorf("Expected the old session to be deleted. Got %+v, %v instead.", sessions, err)
+	}
+	graph, err := store.SessionGraph(store.session, GCVIS_TMPL)
+	if err != nil || len(graph.HeapUse.Points()) != 0 {
+		t.Errorf("Expected the events older than an hour to be deleted. Got %v instead.", err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var startTime = flag.String("start-time", "", "RFC 3339 time the traced program started, e.g. 2026-10-15T09:12:00.250Z, the elapsed times of its traces counting from it. Defaults to the time the program run was started, or gcvis started when reading traces from the standard input or a file")
+
+// traceStart is the time the elapsed times of the traces count from, in
+// nanoseconds since the epoch: -start-time, the start of the program run,
+// or StartTime. It is set by the goroutine running the program, hence
+// atomic.
+var traceStart = StartTime.UnixNano()
+
+// traceStartTime returns the time the elapsed times of the traces count
+// from.
+func traceStartTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&traceStart))
+}
+
+// programStarted makes the elapsed times of the traces count from t, the
+// time the program run started, unless -start-time is set.
+func programStarted(t time.Time) {
+	if *startTime == "" {
+		atomic.StoreInt64(&traceStart, t.UnixNano())
+	}
+}
+
+// applyStartTime makes the elapsed times of the traces count from
+// -start-time, if set.
+func applyStartTime() error {
+	if *startTime == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, *startTime)
+	if err != nil {
+		return fmt.Errorf("invalid -start-time %q, expected an RFC 3339 time: %v", *startTime, err)
+	}
+	atomic.StoreInt64(&traceStart, t.UnixNano())
+	return nil
+}
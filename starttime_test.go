@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProgramStarted(t *testing.T) {
+	defer atomic.StoreInt64(&traceStart, atomic.LoadInt64(&traceStart))
+
+	started := StartTime.Add(300 * time.Millisecond)
+	programStarted(started)
+	if at := traceTime(1.5); !at.Equal(started.Add(1500 * time.Millisecond)) {
+		t.Errorf("Expected traces to be timed from the start of the program. Got %v instead.", at)
+	}
+}
+
+func TestApplyStartTime(t *testing.T) {
+	defer atomic.StoreInt64(&traceStart, atomic.LoadInt64(&traceStart))
+	defer func(saved string) { *startTime = saved }(*startTime)
+
+	*startTime = "2026-10-15T09:12:00.250Z"
+	if err := applyStartTime(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	programStarted(time.Now())
+
+	expected := time.Date(2026, 10, 15, 9, 12, 2, 250e6, time.UTC)
+	if at := traceTime(2); !at.Equal(expected) {
+		t.Errorf("Expected traces to be timed from -start-time. Got %v instead.", at)
+	}
+
+	*startTime = "yesterday"
+	if err := applyStartTime(); err == nil {
+		t.Errorf("Expected an invalid -start-time to be rejected.")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// runStats sums up the GC events of a whole run, for the checks and
+// summaries made when gcvis exits.
+type runStats struct {
+	started     time.Time
+	first, last float64 // elapsed time of the first and last GC traces, in seconds
+
+	NumGC   int64
+	Forced  int64     // cycles forced, e.g. by runtime.GC
+	pauses  []float64 // stop the world time of each cycle, in milliseconds
+	HeapMin int64     // smallest live heap after a cycle, in megabytes
+	HeapMax int64     // largest heap size at the start of a cycle, in megabytes
+	liveMax int64     // largest live heap after a cycle, in megabytes
+	RSSMax  int64     // largest resident memory of the program sampled, in megabytes
+
+	// allocated approximates the megabytes allocated between the first
+	// and the last cycles: the growth of the heap from the live heap
+	// after a cycle to the heap at the start of the next one.
+	allocated int64
+	lastLive  int64
+
+	liveTotal  int64     // sum of the live heaps after the cycles, in megabytes
+	clock      float64   // wall-clock time of the cycles after the first, in milliseconds
+	goalRatios []float64 // heap goal of each cycle over the live heap of the one before
+
+	NumScvg      int64
+	ScvgReleased int64 // memory returned to the operating system, as last reported by the scavenger, in megabytes
+
+	benchmarks benchmarkTracker // of go test -bench
+}
+
+func newRunStats() *runStats {
+	return &runStats{started: time.Now()}
+}
+
+func (s *runStats) addGC(t *gctrace) {
+	if s.NumGC == 0 || t.Heap3 < s.HeapMin {
+		s.HeapMin = t.Heap3
+	}
+	if t.Heap0 > s.HeapMax {
+		s.HeapMax = t.Heap0
+	}
+	if t.Heap3 > s.liveMax {
+		s.liveMax = t.Heap3
+	}
+	if s.NumGC == 0 {
+		s.first = t.ElapsedTime
+	} else {
+		if t.Heap0 > s.lastLive {
+			s.allocated += t.Heap0 - s.lastLive
+		}
+		s.clock += t.STWSclock + t.MASclock + t.STWMclock
+		if s.lastLive >= gogcMinLive && t.Heap1 > 0 {
+			s.goalRatios = append(s.goalRatios, float64(t.Heap1)/float64(s.lastLive))
+		}
+	}
+	s.last = t.ElapsedTime
+	s.lastLive = t.Heap3
+	s.liveTotal += t.Heap3
+
+	s.NumGC++
+	if t.Forced {
+		s.Forced++
+	}
+	s.pauses = append(s.pauses, t.STWSclock+t.STWMclock)
+	s.benchmarks.observeGC(t)
+}
+
+func (s *runStats) addScvg(t *scvgtrace) {
+	s.NumScvg++
+	s.ScvgReleased = t.Released
+}
+
+// addOutput adds a line of output of the program that is not a trace.
+func (s *runStats) addOutput(line string) {
+	s.benchmarks.observeLine(line)
+}
+
+// addRSS adds a sample of the resident memory of the program, in
+// megabytes.
+func (s *runStats) addRSS(mb int64) {
+	if mb > s.RSSMax {
+		s.RSSMax = mb
+	}
+}
+
+// Duration returns the time between the first and the last GC traces, or
+// since the run started if the traces do not tell when they were written.
+func (s *runStats) Duration() time.Duration {
+	if s.last > 0 {
+		return time.Duration((s.last - s.first) * float64(time.Second))
+	}
+	return time.Since(s.started)
+}
+
+// pausePercentile returns the p-th percentile, by nearest rank, of the
+// stop the world time of the cycles.
+func (s *runStats) pausePercentile(p float64) float64 {
+	if len(s.pauses) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), s.pauses...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// allocRate returns the megabytes allocated per second between the first
+// and the last cycles.
+func (s *runStats) allocRate() float64 {
+	d := s.Duration().Seconds()
+	if s.NumGC < 2 || d <= 0 {
+		return 0
+	}
+	return float64(s.allocated) / d
+}
+
+func (s *runStats) totalPause() float64 {
+	total := 0.0
+	for _, p := range s.pauses {
+		total += p
+	}
+	return total
+}
+
+func (s *runStats) avgPause() float64 {
+	if len(s.pauses) == 0 {
+		return 0
+	}
+	return s.totalPause() / float64(len(s.pauses))
+}
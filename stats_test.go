@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunStats(t *testing.T) {
+	s := newRunStats()
+	for i, pause := range []float64{1, 4, 2, 8, 3} {
+		s.addGC(&gctrace{
+			ElapsedTime: float64(10 + i),
+			STWSclock:   pause / 2,
+			STWMclock:   pause / 2,
+			Heap0:       int64(100 + 10*i),
+			Heap3:       int64(50 - i),
+		})
+	}
+
+	if s.NumGC != 5 || s.HeapMax != 140 || s.HeapMin != 46 {
+		t.Errorf("Expected 5 cycles, heaps of 46 to 140MB. Got %d cycles, %d to %dMB instead.", s.NumGC, s.HeapMin, s.HeapMax)
+	}
+	if d := s.Duration(); d != 4*time.Second {
+		t.Errorf("Expected a duration of 4s. Got %v instead.", d)
+	}
+
+	for p, expected := range map[float64]float64{50: 3, 80: 4, 99: 8, 100: 8, 0: 1} {
+		if v := s.pausePercentile(p); v != expected {
+			t.Errorf("Expected a p%v pause of %v. Got %v instead.", p, expected, v)
+		}
+	}
+	if s.totalPause() != 18 || s.avgPause() != 3.6 {
+		t.Errorf("Expected a total pause of 18ms, 3.6ms on average. Got %v, %v instead.", s.totalPause(), s.avgPause())
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// statsdMaxPacket keeps datagrams under the common 1500 bytes MTU.
+const statsdMaxPacket = 1432
+
+var statsdAddr = flag.String("statsd-addr", "", "host:port of a StatsD server to send metrics to over UDP")
+var statsdPrefix = flag.String("statsd-prefix", "", "prefix prepended to the StatsD metric names")
+var statsdTags = flag.Bool("statsd-tags", false, "send labels as DogStatsD tags, with the Datadog unified service tags and origin detection")
+
+func init() {
+	RegisterSink("statsd", newStatsdSink)
+}
+
+// statsdSink sends metrics to StatsD, or DogStatsD when tags are turned on.
+// Timings are sent as timers so percentiles are computed server side, the
+// other metrics as gauges.
+type statsdSink struct {
+	conn   net.Conn
+	prefix string
+	tags   string
+}
+
+func newStatsdSink() (Sink, error) {
+	if *statsdAddr == "" {
+		return nil, nil
+	}
+
+	conn, err := net.Dial("udp", *statsdAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &statsdSink{conn: conn, prefix: *statsdPrefix}
+	if *statsdTags {
+		labels := metricLabels()
+		tags := make([]string, 0, len(labels))
+		for _, name := range sortedLabelNames(labels) {
+			tags = append(tags, name+":"+labels[name])
+		}
+		for _, tag := range datadogTags() {
+			if _, ok := labels[strings.SplitN(tag, ":", 2)[0]]; !ok {
+				tags = append(tags, tag)
+			}
+		}
+		s.tags = "|#" + strings.Join(tags, ",")
+	}
+
+	return s, nil
+}
+
+func (s *statsdSink) ConsumeGC(t *gctrace) error {
+	return s.send(gcMetrics(t))
+}
+
+func (s *statsdSink) ConsumeScvg(t *scvgtrace) error {
+	return s.send(scvgMetrics(t))
+}
+
+// send writes metrics, packing as many of them as possible in each
+// datagram.
+func (s *statsdSink) send(metrics []metric) error {
+	var packet bytes.Buffer
+	for _, m := range metrics {
+		line := s.format(m)
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+			if _, err := s.conn.Write(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+
+	if packet.Len() == 0 {
+		return nil
+	}
+	_, err := s.conn.Write(packet.Bytes())
+	return err
+}
+
+func (s *statsdSink) format(m metric) string {
+	kind := "g"
+	if strings.HasSuffix(m.Name, "_milliseconds") {
+		kind = "ms"
+	}
+
+	return s.prefix + m.Name + ":" + strconv.FormatFloat(m.Value, 'f', -1, 64) + "|" + kind + s.tags
+}
+
+func (s *statsdSink) Flush() error {
+	return nil
+}
+
+func (s *statsdSink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsdSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket returned an error: %v", err)
+	}
+	defer conn.Close()
+
+	savedAddr, savedTags := *statsdAddr, *statsdTags
+	*statsdAddr, *statsdTags = conn.LocalAddr().String(), true
+	defer func() { *statsdAddr, *statsdTags = savedAddr, savedTags }()
+
+	sink, err := newStatsdSink()
+	if err != nil {
+		t.Fatalf("newStatsdSink returned an error: %v", err)
+	}
+	defer sink.Close()
+
+	if err := sink.ConsumeGC(&gctrace{Heap1: 33, STWSclock: 0.11}); err != nil {
+		t.Fatalf("ConsumeGC returned an error: %v", err)
+	}
+
+	buf := make([]byte, statsdMaxPacket)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom returned an error: %v", err)
+	}
+
+	lines := strings.Split(string(buf[:n]), "\n")
+	if len(lines) != len(gcMetrics(&gctrace{})) {
+		t.Errorf("Expected every metric in a single datagram. Got %d lines instead.", len(lines))
+	}
+
+	packet := string(buf[:n])
+	if !strings.Contains(packet, "gcvis_heap_goal_megabytes:33|g|#") {
+		t.Errorf("Expected a heap goal gauge. Got %q instead.", packet)
+	}
+	if !strings.Contains(packet, "gcvis_stw_sweep_clock_milliseconds:0.11|ms|#") {
+		t.Errorf("Expected a STW sweep timer. Got %q instead.", packet)
+	}
+	if !strings.Contains(packet, "srv:"+*serviceName) {
+		t.Errorf("Expected the service tag. Got %q instead.", packet)
+	}
+}
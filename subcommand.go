@@ -2,10 +2,10 @@ package main
 
 import (
 	"io"
-	"log"
 	"os"
 	"os/exec"
 	"sync"
+	"time"
 )
 
 type SubCommand struct {
@@ -13,6 +13,7 @@ type SubCommand struct {
 	PipeRead  io.ReadCloser
 	pipeWrite io.WriteCloser
 	err       error
+	pid       int
 
 	errMtx sync.Mutex
 }
@@ -20,7 +21,7 @@ type SubCommand struct {
 func NewSubCommand(args []string) *SubCommand {
 	pipeRead, pipeWrite, err := os.Pipe()
 	if err != nil {
-		log.Fatal(err)
+		fatalf("%v", err)
 	}
 
 	env := append(os.Environ(), "GODEBUG=gctrace=1")
@@ -37,9 +38,33 @@ func NewSubCommand(args []string) *SubCommand {
 	}
 }
 
+// CaptureStdout reads the standard output of the program along with its
+// standard error, for the TUI to show it rather than the program to write
+// over it. It must be called before Run.
+func (s *SubCommand) CaptureStdout() {
+	s.cmd.Stdout = s.pipeWrite
+}
+
 func (s *SubCommand) Run() {
-	s.setErr(s.cmd.Run())
-	s.pipeWrite.Close()
+	defer s.pipeWrite.Close()
+
+	if err := s.cmd.Start(); err != nil {
+		s.setErr(err)
+		return
+	}
+	programStarted(time.Now())
+	s.errMtx.Lock()
+	s.pid = s.cmd.Process.Pid
+	s.errMtx.Unlock()
+
+	s.setErr(s.cmd.Wait())
+}
+
+// Pid returns the process id of the program, or 0 until it has started.
+func (s *SubCommand) Pid() int {
+	s.errMtx.Lock()
+	defer s.errMtx.Unlock()
+	return s.pid
 }
 
 func (s *SubCommand) Err() error {
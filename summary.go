@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"sync"
+	"text/template"
+	"time"
+)
+
+var summaryInterval = flag.Duration("summary-interval", 0, "how often to write a log line summing up the GC events of the interval, e.g. 1m. 0 turns summaries off")
+var summaryOut = flag.String("summary-out", "stderr", "where to write summary log lines: stderr, stdout or a file path")
+
+// summaryMessage is the message of the summary log lines.
+const summaryMessage = "garbage collection summary"
+
+func init() {
+	RegisterSink("summary", newSummarySink)
+}
+
+// summaryFields sum up the GC events of an interval. Pauses are the stop
+// the world phases of the cycles, in milliseconds.
+type summaryFields struct {
+	IntervalSeconds float64
+	NumGC           int64 // cycles in the interval
+	TotalPause      float64
+	MaxPause        float64
+	HeapHighWater   int64 // largest heap size at the start of a cycle, in megabytes
+}
+
+// summarySink writes a log line summing up the GC events every interval,
+// far fewer lines than one per event for services collecting garbage many
+// times a second.
+type summarySink struct {
+	w    io.WriteCloser
+	tmpl *template.Template
+
+	mu      sync.Mutex
+	current summaryFields
+	started time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newSummarySink() (Sink, error) {
+	if *summaryInterval <= 0 {
+		return nil, nil
+	}
+
+	var tmpl *template.Template
+	if *logFormat != "" {
+		var err error
+		if tmpl, err = parseLogFormat(*logFormat); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := openOutput(*summaryOut)
+	if err != nil || w == nil {
+		return nil, err
+	}
+
+	s := &summarySink{
+		w:       w,
+		tmpl:    tmpl,
+		started: time.Now(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go s.summarizePeriodically(*summaryInterval)
+
+	return s, nil
+}
+
+func (s *summarySink) ConsumeGC(t *gctrace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.current.add(t)
+	return nil
+}
+
+// add sums t up with the GC events already summed up.
+func (f *summaryFields) add(t *gctrace) {
+	f.NumGC++
+	f.TotalPause += t.STWSclock + t.STWMclock
+	if t.STWSclock > f.MaxPause {
+		f.MaxPause = t.STWSclock
+	}
+	if t.STWMclock > f.MaxPause {
+		f.MaxPause = t.STWMclock
+	}
+	if t.Heap0 > f.HeapHighWater {
+		f.HeapHighWater = t.Heap0
+	}
+}
+
+func (s *summarySink) ConsumeScvg(t *scvgtrace) error {
+	return nil
+}
+
+func (s *summarySink) summarizePeriodically(interval time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				errorf("cannot write summary: %v", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Flush writes the summary of the current interval, and starts a new one.
+func (s *summarySink) Flush() error {
+	s.mu.Lock()
+	summary := s.current
+	now := time.Now()
+	summary.IntervalSeconds = now.Sub(s.started).Seconds()
+	s.current, s.started = summaryFields{}, now
+	s.mu.Unlock()
+
+	return s.write(newSummaryLogLine(&summary, now))
+}
+
+func (s *summarySink) write(l *logLine) error {
+	if s.tmpl == nil {
+		return writeLogLine(s.w, l)
+	}
+	return writeLogLineTemplate(s.w, s.tmpl, l)
+}
+
+// Close writes the summary of the last, partial, interval.
+func (s *summarySink) Close() error {
+	close(s.stop)
+	<-s.done
+
+	err := s.Flush()
+	if cerr := s.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func newSummaryLogLine(summary *summaryFields, t time.Time) *logLine {
+	l := newLogLine(summaryMessage, 0)
+	l.Time = exportTime(t)
+	l.Summary = summary
+
+	return l
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarySink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gcvis")
+	if err != nil {
+		t.Fatalf("TempDir returned an error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "summary.log")
+	savedInterval, savedOut := *summaryInterval, *summaryOut
+	*summaryInterval, *summaryOut = time.Hour, path
+	defer func() { *summaryInterval, *summaryOut = savedInterval, savedOut }()
+
+	sink, err := newSummarySink()
+	if err != nil {
+		t.Fatalf("newSummarySink returned an error: %v", err)
+	}
+
+	sink.ConsumeGC(&gctrace{Heap0: 40, STWSclock: 0.5, STWMclock: 1.25})
+	sink.ConsumeGC(&gctrace{Heap0: 60, STWSclock: 2, STWMclock: 0.25})
+	sink.ConsumeScvg(&scvgtrace{Inuse: 100})
+	sink.Flush()
+	sink.ConsumeGC(&gctrace{Heap0: 10, STWSclock: 0.125, STWMclock: 0.125})
+	sink.Close()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile returned an error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a summary per interval. Got %q instead.", lines)
+	}
+
+	var l logLine
+	if err := json.Unmarshal([]byte(lines[0]), &l); err != nil {
+		t.Fatalf("Summary is not valid JSON: %v", err)
+	}
+	expected := summaryFields{NumGC: 2, TotalPause: 4, MaxPause: 2, HeapHighWater: 60}
+	l.Summary.IntervalSeconds = 0
+	if l.Message != summaryMessage || *l.Summary != expected {
+		t.Errorf("Expected summary %+v. Got %q %+v instead.", expected, l.Message, l.Summary)
+	}
+
+	if err := json.Unmarshal([]byte(lines[1]), &l); err != nil {
+		t.Fatalf("Summary is not valid JSON: %v", err)
+	}
+	if l.Summary.NumGC != 1 || l.Summary.HeapHighWater != 10 {
+		t.Errorf("Expected the last interval to be summed up on its own. Got %+v instead.", l.Summary)
+	}
+}
+
+func TestSummarySinkOff(t *testing.T) {
+	sink, err := newSummarySink()
+	if sink != nil || err != nil {
+		t.Errorf("Expected no summary sink by default. Got %v, %v instead.", sink, err)
+	}
+}
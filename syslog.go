@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var syslogAddr = flag.String("syslog", "", "where to send RFC 5424 syslog messages: local for /dev/log, or a udp://, tcp:// or tls:// host:port URL")
+var syslogFacility = flag.String("syslog-facility", "daemon", "syslog facility of the messages, e.g. daemon, user or local0")
+
+// syslogBatchSize bounds the number of messages written at once.
+const syslogBatchSize = 100
+
+// syslogEnterpriseID is the private enterprise number reserved for
+// documentation, which SD-IDs of unregistered structured data elements must
+// carry.
+const syslogEnterpriseID = "32473"
+
+// syslogTimeFormat is RFC 3339 with at most microseconds, as RFC 5424
+// requires.
+const syslogTimeFormat = "2006-01-02T15:04:05.999999Z07:00"
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func init() {
+	RegisterSink("syslog", newSyslogSink)
+}
+
+// syslogSink sends every event as an RFC 5424 message, its fields and
+// labels as structured data elements, e.g.
+//
+//	<30>1 2021-11-03T14:21:38.783Z host gcvis - gc [gc@32473 HeapGoal="4" ...][labels@32473 srv="api"] garbage collection event
+//
+// Stream transports use octet counting framing. Like the Graphite sink, the
+// connection is opened lazily and opened again after a failure.
+type syslogSink struct {
+	network string // udp, tcp, tls or unixgram
+	addr    string
+	pri     int // facility * 8 + severity
+
+	conn    net.Conn
+	batcher *batcher
+}
+
+func newSyslogSink() (Sink, error) {
+	if *syslogAddr == "" {
+		return nil, nil
+	}
+
+	facility, ok := syslogFacilities[*syslogFacility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", *syslogFacility)
+	}
+
+	s := &syslogSink{pri: facility*8 + 6} // severity informational
+	if *syslogAddr == "local" {
+		s.network, s.addr = "unixgram", "/dev/log"
+	} else {
+		u, err := url.Parse(*syslogAddr)
+		if err != nil {
+			return nil, err
+		}
+		switch u.Scheme {
+		case "udp", "tcp", "tls":
+		default:
+			return nil, fmt.Errorf("invalid syslog address %q, expected local or a udp://, tcp:// or tls:// URL", *syslogAddr)
+		}
+		s.network, s.addr = u.Scheme, u.Host
+		if u.Port() == "" {
+			port := "514"
+			if u.Scheme == "tls" {
+				port = "6514"
+			}
+			s.addr = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+	s.batcher = newBatcher(syslogBatchSize, time.Second, s.send)
+
+	return s, nil
+}
+
+func (s *syslogSink) ConsumeGC(t *gctrace) error {
+	return s.add(newGCLogLine(t))
+}
+
+func (s *syslogSink) ConsumeScvg(t *scvgtrace) error {
+	return s.add(newScvgLogLine(t))
+}
+
+func (s *syslogSink) add(l *logLine) error {
+	msg, err := s.format(l)
+	if err != nil {
+		return err
+	}
+
+	s.batcher.Add(msg)
+	return nil
+}
+
+func (s *syslogSink) format(l *logLine) (string, error) {
+	kind, fields, err := eventFields(l)
+	if err != nil {
+		return "", err
+	}
+
+	host := l.Host
+	if host == "" {
+		host = "-"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s gcvis - %s ", s.pri, l.Time.Format(syslogTimeFormat), host, kind)
+
+	b.WriteString("[" + kind + "@" + syslogEnterpriseID)
+	for _, name := range sortedFieldNames(fields) {
+		writeSyslogParam(&b, name, strconv.FormatFloat(fields[name], 'f', -1, 64))
+	}
+	b.WriteString("]")
+
+	labels := metricLabels()
+	delete(labels, "host")
+	b.WriteString("[labels@" + syslogEnterpriseID)
+	for _, name := range sortedLabelNames(labels) {
+		writeSyslogParam(&b, name, labels[name])
+	}
+	b.WriteString("]")
+
+	b.WriteString(" " + l.Message)
+	return b.String(), nil
+}
+
+// writeSyslogParam writes a PARAM-NAME="PARAM-VALUE" pair, replacing the
+// characters not allowed in names and escaping the values.
+func writeSyslogParam(b *strings.Builder, name, value string) {
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r >= 127 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+
+	b.WriteString(" " + name + `="` + syslogParamEscaper.Replace(value) + `"`)
+}
+
+var syslogParamEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+func (s *syslogSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	s.batcher.Close()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// send writes the messages of batch, dialing again after a failure.
+func (s *syslogSink) send(batch []interface{}) {
+	retryWithBackoff(fmt.Sprintf("%d syslog messages", len(batch)), func() (bool, error) {
+		if s.conn == nil {
+			conn, err := s.dial()
+			if err != nil {
+				return true, err
+			}
+			s.conn = conn
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(pushTimeout))
+		if err := s.write(batch); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+func (s *syslogSink) dial() (net.Conn, error) {
+	if s.network == "tls" {
+		return tls.DialWithDialer(&net.Dialer{Timeout: pushTimeout}, "tcp", s.addr, nil)
+	}
+	return net.DialTimeout(s.network, s.addr, pushTimeout)
+}
+
+// write sends a datagram per message, or the octet counted messages on
+// streams.
+func (s *syslogSink) write(batch []interface{}) error {
+	if s.network == "udp" || s.network == "unixgram" {
+		for _, msg := range batch {
+			if _, err := s.conn.Write([]byte(msg.(string))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var body bytes.Buffer
+	for _, msg := range batch {
+		fmt.Fprintf(&body, "%d %s", len(msg.(string)), msg)
+	}
+	_, err := s.conn.Write(body.Bytes())
+	return err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket returned an error: %v", err)
+	}
+	defer conn.Close()
+
+	savedAddr, savedName := *syslogAddr, *serviceName
+	*syslogAddr, *serviceName = "udp://"+conn.LocalAddr().String(), "my]service"
+	defer func() { *syslogAddr, *serviceName = savedAddr, savedName }()
+
+	sink, err := newSyslogSink()
+	if err != nil {
+		t.Fatalf("newSyslogSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{Heap1: 33, STWMclock: 0.5})
+	sink.Close()
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom returned an error: %v", err)
+	}
+	msg := string(buf[:n])
+
+	if !strings.HasPrefix(msg, "<30>1 ") {
+		t.Errorf("Expected the daemon.info priority and version 1. Got %q instead.", msg)
+	}
+	for _, expected := range []string{
+		" gcvis - gc [gc@32473 ",
+		` HeapGoal="33"`,
+		` STWMclock="0.5"`,
+		`[labels@32473 srv="my\]service"]`,
+		"] " + gcMessage,
+	} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected message to contain %q. Got %q instead.", expected, msg)
+		}
+	}
+}
+
+func TestSyslogSinkTCPFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned an error: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		length, _ := r.ReadString(' ')
+		received <- length
+	}()
+
+	saved := *syslogAddr
+	*syslogAddr = "tcp://" + listener.Addr().String()
+	defer func() { *syslogAddr = saved }()
+
+	sink, err := newSyslogSink()
+	if err != nil {
+		t.Fatalf("newSyslogSink returned an error: %v", err)
+	}
+	s := sink.(*syslogSink)
+
+	l := newScvgLogLine(&scvgtrace{Inuse: 1})
+	msg, err := s.format(l)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s.add(l)
+	s.Close()
+
+	if length := <-received; length != strconv.Itoa(len(msg))+" " {
+		t.Errorf("Expected an octet count of %d. Got %q instead.", len(msg), length)
+	}
+}
@@ -5,22 +5,58 @@ const (
 <html>
 <head>
 <title>gcvis - {{ .Title }}</title>
-<script src="//cdnjs.cloudflare.com/ajax/libs/jquery/2.0.3/jquery.min.js"></script>
-<script src="//cdnjs.cloudflare.com/ajax/libs/flot/0.8.2/jquery.flot.min.js"></script>
-<script src="//cdnjs.cloudflare.com/ajax/libs/flot/0.8.2/jquery.flot.selection.min.js"></script>
-<script src="//cdnjs.cloudflare.com/ajax/libs/flot/0.8.2/jquery.flot.stack.min.js"></script>
+<script src="https://cdnjs.cloudflare.com/ajax/libs/jquery/2.0.3/jquery.min.js"></script>
+<script src="https://cdnjs.cloudflare.com/ajax/libs/flot/0.8.2/jquery.flot.min.js"></script>
+<script src="https://cdnjs.cloudflare.com/ajax/libs/flot/0.8.2/jquery.flot.selection.min.js"></script>
+<script src="https://cdnjs.cloudflare.com/ajax/libs/flot/0.8.2/jquery.flot.stack.min.js"></script>
 
 <script type="text/javascript">
 
 (function() {
-	var datagraph_data = [
-		{ label: "gc.heapinuse", data: {{ .HeapUse }} },
-		{ label: "scvg.inuse", data: {{ .ScvgInuse }} },
-		{ label: "scvg.idle", data: {{ .ScvgIdle }} },
-		{ label: "scvg.sys", data: {{ .ScvgSys }} },
-		{ label: "scvg.released", data: {{ .ScvgReleased }} },
-		{ label: "scvg.consumed", data: {{ .ScvgConsumed }} }
-	];
+	// page is the graph the page was served with, drawn until updates are
+	// pulled.
+	var page = {
+		HeapUse: {{ .HeapUse }},
+		ScvgInuse: {{ .ScvgInuse }},
+		ScvgIdle: {{ .ScvgIdle }},
+		ScvgSys: {{ .ScvgSys }},
+		ScvgReleased: {{ .ScvgReleased }},
+		ScvgConsumed: {{ .ScvgConsumed }},
+		ScvgSysGap: {{ .ScvgSysGap }},
+		ScvgReleasedPercent: {{ .ScvgReleasedPercent }},
+		STWSclock: {{ .STWSclock }},
+		MASclock: {{ .MASclock }},
+		STWMclock: {{ .STWMclock }},
+		STWScpu: {{ .STWScpu }},
+		MASAssistcpu: {{ .MASAssistcpu }},
+		MASBGcpu: {{ .MASBGcpu }},
+		MASIdlecpu: {{ .MASIdlecpu }},
+		STWMcpu: {{ .STWMcpu }},
+		Load: {{ .Load }},
+		GCNumber: {{ .GCNumber }},
+		Allocated: {{ .Allocated }},
+		RSS: {{ .RSS }},
+		Fragmentation: {{ .Fragmentation }},
+		Epoch: {{ .Epoch }},
+		Anomalies: {{ .Anomalies }},
+		SLOViolations: {{ .SLOViolations }},
+		AlertViolations: {{ .AlertViolations }}
+	};
+
+	function datagraphData(g) {
+		return [
+			{ label: "gc.heapinuse", data: g.HeapUse },
+			{ label: "scvg.inuse", data: g.ScvgInuse },
+			{ label: "scvg.idle", data: g.ScvgIdle },
+			{ label: "scvg.sys", data: g.ScvgSys },
+			{ label: "scvg.released", data: g.ScvgReleased },
+			{ label: "scvg.consumed", data: g.ScvgConsumed },
+			{ label: "scvg.sys-inuse", data: g.ScvgSysGap },
+			{ label: "scvg.released/idle", data: g.ScvgReleasedPercent, yaxis: 2 },
+			{ label: "rss", data: g.RSS },
+			{ label: "rss-live", data: g.Fragmentation }
+		];
+	}
 
 	var datagraph_options = {
 		legend: {
@@ -31,26 +67,43 @@ const (
 		yaxis: {
 			tickFormatter: function(val) { return val + "MB"; }
 		},
+		yaxes: [{}, {
+			position: "right",
+			min: 0,
+			max: 100,
+			tickFormatter: function(val) { return val + "%"; }
+		}],
 		xaxis: {
-			tickFormatter: function(val) { return val + "s"; }
+			tickFormatter: formatX
 		},
 		selection: {
 			mode: "x"
 		},
+		grid: {
+			markings: []
+		},
 	};
 
-	var clockgraph_data = [
-		{ label: "STW sweep clock", data: {{ .STWSclock }} },
-		{ label: "con mas clock", data: {{ .MASclock }} },
-		{ label: "STW mark clock", data: {{ .STWMclock }} },
-	];
-	var cpugraph_data = [
-		{ label: "STW sweep cpu", data: {{ .STWScpu }} },
-		{ label: "con mas assist cpu", data: {{ .MASAssistcpu }} },
-		{ label: "con mas bg cpu", data: {{ .MASBGcpu }} },
-		{ label: "con mas idle cpu", data: {{ .MASIdlecpu }} },
-		{ label: "STW mark cpu", data: {{ .STWMcpu }} },
-	];
+	function clockgraphData(g) {
+		return [
+			{ label: "con mas clock",      data: g.MASclock },
+		];
+	}
+	function stwgraphData(g) {
+		return [
+			{ label: "STW sweep clock",    data: g.STWSclock },
+			{ label: "STW mark clock",     data: g.STWMclock },
+		].concat(loadSeries(g.Load));
+	}
+	function cpugraphData(g) {
+		return [
+			{ label: "STW sweep cpu",      data: g.STWScpu },
+			{ label: "con mas assist cpu", data: g.MASAssistcpu },
+			{ label: "con mas bg cpu",     data: g.MASBGcpu },
+			{ label: "con mas idle cpu",   data: g.MASIdlecpu },
+			{ label: "STW mark cpu",       data: g.STWMcpu },
+		];
+	}
 
 	var timingsgraph_options = {
 		legend: {
@@ -62,11 +115,14 @@ const (
 			tickFormatter: function(val) { return val + "ms"; }
 		},
 		xaxis: {
-			tickFormatter: function(val) { return val + "s"; }
+			tickFormatter: formatX
 		},
 		selection: {
 			mode: "x"
 		},
+		grid: {
+			markings: []
+		},
 		series: {
 			stack: 0,
 			lines: {
@@ -77,10 +133,369 @@ const (
 		},
 	};
 
+	var stwgraph_options = {
+		legend: {
+			position: "nw",
+			noColumns: 2,
+			backgroundOpacity: 0.2
+		},
+		yaxis: {
+			tickFormatter: function(val) { return val + "ms"; }
+		},
+		yaxes: [{}, {
+			position: "right",
+			min: 0,
+			tickFormatter: function(val) { return val + "/s"; }
+		}],
+		xaxis: {
+			tickFormatter: formatX
+		},
+		selection: {
+			mode: "x"
+		},
+		grid: {
+			markings: []
+		},
+		series: {
+			stack: 0,
+			bars: {
+				show: true,
+				fill: 0.8,
+				lineWidth: 0,
+				align: "center",
+			},
+		},
+	};
+
+	// loadSeries returns the series of the request rate of the program
+	// drawn over the pauses, on the right axis, if there is one.
+	function loadSeries(points) {
+		if (!points || points.length == 0) {
+			return [];
+		}
+		return [{
+			label: "load", data: points, yaxis: 2, stack: false, color: "#555",
+			bars: { show: false }, lines: { show: true, lineWidth: 1 }
+		}];
+	}
+
+	// barWidth returns a bar width, in seconds, narrow enough that the
+	// bars of two consecutive GC cycles never overlap.
+	function barWidth(points) {
+		var width = 0;
+		for (var i = 1; i < points.length; i++) {
+			var gap = points[i][0] - points[i-1][0];
+			if (gap > 0 && (width == 0 || gap < width)) {
+				width = gap;
+			}
+		}
+		if (width == 0) {
+			return 0.1;
+		}
+		return width * 0.8;
+	}
+
+	// seriesColors returns the colors of the series of plot, by label.
+	function seriesColors(plot) {
+		var colors = {};
+		$.each(plot.getData(), function(_, series) {
+			colors[series.label] = series.color;
+		});
+		return colors;
+	}
+
+	// alertMarkings shades the periods the -alert rules fired on the series
+	// of plot they are bound to, in the color of the series.
+	function alertMarkings(plot, violations) {
+		var colors = seriesColors(plot);
+		var markings = [];
+		$.each(violations || [], function(_, v) {
+			if (v.Series && colors[v.Series]) {
+				var color = $.color.parse(colors[v.Series]).scale("a", 0.3).toString();
+				markings.push({ xaxis: { from: v.From, to: v.To }, color: color });
+			}
+		});
+		return markings;
+	}
+
+	// renderAlertViolations lists the periods the -alert rules fired, the
+	// latest first, in the color of the series of plots they are bound to.
+	function renderAlertViolations(violations, plots) {
+		if (!violations || violations.length == 0) {
+			$("#violations").hide();
+			return;
+		}
+		var colors = {};
+		$.each(plots, function(_, plot) {
+			$.extend(colors, seriesColors(plot));
+		});
+
+		var items = $.map(violations.slice().reverse(), function(v) {
+			var to = v.Ongoing ? "ongoing" : formatX(Math.round(toX(v.To) * 100) / 100);
+			var li = $("<li>").attr("data-from", v.From).attr("data-to", v.To);
+			$("<span class=\"swatch\">").css("background", colors[v.Series] || "#999").appendTo(li);
+			li.append(document.createTextNode(v.Rule + ": " + v.Peak + v.Unit + ", " + formatX(Math.round(toX(v.From) * 100) / 100) + " to " + to));
+			return [li];
+		});
+		$("#violations ul").empty().append(items);
+		$("#violations").show();
+	}
+
+	// anomalyMarkings returns a red line at every anomaly of metric, for
+	// the graph showing it.
+	function anomalyMarkings(anomalies, metric) {
+		var markings = [];
+		$.each(anomalies || [], function(_, a) {
+			if (a.Metric == metric) {
+				markings.push({ xaxis: { from: a.Time, to: a.Time }, color: "#d00", lineWidth: 1 });
+			}
+		});
+		return markings;
+	}
+
+	// sloMarkings shades the periods the -slo objectives were violated, on
+	// the heap graph for those on heap sizes, and on the STW graph for the
+	// others.
+	function sloMarkings(violations, heap) {
+		var markings = [];
+		$.each(violations || [], function(_, v) {
+			if ((v.Unit == "MB") == heap) {
+				markings.push({ xaxis: { from: v.From, to: v.To }, color: "rgba(230, 126, 34, 0.2)" });
+			}
+		});
+		return markings;
+	}
+
+	// xMode is what the x-axis of the graphs shows: the time elapsed, the
+	// wall-clock time, the GC cycles or the megabytes allocated. The points
+	// are in elapsed seconds, mapped to the mode with xMaps, the epoch and
+	// the points of the GC cycles of the session.
+	var xMode = "elapsed";
+	var xMaps = { epoch: 0, gc: [], alloc: [] };
+
+	// interpolate returns the value at v of the points, ordered on both
+	// coordinates, mapping their from-th coordinate to the other one. Values
+	// out of the points are clamped to the first or last one.
+	function interpolate(points, v, from) {
+		var to = 1 - from;
+		if (!points || points.length == 0) {
+			return v;
+		}
+		var lo = 0, hi = points.length - 1;
+		if (v <= points[lo][from]) {
+			return points[lo][to];
+		}
+		if (v >= points[hi][from]) {
+			return points[hi][to];
+		}
+		while (hi - lo > 1) {
+			var mid = (lo + hi) >> 1;
+			if (points[mid][from] <= v) {
+				lo = mid;
+			} else {
+				hi = mid;
+			}
+		}
+		var a = points[lo], b = points[hi];
+		if (b[from] == a[from]) {
+			return a[to];
+		}
+		return a[to] + (b[to] - a[to]) * (v - a[from]) / (b[from] - a[from]);
+	}
+
+	// toX returns the x of elapsed seconds in the xMode.
+	function toX(elapsed) {
+		switch (xMode) {
+		case "wallclock":
+			return xMaps.epoch + elapsed;
+		case "gc":
+			return interpolate(xMaps.gc, elapsed, 0);
+		case "alloc":
+			return interpolate(xMaps.alloc, elapsed, 0);
+		}
+		return elapsed;
+	}
+
+	// fromX returns the elapsed seconds of x in the xMode.
+	function fromX(x) {
+		switch (xMode) {
+		case "wallclock":
+			return x - xMaps.epoch;
+		case "gc":
+			return interpolate(xMaps.gc, x, 1);
+		case "alloc":
+			return interpolate(xMaps.alloc, x, 1);
+		}
+		return x;
+	}
+
+	function formatX(val) {
+		switch (xMode) {
+		case "wallclock":
+			return new Date(val * 1000).toLocaleTimeString();
+		case "gc":
+			return "#" + val;
+		case "alloc":
+			return val + "MB";
+		}
+		return val + "s";
+	}
+
+	// remap returns the series of data with their points in the xMode.
+	function remap(data) {
+		if (xMode == "elapsed") {
+			return data;
+		}
+		return $.map(data, function(series) {
+			var points = $.map(series.data || [], function(p) {
+				return [[toX(p[0]), p[1]]];
+			});
+			return [$.extend({}, series, { data: points })];
+		});
+	}
+
+	// remapMarkings returns markings in the xMode.
+	function remapMarkings(markings) {
+		if (xMode == "elapsed") {
+			return markings;
+		}
+		return $.map(markings, function(m) {
+			return [$.extend({}, m, { xaxis: { from: toX(m.xaxis.from), to: toX(m.xaxis.to) } })];
+		});
+	}
+
+	function renderSummary(s) {
+		if (!s) {
+			return;
+		}
+		var ago = Math.max(0, Math.round((Date.now() - s.Received) / 1000));
+		$("#summary").text(
+			"last GC: " + s.STW.toFixed(1) + "ms STW" +
+			", heap " + s.HeapBefore + "\u2192" + s.HeapAfter + "MB" +
+			", goal " + s.HeapGoal + "MB" +
+			", GC overhead " + s.Overhead.toFixed(1) + "%" +
+			", " + ago + "s ago"
+		);
+	}
+
+	// renderHighWater shows the high-water marks h.
+	function renderHighWater(h) {
+		if (!h || !h.Cycles) {
+			$("#highwater-marks").text("high water: no GC " + (h && h.Since ? "since the reset at " + h.Since.toFixed(1) + "s" : "yet"));
+			return;
+		}
+		$("#highwater-marks").text(
+			"high water since " + (h.Since ? h.Since.toFixed(1) + "s" : "start") +
+			": heap " + h.HeapMB + "MB at " + h.HeapAt.toFixed(1) + "s" +
+			", pause " + h.PauseMs.toFixed(3) + "ms at " + h.PauseAt.toFixed(1) + "s" +
+			" over " + h.Cycles + " GCs"
+		);
+	}
+
+	// renderScvgTotals sums up the scavenger traces, if any.
+	function renderScvgTotals(t) {
+		if (!t || !t.Cycles) {
+			$("#scavenger").hide();
+			return;
+		}
+		$("#scavenger").text(
+			"scavenger: " + t.ReleasedMB + "MB released to the OS over " + t.Cycles + " cycles" +
+			", sys up to " + t.MaxSysGapMB + "MB over in use"
+		).show();
+	}
+
+	// renderRuntime shows what is known of the runtime of the program.
+	function renderRuntime(r) {
+		var parts = [];
+		if (r && r.go_version) {
+			parts.push(r.go_version);
+		}
+		if (r && r.gomaxprocs) {
+			parts.push("GOMAXPROCS=" + r.gomaxprocs);
+		}
+		if (r && r.gogc) {
+			parts.push("GOGC=" + r.gogc);
+		}
+		if (r && r.gomemlimit) {
+			parts.push("GOMEMLIMIT=" + r.gomemlimit);
+		}
+		$("#runtime").text(parts.join(" ")).toggle(parts.length > 0);
+	}
+
+	// renderAnnotations lists the annotations of the graph, linking to
+	// what they link to, e.g. a profile.
+	function renderAnnotations(annotations) {
+		var list = $("#annotations").empty();
+		$.each(annotations || [], function(_, a) {
+			var item = $("<li>").text(a.Time.toFixed(1) + "s: " + a.Text);
+			if (a.URL) {
+				item.append(" ").append($("<a>").attr("href", a.URL).text("download"));
+			}
+			list.append(item);
+		});
+	}
+
+	// renderAdvice shows the GOGC and GOMEMLIMIT suggested from the
+	// summary s of the last window of the graph.
+	function renderAdvice(s, last) {
+		var lines = [];
+		var a = s && s.gogc_advice;
+		if (a) {
+			lines.push("GOGC advice (last " + last + "):");
+			$.each(a.candidates, function(i, c) {
+				var line = "  GOGC=" + c.gogc + " " + c.gc_per_minute.toFixed(1) + " GCs/min" +
+					", heap up to " + c.heap_peak_mb.toFixed(0) + "MB" +
+					", " + c.gc_overhead_percent.toFixed(1) + "% overhead";
+				if (c.over_memory_limit) {
+					line += ", over the memory limit";
+				}
+				if (c.current) {
+					line += " (current)";
+				}
+				if (c.gogc == a.suggested_gogc) {
+					line += " \u2190 suggested";
+				}
+				lines.push(line);
+			});
+		}
+		var m = s && s.gomemlimit_advice;
+		if (m) {
+			lines.push("GOMEMLIMIT advice: " + m.recommended_gomemlimit +
+				" for a container limit of " + m.container_limit_mb.toFixed(0) + "MB");
+			$.each(m.risks || [], function(i, risk) {
+				lines.push("  risk of " + risk);
+			});
+		}
+		if (lines.length == 0) {
+			$("#advice").hide();
+			return;
+		}
+		$("#advice").text(lines.join("\n")).show();
+	}
+
+	function renderAlerts(alerts) {
+		if (!alerts || alerts.length == 0) {
+			$("#alerts").hide();
+			return;
+		}
+		$("#alerts").text($.map(alerts, function(a) {
+			var s = a.rule + ": " + a.value + a.unit + " since " + new Date(a.since).toLocaleTimeString();
+			if (a.detail) {
+				s += ", " + a.detail;
+			}
+			return s;
+		}).join("\n")).show();
+	}
+
+	// zoom is the range of seconds selected on the graphs, if any, for
+	// which finer points are pulled.
+	var zoom = null;
+
 	$(document).ready(function() {
-		var datagraph = $.plot("#datagraph", datagraph_data, datagraph_options);
-		var clockgraph = $.plot("#clockgraph", clockgraph_data, timingsgraph_options);
-		var cpugraph = $.plot("#cpugraph", cpugraph_data, timingsgraph_options);
+		var datagraph = $.plot("#datagraph", [], datagraph_options);
+		var clockgraph = $.plot("#clockgraph", [], timingsgraph_options);
+		var stwgraph = $.plot("#stwgraph", [], stwgraph_options);
+		var cpugraph = $.plot("#cpugraph", [], timingsgraph_options);
 
 		var overview = $.plot("#overview", {}, {
 			legend: { show: false},
@@ -108,6 +523,7 @@ const (
 
 		// now connect the four
 		$("#datagraph").bind("plotselected", function (event, ranges) {
+			zoom = ranges.xaxis;
 
 			// do the zooming
 			$.each(datagraph.getXAxes(), function(_, axis) {
@@ -122,10 +538,12 @@ const (
 			// don't fire event on the overview to prevent eternal loop
 			overview.setSelection(ranges, true);
 			clockgraph.setSelection(ranges, true);
+			stwgraph.setSelection(ranges, true);
 			cpugraph.setSelection(ranges, true);
 		});
 
 		$("#clockgraph").bind("plotselected", function (event, ranges) {
+			zoom = ranges.xaxis;
 
 			// do the zooming
 			$.each(clockgraph.getXAxes(), function(_, axis) {
@@ -141,10 +559,33 @@ const (
 
 			overview.setSelection(ranges, true);
 			datagraph.setSelection(ranges, true);
+			stwgraph.setSelection(ranges, true);
+			cpugraph.setSelection(ranges, true);
+		});
+
+		$("#stwgraph").bind("plotselected", function (event, ranges) {
+			zoom = ranges.xaxis;
+
+			// do the zooming
+			$.each(stwgraph.getXAxes(), function(_, axis) {
+				var opts = axis.options;
+				opts.min = ranges.xaxis.from;
+				opts.max = ranges.xaxis.to;
+			});
+			stwgraph.setupGrid();
+			stwgraph.draw();
+			stwgraph.clearSelection();
+
+			// don't fire event on the overview to prevent eternal loop
+
+			overview.setSelection(ranges, true);
+			datagraph.setSelection(ranges, true);
+			clockgraph.setSelection(ranges, true);
 			cpugraph.setSelection(ranges, true);
 		});
 
 		$("#cpugraph").bind("plotselected", function (event, ranges) {
+			zoom = ranges.xaxis;
 
 			// do the zooming
 			$.each(cpugraph.getXAxes(), function(_, axis) {
@@ -160,56 +601,172 @@ const (
 
 			overview.setSelection(ranges, true);
 			datagraph.setSelection(ranges, true);
-			clockraph.setSelection(ranges, true);
+			clockgraph.setSelection(ranges, true);
+			stwgraph.setSelection(ranges, true);
 		});
 
 		$("#overview").bind("plotselected", function (event, ranges) {
 			datagraph.setSelection(ranges);
 			clockgraph.setSelection(ranges);
+			stwgraph.setSelection(ranges);
 			cpugraph.setSelection(ranges);
 		});
 
-		// refresh data every second
-		pullAndRedraw();
+		// shown is the graph drawn last, drawn anew in another xMode.
+		var shown = null;
 
-		function pullAndRedraw() {
-			$.get(window.location.href + 'graph.json', function(graphData) {
-				var datagraph_data = [
-					{ label: "gc.heapinuse", data: graphData.HeapUse },
-					{ label: "scvg.inuse", data: graphData.ScvgInuse },
-					{ label: "scvg.idle", data: graphData.ScvgIdle },
-					{ label: "scvg.sys", data: graphData.ScvgSys },
-					{ label: "scvg.released", data: graphData.ScvgReleased },
-					{ label: "scvg.consumed", data: graphData.ScvgConsumed }
-				];
-				var clockgraph_data = [
-					{ label: "STW sweep clock",    data: graphData.STWSclock },
-					{ label: "con mas clock",      data: graphData.MASclock },
-					{ label: "STW mark clock",     data: graphData.STWMclock },
-				];
-				var cpugraph_data = [
-					{ label: "STW sweep cpu",      data: graphData.STWScpu },
-					{ label: "con mas assist cpu", data: graphData.MASAssistcpu },
-					{ label: "con mas bg cpu",     data: graphData.MASBGcpu },
-					{ label: "con mas idle cpu",   data: graphData.MASIdlecpu },
-					{ label: "STW mark cpu",       data: graphData.STWMcpu },
-				];
-
-				datagraph.setData(datagraph_data);
-				datagraph.setupGrid();
-				datagraph.draw();
-
-				clockgraph.setData(clockgraph_data);
-				clockgraph.setupGrid();
-				clockgraph.draw();
-
-				cpugraph.setData(cpugraph_data);
-				cpugraph.setupGrid();
-				cpugraph.draw();
+		function draw(g) {
+			shown = g;
+			// The points of windows only map the GC cycles of the window.
+			if (!zoom) {
+				xMaps = { epoch: g.Epoch, gc: g.GCNumber, alloc: g.Allocated };
+			}
+
+			var datagraph_data = remap(datagraphData(g));
+			var stwgraph_data = remap(stwgraphData(g));
+
+			// The alert markings take the colors of the series, set with
+			// the data.
+			datagraph.setData(datagraph_data);
+			datagraph.getOptions().grid.markings = remapMarkings(anomalyMarkings(g.Anomalies, "alloc_rate").concat(sloMarkings(g.SLOViolations, true), alertMarkings(datagraph, g.AlertViolations)));
+			datagraph.setupGrid();
+			datagraph.draw();
+
+			clockgraph.setData(remap(clockgraphData(g)));
+			clockgraph.getOptions().grid.markings = remapMarkings(alertMarkings(clockgraph, g.AlertViolations));
+			clockgraph.setupGrid();
+			clockgraph.draw();
 
+			stwgraph.getOptions().series.bars.barWidth = barWidth(stwgraph_data[0].data);
+			stwgraph.setData(stwgraph_data);
+			stwgraph.getOptions().grid.markings = remapMarkings(anomalyMarkings(g.Anomalies, "stw").concat(sloMarkings(g.SLOViolations, false), alertMarkings(stwgraph, g.AlertViolations)));
+			stwgraph.setupGrid();
+			stwgraph.draw();
+
+			cpugraph.setData(remap(cpugraphData(g)));
+			cpugraph.setupGrid();
+			cpugraph.draw();
+
+			renderAlertViolations(g.AlertViolations, [datagraph, clockgraph, stwgraph]);
+
+			// The overview keeps showing the whole session.
+			if (!zoom) {
 				overview.setData(datagraph_data);
 				overview.setupGrid();
 				overview.draw();
+			}
+		}
+
+		// A violation listed zooms on its period, with some of the time
+		// around it.
+		$("#violations").on("click", "li", function() {
+			var from = Number($(this).attr("data-from")), to = Number($(this).attr("data-to"));
+			var margin = Math.max(1, (to - from) / 10);
+			datagraph.setSelection({ xaxis: { from: toX(from - margin), to: toX(to + margin) } });
+		});
+
+		$("#xmode").change(function() {
+			xMode = $(this).val();
+			// The selection is in the units of the previous mode.
+			zoom = null;
+			$.each([datagraph, clockgraph, stwgraph, cpugraph, overview], function(_, plot) {
+				$.each(plot.getXAxes(), function(_, axis) {
+					axis.options.min = plot === overview && xMode == "elapsed" ? 0 : null;
+					axis.options.max = null;
+					axis.options.minTickSize = xMode == "gc" ? 1 : null;
+				});
+				plot.clearSelection(true);
+			});
+			draw(live || shown);
+		});
+
+		draw(page);
+
+		renderAnnotations({{ .Annotations }});
+{{ if not .Report }}
+		renderHighWater({{ .HighWater }});
+		renderScvgTotals({{ .ScvgTotals }});
+		// The next update shows the marks reset.
+		$("#highwater-reset").click(function() {
+			$.post('/api/v1/high-water');
+		});
+
+		// refresh data every second
+		pullAndRedraw();
+{{ end }}
+
+		// live is the whole session, kept up to date with the points added
+		// since cursor, and pulled again every fullEvery milliseconds.
+		var live = null;
+		var cursor = "";
+		var fullEvery = 5 * 60 * 1000;
+		var pulledFull = 0;
+
+		// The GOGC advice is pulled every adviceEvery milliseconds, from
+		// the summary of the last adviceWindow.
+		var adviceEvery = 10 * 1000;
+		var adviceWindow = "5m";
+		var pulledAdvice = 0;
+		var seriesNames = [
+			"HeapUse", "ScvgInuse", "ScvgIdle", "ScvgSys", "ScvgReleased", "ScvgConsumed",
+			"ScvgReleasedPercent", "ScvgSysGap",
+			"STWSclock", "MASclock", "STWMclock",
+			"STWScpu", "MASAssistcpu", "MASBGcpu", "MASIdlecpu", "STWMcpu",
+			"Load", "GCNumber", "Allocated", "RSS", "Fragmentation"
+		];
+
+		function merge(update) {
+			if (update.Full || live === null) {
+				live = update;
+			} else {
+				$.each(seriesNames, function(i, name) {
+					live[name] = (live[name] || []).concat(update[name] || []);
+				});
+				live.LastGC = update.LastGC;
+				live.Anomalies = update.Anomalies;
+				live.SLOViolations = update.SLOViolations;
+				live.AlertViolations = update.AlertViolations;
+				live.Annotations = update.Annotations;
+				live.Runtime = update.Runtime;
+				live.HighWater = update.HighWater;
+				live.ScvgTotals = update.ScvgTotals;
+				live.Epoch = update.Epoch;
+			}
+			cursor = update.Cursor;
+			return live;
+		}
+
+		function pullAndRedraw() {
+			// The server downsamples the points to the width of the graphs.
+			var url = window.location.href + 'graph.json?points=' + $("#datagraph").width();
+			var delta = !zoom;
+			if (zoom) {
+				url += '&from=' + fromX(zoom.from) + '&to=' + fromX(zoom.to);
+			} else {
+				if (Date.now() - pulledFull > fullEvery) {
+					cursor = "";
+					pulledFull = Date.now();
+				}
+				url += '&since=' + cursor;
+			}
+			$.get('/alerts.json', renderAlerts);
+			if (Date.now() - pulledAdvice > adviceEvery) {
+				pulledAdvice = Date.now();
+				$.get('/api/v1/summary?last=' + adviceWindow, function(s) {
+					renderAdvice(s, adviceWindow);
+				});
+			}
+			$.get(url, function(graphData) {
+				if (delta) {
+					graphData = merge(graphData);
+				}
+				renderSummary(graphData.LastGC);
+				renderRuntime(graphData.Runtime);
+				renderHighWater(graphData.HighWater);
+				renderScvgTotals(graphData.ScvgTotals);
+				renderAnnotations(graphData.Annotations);
+
+				draw(graphData);
 
 				setTimeout(pullAndRedraw, 1000);
 			})
@@ -227,6 +784,24 @@ const (
 	float: right;
 }
 dt { float: left; font-weight:bold; width: 160px; }
+
+#violations {
+	position: fixed;
+	top: 10px;
+	right: 10px;
+	width: 260px;
+	max-height: 90%;
+	overflow-y: auto;
+	padding: 5px;
+	background: #fff;
+	border: 1px solid #ddd;
+	box-shadow: 0 3px 10px rgba(0,0,0,0.15);
+	font-family: monospace;
+	font-size: 12px;
+}
+#violations ul { list-style: none; margin: 5px 0 0 0; padding: 0; }
+#violations li { cursor: pointer; margin-bottom: 3px; }
+#violations .swatch { display: inline-block; width: 10px; height: 10px; margin-right: 5px; }
 dd { margin-left: 160px; }
 
 .graph-container {
@@ -300,11 +875,36 @@ dd { margin-left: 160px; }
 </head>
 <body>
 <pre>{{ .Title }}</pre>
+<pre id="runtime"{{ if not .Runtime }} style="display: none;"{{ end }}>{{ with .Runtime }}{{ .String }}{{ end }}</pre>
+{{ with .Report }}
+<pre id="report">{{ .NumGC }} GC cycles over {{ printf "%.1f" .DurationSeconds }}s
+stop the world pauses: {{ printf "%.3f" .TotalPauseMs }}ms in total, {{ printf "%.3f" .AvgPauseMs }}ms on average, p50 {{ index .PausePercentilesMs "p50" | printf "%.3f" }}ms, p95 {{ index .PausePercentilesMs "p95" | printf "%.3f" }}ms, p99 {{ index .PausePercentilesMs "p99" | printf "%.3f" }}ms, max {{ index .PausePercentilesMs "max" | printf "%.3f" }}ms
+heap: {{ .HeapMinMB }}MB to {{ .HeapHighWaterMB }}MB, allocating {{ printf "%.1f" .AllocRateMBPerSecond }}MB/s
+{{- with .GOGCAdvice }}
+GOGC advice: {{ .String }}{{ end }}
+{{- with .GOMEMLIMITAdvice }}
+GOMEMLIMIT advice: {{ .String }}{{ end }}</pre>
+{{ else }}
+<pre id="alerts" style="display: none; color: #fff; background: #c0392b; padding: 5px;"></pre>
+<pre id="summary">waiting for the first GC...</pre>
+<pre id="advice" style="display: none;"></pre>
+<pre id="scavenger" style="display: none;"></pre>
+<pre id="highwater"><span id="highwater-marks"></span> <button id="highwater-reset" title="start the high-water marks anew">reset</button></pre>
 <div id="export">
+	<a href="/events">events</a>
 	<a href="/graph.json">json</a>
 </div>
+{{ end }}
+<div id="violations" style="display: none;" title="periods the -alert rules fired, click to zoom on one"><b>Alerts</b><ul></ul></div>
 <div id="content">
 
+	<p>x-axis: <select id="xmode">
+		<option value="elapsed">elapsed time</option>
+		<option value="wallclock">wall-clock time</option>
+		<option value="gc">GC cycles</option>
+		<option value="alloc">memory allocated</option>
+	</select></p>
+
 	<div class="graph-container">
 		<div id="datagraph" class="demo-placeholder"></div>
 	</div>
@@ -313,6 +913,10 @@ dd { margin-left: 160px; }
 		<div id="clockgraph" class="demo-placeholder"></div>
 	</div>
 
+	<div class="small-graph-container">
+		<div id="stwgraph" class="demo-placeholder"></div>
+	</div>
+
 	<div class="small-graph-container">
 		<div id="cpugraph" class="demo-placeholder"></div>
 	</div>
@@ -323,6 +927,8 @@ dd { margin-left: 160px; }
 
 	<p>The smaller plot is linked to the main plot, so it acts as an overview. Try dragging a selection on either plot, and watch the behavior of the other.</p>
 
+	<ul id="annotations"></ul>
+
 </div>
 
 <pre><b>Legend</b>
@@ -334,8 +940,12 @@ dd { margin-left: 160px; }
 <dt>scvg.sys      </dt><dd> virtual memory requested from the operating system (should aproximate VSS)</dd>
 <dt>scvg.released </dt><dd> virtual memory returned to the operating system by the scavenger</dd>
 <dt>scvg.consumed </dt><dd> virtual memory in use (should roughly match process RSS)</dd>
+<dt>scvg.sys-inuse</dt><dd> virtual memory requested from the operating system that the heap does not use</dd>
+<dt>scvg.released/idle</dt><dd> share of the idle memory released to the operating system, on the right axis</dd>
+<dt>rss           </dt><dd> resident memory of the program, sampled every second when gcvis runs it or is given its -pid</dd>
+<dt>rss-live      </dt><dd> resident memory less the live heap: the estimated fragmentation and overhead of the allocator and runtime</dd>
 
-<dt>STW sweep clock   </dt><dd>stop-the-world sweep clock time</dd>
+<dt>STW sweep clock   </dt><dd>stop-the-world sweep clock time, stacked per GC cycle with the mark phase</dd>
 <dt>con mas clock     </dt><dd>concurrent mark and scan clock time</dd>
 <dt>STW mark clock    </dt><dd>stop-the-world mark clock time</dd>
 <dt>STW sweep cpu     </dt><dd>stop-the-world sweep cpu time</dd>
@@ -343,9 +953,138 @@ dd { margin-left: 160px; }
 <dt>con mas bg cpu    </dt><dd>concurrent mark and scan - background GC cpu time</dd>
 <dt>con mas idle cpu  </dt><dd>concurrent mark and scan - idle GC cpu time</dd>
 <dt>STW mark cpu      </dt><dd>stop-the-world mark cpu time</dd>
+<dt>load              </dt><dd>request rate of the program, of -load-input or -load-scrape, on the right axis of the STW graph</dd>
 </dl>
 
 </pre>
+<footer><pre>gcvis {{ version }}</pre></footer>
+</body>
+</html>
+	`
+
+	EVENTS_TMPL = `
+<html>
+<head>
+<title>gcvis - {{ .Title }} - events</title>
+<script src="https://cdnjs.cloudflare.com/ajax/libs/jquery/2.0.3/jquery.min.js"></script>
+
+<script type="text/javascript">
+
+(function() {
+	// columns are the fields of the events, with their headers. Those zero
+	// in all events, e.g. not traced by the Go version of the program, are
+	// left out.
+	var columns = [
+		["Time", "time (s)"],
+		["NumGC", "gc"],
+		["Forced", "forced"],
+		["PauseMs", "STW pause (ms)"],
+		["STWSclock", "STW sweep clock (ms)"],
+		["MASclock", "con mas clock (ms)"],
+		["STWMclock", "STW mark clock (ms)"],
+		["STWScpu", "STW sweep cpu (ms)"],
+		["MASAssistcpu", "con mas assist cpu (ms)"],
+		["MASBGcpu", "con mas bg cpu (ms)"],
+		["MASIdlecpu", "con mas idle cpu (ms)"],
+		["STWMcpu", "STW mark cpu (ms)"],
+		["Heap0", "heap before (MB)"],
+		["Heap1", "heap goal, or after before go1.5 (MB)"],
+		["Heap2", "heap after (MB)"],
+		["Heap3", "live heap (MB)"],
+		["Nproc", "procs"],
+		["ElapsedTime", "trace time (s)"],
+		["Obj", "objects"],
+		["NMalloc", "mallocs"],
+		["NFree", "frees"],
+		["NSpan", "spans"],
+		["NGoRoutines", "goroutines"],
+		["NBGSweep", "bg sweeps"],
+		["NPauseSweep", "pause sweeps"],
+		["NHandoff", "handoffs"],
+		["NHandoffCnt", "handoff count"],
+		["NSteal", "steals"],
+		["NStealCnt", "steal count"],
+		["NProcYield", "proc yields"],
+		["NOsYield", "os yields"],
+		["NSleep", "sleeps"]
+	];
+	var alwaysShown = { Time: true, NumGC: true, Forced: true, PauseMs: true };
+
+	var events = [];
+	var sortBy = "Time";
+	var sortDesc = false;
+
+	function render() {
+		var shown = $.grep(columns, function(c) {
+			return alwaysShown[c[0]] || $.grep(events, function(e) { return e[c[0]]; }).length > 0;
+		});
+
+		var sorted = events.slice().sort(function(a, b) {
+			var x = Number(a[sortBy]), y = Number(b[sortBy]);
+			return sortDesc ? y - x : x - y;
+		});
+
+		var header = $("<tr>");
+		$.each(shown, function(_, c) {
+			var th = $("<th>").text(c[1] + (c[0] == sortBy ? (sortDesc ? " ▼" : " ▲") : ""));
+			th.click(function() {
+				sortDesc = c[0] == sortBy ? !sortDesc : false;
+				sortBy = c[0];
+				render();
+			});
+			header.append(th);
+		});
+
+		// The fields are numbers and booleans, written as is.
+		var rows = $.map(sorted, function(e) {
+			return "<tr>" + $.map(shown, function(c) {
+				return "<td>" + e[c[0]] + "</td>";
+			}).join("") + "</tr>";
+		});
+
+		$("#events thead").empty().append(header);
+		$("#events tbody").html(rows.join(""));
+		$("#count").text(events.length + " events");
+	}
+
+	function pull() {
+		$.get('/api/v1/events?' + $("#filter").serialize(), function(e) {
+			events = e;
+			render();
+		}).fail(function(xhr) {
+			$("#count").text(xhr.responseText);
+		});
+	}
+
+	$(document).ready(function() {
+		$("#filter").submit(function(event) {
+			event.preventDefault();
+			pull();
+		});
+		pull();
+	});
+})();
+</script>
+<style>
+table { border-collapse: collapse; font-family: monospace; }
+th { cursor: pointer; background: #eee; }
+th, td { border: 1px solid #ddd; padding: 2px 6px; text-align: right; }
+tr:nth-child(even) td { background: #f9f9f9; }
+</style>
+</head>
+<body>
+<pre>{{ .Title }} - <a href="/">graphs</a></pre>
+<form id="filter">
+	from <input name="from" size="8" placeholder="seconds">
+	to <input name="to" size="8" placeholder="seconds">
+	or last <input name="last" size="6" placeholder="e.g. 5m">
+	pause over <input name="min_pause" size="6" placeholder="ms">
+	<label><input type="checkbox" name="forced" value="true"> forced only</label>
+	<input type="submit" value="filter">
+</form>
+<pre id="count"></pre>
+<table id="events"><thead></thead><tbody></tbody></table>
+<footer><pre>gcvis {{ version }}</pre></footer>
 </body>
 </html>
 	`
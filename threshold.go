@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// threshold is a limit on a metric, e.g. stw_mark_clock_milliseconds>5,
+// exceeded by values above it, or below it for a < threshold.
+type threshold struct {
+	Metric string // full metric name, e.g. gcvis_stw_mark_clock_milliseconds
+	Below  bool
+	Value  float64
+}
+
+func (t threshold) String() string {
+	op := ">"
+	if t.Below {
+		op = "<"
+	}
+	return strings.TrimPrefix(t.Metric, "gcvis_") + op + strconv.FormatFloat(t.Value, 'g', -1, 64)
+}
+
+// exceededBy reports whether value exceeds the threshold.
+func (t threshold) exceededBy(value float64) bool {
+	if t.Below {
+		return value < t.Value
+	}
+	return value > t.Value
+}
+
+// parseThreshold parses name>value or name<value, name being a metric name
+// with or without its gcvis_ prefix.
+func parseThreshold(s string) (threshold, error) {
+	i := strings.IndexAny(s, "<>")
+	if i <= 0 {
+		return threshold{}, fmt.Errorf("invalid threshold %q, expected metric>value or metric<value", s)
+	}
+
+	t := threshold{Metric: strings.TrimSpace(s[:i]), Below: s[i] == '<'}
+	if !strings.HasPrefix(t.Metric, "gcvis_") {
+		t.Metric = "gcvis_" + t.Metric
+	}
+	if !isMetricName(t.Metric) {
+		return threshold{}, fmt.Errorf("invalid threshold %q, unknown metric %s", s, t.Metric)
+	}
+
+	var err error
+	if t.Value, err = strconv.ParseFloat(strings.TrimSpace(s[i+1:]), 64); err != nil {
+		return threshold{}, fmt.Errorf("invalid threshold %q: %v", s, err)
+	}
+	return t, nil
+}
+
+// isMetricName reports whether name is the name of a GC or scavenger
+// metric.
+func isMetricName(name string) bool {
+	for _, m := range append(allGCMetrics(&gctrace{}), allScvgMetrics(&scvgtrace{})...) {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// exceededThresholds returns the thresholds exceeded by metrics, with the
+// values exceeding them.
+func exceededThresholds(thresholds []threshold, metrics []metric) ([]threshold, []float64) {
+	var exceeded []threshold
+	var values []float64
+	for _, t := range thresholds {
+		for _, m := range metrics {
+			if m.Name == t.Metric && t.exceededBy(m.Value) {
+				exceeded = append(exceeded, t)
+				values = append(values, m.Value)
+			}
+		}
+	}
+	return exceeded, values
+}
+
+// thresholdsFlag collects repeated threshold flags.
+type thresholdsFlag []threshold
+
+func (f *thresholdsFlag) String() string {
+	s := make([]string, len(*f))
+	for i, t := range *f {
+		s[i] = t.String()
+	}
+	return strings.Join(s, ",")
+}
+
+func (f *thresholdsFlag) Set(value string) error {
+	t, err := parseThreshold(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, t)
+	return nil
+}
+
+func (f *thresholdsFlag) repeatable() {}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestParseThreshold(t *testing.T) {
+	for _, test := range []struct {
+		s        string
+		expected threshold
+	}{
+		{"stw_mark_clock_milliseconds>5", threshold{Metric: "gcvis_stw_mark_clock_milliseconds", Value: 5}},
+		{"gcvis_scvg_released_megabytes < 0.5", threshold{Metric: "gcvis_scvg_released_megabytes", Below: true, Value: 0.5}},
+	} {
+		th, err := parseThreshold(test.s)
+		if err != nil {
+			t.Errorf("parseThreshold(%q) returned an error: %v", test.s, err)
+			continue
+		}
+		if th != test.expected {
+			t.Errorf("Expected %+v. Got %+v instead.", test.expected, th)
+		}
+	}
+
+	for _, s := range []string{"stw_mark_clock_milliseconds", ">5", "pause>5", "heap_goal_megabytes>big"} {
+		if _, err := parseThreshold(s); err == nil {
+			t.Errorf("Expected %q to be rejected.", s)
+		}
+	}
+}
+
+func TestExceededThresholds(t *testing.T) {
+	var thresholds thresholdsFlag
+	thresholds.Set("stw_mark_clock_milliseconds>5")
+	thresholds.Set("heap_goal_megabytes<10")
+	thresholds.Set("heap_live_megabytes>100")
+
+	exceeded, values := exceededThresholds(thresholds, gcMetrics(&gctrace{STWMclock: 7, Heap1: 4, Heap3: 50}))
+	if len(exceeded) != 2 || exceeded[0].String() != "stw_mark_clock_milliseconds>5" || values[0] != 7 || values[1] != 4 {
+		t.Errorf("Expected the pause and heap goal thresholds to be exceeded. Got %v %v instead.", exceeded, values)
+	}
+	if thresholds.String() != "stw_mark_clock_milliseconds>5,heap_goal_megabytes<10,heap_live_megabytes>100" {
+		t.Errorf("Unexpected thresholds %s.", thresholds.String())
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var (
+	timeZoneName  = flag.String("time-zone", "UTC", "Time zone of the timestamps of the log lines and CSV files: UTC, Local or a name of the IANA database, e.g. Europe/Paris")
+	timePrecision = flag.Duration("time-precision", 0, "Precision of the timestamps of the log lines and CSV files, e.g. 1ms or 1s, truncated unless -time-round is set. Defaults to the nanosecond")
+	timeRound     = flag.Bool("time-round", false, "Round the timestamps to -time-precision rather than truncate them")
+)
+
+// timeZone is the location of -time-zone.
+var timeZone = time.UTC
+
+// exportTime returns t in -time-zone, to -time-precision.
+func exportTime(t time.Time) time.Time {
+	if *timePrecision > 0 {
+		if *timeRound {
+			t = t.Round(*timePrecision)
+		} else {
+			t = t.Truncate(*timePrecision)
+		}
+	}
+	return t.In(timeZone)
+}
+
+// applyTimeZone loads the location of -time-zone.
+func applyTimeZone() error {
+	if *timePrecision < 0 {
+		return fmt.Errorf("invalid -time-precision %s, expected a positive duration", *timePrecision)
+	}
+
+	loc, err := time.LoadLocation(*timeZoneName)
+	if err != nil {
+		return fmt.Errorf("invalid -time-zone %q: %v", *timeZoneName, err)
+	}
+	timeZone = loc
+	return nil
+}
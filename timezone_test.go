@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExportTime(t *testing.T) {
+	defer func(saved *time.Location) { timeZone = saved }(timeZone)
+	defer func(saved string) { *timeZoneName = saved }(*timeZoneName)
+	defer func(saved time.Duration) { *timePrecision = saved }(*timePrecision)
+	defer func(saved bool) { *timeRound = saved }(*timeRound)
+
+	at := time.Date(2026, 10, 15, 9, 12, 0, 987654321, time.UTC)
+	if got := exportTime(at).Format(time.RFC3339Nano); got != "2026-10-15T09:12:00.987654321Z" {
+		t.Errorf("Expected UTC to the nanosecond by default. Got %s instead.", got)
+	}
+
+	*timeZoneName = "Europe/Paris"
+	*timePrecision = time.Millisecond
+	if err := applyTimeZone(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := exportTime(at).Format(time.RFC3339Nano); got != "2026-10-15T11:12:00.987+02:00" {
+		t.Errorf("Expected the time in Paris, truncated to the millisecond. Got %s instead.", got)
+	}
+
+	*timePrecision = time.Second
+	*timeRound = true
+	if got := exportTime(at).Format(time.RFC3339Nano); got != "2026-10-15T11:12:01+02:00" {
+		t.Errorf("Expected the time rounded to the second. Got %s instead.", got)
+	}
+
+	*timeZoneName = "Mars/Olympus_Mons"
+	if err := applyTimeZone(); err == nil {
+		t.Errorf("Expected an unknown -time-zone to be rejected.")
+	}
+	*timeZoneName = "UTC"
+	*timePrecision = -time.Second
+	if err := applyTimeZone(); err == nil {
+		t.Errorf("Expected a negative -time-precision to be rejected.")
+	}
+}
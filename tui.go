@@ -0,0 +1,342 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var tuiMode = flag.Bool("tui", false, "render live charts and statistics in the terminal, along with the output of the program, for when no browser can reach gcvis, e.g. over SSH")
+
+// activeTUI is the terminal UI turned on by -tui, which shows the output of
+// the program and the logs of gcvis below its charts.
+var activeTUI *tui
+
+const (
+	tuiRefresh     = time.Second
+	tuiHistory     = 512 // values kept per series, more than the widest sparkline
+	tuiOutputLines = 200
+
+	// ANSI escape sequences.
+	tuiEnter = "\x1b[?1049h\x1b[?25l" // alternate screen, hidden cursor
+	tuiLeave = "\x1b[?25h\x1b[?1049l"
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+func init() {
+	RegisterSink("tui", newTUISink)
+}
+
+// tui renders sparklines of the latest GC events, with statistics of the
+// whole run, redrawing the terminal every tuiRefresh.
+type tui struct {
+	w    io.Writer
+	size func() (width, height int)
+
+	mu      sync.Mutex
+	title   string
+	stats   *runStats
+	series  []*tuiSeries
+	output  []string // latest lines of output, oldest first
+	partial string   // last line written by the logger, until its newline
+
+	interrupt chan os.Signal
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// tuiSeries is a chart of the TUI, fed by the events of its series.
+type tuiSeries struct {
+	label  string
+	unit   string
+	series string // of -series
+	values []float64
+}
+
+func newTUISink() (Sink, error) {
+	if !*tuiMode {
+		return nil, nil
+	}
+
+	fd := int(os.Stderr.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, fmt.Errorf("-tui needs the standard error to be a terminal")
+	}
+
+	t := newTUI(os.Stderr, func() (int, int) {
+		width, height, err := terminal.GetSize(fd)
+		if err != nil {
+			return 80, 24
+		}
+		return width, height
+	})
+	t.start()
+
+	activeTUI = t
+	return t, nil
+}
+
+func newTUI(w io.Writer, size func() (int, int)) *tui {
+	return &tui{
+		w:     w,
+		size:  size,
+		title: "gcvis",
+		stats: newRunStats(),
+		series: []*tuiSeries{
+			{label: "heap live", unit: "MB", series: "heap"},
+			{label: "heap goal", unit: "MB", series: "heap"},
+			{label: "stop the world pause", unit: "ms", series: "stw"},
+			{label: "mark and scan clock", unit: "ms", series: "mas"},
+			{label: "scavenger consumed", unit: "MB", series: "scvg"},
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// start switches the terminal to the TUI, and the logger to its output
+// pane, until Close. Interrupting gcvis restores the terminal first.
+func (t *tui) start() {
+	io.WriteString(t.w, tuiEnter)
+	setLogOutput(t)
+
+	t.interrupt = make(chan os.Signal, 1)
+	signal.Notify(t.interrupt, os.Interrupt)
+
+	go t.redrawPeriodically()
+}
+
+func (t *tui) redrawPeriodically() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(tuiRefresh)
+	defer ticker.Stop()
+
+	t.redraw()
+	for {
+		select {
+		case <-ticker.C:
+			t.redraw()
+		case <-t.interrupt:
+			t.restore()
+			os.Exit(130)
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// SetTitle shows the title of the session and the URL of the web UI.
+func (t *tui) SetTitle(title, url string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.title = "gcvis " + title + "  " + url
+}
+
+// AddOutput shows a line of output of the program.
+func (t *tui) AddOutput(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.addOutput(line)
+}
+
+func (t *tui) addOutput(line string) {
+	t.output = append(t.output, line)
+	if len(t.output) > tuiOutputLines {
+		t.output = t.output[len(t.output)-tuiOutputLines:]
+	}
+}
+
+// Write shows the lines logged by gcvis.
+func (t *tui) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := strings.Split(t.partial+string(p), "\n")
+	for _, line := range lines[:len(lines)-1] {
+		t.addOutput(line)
+	}
+	t.partial = lines[len(lines)-1]
+	return len(p), nil
+}
+
+func (t *tui) ConsumeGC(gc *gctrace) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats.addGC(gc)
+	t.series[0].add(float64(gc.Heap3))
+	t.series[1].add(float64(gc.Heap1))
+	t.series[2].add(gc.STWSclock + gc.STWMclock)
+	t.series[3].add(gc.MASclock)
+	return nil
+}
+
+func (t *tui) ConsumeScvg(s *scvgtrace) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats.addScvg(s)
+	t.series[4].add(float64(s.Consumed))
+	return nil
+}
+
+func (s *tuiSeries) add(v float64) {
+	s.values = append(s.values, v)
+	if len(s.values) > tuiHistory {
+		s.values = append(s.values[:0], s.values[len(s.values)-tuiHistory:]...)
+	}
+}
+
+func (t *tui) Flush() error {
+	return nil
+}
+
+// Close restores the terminal, and the logger.
+func (t *tui) Close() error {
+	close(t.stop)
+	<-t.done
+
+	t.restore()
+	if activeTUI == t {
+		activeTUI = nil
+	}
+	return nil
+}
+
+func (t *tui) restore() {
+	signal.Stop(t.interrupt)
+	setLogOutput(os.Stderr)
+	io.WriteString(t.w, tuiLeave)
+}
+
+func (t *tui) redraw() {
+	io.WriteString(t.w, t.frame())
+}
+
+// frame returns the escape sequences and lines drawing the TUI over the
+// previous frame.
+func (t *tui) frame() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	width, height := t.size()
+
+	var lines []string
+	lines = append(lines, t.title, t.statsLine(), "")
+	for _, s := range t.series {
+		if !enabledSeries[s.series] {
+			continue
+		}
+		lines = append(lines, s.header(), sparkline(s.values, width))
+	}
+	lines = append(lines, "", "── output "+strings.Repeat("─", maxInt(0, width-10)))
+
+	if free := height - len(lines); free > 0 {
+		output := t.output
+		if len(output) > free {
+			output = output[len(output)-free:]
+		}
+		lines = append(lines, output...)
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(truncateRunes(line, width))
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	return b.String()
+}
+
+func (t *tui) statsLine() string {
+	s := t.stats
+	if s.NumGC == 0 {
+		return "waiting for the first GC..."
+	}
+	return fmt.Sprintf("GC %d (%d forced)  pause p95 %.3fms max %.3fms  heap %d-%dMB  %s",
+		s.NumGC, s.Forced, s.pausePercentile(95), s.pausePercentile(100), s.HeapMin, s.HeapMax, s.Duration().Round(time.Second))
+}
+
+func (s *tuiSeries) header() string {
+	if len(s.values) == 0 {
+		return s.label
+	}
+	max := s.values[0]
+	for _, v := range s.values {
+		if v > max {
+			max = v
+		}
+	}
+	return fmt.Sprintf("%-22s %s%s (max %s%s)", s.label, formatTUIValue(s.values[len(s.values)-1]), s.unit, formatTUIValue(max), s.unit)
+}
+
+func formatTUIValue(v float64) string {
+	if v == float64(int64(v)) {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%.3f", v)
+}
+
+// sparkline draws the latest values fitting in width, scaled from the
+// smallest to the largest of them.
+func sparkline(values []float64, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	if len(values) == 0 {
+		return ""
+	}
+
+	min, max := values[0], values[0]
+	for _, v := range values {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+
+	line := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if max > min {
+			level = int((v - min) / (max - min) * float64(len(sparks)-1))
+		}
+		line[i] = sparks[level]
+	}
+	return string(line)
+}
+
+func truncateRunes(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
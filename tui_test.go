@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSparkline(t *testing.T) {
+	if s := sparkline([]float64{0, 1, 2, 3, 4, 5, 6, 7}, 80); s != "▁▂▃▄▅▆▇█" {
+		t.Errorf("Expected a rising sparkline. Got %q instead.", s)
+	}
+	if s := sparkline([]float64{9, 0, 7, 7}, 2); s != "▁▁" {
+		t.Errorf("Expected the latest 2 values, all equal. Got %q instead.", s)
+	}
+	if s := sparkline(nil, 10); s != "" {
+		t.Errorf("Expected no sparkline without values. Got %q instead.", s)
+	}
+}
+
+func TestTUIFrame(t *testing.T) {
+	var b bytes.Buffer
+	ui := newTUI(&b, func() (int, int) { return 60, 20 })
+	ui.SetTitle("godoc", "http://127.0.0.1:4500/")
+
+	ui.ConsumeGC(&gctrace{NumGC: 1, ElapsedTime: 1, Heap0: 8, Heap1: 10, Heap3: 4, STWSclock: 0.5, STWMclock: 0.25})
+	ui.ConsumeGC(&gctrace{NumGC: 2, ElapsedTime: 2, Heap0: 12, Heap1: 14, Heap3: 6, STWSclock: 1, STWMclock: 1, Forced: true})
+	ui.AddOutput("listening on :6060")
+
+	setLogOutput(ui)
+	infof("sink caught up")
+	setLogOutput(os.Stderr)
+
+	frame := ui.frame()
+	for _, expected := range []string{
+		"gcvis godoc  http://127.0.0.1:4500/",
+		"GC 2 (1 forced)",
+		"heap live              6MB (max 6MB)",
+		"stop the world pause   2ms (max 2ms)",
+		"▁█",
+		"listening on :6060",
+		`level=INFO msg="sink caught up`,
+	} {
+		if !strings.Contains(frame, expected) {
+			t.Errorf("Expected the frame to contain %q. Got %q instead.", expected, frame)
+		}
+	}
+	if n := strings.Count(frame, "\r\n") + 1; n > 20 {
+		t.Errorf("Expected at most 20 lines. Got %d instead.", n)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+var showVersion = flag.Bool("version", false, "print the version of gcvis, the Go version, commit and date it was built with, and exit")
+
+// Build metadata, set by the Makefile with
+// -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=...".
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// versionInfo describes the build of gcvis.
+type versionInfo struct {
+	Version   string
+	GoVersion string
+	Commit    string
+	BuildDate string
+}
+
+// buildVersion returns the build metadata set at link time, falling back to
+// the module version go install records for version.
+func buildVersion() versionInfo {
+	v := versionInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Commit:    commit,
+		BuildDate: buildDate,
+	}
+
+	if v.Version == "" {
+		v.Version = "(devel)"
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+			v.Version = info.Main.Version
+		}
+	}
+
+	return v
+}
+
+// String formats v on a single line, e.g.
+// "v1.2.0 (commit 4f2a9c1, built 2026-10-15T09:12:00Z, go1.17.13)".
+func (v versionInfo) String() string {
+	details := []string{}
+	if v.Commit != "" {
+		details = append(details, "commit "+v.Commit)
+	}
+	if v.BuildDate != "" {
+		details = append(details, "built "+v.BuildDate)
+	}
+	details = append(details, v.GoVersion)
+
+	return fmt.Sprintf("%s (%s)", v.Version, strings.Join(details, ", "))
+}
+
+func printVersion(w io.Writer) {
+	fmt.Fprintf(w, "gcvis %s\n", buildVersion())
+}
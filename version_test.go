@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBuildVersion(t *testing.T) {
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	version, commit, buildDate = "v1.2.0", "4f2a9c1", "2026-10-15T09:12:00Z"
+
+	var b bytes.Buffer
+	printVersion(&b)
+
+	expected := "gcvis v1.2.0 (commit 4f2a9c1, built 2026-10-15T09:12:00Z, go"
+	if !strings.HasPrefix(b.String(), expected) {
+		t.Errorf("Expected the version to start with %q. Got %q instead.", expected, b.String())
+	}
+}
+
+func TestBuildVersionWithoutMetadata(t *testing.T) {
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	version, commit, buildDate = "", "", ""
+
+	v := buildVersion()
+	if v.Version == "" || v.GoVersion == "" {
+		t.Errorf("Expected a version and a Go version. Got %+v instead.", v)
+	}
+	if s := v.String(); strings.Contains(s, "commit") || strings.Contains(s, "built") {
+		t.Errorf("Expected no commit nor build date. Got %q instead.", s)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var vmURL = flag.String("victoriametrics-url", "", "VictoriaMetrics to import metrics to, e.g. http://localhost:8428, with the /api/v1/import path if none is given")
+var vmLabels = flag.String("victoriametrics-labels", "", "comma separated key=value labels added to the imported metrics")
+var vmBatchSize = flag.Int("victoriametrics-batch-size", 1000, "maximum number of samples imported in a single VictoriaMetrics request")
+var vmBatchWait = flag.Duration("victoriametrics-batch-wait", 5*time.Second, "maximum time a sample waits before being imported to VictoriaMetrics")
+
+func init() {
+	RegisterSink("victoriametrics", newVictoriaMetricsSink)
+}
+
+// vmSample is a sample of a metric, imported with the labels of the sink.
+type vmSample struct {
+	name      string
+	value     float64
+	timestamp int64 // in milliseconds
+}
+
+// vmLine is a line of the JSON line format of /api/v1/import: the samples
+// of a single time series.
+type vmLine struct {
+	Metric     map[string]string `json:"metric"`
+	Values     []float64         `json:"values"`
+	Timestamps []int64           `json:"timestamps"`
+}
+
+// victoriaMetricsSink imports metrics to VictoriaMetrics, in the JSON line
+// format of /api/v1/import.
+type victoriaMetricsSink struct {
+	url    string
+	header http.Header
+	labels map[string]string
+
+	client  *http.Client
+	batcher *batcher
+}
+
+func newVictoriaMetricsSink() (Sink, error) {
+	if *vmURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(*vmURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -victoriametrics-url: %v", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/api/v1/import"
+	}
+
+	labels, err := parseLabels(*vmLabels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -victoriametrics-labels: %v", err)
+	}
+	for k, v := range metricLabels() {
+		labels[k] = v
+	}
+
+	s := &victoriaMetricsSink{
+		url:    u.String(),
+		header: http.Header{"Content-Type": {"application/stream+json"}},
+		labels: labels,
+		client: &http.Client{Timeout: pushTimeout},
+	}
+	s.batcher = newBatcher(*vmBatchSize, *vmBatchWait, s.send)
+
+	return s, nil
+}
+
+func (s *victoriaMetricsSink) ConsumeGC(t *gctrace) error {
+	s.add(gcMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *victoriaMetricsSink) ConsumeScvg(t *scvgtrace) error {
+	s.add(scvgMetrics(t), traceTime(t.ElapsedTime))
+	return nil
+}
+
+func (s *victoriaMetricsSink) add(metrics []metric, ts time.Time) {
+	for _, m := range metrics {
+		s.batcher.Add(vmSample{name: m.Name, value: m.Value, timestamp: timestampMillis(ts)})
+	}
+}
+
+func (s *victoriaMetricsSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *victoriaMetricsSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+func (s *victoriaMetricsSink) send(batch []interface{}) {
+	body, err := s.encode(batch)
+	if err != nil {
+		errorf("cannot encode VictoriaMetrics samples: %v", err)
+		return
+	}
+
+	retryWithBackoff(fmt.Sprintf("%d VictoriaMetrics samples", len(batch)), func() (bool, error) {
+		return postBody(s.client, s.url, s.header, body)
+	})
+}
+
+// encode returns the lines of the samples of batch, one per metric, in the
+// order the metrics were first seen.
+func (s *victoriaMetricsSink) encode(batch []interface{}) ([]byte, error) {
+	var lines []*vmLine
+	byName := map[string]*vmLine{}
+	for _, v := range batch {
+		sample := v.(vmSample)
+		line, ok := byName[sample.name]
+		if !ok {
+			metric := map[string]string{"__name__": sample.name}
+			for k, v := range s.labels {
+				metric[k] = v
+			}
+			line = &vmLine{Metric: metric}
+			byName[sample.name] = line
+			lines = append(lines, line)
+		}
+		line.Values = append(line.Values, sample.value)
+		line.Timestamps = append(line.Timestamps, sample.timestamp)
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, line := range lines {
+		if err := enc.Encode(line); err != nil {
+			return nil, err
+		}
+	}
+	return body.Bytes(), nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestVictoriaMetricsSink(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		path string
+		body string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+
+		mu.Lock()
+		path = req.URL.Path
+		body += string(b)
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	defer func(saved string) { *vmURL = saved }(*vmURL)
+	defer func(saved string) { *vmLabels = saved }(*vmLabels)
+	*vmURL = server.URL
+	*vmLabels = "env=prod"
+
+	sink, err := newVictoriaMetricsSink()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{NumGC: 1, ElapsedTime: 1, Heap1: 4})
+	sink.ConsumeGC(&gctrace{NumGC: 2, ElapsedTime: 2, Heap1: 8})
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if path != "/api/v1/import" {
+		t.Errorf("Expected the samples to be imported to /api/v1/import. Got %s instead.", path)
+	}
+
+	var goal *vmLine
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	for _, line := range lines {
+		var l vmLine
+		if err := json.Unmarshal([]byte(line), &l); err != nil {
+			t.Fatalf("Unexpected line %q: %v", line, err)
+		}
+		if l.Metric["__name__"] == "gcvis_heap_goal_megabytes" {
+			goal = &l
+		}
+	}
+	if len(lines) != len(gcMetrics(&gctrace{})) {
+		t.Errorf("Expected a line per metric. Got %d lines instead.", len(lines))
+	}
+	if goal == nil || len(goal.Values) != 2 || goal.Values[1] != 8 || len(goal.Timestamps) != 2 {
+		t.Fatalf("Expected the 2 samples of the heap goal on a line. Got %+v instead.", goal)
+	}
+	if goal.Metric["env"] != "prod" || goal.Metric["srv"] != *serviceName {
+		t.Errorf("Expected the -victoriametrics-labels and the labels of every metric. Got %v instead.", goal.Metric)
+	}
+}
+
+func TestVictoriaMetricsSinkOff(t *testing.T) {
+	sink, err := newVictoriaMetricsSink()
+	if err != nil || sink != nil {
+		t.Errorf("Expected the sink to be off by default. Got %v, %v instead.", sink, err)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"time"
+)
+
+var webhookURL = flag.String("webhook", "", "URL to POST a JSON payload to for every event, or only for the events exceeding a -webhook-threshold")
+var webhookThresholds thresholdsFlag
+var webhookHeaders = labelsFlag{}
+
+func init() {
+	flag.Var(&webhookThresholds, "webhook-threshold", "metric>value or metric<value, e.g. stw_mark_clock_milliseconds>5, only posting the events exceeding it. Can be repeated.")
+	flag.Var(webhookHeaders, "webhook-header", "key=value header sent with webhook requests, e.g. for authentication. Can be repeated.")
+
+	RegisterSink("webhook", newWebhookSink)
+}
+
+// webhookPayload is the JSON body posted for an event: its log line, and
+// the thresholds it exceeds, if any were set.
+type webhookPayload struct {
+	Event    *logLine            `json:"event"`
+	Exceeded []webhookExceedance `json:"exceeded,omitempty"`
+}
+
+type webhookExceedance struct {
+	Threshold string  `json:"threshold"`
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+}
+
+// webhookSink posts the events one by one, from the goroutine of a batcher
+// so a slow endpoint is retried without holding up the others.
+type webhookSink struct {
+	url        string
+	header     http.Header
+	thresholds []threshold
+
+	client  *http.Client
+	batcher *batcher
+}
+
+func newWebhookSink() (Sink, error) {
+	if *webhookURL == "" {
+		return nil, nil
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	for k, v := range webhookHeaders {
+		header.Set(k, v)
+	}
+
+	s := &webhookSink{
+		url:        *webhookURL,
+		header:     header,
+		thresholds: webhookThresholds,
+		client:     &http.Client{Timeout: pushTimeout},
+	}
+	s.batcher = newBatcher(1, time.Second, s.send)
+
+	return s, nil
+}
+
+func (s *webhookSink) ConsumeGC(t *gctrace) error {
+	return s.post(newGCLogLine(t), gcMetrics(t))
+}
+
+func (s *webhookSink) ConsumeScvg(t *scvgtrace) error {
+	return s.post(newScvgLogLine(t), scvgMetrics(t))
+}
+
+func (s *webhookSink) post(l *logLine, metrics []metric) error {
+	body, ok, err := webhookBody(l, metrics, s.thresholds)
+	if !ok || err != nil {
+		return err
+	}
+
+	s.batcher.Add(body)
+	return nil
+}
+
+// webhookBody returns the payload of the event l, with the thresholds its
+// metrics exceed, or false if thresholds are set but none is exceeded.
+func webhookBody(l *logLine, metrics []metric, thresholds []threshold) ([]byte, bool, error) {
+	payload := webhookPayload{Event: l}
+	if len(thresholds) > 0 {
+		exceeded, values := exceededThresholds(thresholds, metrics)
+		if len(exceeded) == 0 {
+			return nil, false, nil
+		}
+		for i, t := range exceeded {
+			payload.Exceeded = append(payload.Exceeded, webhookExceedance{
+				Threshold: t.String(),
+				Metric:    t.Metric,
+				Value:     values[i],
+			})
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	return body, err == nil, err
+}
+
+func (s *webhookSink) Flush() error {
+	s.batcher.Flush()
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	s.batcher.Close()
+	return nil
+}
+
+func (s *webhookSink) send(batch []interface{}) {
+	for _, body := range batch {
+		retryWithBackoff("webhook payload", func() (bool, error) {
+			return postBody(s.client, s.url, s.header, body.([]byte))
+		})
+	}
+}
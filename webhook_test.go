@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWebhookSink(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []map[string]interface{}
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		var payload map[string]interface{}
+		json.Unmarshal(body, &payload)
+
+		mu.Lock()
+		defer mu.Unlock()
+		payloads = append(payloads, payload)
+		auth = req.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	savedURL, savedThresholds := *webhookURL, webhookThresholds
+	*webhookURL, webhookThresholds = server.URL, nil
+	webhookThresholds.Set("stw_mark_clock_milliseconds>5")
+	webhookHeaders["Authorization"] = "Bearer secret"
+	defer func() {
+		*webhookURL, webhookThresholds = savedURL, savedThresholds
+		delete(webhookHeaders, "Authorization")
+	}()
+
+	sink, err := newWebhookSink()
+	if err != nil {
+		t.Fatalf("newWebhookSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{NumGC: 1, STWMclock: 1})
+	sink.ConsumeGC(&gctrace{NumGC: 2, STWMclock: 8})
+	sink.ConsumeScvg(&scvgtrace{Inuse: 12})
+	sink.Close()
+
+	if len(payloads) != 1 {
+		t.Fatalf("Expected only the event exceeding the threshold to be posted. Got %v instead.", payloads)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Expected the -webhook-header to be sent. Got %q instead.", auth)
+	}
+
+	event := payloads[0]["event"].(map[string]interface{})
+	if event["gc"].(map[string]interface{})["NumGC"] != 2.0 {
+		t.Errorf("Expected the second GC to be posted. Got %v instead.", event)
+	}
+	exceeded := payloads[0]["exceeded"].([]interface{})[0].(map[string]interface{})
+	if exceeded["threshold"] != "stw_mark_clock_milliseconds>5" || exceeded["value"] != 8.0 {
+		t.Errorf("Unexpected exceeded threshold %v.", exceeded)
+	}
+}
+
+func TestWebhookSinkEveryEvent(t *testing.T) {
+	var mu sync.Mutex
+	posts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		posts++
+	}))
+	defer server.Close()
+
+	savedURL := *webhookURL
+	*webhookURL = server.URL
+	defer func() { *webhookURL = savedURL }()
+
+	sink, err := newWebhookSink()
+	if err != nil {
+		t.Fatalf("newWebhookSink returned an error: %v", err)
+	}
+	sink.ConsumeGC(&gctrace{})
+	sink.ConsumeScvg(&scvgtrace{})
+	sink.Close()
+
+	if posts != 2 {
+		t.Errorf("Expected every event to be posted without thresholds. Got %d posts instead.", posts)
+	}
+}
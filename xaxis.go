@@ -0,0 +1,45 @@
+package main
+
+// The page can show the graphs against the wall-clock time, the GC cycles
+// or the memory allocated, rather than the elapsed time the points are
+// kept in. GCNumber and Allocated map the elapsed time of every GC cycle to
+// these, the page interpolating the points in between.
+
+// epoch returns the unix time, in seconds, of the elapsed time 0 of the
+// graph: the start of the session, moved back past a restored graph for
+// the traces to come to be on time.
+func (g *Graph) epoch() float64 {
+	if g.Epoch != 0 {
+		return g.Epoch
+	}
+	return float64(traceStartTime().UnixNano())/1e9 - g.offset
+}
+
+// observeXAxes adds a GC cycle at elapsed seconds to GCNumber and
+// Allocated. Its caller holds mu.
+func (g *Graph) observeXAxes(t *gctrace, elapsed float64) {
+	// The cycles count anew when the program is restarted, and past a
+	// restored graph, so they are numbered on from the last one.
+	var last, allocated float64
+	if n := g.GCNumber.len; n > 0 {
+		last = g.GCNumber.at(n - 1)[1]
+	}
+	if n := g.Allocated.len; n > 0 {
+		allocated = g.Allocated.at(n - 1)[1]
+	}
+	seq := float64(t.NumGC) + g.gcBase
+	if seq <= last {
+		g.gcBase = last - float64(t.NumGC) + 1
+		seq = last + 1
+	}
+
+	// Like runStats, the memory allocated is the growth of the heap from
+	// the live heap after a cycle to the heap at the start of the next one.
+	if g.lastLive > 0 && t.Heap0 > g.lastLive {
+		allocated += float64(t.Heap0 - g.lastLive)
+	}
+	g.lastLive = t.Heap3
+
+	g.GCNumber.add(graphPoints{elapsed, seq})
+	g.Allocated.add(graphPoints{elapsed, allocated})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphXAxes(t *testing.T) {
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 7, ElapsedTime: 1, Heap0: 40, Heap3: 10})
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 8, ElapsedTime: 2, Heap0: 30, Heap3: 20})
+	// The program restarted.
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 3, Heap0: 50, Heap3: 5})
+
+	s := graph.Snapshot()
+	expectedGC := []graphPoints{{1, 7}, {2, 8}, {3, 9}}
+	if got := s.GCNumber.Points(); !reflect.DeepEqual(got, expectedGC) {
+		t.Errorf("Expected the cycles to be numbered on past the restart, %v. Got %v instead.", expectedGC, got)
+	}
+	// 30-10, then 50-20 megabytes allocated.
+	expectedAlloc := []graphPoints{{1, 0}, {2, 20}, {3, 50}}
+	if got := s.Allocated.Points(); !reflect.DeepEqual(got, expectedAlloc) {
+		t.Errorf("Expected the memory allocated to be %v. Got %v instead.", expectedAlloc, got)
+	}
+
+	expectedEpoch := float64(traceStartTime().UnixNano()) / 1e9
+	if s.Epoch != expectedEpoch {
+		t.Errorf("Expected the epoch to be the start of the traces, %v. Got %v instead.", expectedEpoch, s.Epoch)
+	}
+}
+
+func TestGraphXAxesRestored(t *testing.T) {
+	saved := NewGraph("fake title", GCVIS_TMPL)
+	saved.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 10, Heap0: 40, Heap3: 10})
+	saved.AddGCTraceGraphPoint(&gctrace{NumGC: 2, ElapsedTime: 20, Heap0: 30, Heap3: 20})
+
+	graph := NewGraph("fake title", GCVIS_TMPL)
+	graph.Restore(saved.Snapshot())
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 1, ElapsedTime: 1, Heap0: 50, Heap3: 5})
+	graph.AddGCTraceGraphPoint(&gctrace{NumGC: 2, ElapsedTime: 2, Heap0: 25, Heap3: 5})
+
+	s := graph.Snapshot()
+	expectedGC := []graphPoints{{10, 1}, {20, 2}, {21, 3}, {22, 4}}
+	if got := s.GCNumber.Points(); !reflect.DeepEqual(got, expectedGC) {
+		t.Errorf("Expected the cycles to be numbered on past the restored graph, %v. Got %v instead.", expectedGC, got)
+	}
+	// Nothing is known of the live heap before the first cycle resumed.
+	expectedAlloc := []graphPoints{{10, 0}, {20, 20}, {21, 20}, {22, 40}}
+	if got := s.Allocated.Points(); !reflect.DeepEqual(got, expectedAlloc) {
+		t.Errorf("Expected the memory allocated to be %v. Got %v instead.", expectedAlloc, got)
+	}
+
+	expectedEpoch := float64(traceStartTime().UnixNano())/1e9 - 20
+	if s.Epoch != expectedEpoch {
+		t.Errorf("Expected the epoch to be moved back past the restored graph, %v. Got %v instead.", expectedEpoch, s.Epoch)
+	}
+}